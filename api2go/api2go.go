@@ -0,0 +1,339 @@
+/*
+Package api2go bridges the models of this package, driven by their jsonapi
+tags, and the models of the api2go library, driven by its interfaces, so that
+a service migrating from one to the other can share its model definitions.
+
+Wrap adapts a tagged model to the api2go interfaces that use no api2go types:
+MarshalIdentifier, UnmarshalIdentifier, EntityNamer, UnmarshalToOneRelations
+and UnmarshalToManyRelations, its attributes being its JSON encoding, e.g.
+for the marshaling functions of api2go's jsonapi package:
+
+	b, err := api2gojsonapi.Marshal(api2go.Wrap(post))
+
+Adapt adapts an api2go model to this package, as a NodeMarshaler and a
+NodeUnmarshaler:
+
+	jsonapi.MarshalOnePayload(w, api2go.Adapt(user))
+
+The relationships rendered by api2go models, through GetReferencedIDs, use
+api2go types and are not carried over; those of the payloads are set with
+SetToOneReferenceID and SetToManyReferenceIDs.
+*/
+package api2go
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/google/jsonapi"
+)
+
+// ErrUnknownRelationship is returned by the adapters for a relationship that
+// the model doesn't declare.
+var ErrUnknownRelationship = errors.New("api2go: the model has no such relationship")
+
+// Model adapts Value, a pointer to a struct with jsonapi tags, to the api2go
+// interfaces.
+type Model struct {
+	Value interface{}
+}
+
+// Wrap returns the Model adapting model, a pointer to a struct with jsonapi
+// tags.
+func Wrap(model interface{}) *Model {
+	return &Model{Value: model}
+}
+
+// node returns the Node of the model, without traversing its relationships.
+func (m *Model) node() (*jsonapi.Node, error) {
+	payload, err := jsonapi.MarshalOne(m.Value, jsonapi.WithInclude())
+	if err != nil {
+		return nil, err
+	}
+	return payload.Data, nil
+}
+
+// GetID implements api2go's MarshalIdentifier, returning the primary id of
+// the model, or "" if it has none.
+func (m *Model) GetID() string {
+	node, err := m.node()
+	if err != nil {
+		return ""
+	}
+	return node.ID
+}
+
+// GetName implements api2go's EntityNamer, returning the resource type of
+// the primary annotation.
+func (m *Model) GetName() string {
+	t := reflect.TypeOf(m.Value).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		tag, err := jsonapi.ParseTag(t.Field(i).Tag.Get("jsonapi"))
+		if err == nil && tag.Annotation == "primary" {
+			return tag.Name
+		}
+	}
+	return ""
+}
+
+// SetID implements api2go's UnmarshalIdentifier.
+func (m *Model) SetID(id string) error {
+	return m.unmarshal(&jsonapi.Node{ID: id})
+}
+
+// SetToOneReferenceID implements api2go's UnmarshalToOneRelations, setting
+// the to-one relationship name to the related model with id, or to nil if id
+// is empty.
+func (m *Model) SetToOneReferenceID(name, id string) error {
+	field, relatedType, ok := m.relation(name)
+	if !ok {
+		return ErrUnknownRelationship
+	}
+
+	if id == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	rel := &jsonapi.RelationshipOneNode{Data: &jsonapi.Node{Type: relatedType, ID: id}}
+	return m.unmarshal(&jsonapi.Node{Relationships: map[string]interface{}{name: rel}})
+}
+
+// SetToManyReferenceIDs implements api2go's UnmarshalToManyRelations,
+// setting the to-many relationship name to the related models with ids.
+func (m *Model) SetToManyReferenceIDs(name string, ids []string) error {
+	_, relatedType, ok := m.relation(name)
+	if !ok {
+		return ErrUnknownRelationship
+	}
+
+	rel := &jsonapi.RelationshipManyNode{Data: []*jsonapi.Node{}}
+	for _, id := range ids {
+		rel.Data = append(rel.Data, &jsonapi.Node{Type: relatedType, ID: id})
+	}
+	return m.unmarshal(&jsonapi.Node{Relationships: map[string]interface{}{name: rel}})
+}
+
+// MarshalJSON renders the attributes of the model, as api2go renders the
+// JSON encoding of its models.
+func (m *Model) MarshalJSON() ([]byte, error) {
+	node, err := m.node()
+	if err != nil {
+		return nil, err
+	}
+	if node.Attributes == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(node.Attributes)
+}
+
+// UnmarshalJSON sets the attributes of the model from b, a JSON object.
+func (m *Model) UnmarshalJSON(b []byte) error {
+	var attributes map[string]interface{}
+	if err := json.Unmarshal(b, &attributes); err != nil {
+		return err
+	}
+	return m.unmarshal(&jsonapi.Node{Attributes: attributes})
+}
+
+// unmarshal binds the members of node to the model, keeping its id unless
+// node has one.
+func (m *Model) unmarshal(node *jsonapi.Node) error {
+	node.Type = m.GetName()
+	if node.ID == "" {
+		node.ID = m.GetID()
+	}
+	return jsonapi.UnmarshalNode(node, m.Value, nil)
+}
+
+// relation returns the field of the relationship name and the resource type
+// of its related models.
+func (m *Model) relation(name string) (reflect.Value, string, bool) {
+	v := reflect.ValueOf(m.Value).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		tag, err := jsonapi.ParseTag(field.Tag.Get("jsonapi"))
+		if err != nil || tag.Annotation != "relation" || tag.Name != name {
+			continue
+		}
+
+		for _, option := range tag.Options {
+			if strings.HasPrefix(option, "ids:") {
+				return v.Field(i), strings.TrimPrefix(option, "ids:"), true
+			}
+		}
+
+		target := field.Type
+		for target.Kind() == reflect.Ptr || target.Kind() == reflect.Slice {
+			target = target.Elem()
+		}
+		if target.Kind() != reflect.Struct {
+			return v.Field(i), "", true
+		}
+		return v.Field(i), Wrap(reflect.New(target).Interface()).GetName(), true
+	}
+	return reflect.Value{}, "", false
+}
+
+// Identifier is implemented by the api2go models, as its MarshalIdentifier
+// and UnmarshalIdentifier.
+type Identifier interface {
+	GetID() string
+	SetID(id string) error
+}
+
+// The api2go interfaces that use no api2go types.
+type (
+	entityNamer interface {
+		GetName() string
+	}
+
+	toOneRelations interface {
+		SetToOneReferenceID(name, id string) error
+	}
+
+	toManyRelations interface {
+		SetToManyReferenceIDs(name string, ids []string) error
+	}
+)
+
+// Resource adapts Model, an api2go model, to this package.
+type Resource struct {
+	Model Identifier
+}
+
+// Adapt returns the Resource adapting model, an api2go model.
+func Adapt(model Identifier) *Resource {
+	return &Resource{Model: model}
+}
+
+// MarshalJSONAPINode implements NodeMarshaler, rendering the JSON encoding of
+// the model as its attributes, as api2go does.
+func (r *Resource) MarshalJSONAPINode() (*jsonapi.Node, error) {
+	b, err := json.Marshal(r.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := make(map[string]interface{})
+	if err := json.Unmarshal(b, &attributes); err != nil {
+		return nil, err
+	}
+	delete(attributes, "id")
+	delete(attributes, "type")
+
+	return &jsonapi.Node{Type: r.name(), ID: r.Model.GetID(), Attributes: attributes}, nil
+}
+
+// UnmarshalJSONAPINode implements NodeUnmarshaler, decoding the attributes
+// of node into the model and setting its id and the ids of its relationships.
+func (r *Resource) UnmarshalJSONAPINode(node *jsonapi.Node) error {
+	if len(node.Attributes) > 0 {
+		b, err := json.Marshal(node.Attributes)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, r.Model); err != nil {
+			return err
+		}
+	}
+
+	if node.ID != "" {
+		if err := r.Model.SetID(node.ID); err != nil {
+			return err
+		}
+	}
+
+	for name, rel := range node.Relationships {
+		if err := r.setRelationship(name, rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setRelationship sets the ids of the linkage of the relationship rel, as
+// built or as decoded from a payload, with SetToOneReferenceID or
+// SetToManyReferenceIDs.
+func (r *Resource) setRelationship(name string, rel interface{}) error {
+	b, err := json.Marshal(rel)
+	if err != nil {
+		return err
+	}
+	var linkage struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &linkage); err != nil {
+		return err
+	}
+
+	data := strings.TrimSpace(string(linkage.Data))
+	if strings.HasPrefix(data, "[") {
+		var nodes []*jsonapi.Node
+		if err := json.Unmarshal(linkage.Data, &nodes); err != nil {
+			return err
+		}
+		setter, ok := r.Model.(toManyRelations)
+		if !ok {
+			return ErrUnknownRelationship
+		}
+		ids := make([]string, len(nodes))
+		for i, n := range nodes {
+			ids[i] = n.ID
+		}
+		return setter.SetToManyReferenceIDs(name, ids)
+	}
+
+	if data == "" {
+		// links or meta only, nothing to set
+		return nil
+	}
+	setter, ok := r.Model.(toOneRelations)
+	if !ok {
+		return ErrUnknownRelationship
+	}
+	var n *jsonapi.Node
+	if err := json.Unmarshal(linkage.Data, &n); err != nil {
+		return err
+	}
+	if n == nil {
+		return setter.SetToOneReferenceID(name, "")
+	}
+	return setter.SetToOneReferenceID(name, n.ID)
+}
+
+// name returns the resource type of the model: the one returned by GetName
+// if it has that method, or else, as api2go does, its struct name in
+// lowerCamelCase and pluralized, e.g. "blogPosts" for a BlogPost.
+func (r *Resource) name() string {
+	if namer, ok := r.Model.(entityNamer); ok {
+		return namer.GetName()
+	}
+
+	t := reflect.TypeOf(r.Model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := []rune(t.Name())
+	if len(name) == 0 {
+		return ""
+	}
+	name[0] = unicode.ToLower(name[0])
+	return pluralize(string(name))
+}
+
+// pluralize returns the plural of the English noun s, following the common
+// rules only.
+func pluralize(s string) string {
+	switch {
+	case strings.HasSuffix(s, "y") && !strings.HasSuffix(s, "ay") &&
+		!strings.HasSuffix(s, "ey") && !strings.HasSuffix(s, "oy"):
+		return strings.TrimSuffix(s, "y") + "ies"
+	case strings.HasSuffix(s, "s") || strings.HasSuffix(s, "x") ||
+		strings.HasSuffix(s, "ch") || strings.HasSuffix(s, "sh"):
+		return s + "es"
+	}
+	return s + "s"
+}
@@ -0,0 +1,158 @@
+package api2go
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/jsonapi"
+)
+
+type Post struct {
+	ID         int        `jsonapi:"primary,posts"`
+	Title      string     `jsonapi:"attr,title"`
+	Author     *Author    `jsonapi:"relation,author"`
+	Comments   []*Comment `jsonapi:"relation,comments"`
+	CategoryID string     `jsonapi:"relation,category,ids:categories"`
+}
+
+type Author struct {
+	ID   int    `jsonapi:"primary,authors"`
+	Name string `jsonapi:"attr,name"`
+}
+
+type Comment struct {
+	ID   int    `jsonapi:"primary,comments"`
+	Body string `jsonapi:"attr,body"`
+}
+
+// User is an api2go model.
+type User struct {
+	ID        string   `json:"-"`
+	Name      string   `json:"name"`
+	GroupID   string   `json:"-"`
+	FriendIDs []string `json:"-"`
+}
+
+func (u User) GetID() string { return u.ID }
+
+func (u *User) SetID(id string) error {
+	u.ID = id
+	return nil
+}
+
+func (u *User) SetToOneReferenceID(name, id string) error {
+	if name != "group" {
+		return ErrUnknownRelationship
+	}
+	u.GroupID = id
+	return nil
+}
+
+func (u *User) SetToManyReferenceIDs(name string, ids []string) error {
+	if name != "friends" {
+		return ErrUnknownRelationship
+	}
+	u.FriendIDs = ids
+	return nil
+}
+
+type BlogEntry struct {
+	ID string `json:"-"`
+}
+
+func (e BlogEntry) GetID() string       { return e.ID }
+func (e *BlogEntry) SetID(string) error { return nil }
+
+func TestModel(t *testing.T) {
+	post := &Post{ID: 7, Title: "Title"}
+	m := Wrap(post)
+
+	if e, a := "7", m.GetID(); e != a {
+		t.Fatalf("Was expecting id %q, got %q", e, a)
+	}
+	if e, a := "posts", m.GetName(); e != a {
+		t.Fatalf("Was expecting name %q, got %q", e, a)
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := `{"title":"Title"}`, string(b); e != a {
+		t.Fatalf("Was expecting %s, got %s", e, a)
+	}
+
+	if err := json.Unmarshal([]byte(`{"title":"New"}`), m); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetID("8"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetToOneReferenceID("author", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetToManyReferenceIDs("comments", []string{"4", "5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetToOneReferenceID("category", "news"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Post{
+		ID:         8,
+		Title:      "New",
+		Author:     &Author{ID: 3},
+		Comments:   []*Comment{{ID: 4}, {ID: 5}},
+		CategoryID: "news",
+	}
+	if !reflect.DeepEqual(expected, post) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, post)
+	}
+
+	if err := m.SetToOneReferenceID("author", ""); err != nil {
+		t.Fatal(err)
+	}
+	if post.Author != nil {
+		t.Fatalf("Was expecting no author, got %v", post.Author)
+	}
+	if err := m.SetToOneReferenceID("editor", "1"); err != ErrUnknownRelationship {
+		t.Fatalf("Was expecting ErrUnknownRelationship, got %v", err)
+	}
+}
+
+func TestResource(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := jsonapi.MarshalOnePayload(out, Adapt(&User{ID: "1", Name: "Jane"})); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := `{"data":{"type":"users","id":"1","attributes":{"name":"Jane"}}}`,
+		strings.TrimSpace(out.String()); e != a {
+		t.Fatalf("Was expecting %s, got %s", e, a)
+	}
+
+	in := `{"data":{"type":"users","id":"2","attributes":{"name":"John"},"relationships":{
+		"group":{"data":{"type":"groups","id":"9"}},
+		"friends":{"data":[{"type":"users","id":"1"},{"type":"users","id":"3"}]}}}}`
+	user := new(User)
+	if err := jsonapi.UnmarshalPayload(strings.NewReader(in), Adapt(user)); err != nil {
+		t.Fatal(err)
+	}
+	expected := &User{ID: "2", Name: "John", GroupID: "9", FriendIDs: []string{"1", "3"}}
+	if !reflect.DeepEqual(expected, user) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, user)
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	for model, name := range map[Identifier]string{
+		new(User):      "users",
+		new(BlogEntry): "blogEntries",
+	} {
+		if a := Adapt(model).name(); name != a {
+			t.Fatalf("Was expecting name %q, got %q", name, a)
+		}
+	}
+}
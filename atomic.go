@@ -0,0 +1,338 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+const (
+	// AtomicExtension is the URI of the Atomic Operations extension, which
+	// batches the creation, update and removal of resources in one request.
+	//
+	// see https://jsonapi.org/ext/atomic/
+	AtomicExtension = "https://jsonapi.org/ext/atomic"
+
+	// AtomicMediaType is the media type of the documents of the Atomic
+	// Operations extension, for the Content-Type of the requests and
+	// responses.
+	AtomicMediaType = MediaType + `; ext="` + AtomicExtension + `"`
+
+	// OperationAdd adds a resource, or members to a to-many relationship.
+	OperationAdd = "add"
+	// OperationUpdate updates a resource, or replaces a relationship.
+	OperationUpdate = "update"
+	// OperationRemove removes a resource, or members from a to-many
+	// relationship.
+	OperationRemove = "remove"
+)
+
+// ErrMalformedOperation is returned by UnmarshalOperations when an operation
+// has an unknown op, or both a ref and an href, by Operation.UnmarshalData
+// when the data of the operation is not a resource, and by MarshalOperations
+// for a slice of models as the data of an operation on a resource.
+var ErrMalformedOperation = errors.New("The payload has a malformed operation")
+
+// OperationsPayload is a document of the Atomic Operations extension, whose
+// operations are performed in order, all of them or none.
+type OperationsPayload struct {
+	Operations []*Operation   `json:"atomic:operations"`
+	Links      *Links         `json:"links,omitempty"`
+	Meta       *Meta          `json:"meta,omitempty"`
+	JSONAPI    *JSONAPIObject `json:"jsonapi,omitempty"`
+}
+
+// Operation is an operation of an OperationsPayload.
+type Operation struct {
+	// Op is OperationAdd, OperationUpdate or OperationRemove.
+	Op string `json:"op"`
+
+	// Ref targets the resource, or the relationship of a resource, of the
+	// operation; Href may target it by URL instead.
+	Ref  *OperationRef `json:"ref,omitempty"`
+	Href string        `json:"href,omitempty"`
+
+	// Data is the primary data of the operation: a resource, the resource
+	// identifier of a to-one relationship, or those of a to-many one. When
+	// marshaling, it may be a model, a slice of models for a to-many
+	// relationship, a *Node or a []*Node; once unmarshaled, it is a *Node, a
+	// []*Node or nil.
+	Data interface{} `json:"data"`
+
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// OperationRef is the "ref" of an Operation: the type and id, or local id, of
+// a resource and optionally the name of one of its relationships.
+type OperationRef struct {
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	LocalID      string `json:"lid,omitempty"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+// OperationsResultsPayload is the response to an OperationsPayload, with a
+// result for each of its operations, in order.
+type OperationsResultsPayload struct {
+	Results []*OperationResult `json:"atomic:results"`
+	Links   *Links             `json:"links,omitempty"`
+	Meta    *Meta              `json:"meta,omitempty"`
+	JSONAPI *JSONAPIObject     `json:"jsonapi,omitempty"`
+}
+
+// OperationResult is the result of an Operation: the resource it added or
+// updated, if any.
+type OperationResult struct {
+	Data *Node `json:"data,omitempty"`
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// MarshalJSON renders the data of the operation, leaving it out for the
+// removal of a resource.
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	type operation Operation
+	if o.Data == nil && o.Op == OperationRemove && !o.targetsRelationship() {
+		return json.Marshal(&struct {
+			*operation
+			Data interface{} `json:"data,omitempty"`
+		}{operation: (*operation)(o)})
+	}
+	return json.Marshal((*operation)(o))
+}
+
+// UnmarshalJSON decodes the data of the operation into a *Node or a []*Node.
+func (o *Operation) UnmarshalJSON(b []byte) error {
+	type operation Operation
+	var op struct {
+		*operation
+		Data json.RawMessage `json:"data"`
+	}
+	op.operation = (*operation)(o)
+	if err := json.Unmarshal(b, &op); err != nil {
+		return err
+	}
+
+	switch data := bytes.TrimSpace(op.Data); {
+	case len(data) == 0 || bytes.Equal(data, []byte("null")):
+		o.Data = nil
+	case data[0] == '[':
+		var nodes []*Node
+		if err := json.Unmarshal(data, &nodes); err != nil {
+			return err
+		}
+		o.Data = nodes
+	default:
+		node := new(Node)
+		if err := json.Unmarshal(data, node); err != nil {
+			return err
+		}
+		o.Data = node
+	}
+	return nil
+}
+
+// targetsRelationship reports whether the operation targets a relationship
+// rather than a resource.
+func (o *Operation) targetsRelationship() bool {
+	return o.Ref != nil && o.Ref.Relationship != ""
+}
+
+// MarshalOperations returns the OperationsPayload of ops, whose models are
+// visited into resources, with their relationships as linkage only, or into
+// resource identifiers for the operations on relationships, e.g.
+//
+//	payload, err := jsonapi.MarshalOperations([]*jsonapi.Operation{
+//		{Op: jsonapi.OperationAdd, Data: post},
+//		{Op: jsonapi.OperationRemove, Ref: &jsonapi.OperationRef{Type: "comments", ID: "5"}},
+//	})
+//
+// The "jsonapi" object of the document lists AtomicExtension.
+func MarshalOperations(ops []*Operation, opts ...MarshalOption) (*OperationsPayload, error) {
+	config := newMarshalConfig(append(opts, WithInclude()))
+	if !hasString(config.extensions, AtomicExtension) {
+		config.extensions = append(config.extensions, AtomicExtension)
+	}
+
+	payload := &OperationsPayload{Operations: make([]*Operation, 0, len(ops))}
+	for _, op := range ops {
+		marshaled := *op
+		data, err := operationData(op, config)
+		if err != nil {
+			return nil, err
+		}
+		marshaled.Data = data
+		payload.Operations = append(payload.Operations, &marshaled)
+	}
+
+	var err error
+	if payload.Links, err = config.documentLinks(); err != nil {
+		return nil, err
+	}
+	payload.Meta = config.meta
+	payload.JSONAPI = config.jsonapiObject()
+
+	return payload, nil
+}
+
+// MarshalOperationsPayload writes the OperationsPayload of ops. See
+// MarshalOperations.
+func MarshalOperationsPayload(w io.Writer, ops []*Operation, opts ...MarshalOption) error {
+	payload, err := MarshalOperations(ops, opts...)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(payload)
+}
+
+// operationData returns the data of op marshaled: a *Node, a []*Node, or nil.
+func operationData(op *Operation, config *marshalConfig) (interface{}, error) {
+	switch data := op.Data.(type) {
+	case nil:
+		return nil, nil
+	case *Node, []*Node:
+		return data, nil
+	}
+
+	visit := func(model interface{}) (*Node, error) {
+		if op.targetsRelationship() {
+			return visitModelIdentifier(model)
+		}
+		return newVisitor(NewIncludedSet(), true, config).visitModelNode(model, "")
+	}
+
+	if v := reflect.ValueOf(op.Data); v.Kind() == reflect.Slice {
+		if !op.targetsRelationship() {
+			return nil, ErrMalformedOperation
+		}
+		nodes := []*Node{}
+		for i := 0; i < v.Len(); i++ {
+			node, err := visit(sliceElem(v, i))
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
+		}
+		if err := config.renameTypes(nodes); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	}
+
+	node, err := visit(op.Data)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.renameTypes([]*Node{node}); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// MarshalOperationResults writes the OperationsResultsPayload of the models
+// added or updated by the operations of a request, in order; a nil model
+// stands for an operation without a result, e.g. a removal.
+func MarshalOperationResults(w io.Writer, models []interface{}, opts ...MarshalOption) error {
+	config := newMarshalConfig(append(opts, WithInclude()))
+	if !hasString(config.extensions, AtomicExtension) {
+		config.extensions = append(config.extensions, AtomicExtension)
+	}
+
+	payload := &OperationsResultsPayload{Results: make([]*OperationResult, 0, len(models))}
+	for _, model := range models {
+		result := new(OperationResult)
+		if model != nil {
+			node, err := newVisitor(NewIncludedSet(), true, config).visitModelNode(model, "")
+			if err != nil {
+				return err
+			}
+			if err := config.renameTypes([]*Node{node}); err != nil {
+				return err
+			}
+			result.Data = node
+		}
+		payload.Results = append(payload.Results, result)
+	}
+
+	var err error
+	if payload.Links, err = config.documentLinks(); err != nil {
+		return err
+	}
+	payload.Meta = config.meta
+	payload.JSONAPI = config.jsonapiObject()
+
+	return json.NewEncoder(w).Encode(payload)
+}
+
+// UnmarshalOperations decodes the OperationsPayload read from in, checking
+// its operations and the extensions it applies, other than AtomicExtension.
+// The data of each operation is then bound to a model with UnmarshalData,
+// e.g.
+//
+//	payload, err := jsonapi.UnmarshalOperations(r.Body)
+//	...
+//	for _, op := range payload.Operations {
+//		switch op.Op {
+//		case jsonapi.OperationAdd:
+//			post := new(Post)
+//			if err := op.UnmarshalData(post); err != nil {
+//				...
+//			}
+//		...
+//	}
+func UnmarshalOperations(in io.Reader, opts ...UnmarshalOption) (*OperationsPayload, error) {
+	config := newUnmarshalConfig(opts)
+
+	payload := new(OperationsPayload)
+	if err := config.decode(in, payload); err != nil {
+		return nil, err
+	}
+
+	if obj := payload.JSONAPI; obj != nil {
+		others := *obj
+		others.Ext = nil
+		for _, uri := range obj.Ext {
+			if uri != AtomicExtension {
+				others.Ext = append(others.Ext, uri)
+			}
+		}
+		if err := config.checkExtensions(&others); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, op := range payload.Operations {
+		if op == nil || (op.Op != OperationAdd && op.Op != OperationUpdate &&
+			op.Op != OperationRemove) || (op.Ref != nil && op.Href != "") {
+			return nil, ErrMalformedOperation
+		}
+	}
+
+	return payload, nil
+}
+
+// UnmarshalData binds the resource of the operation, as decoded by
+// UnmarshalOperations, to model, as UnmarshalOne does for a document. It
+// returns ErrMalformedOperation when the data is not a resource.
+func (o *Operation) UnmarshalData(model interface{}, opts ...UnmarshalOption) error {
+	node, ok := o.Data.(*Node)
+	if !ok || node == nil {
+		return ErrMalformedOperation
+	}
+
+	// the node is left as it is, e.g. by the renames of the options
+	copied := *node
+	return UnmarshalNode(&copied, model, nil, opts...)
+}
+
+// hasString reports whether s is one of strs.
+func hasString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
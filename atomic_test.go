@@ -0,0 +1,119 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalOperations(t *testing.T) {
+	blog := testBlog()
+	ops := []*Operation{
+		{Op: OperationAdd, Data: blog.Posts[0]},
+		{Op: OperationUpdate, Ref: &OperationRef{Type: "blogs", ID: "5", Relationship: "posts"},
+			Data: blog.Posts},
+		{Op: OperationRemove, Ref: &OperationRef{Type: "comments", ID: "1"}},
+	}
+
+	out := new(bytes.Buffer)
+	if err := MarshalOperationsPayload(out, ops); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Operations []map[string]json.RawMessage `json:"atomic:operations"`
+		JSONAPI    *JSONAPIObject               `json:"jsonapi"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.JSONAPI == nil || !reflect.DeepEqual([]string{AtomicExtension}, doc.JSONAPI.Ext) {
+		t.Fatalf("Was expecting the atomic extension, got %+v", doc.JSONAPI)
+	}
+	if e, a := 3, len(doc.Operations); e != a {
+		t.Fatalf("Was expecting %d operations, got %d", e, a)
+	}
+
+	var added Node
+	if err := json.Unmarshal(doc.Operations[0]["data"], &added); err != nil {
+		t.Fatal(err)
+	}
+	if added.Type != "posts" || added.Attributes["title"] != "Foo" {
+		t.Fatalf("Was expecting the post resource, got %+v", added)
+	}
+
+	var linkage []map[string]interface{}
+	if err := json.Unmarshal(doc.Operations[1]["data"], &linkage); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{{"type": "posts", "id": "1"}, {"type": "posts", "id": "2"}}
+	if !reflect.DeepEqual(expected, linkage) {
+		t.Fatalf("Was expecting the linkage %v, got %v", expected, linkage)
+	}
+
+	if _, ok := doc.Operations[2]["data"]; ok {
+		t.Fatal("Was expecting no data for the removal of a resource")
+	}
+}
+
+func TestUnmarshalOperations(t *testing.T) {
+	data := `{
+		"jsonapi": {"version": "1.1", "ext": ["` + AtomicExtension + `"]},
+		"atomic:operations": [
+			{"op": "add", "data": {"type": "posts", "attributes": {"title": "New"}}},
+			{"op": "update", "ref": {"type": "posts", "id": "1", "relationship": "latest_comment"}, "data": null},
+			{"op": "remove", "ref": {"type": "comments", "id": "2"}}
+		]
+	}`
+
+	payload, err := UnmarshalOperations(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 3, len(payload.Operations); e != a {
+		t.Fatalf("Was expecting %d operations, got %d", e, a)
+	}
+
+	post := new(Post)
+	if err := payload.Operations[0].UnmarshalData(post); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "New", post.Title; e != a {
+		t.Fatalf("Was expecting the title %q, got %q", e, a)
+	}
+
+	if payload.Operations[1].Data != nil {
+		t.Fatalf("Was expecting null data, got %v", payload.Operations[1].Data)
+	}
+	if err := payload.Operations[2].UnmarshalData(new(Comment)); err != ErrMalformedOperation {
+		t.Fatalf("Was expecting ErrMalformedOperation, got %v", err)
+	}
+
+	malformed := `{"atomic:operations": [{"op": "upsert", "data": {"type": "posts"}}]}`
+	if _, err := UnmarshalOperations(strings.NewReader(malformed)); err != ErrMalformedOperation {
+		t.Fatalf("Was expecting ErrMalformedOperation, got %v", err)
+	}
+}
+
+func TestMarshalOperationResults(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := MarshalOperationResults(out, []interface{}{testBlog().Posts[0], nil}); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc OperationsResultsPayload
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 2, len(doc.Results); e != a {
+		t.Fatalf("Was expecting %d results, got %d", e, a)
+	}
+	if doc.Results[0].Data == nil || doc.Results[0].Data.ID != "1" || doc.Results[1].Data != nil {
+		t.Fatalf("Was expecting the post, then an empty result, got %s", out)
+	}
+	if doc.JSONAPI == nil || !reflect.DeepEqual([]string{AtomicExtension}, doc.JSONAPI.Ext) {
+		t.Fatalf("Was expecting the atomic extension, got %+v", doc.JSONAPI)
+	}
+}
@@ -0,0 +1,117 @@
+package jsonapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// AttributeMarshaler is implemented by the types of attributes that control
+// their own representation, e.g. a decimal type rendered as a string rather
+// than as the struct it is made of:
+//
+//	func (d Decimal) MarshalJSONAPIAttribute() (interface{}, error) {
+//		return d.String(), nil
+//	}
+//
+// The value returned is rendered in place of the field. Types implementing
+// encoding.TextMarshaler, but not json.Marshaler, are rendered as the string
+// returned by MarshalText.
+type AttributeMarshaler interface {
+	MarshalJSONAPIAttribute() (interface{}, error)
+}
+
+// AttributeUnmarshaler is implemented by the types of attributes that parse
+// their own representation, given as decoded by encoding/json: a string, a
+// float64, a bool, a []interface{} or a map[string]interface{}. A null
+// attribute leaves the field untouched.
+//
+// Otherwise, types implementing json.Unmarshaler are given the JSON encoding
+// of the attribute, and types implementing encoding.TextUnmarshaler the
+// attribute when it is a string, as encoding/json does.
+type AttributeUnmarshaler interface {
+	UnmarshalJSONAPIAttribute(value interface{}) error
+}
+
+var (
+	attributeMarshalerType = reflect.TypeOf((*AttributeMarshaler)(nil)).Elem()
+	jsonMarshalerType      = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType      = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// customAttribute returns the value to render for the attribute field v when
+// its type implements AttributeMarshaler, or encoding.TextMarshaler but not
+// json.Marshaler. It returns false for the other types, rendered as usual.
+func customAttribute(v reflect.Value) (interface{}, bool, error) {
+	if m, ok := implementer(v, attributeMarshalerType); ok {
+		value, err := m.(AttributeMarshaler).MarshalJSONAPIAttribute()
+		return value, true, err
+	}
+	if _, ok := implementer(v, jsonMarshalerType); ok {
+		return nil, false, nil
+	}
+	if m, ok := implementer(v, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		return string(text), true, err
+	}
+	return nil, false, nil
+}
+
+// implementer returns the value of v, or a pointer to it, that implements
+// iface. A nil pointer implements nothing.
+func implementer(v reflect.Value, iface reflect.Type) (interface{}, bool) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if v.Type().Implements(iface) {
+		return v.Interface(), true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(iface) {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+// unmarshalCustomAttribute binds val, a decoded attribute, to field when its
+// type, or the type it points to, implements AttributeUnmarshaler,
+// json.Unmarshaler, or encoding.TextUnmarshaler and val is a string. It
+// reports whether it did. Times are left to the layouts of their tags.
+func unmarshalCustomAttribute(val interface{}, field reflect.Value) (bool, error) {
+	t := field.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) || t.Kind() == reflect.Interface {
+		return false, nil
+	}
+
+	value := reflect.New(t)
+	switch u := value.Interface().(type) {
+	case AttributeUnmarshaler:
+		if err := u.UnmarshalJSONAPIAttribute(val); err != nil {
+			return true, err
+		}
+	case json.Unmarshaler:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return true, err
+		}
+		if err := u.UnmarshalJSON(b); err != nil {
+			return true, err
+		}
+	case encoding.TextUnmarshaler:
+		s, ok := val.(string)
+		if !ok {
+			return false, nil
+		}
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return true, err
+		}
+	default:
+		return false, nil
+	}
+
+	assign(field, value)
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package jsonapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalCustomAttributes(t *testing.T) {
+	high := Severity(1)
+	payload, err := MarshalOne(&Ticket{ID: 1, Price: Decimal{Cents: 1250}, Escalate: &high})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"price": "12.50", "severity": "low", "escalate": "high"}
+	if !reflect.DeepEqual(expected, payload.Data.Attributes) {
+		t.Fatalf("Was expecting the attributes %v, got %v", expected, payload.Data.Attributes)
+	}
+}
+
+func TestUnmarshalCustomAttributes(t *testing.T) {
+	payload := &OnePayload{Data: &Node{Type: "tickets", ID: "1", Attributes: map[string]interface{}{
+		"price": "12.50", "refund": "0.75", "severity": "high", "escalate": "low",
+	}}}
+
+	ticket := new(Ticket)
+	if err := UnmarshalOne(payload, ticket); err != nil {
+		t.Fatal(err)
+	}
+
+	low := Severity(0)
+	expected := &Ticket{ID: 1, Price: Decimal{Cents: 1250}, Refund: &Decimal{Cents: 75}, Severity: 1, Escalate: &low}
+	if !reflect.DeepEqual(expected, ticket) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, ticket)
+	}
+}
+
+func TestUnmarshalCustomAttributeErrors(t *testing.T) {
+	for _, attrs := range []map[string]interface{}{
+		{"price": 12.5},
+		{"severity": "urgent"},
+	} {
+		payload := &OnePayload{Data: &Node{Type: "tickets", ID: "1", Attributes: attrs}}
+		if err := UnmarshalOne(payload, new(Ticket)); err == nil {
+			t.Fatalf("%v: was expecting an error", attrs)
+		}
+	}
+
+}
+
+func TestCustomAttributesRoundTrip(t *testing.T) {
+	ticket := &Ticket{ID: 7, Price: Decimal{Cents: 99}, Refund: &Decimal{Cents: 100}, Severity: 1}
+
+	out := new(strings.Builder)
+	if err := MarshalOnePayload(out, ticket); err != nil {
+		t.Fatal(err)
+	}
+	back := new(Ticket)
+	if err := UnmarshalPayload(strings.NewReader(out.String()), back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ticket, back) {
+		t.Fatalf("Was expecting %+v, got %+v", ticket, back)
+	}
+}
@@ -0,0 +1,251 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnregisteredAttributeType is returned when unmarshaling an interface
+// typed attribute whose discriminator names no type registered with
+// RegisterAttributeType.
+var ErrUnregisteredAttributeType = errors.New("The attribute discriminator names no registered type")
+
+// attributeType is a concrete type registered for interface typed attributes.
+type attributeType struct {
+	name string
+	t    reflect.Type // never a pointer
+	ptr  bool         // registered as a pointer
+}
+
+var (
+	attributeTypesMu     sync.RWMutex
+	attributeTypesByName = make(map[string]attributeType)
+	attributeTypesByType = make(map[reflect.Type]attributeType)
+)
+
+// RegisterAttributeType registers the concrete type of value under name, for
+// the interface typed attributes tagged with a discriminator, e.g.
+//
+//	type Notification struct {
+//		ID      int         `jsonapi:"primary,notifications"`
+//		Payload interface{} `jsonapi:"attr,payload,discriminator:kind"`
+//	}
+//
+//	jsonapi.RegisterAttributeType("email", &EmailPayload{})
+//
+// An *EmailPayload held by Payload is rendered as an object with a "kind"
+// member set to "email", and such an object is unmarshaled back into an
+// *EmailPayload. Registering a struct value rather than a pointer unmarshals
+// into a value. It is meant to be called while the application starts.
+func RegisterAttributeType(name string, value interface{}) {
+	t := reflect.TypeOf(value)
+	at := attributeType{name: name, t: t}
+	if t.Kind() == reflect.Ptr {
+		at.t, at.ptr = t.Elem(), true
+	}
+
+	attributeTypesMu.Lock()
+	defer attributeTypesMu.Unlock()
+
+	attributeTypesByName[name] = at
+	attributeTypesByType[at.t] = at
+}
+
+// discriminatedAttribute returns the value to render for an interface typed
+// attribute: value itself, or the object it encodes to with the discriminator
+// member set to the name its type is registered under.
+func discriminatedAttribute(value interface{}, discriminator string) (interface{}, error) {
+	if value == nil || discriminator == "" {
+		return value, nil
+	}
+
+	t := reflect.TypeOf(value)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	attributeTypesMu.RLock()
+	at, ok := attributeTypesByType[t]
+	attributeTypesMu.RUnlock()
+	if !ok {
+		return value, nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		// not rendered as an object; there is nowhere to put the discriminator
+		return value, nil
+	}
+	obj[discriminator] = at.name
+
+	return obj, nil
+}
+
+// interfaceAttributeValue returns the value to set on an interface typed
+// attribute field of type fieldType for the decoded attribute val: an instance
+// of the type registered under its discriminator member if discriminator is
+// given, or else val itself.
+func interfaceAttributeValue(val interface{}, fieldType reflect.Type,
+	discriminator string) (reflect.Value, error) {
+	value := reflect.ValueOf(val)
+
+	if discriminator != "" {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return reflect.Value{}, ErrInvalidType
+		}
+
+		name, _ := obj[discriminator].(string)
+		attributeTypesMu.RLock()
+		at, ok := attributeTypesByName[name]
+		attributeTypesMu.RUnlock()
+		if !ok {
+			return reflect.Value{}, ErrUnregisteredAttributeType
+		}
+
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		value = reflect.New(at.t)
+		if err := json.Unmarshal(b, value.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		if !at.ptr {
+			value = value.Elem()
+		}
+	}
+
+	if !value.Type().AssignableTo(fieldType) {
+		return reflect.Value{}, ErrInvalidType
+	}
+	return value, nil
+}
+
+// stringAttribute returns the number or boolean held by v, or a pointer to
+// one, encoded as a string for the "string" option; a nil pointer is rendered
+// as null. It returns false for values of other kinds, rendered as usual.
+func stringAttribute(v reflect.Value) (interface{}, bool) {
+	if v.Kind() == reflect.Ptr {
+		if !isStringEncodable(v.Type().Elem().Kind()) {
+			return nil, false
+		}
+		if v.IsNil() {
+			return nil, true
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	}
+	return nil, false
+}
+
+// parseStringAttribute parses s, a number or boolean encoded as a string for
+// the "string" option, into a pointer to a value of t, or of the type t points
+// to. It returns false when t is not a number or boolean type, and
+// ErrInvalidType when s is not a valid value of t.
+func parseStringAttribute(s string, t reflect.Type) (reflect.Value, bool, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isStringEncodable(t.Kind()) {
+		return reflect.Value{}, false, nil
+	}
+
+	value := reflect.New(t)
+	var err error
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 10, t.Bits()); err == nil {
+			value.Elem().SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(s, 10, t.Bits()); err == nil {
+			value.Elem().SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, t.Bits()); err == nil {
+			value.Elem().SetFloat(f)
+		}
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			value.Elem().SetBool(b)
+		}
+	}
+	if err != nil {
+		return reflect.Value{}, true, ErrInvalidType
+	}
+	return value, true, nil
+}
+
+// isStringEncodable reports whether values of kind are encoded as strings by
+// the "string" option.
+func isStringEncodable(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return true
+	}
+	return false
+}
+
+// coerceAttribute converts val, a scalar JSON value, into a pointer to a value
+// of t, or of the type t points to, for WithCoercion. It returns false when
+// val already has the kind of t, or can't obviously be converted.
+func coerceAttribute(val interface{}, t reflect.Type) (reflect.Value, bool) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch v := val.(type) {
+	case string:
+		if t.Kind() == reflect.String {
+			return reflect.Value{}, false
+		}
+		value, ok, err := parseStringAttribute(strings.TrimSpace(v), t)
+		return value, ok && err == nil
+	case float64:
+		switch t.Kind() {
+		case reflect.Bool:
+			if v != 0 && v != 1 {
+				return reflect.Value{}, false
+			}
+			value := reflect.New(t)
+			value.Elem().SetBool(v == 1)
+			return value, true
+		case reflect.String:
+			value := reflect.New(t)
+			value.Elem().SetString(strconv.FormatFloat(v, 'f', -1, 64))
+			return value, true
+		}
+	case bool:
+		if t.Kind() == reflect.String {
+			value := reflect.New(t)
+			value.Elem().SetString(strconv.FormatBool(v))
+			return value, true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,131 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterAttributeType("email", &EmailPayload{})
+	RegisterAttributeType("sms", SMSPayload{})
+}
+
+func TestMarshalDiscriminatedAttribute(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	notification := &Notification{ID: 1, Payload: &EmailPayload{To: "a@example.com"}, Extra: []int{1, 2}}
+	if err := MarshalOnePayload(out, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	e := map[string]interface{}{"kind": "email", "to": "a@example.com"}
+	if a := resp.Data.Attributes["payload"]; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting payload %v, got %v", e, a)
+	}
+	if e, a := []interface{}{1.0, 2.0}, resp.Data.Attributes["extra"]; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting extra %v, got %v", e, a)
+	}
+}
+
+func TestUnmarshalDiscriminatedAttribute(t *testing.T) {
+	data := `{"data": {"type": "notifications", "id": "1", "attributes": {
+		"payload": {"kind": "email", "to": "a@example.com"},
+		"extra": {"retries": 3}
+	}}}`
+
+	notification := new(Notification)
+	if err := UnmarshalPayload(strings.NewReader(data), notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := (&EmailPayload{To: "a@example.com"}), notification.Payload; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting payload %#v, got %#v", e, a)
+	}
+	if e, a := map[string]interface{}{"retries": 3.0}, notification.Extra; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting extra %v, got %v", e, a)
+	}
+
+	// registered as a value
+	data = `{"data": {"type": "notifications", "id": "1", "attributes": {"payload": {"kind": "sms", "number": "555"}}}}`
+	notification = new(Notification)
+	if err := UnmarshalPayload(strings.NewReader(data), notification); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := (SMSPayload{Number: "555"}), notification.Payload; e != a {
+		t.Fatalf("Was expecting payload %#v, got %#v", e, a)
+	}
+
+	data = `{"data": {"type": "notifications", "id": "1", "attributes": {"payload": {"kind": "fax"}}}}`
+	if err := UnmarshalPayload(strings.NewReader(data), new(Notification)); err != ErrUnregisteredAttributeType {
+		t.Fatalf("Was expecting ErrUnregisteredAttributeType, got %v", err)
+	}
+}
+
+func TestStringEncodedAttributes(t *testing.T) {
+	offset := int64(-3)
+	gauge := &Gauge{ID: 1, Reading: 2.5, Count: 7, Offset: &offset, Enabled: true,
+		Label: "boiler", Readings: []string{"a"}}
+
+	payload, err := MarshalOne(gauge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"reading":  "2.5",
+		"count":    "7",
+		"offset":   "-3",
+		"limit":    nil,
+		"enabled":  "true",
+		"label":    "boiler",
+		"readings": []string{"a"},
+	}
+	if !reflect.DeepEqual(expected, payload.Data.Attributes) {
+		t.Fatalf("Was expecting the attributes %v, got %v", expected, payload.Data.Attributes)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(out).Encode(payload); err != nil {
+		t.Fatal(err)
+	}
+	got := new(Gauge)
+	if err := UnmarshalPayload(out, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gauge, got) {
+		t.Fatalf("Was expecting %+v, got %+v", gauge, got)
+	}
+}
+
+func TestStringEncodedAttributesUnmarshal(t *testing.T) {
+	// plain numbers and booleans are accepted too
+	data := `{"data": {"type": "gauges", "id": "1",
+		"attributes": {"reading": 2.5, "count": "7", "limit": "10", "enabled": false}}}`
+
+	gauge := new(Gauge)
+	if err := UnmarshalPayload(strings.NewReader(data), gauge); err != nil {
+		t.Fatal(err)
+	}
+	if gauge.Reading != 2.5 || gauge.Count != 7 || gauge.Limit == nil || *gauge.Limit != 10 || gauge.Enabled {
+		t.Fatalf("Was expecting the attributes to be parsed, got %+v", gauge)
+	}
+
+	for _, attributes := range []string{
+		`{"count": "seven"}`,
+		`{"count": "70000"}`,
+		`{"enabled": "yes please"}`,
+	} {
+		data := `{"data": {"type": "gauges", "id": "1", "attributes": ` + attributes + `}}`
+		err := UnmarshalPayload(strings.NewReader(data), new(Gauge))
+		if !errors.Is(err, ErrInvalidType) {
+			t.Fatalf("%s: was expecting ErrInvalidType, got %v", attributes, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize is the capacity beyond which a buffer is not returned
+// to the pool, so that a single huge response doesn't pin its memory.
+const maxPooledBufferSize = 4 << 20
+
+// bufferPool holds the buffers documents are encoded into by MarshalBuffer
+// and Write.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to the pool, unless it grew too large.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// Buffer holds a document encoded by MarshalBuffer in a pooled buffer. Its
+// bytes are valid until it is released.
+type Buffer struct {
+	buf *bytes.Buffer
+}
+
+// MarshalBuffer encodes the document of models, marshaled with MarshalMany
+// when it is a slice and MarshalOne otherwise, into a buffer taken from a
+// pool, cutting the allocations of large responses in high-throughput
+// services. The buffer must be released once its bytes are used, e.g.
+//
+//	buf, err := jsonapi.MarshalBuffer(blogs)
+//	if err != nil {
+//		...
+//	}
+//	defer buf.Release()
+//	w.Write(buf.Bytes())
+//
+// Write encodes the documents into the same pool.
+func MarshalBuffer(models interface{}, opts ...MarshalOption) (*Buffer, error) {
+	payload, _, _, err := marshalResponse(models, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := getBuffer()
+	if err := json.NewEncoder(buf).Encode(payload); err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	return &Buffer{buf: buf}, nil
+}
+
+// Bytes returns the encoded document. The slice is only valid until Release
+// is called, and must be copied to be kept longer.
+func (b *Buffer) Bytes() []byte {
+	if b.buf == nil {
+		return nil
+	}
+	return b.buf.Bytes()
+}
+
+// Len returns the size of the encoded document.
+func (b *Buffer) Len() int {
+	if b.buf == nil {
+		return 0
+	}
+	return b.buf.Len()
+}
+
+// WriteTo writes the encoded document to w. It implements io.WriterTo.
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+// Release returns the buffer to the pool. The Buffer must not be used
+// afterwards; releasing it again does nothing.
+func (b *Buffer) Release() {
+	if b.buf == nil {
+		return
+	}
+	putBuffer(b.buf)
+	b.buf = nil
+}
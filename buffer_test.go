@@ -0,0 +1,57 @@
+package jsonapi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalBuffer(t *testing.T) {
+	blog := testBlog()
+	for _, models := range []interface{}{blog, []*Blog{blog}} {
+		expected := bytes.NewBuffer(nil)
+		var err error
+		if _, ok := models.([]*Blog); ok {
+			err = MarshalManyPayload(expected, models)
+		} else {
+			err = MarshalOnePayload(expected, models)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := MarshalBuffer(models)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected.Bytes(), buf.Bytes()) || buf.Len() != expected.Len() {
+			t.Fatalf("Was expecting %s, got %s", expected.Bytes(), buf.Bytes())
+		}
+
+		out := bytes.NewBuffer(nil)
+		if n, err := buf.WriteTo(out); err != nil || n != int64(expected.Len()) {
+			t.Fatalf("Was expecting %d bytes to be written, got %d (%v)", expected.Len(), n, err)
+		}
+
+		buf.Release()
+		buf.Release()
+		if buf.Bytes() != nil || buf.Len() != 0 {
+			t.Fatal("Was expecting a released buffer to be empty")
+		}
+	}
+}
+
+func TestMarshalBufferError(t *testing.T) {
+	if _, err := MarshalBuffer(&Invoice{ID: 1}); err == nil {
+		t.Fatal("Was expecting an error")
+	}
+}
+
+func TestPutBufferTooLarge(t *testing.T) {
+	buf := getBuffer()
+	buf.Grow(maxPooledBufferSize + 1)
+	putBuffer(buf)
+
+	if got := getBuffer(); got.Cap() > maxPooledBufferSize {
+		t.Fatal("Was expecting a large buffer not to be pooled")
+	}
+}
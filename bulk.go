@@ -0,0 +1,126 @@
+package jsonapi
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BulkError is returned by UnmarshalBulkPayload when some of the resources of
+// the payload could not be unmarshaled. It holds their errors by index in the
+// "data" array.
+type BulkError struct {
+	Errors map[int]error
+}
+
+// Error implements the `Error` interface.
+func (e *BulkError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, i := range e.indexes() {
+		messages = append(messages, fmt.Sprintf("data[%d]: %v", i, e.Errors[i]))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ErrorObjects returns the ErrorObjects of each failed resource, with its index
+// in the "data" array as meta, ready to be written with MarshalErrors. A
+// resource failing validation has one for each of its *ValidationError
+// objects.
+func (e *BulkError) ErrorObjects() []*ErrorObject {
+	objects := make([]*ErrorObject, 0, len(e.Errors))
+	for _, i := range e.indexes() {
+		var errs []*ErrorObject
+		switch err := e.Errors[i].(type) {
+		case *ValidationError:
+			errs = err.Errors
+		case *ErrorObject:
+			errs = []*ErrorObject{err}
+		default:
+			errs = []*ErrorObject{{
+				Title:  "Invalid resource",
+				Detail: err.Error(),
+				Status: strconv.Itoa(422),
+			}}
+		}
+
+		for _, obj := range errs {
+			copied := *obj
+			meta := map[string]interface{}{"index": i}
+			if obj.Meta != nil {
+				for k, v := range *obj.Meta {
+					meta[k] = v
+				}
+			}
+			copied.Meta = &meta
+
+			objects = append(objects, &copied)
+		}
+	}
+	return objects
+}
+
+func (e *BulkError) indexes() []int {
+	indexes := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// UnmarshalBulkPayload unmarshals the resources of a bulk request, whose "data"
+// is an array of resources to create or update, into new instances of t. Unlike
+// UnmarshalManyPayload it unmarshals every resource it can: the returned slice
+// has an entry for each of them, nil for those that failed, and the error is a
+// *BulkError reporting the failures by index, e.g.
+//
+//	models, err := jsonapi.UnmarshalBulkPayload(r.Body, reflect.TypeOf(new(Post)))
+//	if bulkErr, ok := err.(*jsonapi.BulkError); ok {
+//		w.WriteHeader(http.StatusUnprocessableEntity)
+//		jsonapi.MarshalErrors(w, bulkErr.ErrorObjects())
+//		return
+//	}
+//
+// The results can be written back with MarshalManyPayload.
+func UnmarshalBulkPayload(in io.Reader, t reflect.Type,
+	opts ...UnmarshalOption) ([]interface{}, error) {
+	config := newUnmarshalConfig(opts)
+
+	payload := new(ManyPayload)
+	if err := config.decode(in, payload); err != nil {
+		return nil, err
+	}
+	if err := config.checkExtensions(payload.JSONAPI); err != nil {
+		return nil, err
+	}
+
+	if err := config.renameTypes(payload.Data, payload.Included); err != nil {
+		return nil, err
+	}
+
+	includedMap := includedNodeMap(payload.Data, payload.Included)
+	u := newUnmarshaler(config, &includedMap)
+
+	models := make([]interface{}, len(payload.Data))
+	bulkErr := &BulkError{Errors: make(map[int]error)}
+
+	for i, data := range payload.Data {
+		model, ok := u.primaryModel(data, t)
+		if !ok {
+			if err := u.unmarshalNode(data, model); err != nil {
+				bulkErr.Errors[i] = err
+				continue
+			}
+		}
+		models[i] = model.Interface()
+	}
+
+	if len(bulkErr.Errors) > 0 {
+		return models, bulkErr
+	}
+
+	return models, nil
+}
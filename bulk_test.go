@@ -0,0 +1,98 @@
+package jsonapi
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalBulkPayload(t *testing.T) {
+	data := `{"data": [
+		{"type": "accounts", "attributes": {"email": "Alice@example.com"}},
+		{"type": "accounts", "attributes": {"display_name": "bob"}},
+		{"type": "accounts", "attributes": {"email": "carol@example.com"}}
+	]}`
+
+	models, err := UnmarshalBulkPayload(strings.NewReader(data), reflect.TypeOf(new(Account)))
+
+	bulkErr, ok := err.(*BulkError)
+	if !ok {
+		t.Fatalf("Was expecting a *BulkError, got %v", err)
+	}
+	if e, a := errAccountEmail, bulkErr.Errors[1]; e != a || len(bulkErr.Errors) != 1 {
+		t.Fatalf("Was expecting `%v` for index 1, got %v", e, bulkErr.Errors)
+	}
+
+	if e, a := 3, len(models); e != a {
+		t.Fatalf("Was expecting %d models, got %d", e, a)
+	}
+	if models[1] != nil {
+		t.Fatalf("Was expecting no model for index 1, got %v", models[1])
+	}
+	if e, a := "carol@example.com", models[2].(*Account).Email; e != a {
+		t.Fatalf("Was expecting email %q, got %q", e, a)
+	}
+
+	objects := bulkErr.ErrorObjects()
+	if e, a := 1, (*objects[0].Meta)["index"]; len(objects) != 1 || e != a {
+		t.Fatalf("Was expecting an error object for index %d, got %v", e, a)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalErrors(out, objects); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalBulkPayloadValid(t *testing.T) {
+	data := `{"data": [{"type": "comments", "attributes": {"body": "foo"}}, {"type": "comments", "attributes": {"body": "bar"}}]}`
+
+	models, err := UnmarshalBulkPayload(strings.NewReader(data), reflect.TypeOf(new(Comment)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "bar", models[1].(*Comment).Body; e != a {
+		t.Fatalf("Was expecting body %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalBulkPayloadValidation(t *testing.T) {
+	data := `{"data": [
+		{"type": "reservations", "attributes": {"name": "Smith", "party": 4}},
+		{"type": "reservations", "attributes": {"party": 0}}
+	]}`
+
+	_, err := UnmarshalBulkPayload(strings.NewReader(data), reflect.TypeOf(new(Reservation)))
+	bulkErr, ok := err.(*BulkError)
+	if !ok {
+		t.Fatalf("Was expecting a *BulkError, got %v", err)
+	}
+
+	objects := bulkErr.ErrorObjects()
+	if e, a := 2, len(objects); e != a {
+		t.Fatalf("Was expecting %d error objects, got %d", e, a)
+	}
+	for _, obj := range objects {
+		if e, a := 1, (*obj.Meta)["index"]; e != a {
+			t.Fatalf("Was expecting index %d, got %v", e, a)
+		}
+	}
+}
+
+func TestUnmarshalBulkPayloadSharedResources(t *testing.T) {
+	data := `{"data": [
+		{"type": "people", "id": "1", "attributes": {"name": "alice"}, "relationships": {
+			"best_friend": {"data": {"type": "people", "id": "2"}}
+		}},
+		{"type": "people", "id": "2", "attributes": {"name": "bob"}}
+	]}`
+
+	models, err := UnmarshalBulkPayload(strings.NewReader(data), reflect.TypeOf(new(Person)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models[0].(*Person).BestFriend != models[1] {
+		t.Fatal("Was expecting the forward reference to be the second resource")
+	}
+}
@@ -0,0 +1,226 @@
+package jsonapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ModelError is returned by CheckModel, listing the problems found in the
+// jsonapi tags of a model and of the models it relates to.
+type ModelError struct {
+	// Type is the struct type of the model checked.
+	Type reflect.Type
+
+	// Problems describes each problem, prefixed with the field it was found
+	// on, e.g. "Post.Title: duplicate member name \"title\"".
+	Problems []string
+}
+
+// Error implements the `Error` interface.
+func (e *ModelError) Error() string {
+	return fmt.Sprintf("The jsonapi tags of %s are invalid: %s",
+		e.Type, strings.Join(e.Problems, "; "))
+}
+
+// CheckModel validates the jsonapi tags of model, a struct or a pointer to one,
+// and of the models it relates to, so that services can check their models
+// when they start or in their tests rather than on the first request, e.g.
+//
+//	func TestModels(t *testing.T) {
+//		for _, model := range []interface{}{new(Blog), new(Post), new(Comment)} {
+//			if err := jsonapi.CheckModel(model); err != nil {
+//				t.Error(err)
+//			}
+//		}
+//	}
+//
+// It reports malformed tags and unknown options, a missing or duplicate
+// primary field and ID types that can't be rendered, attributes and
+// relationships sharing a name, relations to models without a primary field,
+// and fields of types that can't be encoded, such as channels and functions,
+// as a *ModelError. Models implementing NodeMarshaler are not checked.
+func CheckModel(model interface{}) error {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrInvalidType
+	}
+
+	c := &modelChecker{checked: make(map[reflect.Type]bool)}
+	c.check(t)
+
+	if len(c.problems) > 0 {
+		return &ModelError{Type: t, Problems: c.problems}
+	}
+	return nil
+}
+
+// modelChecker collects the problems of a model and of its related models.
+type modelChecker struct {
+	checked  map[reflect.Type]bool
+	problems []string
+}
+
+var nodeMarshalerType = reflect.TypeOf((*NodeMarshaler)(nil)).Elem()
+
+func (c *modelChecker) report(t reflect.Type, field, format string, args ...interface{}) {
+	c.problems = append(c.problems,
+		fmt.Sprintf("%s.%s: %s", t.Name(), field, fmt.Sprintf(format, args...)))
+}
+
+// check validates the struct type t, then the models it relates to.
+func (c *modelChecker) check(t reflect.Type) {
+	if c.checked[t] || reflect.PtrTo(t).Implements(nodeMarshalerType) {
+		return
+	}
+	c.checked[t] = true
+
+	config := DefaultConfig()
+
+	var (
+		primaries int
+		members   = make(map[string]string)
+		related   []reflect.Type
+	)
+
+	for _, field := range checkedFields(t) {
+		parsed, err := ParseTag(field.Tag.Get(annotationJSONAPI))
+		if tagErr, ok := err.(*TagError); ok {
+			c.report(t, field.Name, "%s", tagErr.Problem)
+		}
+		if parsed == nil {
+			continue
+		}
+		annotation := parsed.Annotation
+
+		switch annotation {
+		case annotationPrimary:
+			primaries++
+			if !isIDKind(derefType(field.Type).Kind()) {
+				c.report(t, field.Name, "the id can't be a %s", field.Type)
+			}
+		case annotationClientID, annotationLocalID:
+			if field.Type.Kind() != reflect.String {
+				c.report(t, field.Name, "%q must be a string", annotation)
+			}
+		case annotationLinks:
+			if field.Type != reflect.TypeOf(Links{}) && field.Type != reflect.TypeOf(new(Links)) {
+				c.report(t, field.Name, "%q must be a Links or a *Links", annotation)
+			}
+		case annotationLinkageMeta:
+			if field.Type != reflect.TypeOf([]*Meta{}) && field.Type != reflect.TypeOf(new(Meta)) {
+				c.report(t, field.Name, "%q must be a []*Meta or a *Meta", annotation)
+			}
+		case annotationExtraRelations, annotationExtras:
+			if field.Type != reflect.TypeOf(map[string]interface{}{}) {
+				c.report(t, field.Name, "%q must be a map[string]interface{}", annotation)
+			}
+		case annotationRelationPresent:
+			if field.Type.Kind() != reflect.Bool {
+				c.report(t, field.Name, "%q must be a bool", annotation)
+			}
+		case annotationAttribute, annotationRelation:
+			name := config.memberName(parsed.Name)
+			if name == "id" || name == "type" {
+				c.report(t, field.Name, "%q is reserved", name)
+			}
+			if other, ok := members[name]; ok {
+				c.report(t, field.Name, "duplicate member name %q, also used by %s", name, other)
+			}
+			members[name] = field.Name
+
+			if annotation == annotationAttribute {
+				if kind, ok := unsupportedKind(field.Type); ok {
+					c.report(t, field.Name, "%s values can't be encoded", kind)
+				}
+				continue
+			}
+
+			if parsed.HasOption(annotationIDs) {
+				continue
+			}
+			if mt := derefType(field.Type); mt.Kind() == reflect.Map && !isRelationMapType(mt) {
+				c.report(t, field.Name, "a map relation must be keyed by strings and hold pointers to models, not %s", field.Type)
+				continue
+			}
+			target := relationTargetType(field.Type)
+			switch {
+			case target.Kind() == reflect.Interface:
+			case target.Kind() != reflect.Struct:
+				c.report(t, field.Name, "a relation must hold models, not %s", field.Type)
+			default:
+				if err := checkRelationTarget(t, field); err != nil {
+					c.report(t, field.Name, "%s has no primary annotated field", target)
+					continue
+				}
+				related = append(related, target)
+			}
+		}
+	}
+
+	switch {
+	case primaries == 0:
+		c.problems = append(c.problems, fmt.Sprintf("%s: no primary annotated field", t.Name()))
+	case primaries > 1:
+		c.problems = append(c.problems, fmt.Sprintf("%s: several primary annotated fields", t.Name()))
+	}
+
+	for _, r := range related {
+		c.check(r)
+	}
+}
+
+// checkedFields returns the tagged fields of the struct type t, then those
+// promoted from its embedded structs that are not hidden by a shallower field
+// for the same member. Unlike schemaOf, the fields that are ambiguous or
+// duplicated at the same depth are kept, to be reported.
+func checkedFields(t reflect.Type) []reflect.StructField {
+	fields := collectFields(t, nil, map[reflect.Type]bool{t: true})
+
+	depth := make(map[string]int)
+	var checked []reflect.StructField
+	for _, f := range fields {
+		key := memberKey(f.args)
+		if d, ok := depth[key]; ok && d < len(f.index) {
+			continue
+		}
+		depth[key] = len(f.index)
+		checked = append(checked, f.field)
+	}
+	return checked
+}
+
+// isIDKind reports whether fields of kind can hold a primary id.
+func isIDKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// unsupportedKind returns the kind of the values held by t, however deeply,
+// that encoding/json can't encode, if any.
+func unsupportedKind(t reflect.Type) (reflect.Kind, bool) {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return t.Kind(), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return unsupportedKind(t.Elem())
+	}
+	return reflect.Invalid, false
+}
+
+// derefType follows the pointers of t, however many levels.
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,70 @@
+package jsonapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckModel(t *testing.T) {
+	for _, model := range []interface{}{new(Blog), Post{}, new(Timetable), new(Team), new(Course)} {
+		if err := CheckModel(model); err != nil {
+			t.Fatalf("Was expecting %T to be valid, got %v", model, err)
+		}
+	}
+
+	if err := CheckModel("blogs"); err != ErrInvalidType {
+		t.Fatalf("Was expecting ErrInvalidType, got %v", err)
+	}
+}
+
+func TestCheckModelProblems(t *testing.T) {
+	err := CheckModel(new(Misfit))
+
+	modelErr, ok := err.(*ModelError)
+	if !ok {
+		t.Fatalf("Was expecting a *ModelError, got %v", err)
+	}
+	if e, a := reflect.TypeOf(Misfit{}), modelErr.Type; e != a {
+		t.Fatalf("Was expecting type %v, got %v", e, a)
+	}
+
+	for _, problem := range []string{
+		`Misfit.ID: the id can't be a float64`,
+		`Misfit.Name: unknown option "sorted"`,
+		`Misfit.Label: duplicate member name "name", also used by Name`,
+		`Misfit.Type: "type" is reserved`,
+		`Misfit.Callback: func values can't be encoded`,
+		`Misfit.Links: "links" must be a Links or a *Links`,
+		`Misfit.Owner: a relation must hold models, not string`,
+		`Invoice.Lines: jsonapi.LineItem has no primary annotated field`,
+		`Misfit.Color: unknown annotation "colour"`,
+		`Misfit.Present: "relation-present" must be a bool`,
+		`Misfit.Extra: "attr" needs a name`,
+		`Misfit.Squad: a map relation must be keyed by strings and hold pointers to models, not map[string]jsonapi.Person`,
+		`Misfit: several primary annotated fields`,
+	} {
+		found := false
+		for _, p := range modelErr.Problems {
+			found = found || p == problem
+		}
+		if !found {
+			t.Errorf("Was expecting the problem %q, got:\n%s", problem, strings.Join(modelErr.Problems, "\n"))
+		}
+	}
+}
+
+func TestCheckModelEmbeddedStructs(t *testing.T) {
+	if err := CheckModel(new(Story)); err != nil {
+		t.Fatalf("Was expecting Story to be valid, got %v", err)
+	}
+
+	err := CheckModel(new(Audited))
+	modelErr, ok := err.(*ModelError)
+	if !ok {
+		t.Fatalf("Was expecting a *ModelError, got %v", err)
+	}
+	if e, a := []string{`Audited.CreatedBy: unknown option "bogus"`}, modelErr.Problems; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the problems %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,44 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// NewRequest returns an *http.Request for a JSON API server, the client side
+// mirror of MarshalOnePayload. For POST and PATCH requests, model is marshaled
+// as the body with its relationships embedded, as with
+// MarshalOnePayloadEmbedded; other methods have no body and model may be nil.
+// The Accept header, and the Content-Type header of requests with a body, are
+// set to MediaType.
+//
+//	req, err := jsonapi.NewRequest(ctx, http.MethodPost, "https://api.example.com/blogs", blog)
+//	...
+//	resp, err := http.DefaultClient.Do(req)
+func NewRequest(ctx context.Context, method, url string, model interface{},
+	opts ...MarshalOption) (*http.Request, error) {
+	var body io.Reader
+	hasBody := model != nil && (method == http.MethodPost || method == http.MethodPatch)
+	if hasBody {
+		buf := bytes.NewBuffer(nil)
+		if err := MarshalOnePayloadEmbedded(buf, model, withContextOption(ctx, opts)...); err != nil {
+			return nil, err
+		}
+		body = buf
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Accept", MediaType)
+	if hasBody {
+		req.Header.Set("Content-Type", MediaType)
+	}
+
+	return req, nil
+}
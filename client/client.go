@@ -0,0 +1,399 @@
+/*
+Package client consumes JSON API services: it builds the requests with
+jsonapi.NewRequest, binds the documents of the responses to models and
+returns the errors documents as Go errors, e.g.
+
+	post := new(Post)
+	err := client.Get(ctx, "https://api.example.com/posts/1", post, client.Include("author"))
+
+	it := client.List(ctx, "https://api.example.com/posts", reflect.TypeOf(new(Post)))
+	for {
+		post, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			...
+		}
+		// ...use post.(*Post)
+	}
+
+A response with a status other than 2xx is returned as an *Error, holding the
+error objects of its document.
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/google/jsonapi"
+)
+
+// DefaultClient is the Client used by Get, List, Create, Patch and Delete.
+var DefaultClient = &Client{}
+
+// Client sends requests to JSON API services.
+type Client struct {
+	// HTTPClient sends the requests; http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+
+	// Options apply to every request, before those given to each call.
+	Options []Option
+}
+
+// Option configures a request of a Client.
+type Option func(*config)
+
+type config struct {
+	query         url.Values
+	header        http.Header
+	marshalOpts   []jsonapi.MarshalOption
+	unmarshalOpts []jsonapi.UnmarshalOption
+}
+
+// Include requests the related resources of the include paths, e.g.
+// "comments.author", with the include query parameter.
+func Include(paths ...string) Option {
+	return func(c *config) {
+		c.addList("include", paths)
+	}
+}
+
+// Fields requests the sparse fieldset of the resources of type resourceType,
+// with the fields[resourceType] query parameter.
+func Fields(resourceType string, fields ...string) Option {
+	return func(c *config) {
+		c.addList("fields["+resourceType+"]", fields)
+	}
+}
+
+// Sort requests the resources sorted by fields, prefixed with "-" when
+// descending, with the sort query parameter.
+func Sort(fields ...string) Option {
+	return func(c *config) {
+		c.addList("sort", fields)
+	}
+}
+
+// Filter sets the filter[member] query parameter to value.
+func Filter(member, value string) Option {
+	return Param("filter["+member+"]", value)
+}
+
+// Page sets the page[member] query parameter to value, e.g. Page("size", "50").
+func Page(member, value string) Option {
+	return Param("page["+member+"]", value)
+}
+
+// Param sets the query parameter key to value.
+func Param(key, value string) Option {
+	return func(c *config) {
+		c.query.Set(key, value)
+	}
+}
+
+// Header sets the header key of the request to value.
+func Header(key, value string) Option {
+	return func(c *config) {
+		c.header.Set(key, value)
+	}
+}
+
+// MarshalOptions are applied when marshaling the body of a request.
+func MarshalOptions(opts ...jsonapi.MarshalOption) Option {
+	return func(c *config) {
+		c.marshalOpts = append(c.marshalOpts, opts...)
+	}
+}
+
+// UnmarshalOptions are applied when unmarshaling the body of a response.
+func UnmarshalOptions(opts ...jsonapi.UnmarshalOption) Option {
+	return func(c *config) {
+		c.unmarshalOpts = append(c.unmarshalOpts, opts...)
+	}
+}
+
+// addList adds items to the comma separated list of the query parameter key.
+func (c *config) addList(key string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	if v := c.query.Get(key); v != "" {
+		items = append([]string{v}, items...)
+	}
+	c.query.Set(key, strings.Join(items, ","))
+}
+
+func (cl *Client) newConfig(opts []Option) *config {
+	c := &config{query: url.Values{}, header: http.Header{}}
+	for _, opt := range cl.Options {
+		opt(c)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned for a response with a status other than 2xx.
+type Error struct {
+	// StatusCode is the status of the response.
+	StatusCode int
+
+	// Errors holds the error objects of the document of the response, if it
+	// is an errors document.
+	Errors []*jsonapi.ErrorObject
+}
+
+// Error implements the `Error` interface.
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("jsonapi/client: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	for _, obj := range e.Errors {
+		msg += "; " + strings.TrimSpace(obj.Error())
+	}
+	return msg
+}
+
+// Get binds the resource of the document at rawURL to model, e.g.
+//
+//	err := client.Get(ctx, "https://api.example.com/posts/1", post, client.Include("author"))
+func (cl *Client) Get(ctx context.Context, rawURL string, model interface{}, opts ...Option) error {
+	c := cl.newConfig(opts)
+	body, err := cl.do(ctx, http.MethodGet, rawURL, nil, c)
+	if err != nil {
+		return err
+	}
+	return jsonapi.UnmarshalOneBytes(body, model, c.unmarshalOpts...)
+}
+
+// Create posts model to the collection at rawURL, with its relationships
+// embedded as MarshalOnePayloadEmbedded does. The resource of the response,
+// if any, is bound back to model, e.g. to set the id assigned by the server.
+func (cl *Client) Create(ctx context.Context, rawURL string, model interface{}, opts ...Option) error {
+	return cl.send(ctx, http.MethodPost, rawURL, model, opts)
+}
+
+// Patch updates the resource at rawURL with model, marshaled as Create does.
+// The resource of the response, if any, is bound back to model.
+func (cl *Client) Patch(ctx context.Context, rawURL string, model interface{}, opts ...Option) error {
+	return cl.send(ctx, http.MethodPatch, rawURL, model, opts)
+}
+
+// Delete deletes the resource at rawURL.
+func (cl *Client) Delete(ctx context.Context, rawURL string, opts ...Option) error {
+	_, err := cl.do(ctx, http.MethodDelete, rawURL, nil, cl.newConfig(opts))
+	return err
+}
+
+// send sends model with method and binds the resource of the response, if
+// the response has a body, back to it.
+func (cl *Client) send(ctx context.Context, method, rawURL string, model interface{}, opts []Option) error {
+	c := cl.newConfig(opts)
+	body, err := cl.do(ctx, method, rawURL, model, c)
+	if err != nil || len(bytes.TrimSpace(body)) == 0 {
+		return err
+	}
+	return jsonapi.UnmarshalOneBytes(body, model, c.unmarshalOpts...)
+}
+
+// do sends the request and returns the body of its response, or an *Error
+// for a status other than 2xx.
+func (cl *Client) do(ctx context.Context, method, rawURL string, model interface{}, c *config) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.query) > 0 {
+		query := u.Query()
+		for key, values := range c.query {
+			query[key] = values
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := jsonapi.NewRequest(ctx, method, u.String(), model, c.marshalOpts...)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+
+	httpClient := cl.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errorOf(resp.StatusCode, body)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	return body, nil
+}
+
+// errorOf returns the *Error of a response with status and body. A body that
+// is not an errors document leaves the error objects empty.
+func errorOf(status int, body []byte) *Error {
+	e := &Error{StatusCode: status}
+	var payload jsonapi.ErrorsPayload
+	if err := json.Unmarshal(body, &payload); err == nil {
+		e.Errors = payload.Errors
+	}
+	return e
+}
+
+// Iterator reads the resources of a collection, page by page, following the
+// next links of the documents.
+type Iterator struct {
+	cl  *Client
+	ctx context.Context
+	t   reflect.Type
+	c   *config
+
+	next  string
+	page  []interface{}
+	links jsonapi.Links
+	meta  jsonapi.Meta
+	err   error
+}
+
+// List returns an Iterator over the resources of the collection at rawURL,
+// bound to new instances of t. The options apply to every page, but the query
+// parameters only to the first one, the next links carrying their own.
+func (cl *Client) List(ctx context.Context, rawURL string, t reflect.Type, opts ...Option) *Iterator {
+	return &Iterator{cl: cl, ctx: ctx, t: t, c: cl.newConfig(opts), next: rawURL}
+}
+
+// Next returns the next resource of the collection, fetching the next page
+// when those of the current one are read, or io.EOF once the last page, which
+// has no next link, is read.
+func (it *Iterator) Next() (interface{}, error) {
+	for len(it.page) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		if it.next == "" {
+			return nil, io.EOF
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return nil, err
+		}
+	}
+
+	model := it.page[0]
+	it.page = it.page[1:]
+	return model, nil
+}
+
+// Links returns the top-level links of the last page fetched.
+func (it *Iterator) Links() jsonapi.Links {
+	return it.links
+}
+
+// Meta returns the top-level meta of the last page fetched, e.g. the total
+// number of resources.
+func (it *Iterator) Meta() jsonapi.Meta {
+	return it.meta
+}
+
+// fetch reads the page at it.next.
+func (it *Iterator) fetch() error {
+	pageURL := it.next
+	body, err := it.cl.do(it.ctx, http.MethodGet, pageURL, nil, it.c)
+	if err != nil {
+		return err
+	}
+	// the next links carry the query parameters
+	it.c.query = url.Values{}
+
+	var payload jsonapi.ManyPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	models, err := jsonapi.UnmarshalMany(&payload, it.t, it.c.unmarshalOpts...)
+	if err != nil {
+		return err
+	}
+	it.page = models
+
+	it.links, it.meta = nil, nil
+	if payload.Links != nil {
+		it.links = *payload.Links
+	}
+	if payload.Meta != nil {
+		it.meta = *payload.Meta
+	}
+
+	it.next = ""
+	if href := it.links.Href(jsonapi.KeyNextPage); href != "" {
+		next, err := resolveURL(pageURL, href)
+		if err != nil {
+			return err
+		}
+		if next == pageURL {
+			return fmt.Errorf("jsonapi/client: the next link of %s is the page itself", pageURL)
+		}
+		it.next = next
+	}
+	return nil
+}
+
+// resolveURL resolves href, which may be relative, against base.
+func resolveURL(base, href string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	h, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(h).String(), nil
+}
+
+// Get binds the resource of the document at rawURL to model, with the
+// DefaultClient.
+func Get(ctx context.Context, rawURL string, model interface{}, opts ...Option) error {
+	return DefaultClient.Get(ctx, rawURL, model, opts...)
+}
+
+// List returns an Iterator over the resources of the collection at rawURL,
+// with the DefaultClient.
+func List(ctx context.Context, rawURL string, t reflect.Type, opts ...Option) *Iterator {
+	return DefaultClient.List(ctx, rawURL, t, opts...)
+}
+
+// Create posts model to the collection at rawURL, with the DefaultClient.
+func Create(ctx context.Context, rawURL string, model interface{}, opts ...Option) error {
+	return DefaultClient.Create(ctx, rawURL, model, opts...)
+}
+
+// Patch updates the resource at rawURL with model, with the DefaultClient.
+func Patch(ctx context.Context, rawURL string, model interface{}, opts ...Option) error {
+	return DefaultClient.Patch(ctx, rawURL, model, opts...)
+}
+
+// Delete deletes the resource at rawURL, with the DefaultClient.
+func Delete(ctx context.Context, rawURL string, opts ...Option) error {
+	return DefaultClient.Delete(ctx, rawURL, opts...)
+}
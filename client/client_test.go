@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/google/jsonapi"
+)
+
+type Post struct {
+	ID     string  `jsonapi:"primary,posts"`
+	Title  string  `jsonapi:"attr,title"`
+	Author *Author `jsonapi:"relation,author,omitempty"`
+}
+
+type Author struct {
+	ID   string `jsonapi:"primary,authors"`
+	Name string `jsonapi:"attr,name"`
+}
+
+func TestGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e, a := "author", r.URL.Query().Get("include"); e != a {
+			t.Errorf("Was expecting include %q, got %q", e, a)
+		}
+		if e, a := jsonapi.MediaType, r.Header.Get("Accept"); e != a {
+			t.Errorf("Was expecting Accept %q, got %q", e, a)
+		}
+		post := &Post{ID: "1", Title: "Hello", Author: &Author{ID: "2", Name: "Ann"}}
+		jsonapi.Write(w, http.StatusOK, post)
+	}))
+	defer server.Close()
+
+	post := new(Post)
+	if err := Get(context.Background(), server.URL+"/posts/1", post, Include("author")); err != nil {
+		t.Fatal(err)
+	}
+	if post.Title != "Hello" || post.Author == nil || post.Author.Name != "Ann" {
+		t.Fatalf("Was expecting the post and its included author, got %+v", post)
+	}
+}
+
+func TestList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page[number]"))
+		if page == 0 {
+			page = 1
+			if e, a := "title", r.URL.Query().Get("sort"); e != a {
+				t.Errorf("Was expecting sort %q, got %q", e, a)
+			}
+		}
+
+		posts := []interface{}{
+			&Post{ID: strconv.Itoa(2*page - 1)},
+			&Post{ID: strconv.Itoa(2 * page)},
+		}
+		links := &jsonapi.Links{}
+		if page < 3 {
+			// a relative next link, resolved against the page
+			(*links)[jsonapi.KeyNextPage] = fmt.Sprintf("/posts?page[number]=%d", page+1)
+		}
+		w.Header().Set("Content-Type", jsonapi.MediaType)
+		jsonapi.MarshalManyPayload(w, posts, jsonapi.WithLinks(links))
+	}))
+	defer server.Close()
+
+	it := List(context.Background(), server.URL+"/posts", reflect.TypeOf(new(Post)), Sort("title"))
+	var ids []string
+	for {
+		post, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, post.(*Post).ID)
+	}
+
+	want := []string{"1", "2", "3", "4", "5", "6"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("Was expecting %v, got %v", want, ids)
+	}
+	if _, err := it.Next(); err != io.EOF {
+		t.Fatalf("Was expecting io.EOF, got %v", err)
+	}
+}
+
+func TestCreatePatchDelete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			post := new(Post)
+			if err := jsonapi.UnmarshalPayload(r.Body, post); err != nil {
+				t.Error(err)
+			}
+			if post.Author == nil || post.Author.Name != "Ann" {
+				t.Errorf("Was expecting the embedded author, got %+v", post.Author)
+			}
+			post.ID = "10"
+			jsonapi.Write(w, http.StatusCreated, post)
+		case http.MethodPatch:
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			jsonapi.WriteError(w, &jsonapi.ErrorObject{
+				Title:  "Forbidden",
+				Detail: "The post is locked",
+				Status: "403",
+			})
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	post := &Post{Title: "Hello", Author: &Author{ID: "2", Name: "Ann"}}
+	if err := Create(ctx, server.URL+"/posts", post); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "10", post.ID; e != a {
+		t.Fatalf("Was expecting the id %q assigned by the server, got %q", e, a)
+	}
+
+	post.Title = "Bye"
+	if err := Patch(ctx, server.URL+"/posts/10", post); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "Bye", post.Title; e != a {
+		t.Fatalf("Was expecting the title %q to be kept, got %q", e, a)
+	}
+
+	err := Delete(ctx, server.URL+"/posts/10")
+	var clientErr *Error
+	if !errors.As(err, &clientErr) {
+		t.Fatalf("Was expecting an *Error, got %v", err)
+	}
+	if e, a := http.StatusForbidden, clientErr.StatusCode; e != a {
+		t.Fatalf("Was expecting status %d, got %d", e, a)
+	}
+	if len(clientErr.Errors) != 1 || clientErr.Errors[0].Detail != "The post is locked" {
+		t.Fatalf("Was expecting the error object of the response, got %v", clientErr.Errors)
+	}
+}
+
+func TestErrorWithoutDocument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gateway timeout", http.StatusGatewayTimeout)
+	}))
+	defer server.Close()
+
+	it := List(context.Background(), server.URL+"/posts", reflect.TypeOf(new(Post)))
+	_, err := it.Next()
+	clientErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Was expecting an *Error, got %v", err)
+	}
+	if clientErr.StatusCode != http.StatusGatewayTimeout || len(clientErr.Errors) != 0 {
+		t.Fatalf("Was expecting a 504 without error objects, got %v", clientErr)
+	}
+	if _, again := it.Next(); again != err {
+		t.Fatalf("Was expecting the error again, got %v", again)
+	}
+}
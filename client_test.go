@@ -0,0 +1,45 @@
+package jsonapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestNewRequest(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey("tenant"), "acme")
+
+	req, err := NewRequest(ctx, http.MethodPost, "https://api.example.com/blogs", testBlog())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := MediaType, req.Header.Get("Content-Type"); e != a {
+		t.Fatalf("Was expecting Content-Type %q, got %q", e, a)
+	}
+	if e, a := MediaType, req.Header.Get("Accept"); e != a {
+		t.Fatalf("Was expecting Accept %q, got %q", e, a)
+	}
+	if req.Context() != ctx {
+		t.Fatal("Was expecting the request to carry the context")
+	}
+
+	blog := new(Blog)
+	if err := UnmarshalPayload(req.Body, blog); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := testBlog().Posts[1].Comments[1].Body, blog.Posts[1].Comments[1].Body; e != a {
+		t.Fatalf("Was expecting the embedded comment %q, got %q", e, a)
+	}
+
+	req, err = NewRequest(ctx, http.MethodGet, "https://api.example.com/blogs/5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Body != nil {
+		t.Fatal("Was not expecting a body")
+	}
+	if a := req.Header.Get("Content-Type"); a != "" {
+		t.Fatalf("Was not expecting a Content-Type, got %q", a)
+	}
+}
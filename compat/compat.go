@@ -0,0 +1,237 @@
+/*
+Package compat exposes the API of the upstream google/jsonapi package on top
+of this one, so that projects can migrate incrementally: importing it under
+the jsonapi name keeps code written for upstream compiling and behaving as
+before, while the rest of the project moves to the jsonapi package, e.g.
+
+	import jsonapi "github.com/google/jsonapi/compat"
+
+	payload, err := jsonapi.Marshal(blogs)
+
+The types are aliases of those of the jsonapi package, so the same models,
+payloads and errors are shared by both. The functions apply the upstream
+defaults, a zero jsonapi.Config, whatever is set by jsonapi.SetDefaultConfig:
+unix timestamps for times not tagged iso8601, member names as tagged, and
+unknown attributes ignored. The documents are those of upstream too: empty
+ids are rendered, empty attributes and relationships are left out, and the
+primary records related to other records are included as well.
+
+Instrumentation is the one of the jsonapi package, jsonapi.Instrumentation.
+*/
+package compat
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/google/jsonapi"
+)
+
+// The constants of the upstream package.
+const (
+	MediaType = jsonapi.MediaType
+
+	KeyFirstPage    = jsonapi.KeyFirstPage
+	KeyLastPage     = jsonapi.KeyLastPage
+	KeyPreviousPage = jsonapi.KeyPreviousPage
+	KeyNextPage     = jsonapi.KeyNextPage
+
+	QueryParamPageNumber = jsonapi.QueryParamPageNumber
+	QueryParamPageSize   = jsonapi.QueryParamPageSize
+	QueryParamPageOffset = jsonapi.QueryParamPageOffset
+	QueryParamPageLimit  = jsonapi.QueryParamPageLimit
+	QueryParamPageCursor = jsonapi.QueryParamPageCursor
+)
+
+// The types of the upstream package, shared with the jsonapi package.
+type (
+	Node                 = jsonapi.Node
+	OnePayload           = jsonapi.OnePayload
+	ManyPayload          = jsonapi.ManyPayload
+	RelationshipOneNode  = jsonapi.RelationshipOneNode
+	RelationshipManyNode = jsonapi.RelationshipManyNode
+	Links                = jsonapi.Links
+	Link                 = jsonapi.Link
+	Linkable             = jsonapi.Linkable
+	RelationshipLinkable = jsonapi.RelationshipLinkable
+	Meta                 = jsonapi.Meta
+	Metable              = jsonapi.Metable
+	RelationshipMetable  = jsonapi.RelationshipMetable
+	ErrorsPayload        = jsonapi.ErrorsPayload
+	ErrorObject          = jsonapi.ErrorObject
+	Event                = jsonapi.Event
+	Events               = jsonapi.Events
+	Runtime              = jsonapi.Runtime
+)
+
+// NewRuntime returns a new Runtime. The calls of a Runtime apply the
+// defaults of the jsonapi package, not those of upstream.
+func NewRuntime() *Runtime {
+	return jsonapi.NewRuntime()
+}
+
+// The instrumentation events of the upstream package.
+const (
+	UnmarshalStart = jsonapi.UnmarshalStart
+	UnmarshalStop  = jsonapi.UnmarshalStop
+	MarshalStart   = jsonapi.MarshalStart
+	MarshalStop    = jsonapi.MarshalStop
+)
+
+// The errors of the upstream package, the same values as those of the jsonapi
+// package.
+var (
+	ErrInvalidTime            = jsonapi.ErrInvalidTime
+	ErrInvalidISO8601         = jsonapi.ErrInvalidISO8601
+	ErrUnknownFieldNumberType = jsonapi.ErrUnknownFieldNumberType
+	ErrInvalidType            = jsonapi.ErrInvalidType
+	ErrUnsupportedPtrType     = jsonapi.ErrUnsupportedPtrType
+	ErrBadJSONAPIStructTag    = jsonapi.ErrBadJSONAPIStructTag
+	ErrBadJSONAPIID           = jsonapi.ErrBadJSONAPIID
+	ErrExpectedSlice          = jsonapi.ErrExpectedSlice
+
+	// ErrUnexpectedType is returned by Marshal for models that are neither a
+	// pointer to a struct nor a slice.
+	ErrUnexpectedType = errors.New("models should be a struct pointer or slice of struct pointers")
+)
+
+// Payloader is the payload returned by Marshal, a *OnePayload or a
+// *ManyPayload.
+type Payloader interface{}
+
+// Marshal returns the payload of models, a *OnePayload for a pointer to a
+// struct or a *ManyPayload for a slice. The links and meta of a slice type
+// implementing Linkable or Metable are the top-level links and meta of the
+// *ManyPayload.
+func Marshal(models interface{}) (Payloader, error) {
+	switch v := reflect.ValueOf(models); v.Kind() {
+	case reflect.Slice:
+		m := make([]interface{}, v.Len())
+		for i := range m {
+			m[i] = v.Index(i).Interface()
+		}
+
+		payload, err := jsonapi.MarshalMany(m, defaults())
+		if err != nil {
+			return nil, err
+		}
+		payload.Included = withPrimaryIncluded(payload.Data, payload.Included)
+		if linkable, ok := models.(Linkable); ok {
+			payload.Links = linkable.JSONAPILinks()
+		}
+		if metable, ok := models.(Metable); ok {
+			payload.Meta = metable.JSONAPIMeta()
+		}
+		return payload, nil
+	case reflect.Ptr:
+		if v.Elem().Kind() != reflect.Struct {
+			return nil, ErrUnexpectedType
+		}
+		payload, err := jsonapi.MarshalOne(models, defaults())
+		if err != nil {
+			return nil, err
+		}
+		payload.Included = withPrimaryIncluded([]*Node{payload.Data}, payload.Included)
+		return payload, nil
+	default:
+		return nil, ErrUnexpectedType
+	}
+}
+
+// MarshalPayload writes the document of models, a pointer to a struct or a
+// slice, with the related records included.
+func MarshalPayload(w io.Writer, models interface{}) error {
+	payload, err := Marshal(models)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(payload)
+}
+
+// MarshalPayloadWithoutIncluded writes the document of models, a pointer to a
+// struct or a slice, without the related records.
+func MarshalPayloadWithoutIncluded(w io.Writer, model interface{}) error {
+	payload, err := Marshal(model)
+	if err != nil {
+		return err
+	}
+
+	switch p := payload.(type) {
+	case *OnePayload:
+		p.Included = nil
+	case *ManyPayload:
+		p.Included = nil
+	}
+	return json.NewEncoder(w).Encode(payload)
+}
+
+// MarshalOnePayloadEmbedded writes the document of model with its related
+// records embedded in its relationships rather than included, for tests.
+func MarshalOnePayloadEmbedded(w io.Writer, model interface{}) error {
+	return jsonapi.MarshalOnePayloadEmbedded(w, model, defaults())
+}
+
+// MarshalErrors writes the errors document of errorObjects.
+func MarshalErrors(w io.Writer, errorObjects []*ErrorObject) error {
+	return jsonapi.MarshalErrors(w, errorObjects)
+}
+
+// UnmarshalPayload reads the document of a single record from in into model,
+// a pointer to a struct.
+func UnmarshalPayload(in io.Reader, model interface{}) error {
+	return jsonapi.UnmarshalPayload(in, model, unmarshalDefaults())
+}
+
+// UnmarshalManyPayload reads the document of many records from in, returning
+// new pointers to structs of type t.
+func UnmarshalManyPayload(in io.Reader, t reflect.Type) ([]interface{}, error) {
+	return jsonapi.UnmarshalManyPayload(in, t, unmarshalDefaults())
+}
+
+// defaults applies the upstream defaults to a Marshal call.
+func defaults() jsonapi.MarshalOption {
+	return jsonapi.WithConfig(jsonapi.Config{})
+}
+
+// unmarshalDefaults applies the upstream defaults to an Unmarshal call.
+func unmarshalDefaults() jsonapi.UnmarshalOption {
+	return jsonapi.WithUnmarshalConfig(jsonapi.Config{})
+}
+
+// withPrimaryIncluded adds to included the primary records of data related to
+// any record of the document, which the jsonapi package leaves out but
+// upstream, including every related record, doesn't.
+func withPrimaryIncluded(data, included []*Node) []*Node {
+	related := make(map[string]bool)
+	for _, nodes := range [][]*Node{data, included} {
+		for _, n := range nodes {
+			for _, relationship := range n.Relationships {
+				switch r := relationship.(type) {
+				case *RelationshipOneNode:
+					if r.Data != nil {
+						related[nodeKey(r.Data)] = true
+					}
+				case *RelationshipManyNode:
+					for _, d := range r.Data {
+						related[nodeKey(d)] = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, n := range data {
+		if key := nodeKey(n); related[key] {
+			included = append(included, n)
+			// a record is included once
+			delete(related, key)
+		}
+	}
+	return included
+}
+
+func nodeKey(n *Node) string {
+	return n.Type + "," + n.ID
+}
@@ -0,0 +1,175 @@
+package compat
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/jsonapi"
+)
+
+type Post struct {
+	ID        int        `jsonapi:"primary,posts"`
+	Title     string     `jsonapi:"attr,title"`
+	Comments  []*Comment `jsonapi:"relation,comments"`
+	ViewCount int        `jsonapi:"attr,view_count"`
+}
+
+type Comment struct {
+	ID   int    `jsonapi:"primary,comments"`
+	Body string `jsonapi:"attr,body"`
+}
+
+type Person struct {
+	ID      string    `jsonapi:"primary,people"`
+	Name    string    `jsonapi:"attr,name,omitempty"`
+	Friends []*Person `jsonapi:"relation,friends,omitempty"`
+}
+
+type Posts []*Post
+
+func (p Posts) JSONAPILinks() *Links {
+	return &Links{KeyNextPage: "/posts?page[number]=2"}
+}
+
+func (p Posts) JSONAPIMeta() *Meta {
+	return &Meta{"total": len(p)}
+}
+
+func testPost() *Post {
+	return &Post{ID: 1, Title: "Title", ViewCount: 3, Comments: []*Comment{{ID: 2, Body: "Body"}}}
+}
+
+func TestMarshal(t *testing.T) {
+	one, err := Marshal(testPost())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := one.(*OnePayload); !ok || len(p.Included) != 1 {
+		t.Fatalf("Was expecting a *OnePayload with an included comment, got %#v", one)
+	}
+
+	many, err := Marshal(Posts{testPost()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := many.(*ManyPayload)
+	if !ok {
+		t.Fatalf("Was expecting a *ManyPayload, got %T", many)
+	}
+	if e, a := "/posts?page[number]=2", (*p.Links)[KeyNextPage]; e != a {
+		t.Fatalf("Was expecting the next link %q, got %v", e, a)
+	}
+	if e, a := 1, (*p.Meta)["total"]; e != a {
+		t.Fatalf("Was expecting a total of %d, got %v", e, a)
+	}
+
+	for _, models := range []interface{}{*testPost(), new(string), "posts"} {
+		if _, err := Marshal(models); err != ErrUnexpectedType {
+			t.Fatalf("Was expecting ErrUnexpectedType for %T, got %v", models, err)
+		}
+	}
+}
+
+func TestMarshalPayloadWithoutIncluded(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := MarshalPayloadWithoutIncluded(out, []*Post{testPost()}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), `"included"`) {
+		t.Fatalf("Was expecting no included records, got %s", out)
+	}
+}
+
+func TestUpstreamDefaults(t *testing.T) {
+	defer jsonapi.SetDefaultConfig(jsonapi.DefaultConfig())
+	jsonapi.SetDefaultConfig(jsonapi.Config{
+		NamingStrategy: func(name string) string { return strings.Replace(name, "_", "-", -1) },
+		Strict:         true,
+	})
+
+	out := new(bytes.Buffer)
+	if err := MarshalPayload(out, testPost()); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Data struct{ Attributes map[string]interface{} }
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.Data.Attributes["view_count"]; !ok {
+		t.Fatalf("Was expecting the member names as tagged, got %v", doc.Data.Attributes)
+	}
+
+	in := `{"data":{"type":"posts","id":"1","attributes":{"title":"Title","unknown":true}}}`
+	post := new(Post)
+	if err := UnmarshalPayload(strings.NewReader(in), post); err != nil {
+		t.Fatalf("Was expecting unknown attributes to be ignored, got %v", err)
+	}
+	if e, a := "Title", post.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalManyPayload(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := MarshalPayload(out, []*Post{testPost(), testPost()}); err != nil {
+		t.Fatal(err)
+	}
+
+	posts, err := UnmarshalManyPayload(out, reflect.TypeOf(new(Post)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := testPost(), posts[0]; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting %v, got %v", e, a)
+	}
+}
+
+func TestUpstreamDocuments(t *testing.T) {
+	bob := &Person{ID: "2", Name: "Bob"}
+	ann := &Person{ID: "1", Name: "Ann", Friends: []*Person{bob}}
+
+	for _, test := range []struct {
+		name   string
+		models interface{}
+		golden string
+	}{{
+		name:   "empty id",
+		models: &Person{Name: "Ann"},
+		golden: `{"data":{"type":"people","id":"","attributes":{"name":"Ann"}}}`,
+	}, {
+		name:   "empty members",
+		models: &Person{ID: "1"},
+		golden: `{"data":{"type":"people","id":"1"}}`,
+	}, {
+		name:   "primary included",
+		models: []*Person{ann, bob},
+		golden: `{
+			"data":[
+				{"type":"people","id":"1","attributes":{"name":"Ann"},"relationships":{"friends":{"data":[{"type":"people","id":"2"}]}}},
+				{"type":"people","id":"2","attributes":{"name":"Bob"}}
+			],
+			"included":[{"type":"people","id":"2","attributes":{"name":"Bob"}}]
+		}`,
+	}} {
+		out := new(bytes.Buffer)
+		if err := MarshalPayload(out, test.models); err != nil {
+			t.Fatal(err)
+		}
+
+		var e, a interface{}
+		if err := json.Unmarshal([]byte(test.golden), &e); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(out.Bytes(), &a); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e, a) {
+			t.Fatalf("%s: Was expecting the upstream document %s, got %s", test.name, test.golden, out)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package jsonapi
+
+import (
+	"strings"
+	"sync"
+)
+
+// TimeFormat is how time attributes without an iso8601 tag option are
+// rendered and parsed.
+type TimeFormat int
+
+const (
+	// TimeFormatUnix renders times as unix timestamps, in seconds.
+	TimeFormatUnix TimeFormat = iota
+	// TimeFormatISO8601 renders times as ISO8601 strings, as if the attributes
+	// were tagged iso8601.
+	TimeFormatISO8601
+)
+
+// UnknownRelationships is how the Unmarshal functions handle the
+// relationships of the payload that the model doesn't declare.
+type UnknownRelationships int
+
+const (
+	// UnknownRelationshipsIgnore skips them.
+	UnknownRelationshipsIgnore UnknownRelationships = iota
+	// UnknownRelationshipsReject returns ErrUnknownRelationship.
+	UnknownRelationshipsReject
+	// UnknownRelationshipsCollect sets them in the extra-relationships
+	// annotated field of the model, if it has one, so that they are rendered
+	// back when the model is marshaled, e.g. by pass-through proxies.
+	UnknownRelationshipsCollect
+)
+
+// NamingStrategy derives the attribute and relationship member names of the
+// document from the names given in the jsonapi tags, e.g. to render tagged
+// snake_case names as camelCase.
+type NamingStrategy func(name string) string
+
+// Config holds the defaults applied by the Marshal and Unmarshal functions.
+// The package level defaults are set with SetDefaultConfig, and can be
+// overridden for a single call with WithConfig or WithUnmarshalConfig.
+type Config struct {
+	// TimeFormat is the format of the time attributes not tagged iso8601.
+	TimeFormat TimeFormat
+
+	// NamingStrategy, when set, is applied to the attribute and relationship
+	// names of the tags.
+	NamingStrategy NamingStrategy
+
+	// Strict makes the Unmarshal functions return ErrUnknownAttribute for
+	// attributes of the payload that the model doesn't declare.
+	Strict bool
+
+	// UnknownRelationships is how the Unmarshal functions handle the
+	// relationships of the payload that the model doesn't declare; they are
+	// ignored by default.
+	UnknownRelationships UnknownRelationships
+
+	// EmitEmptyMembers renders the attributes and relationships of the
+	// resource objects as {} when they are empty, rather than leaving them out,
+	// for clients that expect them to always be present.
+	EmitEmptyMembers bool
+
+	// BaseURL is prepended to the links starting with "/", so that models can
+	// return links relative to the root of the API.
+	BaseURL string
+
+	// Version is the version of the JSON API specification served, "1.0" when
+	// empty. With "1.1", CheckRequestHeaders accepts the ext and profile
+	// parameters of the media type.
+	Version string
+
+	// Extensions lists the URIs of the extensions the API supports. The
+	// Unmarshal functions return ErrUnsupportedExtension for documents
+	// applying others, and CheckRequestHeaders rejects the requests asking for
+	// others in the ext parameter of the media type.
+	Extensions []string
+}
+
+var (
+	defaultConfigMu sync.RWMutex
+	defaultConfig   Config
+)
+
+// SetDefaultConfig sets the Config applied by every Marshal and Unmarshal
+// call that doesn't override it. It is safe for concurrent use, but is meant
+// to be called once while the application starts.
+func SetDefaultConfig(c Config) {
+	defaultConfigMu.Lock()
+	defer defaultConfigMu.Unlock()
+
+	defaultConfig = c
+}
+
+// DefaultConfig returns the Config set by SetDefaultConfig.
+func DefaultConfig() Config {
+	defaultConfigMu.RLock()
+	defer defaultConfigMu.RUnlock()
+
+	return defaultConfig
+}
+
+// memberName returns the document member name for a tagged name.
+func (c Config) memberName(name string) string {
+	if c.NamingStrategy == nil {
+		return name
+	}
+	return c.NamingStrategy(name)
+}
+
+// resolveLinks returns links with BaseURL prepended to its relative hrefs.
+func (c Config) resolveLinks(links *Links) *Links {
+	if links == nil || c.BaseURL == "" {
+		return links
+	}
+
+	resolved := make(Links, len(*links))
+	for k, v := range *links {
+		switch link := v.(type) {
+		case string:
+			resolved[k] = c.resolveHref(link)
+		case Link:
+			link.Href = c.resolveHref(link.Href)
+			resolved[k] = link
+		case *Link:
+			if link != nil {
+				l := *link
+				l.Href = c.resolveHref(l.Href)
+				link = &l
+			}
+			resolved[k] = link
+		case map[string]interface{}:
+			// a link object decoded from a payload
+			obj := make(map[string]interface{}, len(link))
+			for name, value := range link {
+				obj[name] = value
+			}
+			if href, ok := obj["href"].(string); ok {
+				obj["href"] = c.resolveHref(href)
+			}
+			resolved[k] = obj
+		default:
+			resolved[k] = v
+		}
+	}
+
+	return &resolved
+}
+
+func (c Config) resolveHref(href string) string {
+	if !strings.HasPrefix(href, "/") || strings.HasPrefix(href, "//") {
+		return href
+	}
+	return strings.TrimSuffix(c.BaseURL, "/") + href
+}
@@ -0,0 +1,143 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultConfigTimeFormat(t *testing.T) {
+	SetDefaultConfig(Config{TimeFormat: TimeFormatISO8601})
+	defer SetDefaultConfig(Config{})
+
+	createdAt := time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)
+	payload, err := MarshalOne(&Blog{ID: 1, CreatedAt: createdAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "2016-08-17T08:27:12Z", payload.Data.Attributes["created_at"]; e != a {
+		t.Fatalf("Was expecting created_at %v, got %v", e, a)
+	}
+
+	// a per call Config overrides the default
+	payload, err = MarshalOne(&Blog{ID: 1, CreatedAt: createdAt}, WithConfig(Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := createdAt.Unix(), payload.Data.Attributes["created_at"]; e != a {
+		t.Fatalf("Was expecting created_at %v, got %v", e, a)
+	}
+}
+
+func TestConfigNamingStrategy(t *testing.T) {
+	config := Config{NamingStrategy: func(name string) string {
+		return strings.Replace(name, "_", "-", -1)
+	}}
+
+	payload, err := MarshalOne(testBlog(), WithConfig(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload.Data.Attributes["view-count"]; !ok {
+		t.Fatalf("Was expecting a view-count attribute, got %v", payload.Data.Attributes)
+	}
+	if _, ok := payload.Data.Relationships["current-post"]; !ok {
+		t.Fatalf("Was expecting a current-post relationship, got %v", payload.Data.Relationships)
+	}
+
+	data := `{"data": {"type": "posts", "id": "1", "attributes": {"blog-id": 5},
+		"relationships": {"latest-comment": {"data": {"type": "comments", "id": "2"}}}}}`
+
+	post := new(Post)
+	if err := UnmarshalPayload(strings.NewReader(data), post, WithUnmarshalConfig(config)); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 5, post.BlogID; e != a {
+		t.Fatalf("Was expecting blog id %d, got %d", e, a)
+	}
+	if post.LatestComment == nil || post.LatestComment.ID != 2 {
+		t.Fatalf("Was expecting latest comment 2, got %v", post.LatestComment)
+	}
+}
+
+func TestConfigStrict(t *testing.T) {
+	data := `{"data": {"type": "comments", "id": "1", "attributes": {"body": "Hi", "mood": "happy"}}}`
+
+	err := UnmarshalPayload(strings.NewReader(data), new(Comment), WithUnmarshalConfig(Config{Strict: true}))
+	if err != ErrUnknownAttribute {
+		t.Fatalf("Was expecting `%v`, got `%v`", ErrUnknownAttribute, err)
+	}
+
+	if err := UnmarshalPayload(strings.NewReader(data), new(Comment)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigBaseURL(t *testing.T) {
+	article := &Article{ID: 1, Links: &Links{
+		"self":      "/articles/1",
+		"related":   Link{Href: "/articles/1/author"},
+		"canonical": "https://example.org/articles/1",
+	}}
+
+	payload, err := MarshalOne(article, WithConfig(Config{BaseURL: "https://api.example.com/"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links := payload.Data.Links
+	if e, a := "https://api.example.com/articles/1", links.Href("self"); e != a {
+		t.Fatalf("Was expecting self %q, got %q", e, a)
+	}
+	if e, a := "https://api.example.com/articles/1/author", links.Href("related"); e != a {
+		t.Fatalf("Was expecting related %q, got %q", e, a)
+	}
+	if e, a := "https://example.org/articles/1", links.Href("canonical"); e != a {
+		t.Fatalf("Was expecting canonical %q, got %q", e, a)
+	}
+	if e, a := "/articles/1", article.Links.Href("self"); e != a {
+		t.Fatalf("Was not expecting the model links to change, got %q", a)
+	}
+}
+
+func TestConfigEmitEmptyMembers(t *testing.T) {
+	id := "1"
+	car := &Car{ID: &id}
+
+	for _, emit := range []bool{true, false} {
+		payload, err := MarshalOne(car, WithConfig(Config{EmitEmptyMembers: emit}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, member := range []string{`"attributes":{}`, `"relationships":{}`} {
+			if e, a := emit, bytes.Contains(b, []byte(member)); e != a {
+				t.Fatalf("Was expecting %s to be rendered: %v, got %s", member, e, b)
+			}
+		}
+	}
+}
+
+func TestNodeLeavesOutEmptyMembers(t *testing.T) {
+	// a Node built by hand is rendered as its struct tags describe
+	node := &Node{
+		Type:          "cars",
+		ID:            "1",
+		Attributes:    map[string]interface{}{},
+		Relationships: map[string]interface{}{},
+	}
+	b, err := json.Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := `{"type":"cars","id":"1"}`, string(b); e != a {
+		t.Fatalf("Was expecting %s, got %s", e, a)
+	}
+}
@@ -2,16 +2,45 @@ package jsonapi
 
 const (
 	// StructTag annotation strings
-	annotationJSONAPI   = "jsonapi"
-	annotationPrimary   = "primary"
-	annotationClientID  = "client-id"
-	annotationAttribute = "attr"
-	annotationRelation  = "relation"
-	annotationOmitEmpty = "omitempty"
-	annotationISO8601   = "iso8601"
-	annotationSeperator = ","
+	annotationJSONAPI         = "jsonapi"
+	annotationPrimary         = "primary"
+	annotationClientID        = "client-id"
+	annotationLocalID         = "lid"
+	annotationAttribute       = "attr"
+	annotationRelation        = "relation"
+	annotationLinks           = "links"
+	annotationMeta            = "meta"
+	annotationLinkageMeta     = "linkage-meta"
+	annotationRelationPresent = "relation-present"
+	annotationExtraRelations  = "extra-relationships"
+	annotationExtras          = "extras"
+	annotationOmitEmpty       = "omitempty"
+	annotationOmitZero        = "omitzero"
+	annotationNoInclude       = "noinclude"
+	annotationNoLinkage       = "nolinkage"
+	annotationIDs             = "ids"
+	annotationMapKey          = "mapkey"
+	annotationInverse         = "inverse"
+	annotationDiscriminator   = "discriminator"
+	annotationISO8601         = "iso8601"
+	annotationDate            = "date"
+	annotationRFC3339         = "rfc3339"
+	annotationUnixMilli       = "unixmilli"
+	annotationFormat          = "format"
+	annotationReadOnly        = "readonly"
+	annotationDeprecated      = "deprecated"
+	annotationString          = "string"
+	annotationScope           = "scope"
+	annotationSeperator       = ","
+
+	annotationValueSeparator = ":"
 
 	iso8601TimeFormat = "2006-01-02T15:04:05Z"
+	dateFormat        = "2006-01-02"
+
+	// unixMilliLayout stands for the times rendered as unix timestamps in
+	// milliseconds; it is not a layout of the time package.
+	unixMilliLayout = "unixmilli"
 
 	// MediaType is the identifier for the JSON API media type
 	//
@@ -0,0 +1,44 @@
+package jsonapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Deprecation describes the deprecation of an endpoint or of a version of an
+// API, rendered by WithDeprecation and SetDeprecationHeaders.
+type Deprecation struct {
+	// Date is when it was deprecated; the zero time renders the Deprecation
+	// header in the "true" form of the drafts preceding RFC 9745.
+	Date time.Time
+
+	// Sunset is when it will stop being served, if known.
+	Sunset time.Time
+}
+
+// meta returns the top-level meta members rendered by WithDeprecation.
+func (d Deprecation) meta() *Meta {
+	meta := Meta{"deprecated": true}
+	if !d.Sunset.IsZero() {
+		meta["sunset"] = d.Sunset.UTC().Format(iso8601TimeFormat)
+	}
+	return &meta
+}
+
+// SetDeprecationHeaders sets the Deprecation header and, when the sunset is
+// known, the Sunset header (RFC 8594) of a response. The Deprecation header is
+// the structured date of RFC 9745, e.g. "@1767225600", when the Date is known,
+// and otherwise "true", the form of the earlier drafts, which RFC 9745 doesn't
+// define. Write sets them on its own for the payloads marshaled
+// WithDeprecation.
+func SetDeprecationHeaders(w http.ResponseWriter, d Deprecation) {
+	if d.Date.IsZero() {
+		w.Header().Set("Deprecation", "true")
+	} else {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Date.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,64 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalDeprecatedMembers(t *testing.T) {
+	listing := &Listing{ID: 1, Title: "Loft", Headline: "Loft", Agent: &Person{ID: 2}}
+
+	payload, err := MarshalOne(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"headline", "agent"}
+	if payload.Data.Meta == nil || !reflect.DeepEqual(expected, (*payload.Data.Meta)["deprecated"]) {
+		t.Fatalf("Was expecting deprecated members %v, got %v", expected, payload.Data.Meta)
+	}
+	if payload.Meta != nil {
+		t.Fatalf("Was expecting no top-level meta, got %v", *payload.Meta)
+	}
+}
+
+func TestWriteWithDeprecation(t *testing.T) {
+	deprecation := Deprecation{
+		Date:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sunset: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	rec := httptest.NewRecorder()
+	if err := Write(rec, http.StatusOK, testBlog(), WithDeprecation(deprecation)); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "@1767225600", rec.Header().Get("Deprecation"); e != a {
+		t.Fatalf("Was expecting Deprecation %q, got %q", e, a)
+	}
+	if e, a := "Fri, 01 Jan 2027 00:00:00 GMT", rec.Header().Get("Sunset"); e != a {
+		t.Fatalf("Was expecting Sunset %q, got %q", e, a)
+	}
+
+	payload := new(OnePayload)
+	if err := json.NewDecoder(rec.Body).Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+	expected := Meta{"deprecated": true, "sunset": "2027-01-01T00:00:00Z"}
+	if payload.Meta == nil || !reflect.DeepEqual(expected, *payload.Meta) {
+		t.Fatalf("Was expecting meta %v, got %v", expected, payload.Meta)
+	}
+
+	rec = httptest.NewRecorder()
+	SetDeprecationHeaders(rec, Deprecation{})
+	if e, a := "true", rec.Header().Get("Deprecation"); e != a {
+		t.Fatalf("Was expecting Deprecation %q, got %q", e, a)
+	}
+	if a := rec.Header().Get("Sunset"); a != "" {
+		t.Fatalf("Was expecting no Sunset header, got %q", a)
+	}
+}
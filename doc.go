@@ -45,6 +45,12 @@ value arguments are comma separated.  The first argument must be, "primary", and
 the second must be the name that should appear in the "type" field for all data
 objects that represent this type of model.
 
+Value, lid: "lid"
+
+A string field annotated with "lid" holds the local id that a client assigns to a resource it
+creates, before the server assigns its id.  It is rendered as the "lid" of the resource, and of
+its resource identifiers, and populated when unmarshaling.
+
 Value, attr: "attr,<key name in attributes hash>[,<extra arguments>]"
 
 These fields' values should end up in the "attribute" hash for a record.  The first
@@ -53,15 +59,76 @@ the the "attributes" hash for that record.
 
 The following extra arguments are also supported:
 
-"omitempty": excludes the fields value from the "attribute" hash.
+"omitempty": excludes the fields value from the "attribute" hash when it is empty, as told by
+its JSONAPIIsEmpty() method for types implementing Emptier.
+"discriminator:<name>": for interface typed fields, renders values of the types registered with
+RegisterAttributeType with the <name> member set to their registered name, and unmarshals such
+objects back into the registered type.  Without it, interface typed fields are set to the
+decoded JSON value.
+"omitzero": excludes the field from the "attribute" hash when it is zero, as told by its
+IsZero() method if it has one (e.g. time ranges or decimals), or else by its type's zero value.
 "iso8601": uses the ISO8601 timestamp format when serialising or deserialising the time.Time value.
+"readonly": excludes the field from the payloads built by MarshalCreatePayload.
+"scope:<name>": renders the field only for the calls granted the scope <name>, with WithScopes
+or ContextWithScopes; the option can be repeated, the field being rendered for any of the scopes.
+"string": renders numbers and booleans as JSON strings, and parses such strings when
+unmarshaling, as the option of the encoding/json package does.
 
-Value, relation: "relation,<key name in relationships hash>"
+Value, relation: "relation,<key name in relationships hash>[,<extra arguments>]"
 
 Relations are struct fields that represent a one-to-one or one-to-many to other structs.
 jsonapi will traverse the graph of relationships and marshal or unmarshal records.  The first
 argument must be, "relation", and the second should be the name of the relationship, used as
-the key in the "relationships" hash for the record.
+the key in the "relationships" hash for the record.  Cycles in the graph (e.g. a child that
+points back at its parent) are detected, and the repeated record is rendered as a resource
+identifier instead of being traversed again.
+
+The following extra arguments are also supported:
+
+"omitempty": excludes empty to-one and to-many relationships from the "relationships" hash.
+"noinclude": emits the relationship linkage only; related records are never added to "included".
+"nolinkage": emits the relationship links and meta only, without resource linkage, e.g. for the
+count of an expensive relation; the relationship is left out when it has neither.
+"ids:<type>": the field holds the ID (or a slice of IDs) of the related resources of the given
+type, rather than the related structs.  Only the resource linkage is marshaled, and the IDs are
+stored when unmarshaling.  The type may be omitted for fields that are only unmarshaled.
+"mapkey:<name>": for to-many relations held by a map[string]*T field, renders the map key as
+the <name> member of each resource identifier's meta, and keys the map by it when
+unmarshaling.  Without it, the map is keyed by the related IDs.
+
+Value, links: "links"
+
+A field of type *Links (or Links) annotated with "links" holds the resource's own links
+object.  It is populated from the "links" of the resource when unmarshaling, and rendered as
+the resource's "links" when marshaling unless the model implements Linkable.
+
+Value, meta: "meta"
+
+A field annotated with "meta" holds the resource's own meta object.  It may be a *Meta, a
+map or a struct (encoded with its json tags).  It is populated from the "meta" of the resource
+when unmarshaling, and rendered as the resource's "meta" when marshaling unless the model
+implements Metable.
+
+Value, linkage-meta: "linkage-meta,<key name in relationships hash>"
+
+A field annotated with "linkage-meta" holds the meta of the resource identifiers in the data
+of the named relationship: a []*Meta, paired by index with the related records, for a to-many
+relationship, or a *Meta for a to-one relationship.  It is rendered when marshaling and
+populated when unmarshaling.
+
+Value, extras: "extras"
+
+A map[string]interface{} field annotated with "extras" collects the attributes of the payload
+that are not bound to an attr annotated field when unmarshaling, unless the model implements
+AttributeSetter, and so are not unknown in strict mode.  They are rendered back when
+marshaling, unless the model declares them, for lossless proxying.
+
+Value, extra-relationships: "extra-relationships"
+
+A map[string]interface{} field annotated with "extra-relationships" collects the relationships
+of the payload that the model doesn't declare, as *RelationshipOneNode, *RelationshipManyNode
+or *RelationshipLinksNode values, when unmarshaling with the UnknownRelationshipsCollect policy
+of the Config.  They are rendered back when marshaling, unless the model declares them.
 
 Use the methods below to Marshal and Unmarshal jsonapi.org json payloads.
 
@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // MarshalErrors writes a JSON API response using the given `[]error`.
@@ -45,11 +49,71 @@ type ErrorObject struct {
 	// Code is an application-specific error code, expressed as a string value.
 	Code string `json:"code,omitempty"`
 
+	// Source identifies the part of the request that caused the problem.
+	Source *ErrorSource `json:"source,omitempty"`
+
 	// Meta is an object containing non-standard meta-information about the error.
 	Meta *map[string]interface{} `json:"meta,omitempty"`
+
+	// Links holds the "about" link of the problem and, from version 1.1, its "type" link, e.g. the error types of a profile.
+	Links *Links `json:"links,omitempty"`
 }
 
 // Error implements the `Error` interface.
 func (e *ErrorObject) Error() string {
 	return fmt.Sprintf("Error: %s %s\n", e.Title, e.Detail)
 }
+
+// ErrorSource is the source object of an ErrorObject.
+type ErrorSource struct {
+	// Pointer is a JSON Pointer to the member of the request document that caused the problem, e.g. "/data/attributes/title".
+	Pointer string `json:"pointer,omitempty"`
+
+	// Parameter is the query parameter that caused the problem, e.g. "sort".
+	Parameter string `json:"parameter,omitempty"`
+
+	// Header is the request header that caused the problem.
+	Header string `json:"header,omitempty"`
+}
+
+// NewRateLimitError returns a 429 Too Many Requests error object for a client
+// that exhausted its quota of limit requests, which resets at reset. Its meta
+// holds the limit, the remaining requests, the reset time as a unix timestamp
+// and the number of seconds to wait before retrying, as retry_after.
+func NewRateLimitError(limit, remaining int, reset time.Time) *ErrorObject {
+	return &ErrorObject{
+		Title:  http.StatusText(http.StatusTooManyRequests),
+		Detail: "The rate limit was exceeded, retry after " + reset.UTC().Format(time.RFC1123),
+		Status: strconv.Itoa(http.StatusTooManyRequests),
+		Meta: &map[string]interface{}{
+			"limit":       limit,
+			"remaining":   remaining,
+			"reset":       reset.Unix(),
+			"retry_after": retryAfter(reset),
+		},
+	}
+}
+
+// retryAfter returns the number of seconds until reset, rounded up.
+func retryAfter(reset time.Time) int {
+	wait := time.Until(reset)
+	if wait <= 0 {
+		return 0
+	}
+	return int((wait + time.Second - 1) / time.Second)
+}
+
+// ValidationError is returned by the Unmarshal functions when a model
+// implementing Validator reports errors, and by Query.CheckSort.
+type ValidationError struct {
+	Errors []*ErrorObject
+}
+
+// Error implements the `Error` interface.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, obj := range e.Errors {
+		messages[i] = strings.TrimSpace(obj.Error())
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,112 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedExtension is returned by the Unmarshal functions when the
+// "ext" member of the "jsonapi" object of the document lists an extension
+// missing from the Extensions of the Config. WriteError writes it as a 415
+// Unsupported Media Type, as the specification mandates.
+var ErrUnsupportedExtension = errors.New("The payload applies an unsupported extension")
+
+// specVersionExtensions is the version of the specification introducing
+// extensions and profiles.
+const specVersionExtensions = "1.1"
+
+// specVersion is the version of the specification served when the Config
+// sets none.
+const specVersion = "1.0"
+
+// WithExtensions lists the URIs of the extensions applied to the document in
+// the "ext" member of its top-level "jsonapi" object, e.g. those negotiated
+// with the ext parameter of the media type.
+func WithExtensions(uris ...string) MarshalOption {
+	return func(c *marshalConfig) {
+		c.extensions = append(c.extensions, uris...)
+	}
+}
+
+// WithProfiles lists the URIs of the profiles applied to the document in the
+// "profile" member of its top-level "jsonapi" object.
+func WithProfiles(uris ...string) MarshalOption {
+	return func(c *marshalConfig) {
+		c.profiles = append(c.profiles, uris...)
+	}
+}
+
+// WithJSONAPI renders obj as the top-level "jsonapi" object of the document,
+// e.g. &jsonapi.JSONAPIObject{Version: "1.1"}, with the extensions and
+// profiles of WithExtensions and WithProfiles appended to its own. Without a
+// version, the Version of the Config is rendered, or else "1.0".
+func WithJSONAPI(obj *JSONAPIObject) MarshalOption {
+	return func(c *marshalConfig) {
+		c.jsonapi = obj
+	}
+}
+
+// jsonapiObject returns the top-level "jsonapi" object of WithJSONAPI,
+// listing the extensions and profiles of the options, or nil if there is no
+// such object, extension or profile, and the Config has no Version.
+func (c *marshalConfig) jsonapiObject() *JSONAPIObject {
+	if c.jsonapi == nil && len(c.extensions) == 0 && len(c.profiles) == 0 && c.defaults.Version == "" {
+		return nil
+	}
+
+	obj := new(JSONAPIObject)
+	if c.jsonapi != nil {
+		*obj = *c.jsonapi
+	}
+	// the slices of obj are copied, so that the given object is not modified
+	obj.Ext = append(obj.Ext[:len(obj.Ext):len(obj.Ext)], c.extensions...)
+	obj.Profile = append(obj.Profile[:len(obj.Profile):len(obj.Profile)], c.profiles...)
+
+	if obj.Version == "" {
+		obj.Version = c.defaults.Version
+	}
+	if obj.Version == "" {
+		obj.Version = specVersion
+		if len(obj.Ext) > 0 || len(obj.Profile) > 0 {
+			obj.Version = specVersionExtensions
+		}
+	}
+	return obj
+}
+
+// checkExtensions returns ErrUnsupportedExtension if obj, the "jsonapi"
+// object of a document, applies an extension that isn't supported.
+func (c *unmarshalConfig) checkExtensions(obj *JSONAPIObject) error {
+	if obj == nil {
+		return nil
+	}
+	for _, uri := range obj.Ext {
+		if !c.defaults.supportsExtension(uri) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedExtension, uri)
+		}
+	}
+	return nil
+}
+
+// supportsExtension reports whether uri is one of the Extensions.
+func (c Config) supportsExtension(uri string) bool {
+	for _, supported := range c.Extensions {
+		if supported == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedExtension returns the first extension of ext, the value of the
+// ext parameter of the media type, a space-separated list of URIs, that isn't
+// supported.
+func (c Config) unsupportedExtension(ext string) (string, bool) {
+	for _, uri := range strings.Fields(ext) {
+		if !c.supportsExtension(uri) {
+			return uri, true
+		}
+	}
+	return "", false
+}
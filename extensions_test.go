@@ -0,0 +1,153 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const atomicExtension = "https://jsonapi.org/ext/atomic"
+
+func TestMarshalExtensionsAndProfiles(t *testing.T) {
+	payload, err := MarshalOne(testBlog(),
+		WithExtensions(atomicExtension), WithProfiles("https://example.com/cursor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &JSONAPIObject{
+		Version: "1.1",
+		Ext:     []string{atomicExtension},
+		Profile: []string{"https://example.com/cursor"},
+	}
+	if !reflect.DeepEqual(expected, payload.JSONAPI) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, payload.JSONAPI)
+	}
+
+	many, err := MarshalMany([]interface{}{testBlog()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if many.JSONAPI != nil {
+		t.Fatalf("Was expecting no jsonapi object without extensions, got %+v", many.JSONAPI)
+	}
+
+	out := new(bytes.Buffer)
+	if err := MarshalManyPayload(out, []*Blog{testBlog()}, WithProfiles("https://example.com/cursor")); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := map[string]interface{}{
+		"version": "1.1",
+		"profile": []interface{}{"https://example.com/cursor"},
+	}, doc["jsonapi"]; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the jsonapi object %v, got %v", e, a)
+	}
+}
+
+func TestUnmarshalExtensions(t *testing.T) {
+	in := `{"jsonapi":{"version":"1.1","ext":["` + atomicExtension + `"]},
+		"data":{"type":"blogs","id":"1","attributes":{"title":"Title"}}}`
+
+	err := UnmarshalPayload(strings.NewReader(in), new(Blog))
+	if !errors.Is(err, ErrUnsupportedExtension) {
+		t.Fatalf("Was expecting ErrUnsupportedExtension, got %v", err)
+	}
+	if !strings.Contains(err.Error(), atomicExtension) {
+		t.Fatalf("Was expecting the extension in %q", err)
+	}
+
+	many := `{"jsonapi":{"ext":["` + atomicExtension + `"]},"data":[]}`
+	if _, err := UnmarshalManyPayload(strings.NewReader(many), reflect.TypeOf(new(Blog))); !errors.Is(err, ErrUnsupportedExtension) {
+		t.Fatalf("Was expecting ErrUnsupportedExtension, got %v", err)
+	}
+	if _, err := UnmarshalBulkPayload(strings.NewReader(many), reflect.TypeOf(new(Blog))); !errors.Is(err, ErrUnsupportedExtension) {
+		t.Fatalf("Was expecting ErrUnsupportedExtension, got %v", err)
+	}
+
+	blog := new(Blog)
+	config := WithUnmarshalConfig(Config{Extensions: []string{atomicExtension}})
+	if err := UnmarshalPayload(strings.NewReader(in), blog, config); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "Title", blog.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestMarshalJSONAPIObject(t *testing.T) {
+	version := &JSONAPIObject{Version: "1.1", Meta: &Meta{"implementation": "acme"}}
+
+	out := new(bytes.Buffer)
+	if err := MarshalOnePayloadWithMeta(out, testBlog(), &Meta{"request_id": "abc"},
+		WithJSONAPI(version), WithProfiles("https://example.com/cursor")); err != nil {
+		t.Fatal(err)
+	}
+	if version.Profile != nil {
+		t.Fatalf("Was expecting the given jsonapi object to be left untouched, got %+v", version)
+	}
+
+	obj := new(JSONAPIObject)
+	if err := UnmarshalPayloadWithJSONAPI(bytes.NewReader(out.Bytes()), new(Blog), obj); err != nil {
+		t.Fatal(err)
+	}
+	expected := &JSONAPIObject{
+		Version: "1.1",
+		Profile: []string{"https://example.com/cursor"},
+		Meta:    &Meta{"implementation": "acme"},
+	}
+	if !reflect.DeepEqual(expected, obj) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, obj)
+	}
+
+	var meta Meta
+	if err := UnmarshalPayloadWithMeta(bytes.NewReader(out.Bytes()), new(Blog), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "abc", meta["request_id"]; e != a {
+		t.Fatalf("Was expecting the request_id meta %q, got %v", e, a)
+	}
+
+	// without a version, that of the Config is rendered, or else 1.0
+	out.Reset()
+	if err := MarshalManyPayloadWithMeta(out, []*Blog{testBlog()}, &Meta{"total": 1},
+		WithJSONAPI(&JSONAPIObject{})); err != nil {
+		t.Fatal(err)
+	}
+	obj = new(JSONAPIObject)
+	if _, err := UnmarshalManyPayloadWithJSONAPI(out, reflect.TypeOf(new(Blog)), obj); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "1.0", obj.Version; e != a {
+		t.Fatalf("Was expecting the version %q, got %q", e, a)
+	}
+
+	payload, err := MarshalOne(testBlog(), WithJSONAPI(&JSONAPIObject{}), WithConfig(Config{Version: "1.1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "1.1", payload.JSONAPI.Version; e != a {
+		t.Fatalf("Was expecting the version %q of the Config, got %q", e, a)
+	}
+
+	// the Version of the Config alone renders the object
+	out.Reset()
+	if err := MarshalOnePayload(out, testBlog(), WithConfig(Config{Version: "1.1"})); err != nil {
+		t.Fatal(err)
+	}
+	if e := `"jsonapi":{"version":"1.1"}`; !strings.Contains(out.String(), e) {
+		t.Fatalf("Was expecting %s, got %s", e, out)
+	}
+	if payload, err = MarshalOne(testBlog()); err != nil {
+		t.Fatal(err)
+	}
+	if payload.JSONAPI != nil {
+		t.Fatalf("Was expecting no jsonapi object, got %+v", payload.JSONAPI)
+	}
+}
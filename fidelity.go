@@ -0,0 +1,180 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Loss is a member of a resource object of a payload that the model it is
+// bound to doesn't hold, and that would be missing or different were the
+// model marshaled back.
+type Loss struct {
+	// ResourceType and ResourceID identify the resource concerned.
+	ResourceType string
+	ResourceID   string
+
+	// Pointer is the JSON Pointer of the member within the resource object,
+	// e.g. "/attributes/color" or "/relationships/tags/meta", or "" for an
+	// included resource that no model holds.
+	Pointer string
+}
+
+// LossError is returned by the Unmarshal functions with WithFidelity when the
+// models can't hold every member of the resource objects of the payload.
+type LossError struct {
+	Losses []Loss
+}
+
+// Error implements the `Error` interface.
+func (e *LossError) Error() string {
+	losses := make([]string, len(e.Losses))
+	for i, l := range e.Losses {
+		losses[i] = fmt.Sprintf("%s %s%s", l.ResourceType, l.ResourceID, l.Pointer)
+	}
+	return "The models can't hold every member of the payload: " + strings.Join(losses, ", ")
+}
+
+// WithFidelity makes the Unmarshal functions check that marshaling the
+// models back renders every member of the resource objects of the payload:
+// their attributes, relationships, links and meta, and those of their
+// resource identifiers. When it doesn't, e.g. for an unknown attribute or the
+// links of a relationship that the model doesn't render, they return a
+// *LossError listing the members that would be lost, so that API gateways and
+// migration tools don't drop data silently. The model passed to
+// UnmarshalPayload is bound all the same.
+//
+// The models are marshaled with the Config and member renames of the call.
+// Unknown attributes and relationships are kept by the extras and
+// extra-relationships annotated fields. The top-level members of the document
+// are not held by models, and are not checked.
+func WithFidelity() UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.fidelity = true
+	}
+}
+
+// checkFidelity returns a *LossError if models, the models bound to the
+// primary data, or the models bound to the included resources, don't render
+// every member of their resource object.
+func (u *unmarshaler) checkFidelity(primary []*Node, models []reflect.Value, included []*Node) error {
+	var losses []Loss
+
+	check := func(n *Node, model reflect.Value) error {
+		nodeLosses, err := u.losses(n, model)
+		losses = append(losses, nodeLosses...)
+		return err
+	}
+
+	for i, n := range primary {
+		if err := check(n, models[i]); err != nil {
+			return err
+		}
+	}
+	for _, n := range included {
+		model, ok := u.models[fmt.Sprintf("%s,%s", n.Type, n.ID)]
+		if !ok {
+			losses = append(losses, Loss{ResourceType: n.Type, ResourceID: n.ID})
+			continue
+		}
+		if err := check(n, model); err != nil {
+			return err
+		}
+	}
+
+	if len(losses) > 0 {
+		return &LossError{Losses: losses}
+	}
+	return nil
+}
+
+// losses returns the members of n that model, bound to n, doesn't render.
+func (u *unmarshaler) losses(n *Node, model reflect.Value) ([]Loss, error) {
+	payload, err := MarshalOne(model.Interface(), WithConfig(u.config.defaults),
+		WithRenames(Renames{Members: u.config.renames.Members}), WithInclude())
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := genericJSON(n)
+	if err != nil {
+		return nil, err
+	}
+	out, err := genericJSON(payload.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var pointers []string
+	lostMembers(in, out, "", &pointers)
+
+	losses := make([]Loss, len(pointers))
+	for i, p := range pointers {
+		losses[i] = Loss{ResourceType: n.Type, ResourceID: n.ID, Pointer: p}
+	}
+	return losses, nil
+}
+
+// lostMembers appends to pointers the JSON Pointers of the members of in, a
+// generic JSON value, that are missing from or different in out.
+func lostMembers(in, out interface{}, pointer string, pointers *[]string) {
+	switch in := in.(type) {
+	case map[string]interface{}:
+		outMap, ok := out.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		names := make([]string, 0, len(in))
+		for name := range in {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			member := pointer + "/" + escapePointer(name)
+			if value, ok := outMap[name]; ok {
+				lostMembers(in[name], value, member, pointers)
+			} else {
+				*pointers = append(*pointers, member)
+			}
+		}
+		return
+	case []interface{}:
+		outSlice, ok := out.([]interface{})
+		if !ok || len(outSlice) != len(in) {
+			break
+		}
+
+		for i := range in {
+			lostMembers(in[i], outSlice[i], pointer+"/"+strconv.Itoa(i), pointers)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(in, out) {
+		*pointers = append(*pointers, pointer)
+	}
+}
+
+// escapePointer escapes name as a reference token of a JSON Pointer.
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// genericJSON returns v as generic JSON values, as decoded by encoding/json.
+func genericJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
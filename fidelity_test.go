@@ -0,0 +1,69 @@
+package jsonapi
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const proxiedPayload = `{"data": {"type": "proxied", "id": "1",
+	"attributes": {"name": "Proxy", "color": "red"},
+	"relationships": {
+		"owner": {"data": {"type": "people", "id": "2"}, "links": {"related": "/proxied/1/owner"}},
+		"tags": {"data": [{"type": "tags", "id": "3"}]}
+	}},
+	"included": [
+		{"type": "people", "id": "2", "attributes": {"name": "Ann", "age": 40}},
+		{"type": "tags", "id": "3", "attributes": {"label": "new"}},
+		{"type": "people", "id": "9", "attributes": {"name": "Bob"}}
+	]}`
+
+func TestUnmarshalWithFidelity(t *testing.T) {
+	model := new(Proxied)
+	err := UnmarshalPayload(strings.NewReader(proxiedPayload), model, WithFidelity())
+
+	var lossErr *LossError
+	if !errors.As(err, &lossErr) {
+		t.Fatalf("Was expecting a *LossError, got %v", err)
+	}
+	expected := []Loss{
+		{ResourceType: "proxied", ResourceID: "1", Pointer: "/relationships/owner/links"},
+		{ResourceType: "proxied", ResourceID: "1", Pointer: "/relationships/tags"},
+		{ResourceType: "people", ResourceID: "2", Pointer: "/attributes/age"},
+		{ResourceType: "tags", ResourceID: "3"},
+		{ResourceType: "people", ResourceID: "9"},
+	}
+	if !reflect.DeepEqual(expected, lossErr.Losses) {
+		t.Fatalf("Was expecting the losses %v, got %v", expected, lossErr.Losses)
+	}
+
+	// the model is bound all the same
+	if model.Name != "Proxy" || model.Owner == nil || model.Owner.Name != "Ann" {
+		t.Fatalf("Was expecting the model to be bound, got %+v", model)
+	}
+}
+
+func TestUnmarshalWithFidelityLossless(t *testing.T) {
+	payload := `{"data": [{"type": "proxied", "id": "1",
+		"attributes": {"name": "Proxy", "color": "red"},
+		"relationships": {
+			"owner": {"data": {"type": "people", "id": "2"}},
+			"tags": {"data": [{"type": "tags", "id": "3", "meta": {"weight": 2}}], "links": {"self": "/tags"}}
+		}}]}`
+
+	models, err := UnmarshalManyPayload(strings.NewReader(payload), reflect.TypeOf(new(Proxied)),
+		WithFidelity(), WithUnmarshalConfig(Config{UnknownRelationships: UnknownRelationshipsCollect}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].(*Proxied).Extras["color"] != "red" {
+		t.Fatalf("Was expecting the extras to be kept, got %v", models)
+	}
+
+	_, err = UnmarshalManyPayload(strings.NewReader(payload), reflect.TypeOf(new(Proxied)), WithFidelity())
+	var lossErr *LossError
+	if !errors.As(err, &lossErr) || len(lossErr.Losses) != 1 || lossErr.Losses[0].Pointer != "/relationships/tags" {
+		t.Fatalf("Was expecting the tags to be lost, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package jsonapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterOperator is the comparison of a Predicate, given after the member of
+// a filter parameter, e.g. filter[view_count][gt]=10. Parameters without an
+// operator, e.g. filter[title]=Go, compare with FilterEq.
+type FilterOperator string
+
+// The operators of the filter parameters.
+const (
+	FilterEq   FilterOperator = "eq"
+	FilterNe   FilterOperator = "ne"
+	FilterLt   FilterOperator = "lt"
+	FilterGt   FilterOperator = "gt"
+	FilterIn   FilterOperator = "in"
+	FilterLike FilterOperator = "like"
+)
+
+// Predicate is a filter parameter bound to a field of a model.
+type Predicate struct {
+	// Member is the attribute name, or "id".
+	Member string
+
+	// Field is the name of the struct field of the model.
+	Field string
+
+	Operator FilterOperator
+
+	// Value is the filter value converted to the type of the field, e.g. an
+	// int or a time.Time; a slice of that type for FilterIn, whose values are
+	// comma separated.
+	Value interface{}
+}
+
+// filterField is a member of a model that can be filtered.
+type filterField struct {
+	name   string
+	typ    reflect.Type
+	layout string
+}
+
+// Filters returns the predicates of the filter of q bound to the fields of
+// model, a struct or a pointer to one, ordered by member, e.g.
+//
+//	predicates, err := query.Filters(new(Blog))
+//	if err != nil {
+//		jsonapi.WriteError(w, err)
+//		return
+//	}
+//
+// Only "id" and the attributes of model can be filtered, and FilterLike only
+// applies to strings. The parameters naming other members or operators, or
+// whose values don't convert to the type of the field, result in a
+// *ValidationError holding a 400 Bad Request error object for each of them,
+// whose source is the parameter.
+func (q *Query) Filters(model interface{}) ([]*Predicate, error) {
+	fields := filterFields(model)
+
+	keys := make([]string, 0, len(q.Filter))
+	for key := range q.Filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var (
+		predicates []*Predicate
+		objects    []*ErrorObject
+	)
+	for _, key := range keys {
+		member, operator := splitFilterKey(key)
+
+		predicate, detail := bindPredicate(fields, member, operator, q.Filter[key])
+		if detail != "" {
+			parameter := "filter[" + member + "]"
+			if key != member {
+				parameter += "[" + string(operator) + "]"
+			}
+
+			obj := statusErrorObject(http.StatusBadRequest, detail)
+			obj.Source = &ErrorSource{Parameter: parameter}
+			objects = append(objects, obj)
+			continue
+		}
+		predicates = append(predicates, predicate)
+	}
+
+	if len(objects) > 0 {
+		return nil, &ValidationError{Errors: objects}
+	}
+	return predicates, nil
+}
+
+// bindPredicate returns the predicate of a filter parameter, or the detail
+// of the error object describing why it can't be applied.
+func bindPredicate(fields map[string]filterField, member string,
+	operator FilterOperator, value string) (*Predicate, string) {
+	field, ok := fields[member]
+	if !ok {
+		return nil, fmt.Sprintf("The resource can't be filtered by %q", member)
+	}
+
+	predicate := &Predicate{Member: member, Field: field.name, Operator: operator}
+
+	switch operator {
+	case FilterEq, FilterNe, FilterLt, FilterGt:
+		v, err := filterValue(field, value)
+		if err != nil {
+			return nil, fmt.Sprintf("Invalid value for %q: %v", member, err)
+		}
+		predicate.Value = v.Interface()
+	case FilterIn:
+		values := splitList(value)
+		slice := reflect.MakeSlice(reflect.SliceOf(field.typ), 0, len(values))
+		for _, item := range values {
+			v, err := filterValue(field, item)
+			if err != nil {
+				return nil, fmt.Sprintf("Invalid value for %q: %v", member, err)
+			}
+			slice = reflect.Append(slice, v)
+		}
+		predicate.Value = slice.Interface()
+	case FilterLike:
+		if field.typ.Kind() != reflect.String {
+			return nil, fmt.Sprintf("%q can't be filtered with %q", member, operator)
+		}
+		predicate.Value = value
+	default:
+		return nil, fmt.Sprintf("Unknown filter operator %q", operator)
+	}
+
+	return predicate, ""
+}
+
+// filterFields returns the members of model that can be filtered, by name.
+func filterFields(model interface{}) map[string]filterField {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	config := DefaultConfig()
+
+	fields := make(map[string]filterField)
+	for _, f := range schemaOf(t).fields {
+		structField, args := f.field, f.args
+
+		typ := structField.Type
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		switch {
+		case args[0] == annotationPrimary:
+			fields["id"] = filterField{name: structField.Name, typ: typ}
+		case args[0] == annotationAttribute && len(args) > 1:
+			// a bad layout is reported by Marshal and Unmarshal
+			layout, _ := attributeTimeLayout(args, config.TimeFormat)
+			fields[config.memberName(args[1])] = filterField{name: structField.Name, typ: typ, layout: layout}
+		}
+	}
+
+	return fields
+}
+
+// splitFilterKey returns the member and the operator of a key of
+// Query.Filter, e.g. "view_count" and FilterGt for "view_count[gt]".
+func splitFilterKey(key string) (string, FilterOperator) {
+	i := strings.Index(key, "[")
+	if i < 0 || !strings.HasSuffix(key, "]") {
+		return key, FilterEq
+	}
+	return key[:i], FilterOperator(key[i+1 : len(key)-1])
+}
+
+// filterValue converts value to the type of field.
+func filterValue(field filterField, value string) (reflect.Value, error) {
+	v := reflect.New(field.typ).Elem()
+
+	if field.typ == reflect.TypeOf(time.Time{}) {
+		var at interface{} = value
+		if field.layout == "" || field.layout == unixMilliLayout {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return v, ErrInvalidTime
+			}
+			at = n
+		}
+
+		t, err := parseTimeAttribute(at, field.layout)
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(t))
+		return v, nil
+	}
+
+	switch field.typ.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return v, fmt.Errorf("%q is not a boolean", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.typ.Bits())
+		if err != nil {
+			return v, fmt.Errorf("%q is not an integer", value)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.typ.Bits())
+		if err != nil {
+			return v, fmt.Errorf("%q is not a positive integer", value)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.typ.Bits())
+		if err != nil {
+			return v, fmt.Errorf("%q is not a number", value)
+		}
+		v.SetFloat(f)
+	default:
+		return v, fmt.Errorf("%s values can't be filtered", field.typ)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,66 @@
+package jsonapi
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueryFilters(t *testing.T) {
+	values, err := url.ParseQuery("filter[title]=Go&filter[view_count][gt]=10" +
+		"&filter[id][in]=1,2&filter[created_at][lt]=1500000000&filter[title][like]=Go%25")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	predicates, err := ParseQuery(values).Filters(new(Blog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Predicate{
+		{Member: "created_at", Field: "CreatedAt", Operator: FilterLt, Value: time.Unix(1500000000, 0)},
+		{Member: "id", Field: "ID", Operator: FilterIn, Value: []int{1, 2}},
+		{Member: "title", Field: "Title", Operator: FilterEq, Value: "Go"},
+		{Member: "title", Field: "Title", Operator: FilterLike, Value: "Go%"},
+		{Member: "view_count", Field: "ViewCount", Operator: FilterGt, Value: 10},
+	}
+	if !reflect.DeepEqual(expected, predicates) {
+		for i, p := range predicates {
+			t.Logf("%d: %+v", i, p)
+		}
+		t.Fatal("Was expecting the predicates of the filter")
+	}
+}
+
+func TestQueryFiltersErrors(t *testing.T) {
+	values, err := url.ParseQuery("filter[secret]=1&filter[view_count][gt]=many" +
+		"&filter[view_count][like]=1&filter[title][between]=a,b&filter[id]=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseQuery(values).Filters(new(Blog))
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Was expecting a *ValidationError, got %v", err)
+	}
+
+	expected := []string{
+		"filter[secret]",
+		"filter[title][between]",
+		"filter[view_count][gt]",
+		"filter[view_count][like]",
+	}
+	var parameters []string
+	for _, obj := range validationErr.Errors {
+		if e, a := "400", obj.Status; e != a {
+			t.Fatalf("Was expecting status %s, got %s", e, a)
+		}
+		parameters = append(parameters, obj.Source.Parameter)
+	}
+	if !reflect.DeepEqual(expected, parameters) {
+		t.Fatalf("Was expecting errors for %v, got %v", expected, parameters)
+	}
+}
@@ -0,0 +1,5 @@
+module github.com/google/jsonapi
+
+go 1.18
+
+require golang.org/x/tools v0.14.0
@@ -0,0 +1,381 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clientErrors are the errors caused by a malformed request payload, written
+// by WriteError as 400 Bad Request.
+var clientErrors = []error{
+	ErrInvalidTime,
+	ErrInvalidISO8601,
+	ErrInvalidDate,
+	ErrInvalidTimeLayout,
+	ErrUnknownFieldNumberType,
+	ErrUnsupportedPtrType,
+	ErrInvalidType,
+	ErrUnknownAttribute,
+	ErrUnknownRelationship,
+	ErrAttributeNotAllowed,
+	ErrBadJSONAPIID,
+	ErrBadTypePrefix,
+	ErrMalformedManyPayload,
+	ErrNestingTooDeep,
+	ErrRelationshipTooDeep,
+	ErrUnregisteredAttributeType,
+	ErrUnregisteredType,
+	ErrMalformedOperation,
+}
+
+// Write writes a complete JSON API response: the Content-Type header, status
+// and the payload of models, marshaled with MarshalMany when it is a slice and
+// MarshalOne otherwise, e.g.
+//
+//	func ShowBlog(w http.ResponseWriter, r *http.Request) {
+//		...
+//		jsonapi.Write(w, http.StatusOK, blog)
+//	}
+//
+// The pagination links of the payload are also written as Link headers (see
+// SetLinkHeader), and the Deprecation and Sunset headers are set for the
+// payloads marshaled WithDeprecation. A nil model, e.g. a (*Blog)(nil), is
+// written as an empty to-one response, {"data":null}. The payload is marshaled before
+// anything is written, so that when marshaling fails the response is a 500
+// errors document rather than a half written payload; the marshaling error is
+// then returned.
+func Write(w http.ResponseWriter, status int, models interface{}, opts ...MarshalOption) error {
+	payload, links, config, err := marshalResponse(models, opts)
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err == nil {
+		err = json.NewEncoder(buf).Encode(payload)
+	}
+	if err != nil {
+		writeErrors(w, http.StatusInternalServerError,
+			[]*ErrorObject{statusErrorObject(http.StatusInternalServerError, "")})
+		return err
+	}
+
+	SetLinkHeader(w, links)
+	if config.deprecation != nil {
+		SetDeprecationHeaders(w, *config.deprecation)
+	}
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(status)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// marshalResponse returns the payload of models, its top-level links and the
+// config of opts.
+func marshalResponse(models interface{}, opts []MarshalOption) (interface{}, *Links, *marshalConfig, error) {
+	config := newMarshalConfig(opts)
+
+	v := reflect.ValueOf(models)
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		links, err := config.documentLinks()
+		if err != nil {
+			return nil, nil, config, err
+		}
+		payload := &OnePayload{
+			Links:   links,
+			Meta:    config.deprecationMeta(config.queryMeta(config.meta)),
+			JSONAPI: config.jsonapiObject(),
+		}
+		return payload, payload.Links, config, nil
+	}
+
+	if v.Kind() != reflect.Slice {
+		payload, err := MarshalOne(models, opts...)
+		if err != nil {
+			return nil, nil, config, err
+		}
+		return payload, payload.Links, config, nil
+	}
+
+	m, err := convertToSliceInterface(&models)
+	if err != nil {
+		return nil, nil, config, err
+	}
+	payload, err := MarshalMany(m, opts...)
+	if err != nil {
+		return nil, nil, config, err
+	}
+	return payload, payload.Links, config, nil
+}
+
+// paginationRelations are the members of a links object mirrored by
+// SetLinkHeader, in the order they are written.
+var paginationRelations = []string{"first", "prev", "next", "last"}
+
+// SetLinkHeader adds a Link header (RFC 8288) for each pagination link of
+// links, e.g. `Link: <https://example.com/blogs?page[number]=3>; rel="next"`,
+// so that generic HTTP clients can paginate without parsing the payload.
+func SetLinkHeader(w http.ResponseWriter, links *Links) {
+	for _, rel := range paginationRelations {
+		if href := links.Href(rel); href != "" {
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=%q", href, rel))
+		}
+	}
+}
+
+// WriteError writes an errors document for err, with a status inferred from
+// it:
+//
+//   - an *ErrorObject, a *ValidationError or a *BulkError is written as is,
+//     with the status of its error objects (422 when they have none);
+//   - the errors of the package caused by a malformed payload, e.g.
+//     ErrInvalidTime or ErrUnknownAttribute, and JSON syntax errors are written
+//     as 400 Bad Request, with the error as detail;
+//   - ErrPayloadTooLarge is written as 413 Request Entity Too Large;
+//   - ErrUnsupportedExtension is written as 415 Unsupported Media Type;
+//   - any other error is written as a 500 Internal Server Error that doesn't
+//     disclose it.
+func WriteError(w http.ResponseWriter, err error) error {
+	var (
+		obj           *ErrorObject
+		validationErr *ValidationError
+		bulkErr       *BulkError
+		syntaxErr     *json.SyntaxError
+		typeErr       *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &obj):
+		return writeErrorObjects(w, []*ErrorObject{obj})
+	case errors.As(err, &validationErr):
+		return writeErrorObjects(w, validationErr.Errors)
+	case errors.As(err, &bulkErr):
+		return writeErrorObjects(w, bulkErr.ErrorObjects())
+	case errors.Is(err, ErrPayloadTooLarge):
+		return writeErrors(w, http.StatusRequestEntityTooLarge,
+			[]*ErrorObject{statusErrorObject(http.StatusRequestEntityTooLarge, err.Error())})
+	case errors.Is(err, ErrUnsupportedExtension):
+		return writeErrors(w, http.StatusUnsupportedMediaType,
+			[]*ErrorObject{statusErrorObject(http.StatusUnsupportedMediaType, err.Error())})
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), isClientError(err):
+		return writeErrors(w, http.StatusBadRequest,
+			[]*ErrorObject{statusErrorObject(http.StatusBadRequest, err.Error())})
+	default:
+		return writeErrors(w, http.StatusInternalServerError,
+			[]*ErrorObject{statusErrorObject(http.StatusInternalServerError, "")})
+	}
+}
+
+func isClientError(err error) bool {
+	for _, clientErr := range clientErrors {
+		if errors.Is(err, clientErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeErrorObjects writes objects with the status that applies to all of
+// them: their own when they share it, 400 for several client errors, or else
+// 500. Objects without a status are taken as 422 Unprocessable Entity.
+func writeErrorObjects(w http.ResponseWriter, objects []*ErrorObject) error {
+	status := 0
+	for _, obj := range objects {
+		s, err := strconv.Atoi(obj.Status)
+		if err != nil {
+			s = http.StatusUnprocessableEntity
+		}
+
+		switch {
+		case status == 0 || status == s:
+			status = s
+		case status < 500 && s < 500:
+			status = http.StatusBadRequest
+		default:
+			status = http.StatusInternalServerError
+		}
+	}
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	return writeErrors(w, status, objects)
+}
+
+// WriteRateLimitError writes the error object of NewRateLimitError as a 429
+// errors document, with the matching Retry-After, X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset headers.
+func WriteRateLimitError(w http.ResponseWriter, limit, remaining int, reset time.Time) error {
+	obj := NewRateLimitError(limit, remaining, reset)
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter(reset)))
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+	return writeErrors(w, http.StatusTooManyRequests, []*ErrorObject{obj})
+}
+
+// statusErrorObject returns an error object for status, titled with its text.
+func statusErrorObject(status int, detail string) *ErrorObject {
+	return &ErrorObject{
+		Title:  http.StatusText(status),
+		Detail: detail,
+		Status: strconv.Itoa(status),
+	}
+}
+
+func writeErrors(w http.ResponseWriter, status int, objects []*ErrorObject) error {
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(status)
+	return MarshalErrors(w, objects)
+}
+
+// Recoverer returns a middleware recovering the panics of the handlers it
+// wraps, so that they are answered with a 500 errors document rather than a
+// blank response. Each panic is given an incident id, rendered in the meta of
+// the error object and passed, with the recovered value, to report when it is
+// not nil, so that it can be logged and matched with the response:
+//
+//	handler := jsonapi.Recoverer(func(r *http.Request, incident string, recovered interface{}) {
+//		log.Printf("incident %s: %v\n%s", incident, recovered, debug.Stack())
+//	})(mux)
+func Recoverer(report func(r *http.Request, incident string, recovered interface{})) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					// the server aborts the response without logging it
+					panic(recovered)
+				}
+
+				incident, _ := newUUID()
+				if report != nil {
+					report(r, incident, recovered)
+				}
+
+				obj := statusErrorObject(http.StatusInternalServerError, "")
+				obj.Meta = &map[string]interface{}{"incident": incident}
+				writeErrors(w, http.StatusInternalServerError, []*ErrorObject{obj})
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CheckRequestHeaders returns the error object the specification mandates
+// for a request whose headers don't negotiate the JSON API media type
+// properly, or nil:
+//
+//   - 415 Unsupported Media Type when its Content-Type is the media type with
+//     parameters;
+//   - 406 Not Acceptable when its Accept header lists the media type, but
+//     only with parameters.
+//
+// When the Version of the default Config is "1.1", the ext and profile
+// parameters are allowed, as long as ext lists only supported Extensions: a
+// Content-Type asking for others is answered with 415, and an Accept header
+// whose media types all ask for others with 406. It is meant to be called
+// before handling a request, e.g.
+//
+//	if obj := jsonapi.CheckRequestHeaders(r); obj != nil {
+//		jsonapi.WriteError(w, obj)
+//		return
+//	}
+func CheckRequestHeaders(r *http.Request) *ErrorObject {
+	config := DefaultConfig()
+	allowed := func(params map[string]string) bool {
+		for name := range params {
+			if config.Version != specVersionExtensions || (name != "ext" && name != "profile") {
+				return false
+			}
+		}
+		return true
+	}
+	supported := func(params map[string]string) bool {
+		_, unsupported := config.unsupportedExtension(params["ext"])
+		return !unsupported
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err == nil && mediaType == MediaType {
+			if !allowed(params) {
+				return statusErrorObject(http.StatusUnsupportedMediaType,
+					"The Content-Type header must not specify media type parameters")
+			}
+			if uri, unsupported := config.unsupportedExtension(params["ext"]); unsupported {
+				return statusErrorObject(http.StatusUnsupportedMediaType,
+					fmt.Sprintf("The extension %s is not supported", uri))
+			}
+		}
+	}
+
+	listed, acceptable := false, false
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil || mediaType != MediaType {
+				continue
+			}
+			// the weight is a parameter of the range, not of the media type
+			delete(params, "q")
+			listed = true
+			acceptable = acceptable || (allowed(params) && supported(params))
+		}
+	}
+	if listed && !acceptable {
+		return statusErrorObject(http.StatusNotAcceptable,
+			"The Accept header must list the media type without parameters or with supported extensions")
+	}
+
+	return nil
+}
+
+// ParseRequest checks the headers of r with CheckRequestHeaders, and returns
+// the Query of its query parameters, e.g.
+//
+//	func ListBlogs(w http.ResponseWriter, r *http.Request) {
+//		query, err := jsonapi.ParseRequest(r)
+//		if err != nil {
+//			jsonapi.WriteError(w, err)
+//			return
+//		}
+//		...
+//		jsonapi.WriteResponse(w, http.StatusOK, blogs, query)
+//	}
+//
+// The error is the *ErrorObject of CheckRequestHeaders, written by WriteError
+// as 415 Unsupported Media Type or 406 Not Acceptable.
+func ParseRequest(r *http.Request) (*Query, error) {
+	if obj := CheckRequestHeaders(r); obj != nil {
+		return nil, obj
+	}
+	return ParseQuery(r.URL.Query()), nil
+}
+
+// WriteResponse does the same as Write, sideloading the include paths and
+// rendering the sparse fieldsets of q, as WithInclude and WithFields do; the
+// include paths apply only when the request gives them. opts are applied
+// after them. q may be nil.
+func WriteResponse(w http.ResponseWriter, status int, models interface{}, q *Query,
+	opts ...MarshalOption) error {
+	var queryOpts []MarshalOption
+	if q != nil && q.Include != nil {
+		queryOpts = append(queryOpts, WithInclude(q.Include...))
+	}
+	if q != nil && len(q.Fields) > 0 {
+		queryOpts = append(queryOpts, WithFields(q.Fields))
+	}
+	return Write(w, status, models, append(queryOpts, opts...)...)
+}
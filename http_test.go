@@ -0,0 +1,295 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := Write(rec, http.StatusCreated, testBlog()); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := http.StatusCreated, rec.Code; e != a {
+		t.Fatalf("Was expecting status %d, got %d", e, a)
+	}
+	if e, a := MediaType, rec.Header().Get("Content-Type"); e != a {
+		t.Fatalf("Was expecting Content-Type %q, got %q", e, a)
+	}
+	one := new(OnePayload)
+	if err := json.NewDecoder(rec.Body).Decode(one); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "blogs", one.Data.Type; e != a {
+		t.Fatalf("Was expecting type %q, got %q", e, a)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := Write(rec, http.StatusOK, []*Blog{testBlog(), testBlog()}); err != nil {
+		t.Fatal(err)
+	}
+	many := new(ManyPayload)
+	if err := json.NewDecoder(rec.Body).Decode(many); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 2, len(many.Data); e != a {
+		t.Fatalf("Was expecting %d blogs, got %d", e, a)
+	}
+}
+
+func TestWriteNil(t *testing.T) {
+	for _, model := range []interface{}{nil, (*Blog)(nil)} {
+		rec := httptest.NewRecorder()
+		if err := Write(rec, http.StatusOK, model, WithMeta(&Meta{"total": 0})); err != nil {
+			t.Fatal(err)
+		}
+
+		if e, a := http.StatusOK, rec.Code; e != a {
+			t.Fatalf("Was expecting status %d, got %d", e, a)
+		}
+		if e, a := `{"data":null,"meta":{"total":0}}`, strings.TrimSpace(rec.Body.String()); e != a {
+			t.Fatalf("Was expecting %s, got %s", e, a)
+		}
+	}
+}
+
+func TestWriteMarshalError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := Write(rec, http.StatusOK, &Account{}); err != errAccountEmail {
+		t.Fatalf("Was expecting `%v`, got `%v`", errAccountEmail, err)
+	}
+
+	if e, a := http.StatusInternalServerError, rec.Code; e != a {
+		t.Fatalf("Was expecting status %d, got %d", e, a)
+	}
+	errs := new(ErrorsPayload)
+	if err := json.NewDecoder(rec.Body).Decode(errs); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "500", errs.Errors[0].Status; len(errs.Errors) != 1 || e != a {
+		t.Fatalf("Was expecting a single %s error, got %v", e, errs.Errors)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{"error object", &ErrorObject{Title: "Forbidden", Status: "403"}, 403, ""},
+		{"validation error", &ValidationError{Errors: []*ErrorObject{{Title: "Invalid"}, {Title: "Invalid"}}}, 422, ""},
+		{"mixed statuses", &ValidationError{Errors: []*ErrorObject{{Status: "409"}, {Status: "422"}}}, 400, ""},
+		{"package error", fmt.Errorf("binding: %w", ErrUnknownAttribute), 400, "binding: " + ErrUnknownAttribute.Error()},
+		{"payload too large", ErrPayloadTooLarge, 413, ErrPayloadTooLarge.Error()},
+		{"unsupported extension", ErrUnsupportedExtension, 415, ErrUnsupportedExtension.Error()},
+		{"syntax error", json.Unmarshal([]byte("{"), new(OnePayload)), 400, "unexpected end of JSON input"},
+		{"other error", errors.New("connection refused to db:5432"), 500, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if err := WriteError(rec, tc.err); err != nil {
+				t.Fatal(err)
+			}
+
+			if e, a := tc.status, rec.Code; e != a {
+				t.Fatalf("Was expecting status %d, got %d", e, a)
+			}
+			errs := new(ErrorsPayload)
+			if err := json.NewDecoder(rec.Body).Decode(errs); err != nil {
+				t.Fatal(err)
+			}
+			if e, a := tc.detail, errs.Errors[0].Detail; e != a {
+				t.Fatalf("Was expecting detail %q, got %q", e, a)
+			}
+		})
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	var reported string
+	handler := Recoverer(func(r *http.Request, incident string, recovered interface{}) {
+		reported = fmt.Sprintf("%s %v", incident, recovered)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blogs", nil))
+
+	if e, a := http.StatusInternalServerError, rec.Code; e != a {
+		t.Fatalf("Was expecting status %d, got %d", e, a)
+	}
+	if e, a := MediaType, rec.Header().Get("Content-Type"); e != a {
+		t.Fatalf("Was expecting Content-Type %q, got %q", e, a)
+	}
+	errs := new(ErrorsPayload)
+	if err := json.NewDecoder(rec.Body).Decode(errs); err != nil {
+		t.Fatal(err)
+	}
+	incident, _ := (*errs.Errors[0].Meta)["incident"].(string)
+	if incident == "" {
+		t.Fatal("Was expecting an incident id")
+	}
+	if e, a := incident+" boom", reported; e != a {
+		t.Fatalf("Was expecting the report %q, got %q", e, a)
+	}
+}
+
+func TestCheckRequestHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		version     string
+		extensions  []string
+		contentType string
+		accept      string
+		status      string
+	}{
+		{"plain", "", nil, MediaType, MediaType, ""},
+		{"no headers", "", nil, "", "", ""},
+		{"other media types", "", nil, "application/json; charset=utf-8", "text/html; level=1", ""},
+		{"content type parameter", "", nil, MediaType + "; charset=utf-8", MediaType, "415"},
+		{"accept parameters only", "", nil, MediaType, MediaType + "; charset=utf-8, text/html", "406"},
+		{"one plain accept", "", nil, MediaType, MediaType + "; charset=utf-8, " + MediaType + "; q=0.5", ""},
+		{"ext in 1.0", "", nil, MediaType + `; ext="https://jsonapi.org/ext/atomic"`, MediaType, "415"},
+		{"ext in 1.1", "1.1", []string{"https://jsonapi.org/ext/atomic"}, MediaType + `; ext="https://jsonapi.org/ext/atomic"`, MediaType + `; profile="https://example.com/cursor"`, ""},
+		{"other parameter in 1.1", "1.1", nil, MediaType, MediaType + "; version=2", "406"},
+		{"unsupported ext in 1.1", "1.1", nil, MediaType + `; ext="https://jsonapi.org/ext/atomic"`, MediaType, "415"},
+		{"unsupported accept ext in 1.1", "1.1", nil, MediaType, MediaType + `; ext="https://jsonapi.org/ext/atomic"`, "406"},
+		{"one supported accept in 1.1", "1.1", nil, MediaType, MediaType + `; ext="https://jsonapi.org/ext/atomic", ` + MediaType, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			SetDefaultConfig(Config{Version: tc.version, Extensions: tc.extensions})
+			defer SetDefaultConfig(Config{})
+
+			r := httptest.NewRequest(http.MethodPost, "/blogs", nil)
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+
+			obj := CheckRequestHeaders(r)
+			if tc.status == "" {
+				if obj != nil {
+					t.Fatalf("Was expecting no error, got %v", obj)
+				}
+				return
+			}
+			if obj == nil || obj.Status != tc.status {
+				t.Fatalf("Was expecting a %s error, got %v", tc.status, obj)
+			}
+		})
+	}
+}
+
+func TestParseRequestWriteResponse(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/blogs/5?include=current_post&fields[blogs]=title,current_post&fields[posts]=title", nil)
+	r.Header.Set("Accept", MediaType)
+
+	query, err := ParseRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := WriteResponse(w, http.StatusOK, testBlog(), query); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := MediaType, w.Header().Get("Content-Type"); e != a {
+		t.Fatalf("Was expecting the Content-Type %s, got %s", e, a)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := map[string]interface{}{"title": "Title 1"}, resp.Data.Attributes; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the attributes %v, got %v", e, a)
+	}
+	if len(resp.Included) != 1 || resp.Included[0].Type != "posts" || len(resp.Included[0].Attributes) != 1 {
+		t.Fatalf("Was expecting the title of the current post only to be included, got %v", resp.Included)
+	}
+
+	r.Header.Set("Accept", MediaType+"; charset=utf-8")
+	if _, err := ParseRequest(r); err == nil || err.(*ErrorObject).Status != "406" {
+		t.Fatalf("Was expecting a 406 error object, got %v", err)
+	}
+}
+
+func TestWriteLinkHeader(t *testing.T) {
+	paginate := WithPayloadHook(func(payload interface{}) error {
+		payload.(*ManyPayload).Links = &Links{
+			"self":  "https://example.com/blogs?page[number]=2",
+			"first": "https://example.com/blogs?page[number]=1",
+			"prev":  Link{Href: "https://example.com/blogs?page[number]=1"},
+			"next":  "https://example.com/blogs?page[number]=3",
+		}
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	if err := Write(rec, http.StatusOK, []*Blog{testBlog()}, paginate); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`<https://example.com/blogs?page[number]=1>; rel="first"`,
+		`<https://example.com/blogs?page[number]=1>; rel="prev"`,
+		`<https://example.com/blogs?page[number]=3>; rel="next"`,
+	}
+	if a := rec.Header().Values("Link"); !reflect.DeepEqual(expected, a) {
+		t.Fatalf("Was expecting Link headers %q, got %q", expected, a)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := Write(rec, http.StatusOK, testBlog()); err != nil {
+		t.Fatal(err)
+	}
+	if a := rec.Header().Values("Link"); len(a) != 0 {
+		t.Fatalf("Was expecting no Link header, got %q", a)
+	}
+}
+
+func TestWriteRateLimitError(t *testing.T) {
+	reset := time.Now().Add(30 * time.Second)
+
+	rec := httptest.NewRecorder()
+	if err := WriteRateLimitError(rec, 100, 0, reset); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := http.StatusTooManyRequests, rec.Code; e != a {
+		t.Fatalf("Was expecting status %d, got %d", e, a)
+	}
+	for header, e := range map[string]string{
+		"Retry-After":           "30",
+		"X-RateLimit-Limit":     "100",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     strconv.FormatInt(reset.Unix(), 10),
+	} {
+		if a := rec.Header().Get(header); e != a {
+			t.Fatalf("Was expecting %s %q, got %q", header, e, a)
+		}
+	}
+
+	errs := new(ErrorsPayload)
+	if err := json.NewDecoder(rec.Body).Decode(errs); err != nil {
+		t.Fatal(err)
+	}
+	meta := *errs.Errors[0].Meta
+	if meta["limit"] != float64(100) || meta["remaining"] != float64(0) || meta["retry_after"] != float64(30) {
+		t.Fatalf("Was expecting the rate limit in meta, got %v", meta)
+	}
+}
@@ -0,0 +1,121 @@
+package jsonapi
+
+import "fmt"
+
+// ConflictPolicy decides what an IncludedSet does when a Node is added with
+// the type and id of a Node it already holds.
+type ConflictPolicy int
+
+const (
+	// KeepFirst keeps the Node added first, ignoring the later ones.
+	KeepFirst ConflictPolicy = iota
+	// KeepLast replaces the Node held with the one added, at the same
+	// position.
+	KeepLast
+	// MergeMembers adds the attributes, relationships, links and meta of the
+	// Node added that the Node held doesn't have.
+	MergeMembers
+)
+
+// IncludedSet holds the Nodes sideloaded into the included array of a
+// payload, at most one per type and id, in the order they were added. A set
+// can be seeded before being passed to VisitModelNode, e.g. with records the
+// client must always get, and inspected afterwards.
+type IncludedSet struct {
+	// Conflict is applied when a Node with the type and id of one in the set
+	// is added; KeepFirst by default.
+	Conflict ConflictPolicy
+
+	nodes []*Node
+	index map[string]int
+}
+
+// NewIncludedSet returns an empty IncludedSet holding nodes.
+func NewIncludedSet(nodes ...*Node) *IncludedSet {
+	s := &IncludedSet{index: make(map[string]int)}
+	for _, n := range nodes {
+		s.Add(n)
+	}
+	return s
+}
+
+// Add adds n to the set, applying the conflict policy when the set already
+// holds a Node with its type and id. It reports whether n was new to the set.
+func (s *IncludedSet) Add(n *Node) bool {
+	if s.index == nil {
+		s.index = make(map[string]int)
+	}
+
+	k := includedKey(n.Type, n.ID)
+	i, ok := s.index[k]
+	if !ok {
+		s.index[k] = len(s.nodes)
+		s.nodes = append(s.nodes, n)
+		return true
+	}
+
+	switch s.Conflict {
+	case KeepLast:
+		s.nodes[i] = n
+	case MergeMembers:
+		mergeNodes(s.nodes[i], n)
+	}
+	return false
+}
+
+// Has reports whether the set holds a Node with type t and id.
+func (s *IncludedSet) Has(t, id string) bool {
+	_, ok := s.index[includedKey(t, id)]
+	return ok
+}
+
+// Get returns the Node with type t and id, or nil.
+func (s *IncludedSet) Get(t, id string) *Node {
+	i, ok := s.index[includedKey(t, id)]
+	if !ok {
+		return nil
+	}
+	return s.nodes[i]
+}
+
+// Len returns the number of Nodes in the set.
+func (s *IncludedSet) Len() int {
+	return len(s.nodes)
+}
+
+// Nodes returns the Nodes of the set, in the order they were added.
+func (s *IncludedSet) Nodes() []*Node {
+	nodes := make([]*Node, len(s.nodes))
+	copy(nodes, s.nodes)
+	return nodes
+}
+
+func includedKey(t, id string) string {
+	return fmt.Sprintf("%s,%s", t, id)
+}
+
+// mergeNodes adds the members of from that into doesn't have to into.
+func mergeNodes(into, from *Node) {
+	for k, v := range from.Attributes {
+		if into.Attributes == nil {
+			into.Attributes = make(map[string]interface{})
+		}
+		if _, ok := into.Attributes[k]; !ok {
+			into.Attributes[k] = v
+		}
+	}
+	for k, v := range from.Relationships {
+		if into.Relationships == nil {
+			into.Relationships = make(map[string]interface{})
+		}
+		if _, ok := into.Relationships[k]; !ok {
+			into.Relationships[k] = v
+		}
+	}
+	if into.Links == nil {
+		into.Links = from.Links
+	}
+	if into.Meta == nil {
+		into.Meta = from.Meta
+	}
+}
@@ -0,0 +1,65 @@
+package jsonapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIncludedSetConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		conflict ConflictPolicy
+		expected map[string]interface{}
+	}{
+		{"keep first", KeepFirst, map[string]interface{}{"name": "alice"}},
+		{"keep last", KeepLast, map[string]interface{}{"name": "bob", "age": 30}},
+		{"merge members", MergeMembers, map[string]interface{}{"name": "alice", "age": 30}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			first := &Node{Type: "people", ID: "1", Attributes: map[string]interface{}{"name": "alice"}}
+			second := &Node{Type: "people", ID: "1", Attributes: map[string]interface{}{"name": "bob", "age": 30}}
+			other := &Node{Type: "pets", ID: "1"}
+
+			s := &IncludedSet{Conflict: tc.conflict}
+			if !s.Add(first) || !s.Add(other) {
+				t.Fatal("Was expecting the first nodes to be new to the set")
+			}
+			if s.Add(second) {
+				t.Fatal("Was expecting the node to conflict")
+			}
+
+			if e, a := 2, s.Len(); e != a {
+				t.Fatalf("Was expecting %d nodes, got %d", e, a)
+			}
+			if !s.Has("pets", "1") || s.Has("pets", "2") {
+				t.Fatal("Was expecting the set to hold pets 1 only")
+			}
+			if a := s.Get("people", "1").Attributes; !reflect.DeepEqual(tc.expected, a) {
+				t.Fatalf("Was expecting attributes %v, got %v", tc.expected, a)
+			}
+			// the order of addition is kept
+			if nodes := s.Nodes(); nodes[0].Type != "people" || nodes[1] != other {
+				t.Fatalf("Was expecting the nodes in the order they were added, got %v", nodes)
+			}
+		})
+	}
+}
+
+func TestVisitModelNodeSeededIncludedSet(t *testing.T) {
+	seed := &Node{Type: "comments", ID: "1", Attributes: map[string]interface{}{"body": "seeded"}}
+	included := NewIncludedSet(seed)
+
+	if _, err := VisitModelNode(testBlog(), included, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if included.Get("comments", "1") != seed {
+		t.Fatal("Was expecting the seeded node to be kept")
+	}
+	if !included.Has("posts", "1") || !included.Has("posts", "2") {
+		t.Fatal("Was expecting the posts to be sideloaded")
+	}
+	if nodes := included.Nodes(); nodes[0] != seed {
+		t.Fatal("Was expecting the seeded node first")
+	}
+}
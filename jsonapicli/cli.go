@@ -0,0 +1,288 @@
+/*
+Package jsonapicli implements the jsonapi command, which validates, formats,
+flattens and diffs JSON:API documents from the terminal:
+
+	go install github.com/google/jsonapi/jsonapicli/cmd/jsonapi
+	curl -s https://api.example.com/blogs/1 | jsonapi validate
+	jsonapi fmt -canonical response.json
+	jsonapi flatten response.json
+	jsonapi diff expected.json actual.json
+
+The documents are read from the files given, or from the standard input for
+"-" or when no file is given. The command exits with 1 when a document is
+invalid or two documents differ, and with 2 on a usage error.
+
+The jsonapi command validates documents against the specification only. To
+also check them against the models of a service, build a command registering
+them with Main:
+
+	func main() {
+		jsonapicli.Main(new(Blog), new(Post), new(Comment))
+	}
+
+validate then binds each primary resource to the model of its type, reporting
+the errors of its tags as CheckModel does, the errors of unmarshaling it, and
+the members the model can't hold, as WithFidelity does.
+*/
+package jsonapicli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+const usage = `usage: jsonapi <command> [arguments]
+
+The commands are:
+
+	validate [file]          report the problems of a document
+	fmt [-canonical] [file]  indent a document, or print its canonical form
+	flatten [file]           print the resources of a document as plain JSON
+	diff <file> <file>       report the members that differ between two documents
+`
+
+// Main runs the jsonapi command with the arguments of the process, checking
+// documents against models, and exits with its status.
+func Main(models ...interface{}) {
+	os.Exit(Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, models...))
+}
+
+// Run runs the jsonapi command with args, its arguments without the name of
+// the program, reading the documents not given as files from stdin. It
+// returns the exit status of the command.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer, models ...interface{}) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	c := &command{stdin: stdin, stdout: stdout, stderr: stderr}
+	switch args[0] {
+	case "validate":
+		return c.validate(args[1:], models)
+	case "fmt":
+		return c.fmt(args[1:])
+	case "flatten":
+		return c.flatten(args[1:])
+	case "diff":
+		return c.diff(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "jsonapi: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// command holds the streams of a run of the jsonapi command.
+type command struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// flags parses the arguments of the subcommand name with fs, and returns the
+// remaining ones unless there are more than max.
+func (c *command) flags(fs *flag.FlagSet, args []string, max int) ([]string, bool) {
+	fs.SetOutput(c.stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, false
+	}
+	if fs.NArg() > max {
+		fmt.Fprintf(c.stderr, "jsonapi %s: too many arguments\n", fs.Name())
+		return nil, false
+	}
+	return fs.Args(), true
+}
+
+// read returns the content of the file name, or of the standard input for ""
+// or "-".
+func (c *command) read(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return ioutil.ReadAll(c.stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// decode reads the document of the file name as generic JSON values, numbers
+// kept as json.Number so that they are printed back unchanged.
+func (c *command) decode(name string) (interface{}, error) {
+	b, err := c.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", displayName(name), err)
+	}
+	return doc, nil
+}
+
+// fail reports err, and returns the exit status of a failed subcommand.
+func (c *command) fail(subcommand string, err error) int {
+	fmt.Fprintf(c.stderr, "jsonapi %s: %v\n", subcommand, err)
+	return 2
+}
+
+func (c *command) validate(args []string, models []interface{}) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	args, ok := c.flags(fs, args, 1)
+	if !ok {
+		return 2
+	}
+	name := firstArg(args)
+
+	doc, err := c.read(name)
+	if err != nil {
+		return c.fail("validate", err)
+	}
+
+	problems := Validate(doc)
+	if len(problems) == 0 && len(models) > 0 {
+		problems, err = validateModels(doc, models)
+		if err != nil {
+			return c.fail("validate", err)
+		}
+	}
+
+	for _, p := range problems {
+		fmt.Fprintf(c.stdout, "%s%s\n", displayName(name), p)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (c *command) fmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	canonical := fs.Bool("canonical", false, "print the canonical form: compact, members and included resources sorted")
+	args, ok := c.flags(fs, args, 1)
+	if !ok {
+		return 2
+	}
+
+	doc, err := c.decode(firstArg(args))
+	if err != nil {
+		return c.fail("fmt", err)
+	}
+
+	var out []byte
+	if *canonical {
+		out, err = json.Marshal(canonicalize(doc))
+	} else {
+		out, err = json.MarshalIndent(doc, "", "  ")
+	}
+	if err != nil {
+		return c.fail("fmt", err)
+	}
+	fmt.Fprintf(c.stdout, "%s\n", out)
+	return 0
+}
+
+func (c *command) flatten(args []string) int {
+	fs := flag.NewFlagSet("flatten", flag.ContinueOnError)
+	args, ok := c.flags(fs, args, 1)
+	if !ok {
+		return 2
+	}
+
+	doc, err := c.decode(firstArg(args))
+	if err != nil {
+		return c.fail("flatten", err)
+	}
+
+	out, err := json.MarshalIndent(Flatten(doc), "", "  ")
+	if err != nil {
+		return c.fail("flatten", err)
+	}
+	fmt.Fprintf(c.stdout, "%s\n", out)
+	return 0
+}
+
+func (c *command) diff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	args, ok := c.flags(fs, args, 2)
+	if !ok {
+		return 2
+	}
+	if len(args) != 2 {
+		fmt.Fprintln(c.stderr, "jsonapi diff: two documents are needed")
+		return 2
+	}
+
+	a, err := c.decode(args[0])
+	if err != nil {
+		return c.fail("diff", err)
+	}
+	b, err := c.decode(args[1])
+	if err != nil {
+		return c.fail("diff", err)
+	}
+
+	differences := Diff(a, b)
+	for _, d := range differences {
+		fmt.Fprintln(c.stdout, d)
+	}
+	if len(differences) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// canonicalize returns doc with its included resources sorted by type and
+// id; encoding/json sorts the members of objects.
+func canonicalize(doc interface{}) interface{} {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return doc
+	}
+	included, ok := obj["included"].([]interface{})
+	if !ok {
+		return doc
+	}
+
+	sort.SliceStable(included, func(i, j int) bool {
+		ti, ii := identity(included[i])
+		tj, ij := identity(included[j])
+		if ti != tj {
+			return ti < tj
+		}
+		return ii < ij
+	})
+	return doc
+}
+
+// identity returns the type and id of the resource object r, or empty strings.
+func identity(r interface{}) (string, string) {
+	obj, _ := r.(map[string]interface{})
+	t, _ := obj["type"].(string)
+	id, _ := obj["id"].(string)
+	return t, id
+}
+
+// firstArg returns the first of args, or "" for the standard input.
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// displayName returns the name of the file name in messages; the standard
+// input has none.
+func displayName(name string) string {
+	if name == "" || name == "-" {
+		return ""
+	}
+	return name + ":"
+}
@@ -0,0 +1,189 @@
+package jsonapicli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Article struct {
+	ID     string  `jsonapi:"primary,articles"`
+	Title  string  `jsonapi:"attr,title"`
+	Author *Author `jsonapi:"relation,author"`
+}
+
+type Author struct {
+	ID       string     `jsonapi:"primary,authors"`
+	Name     string     `jsonapi:"attr,name"`
+	Articles []*Article `jsonapi:"relation,articles"`
+}
+
+const article = `{
+	"data": {
+		"type": "articles",
+		"id": "1",
+		"attributes": {"title": "JSON:API"},
+		"relationships": {"author": {"data": {"type": "authors", "id": "9"}}}
+	},
+	"included": [{
+		"type": "authors",
+		"id": "9",
+		"attributes": {"name": "Dan"},
+		"relationships": {"articles": {"data": [{"type": "articles", "id": "1"}]}}
+	}]
+}`
+
+// run runs the jsonapi command with stdin, and returns its status and
+// outputs.
+func run(stdin string, args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	status := Run(args, strings.NewReader(stdin), stdout, stderr, new(Article), new(Author))
+	return status, stdout.String(), stderr.String()
+}
+
+func TestValidate(t *testing.T) {
+	if problems := Validate([]byte(article)); len(problems) != 0 {
+		t.Fatalf("Was expecting no problems, got %v", problems)
+	}
+
+	for _, test := range []struct {
+		doc      string
+		expected []string
+	}{
+		{`[]`, []string{": a document must be an object"}},
+		{`{"links": {}}`, []string{`: a document must have "data", "errors" or "meta"`}},
+		{`{"data": null, "errors": [], "extra": 1}`, []string{
+			`: a document can't have both "data" and "errors"`,
+			`: unknown member "extra"`,
+		}},
+		{`{"data": {"type": "articles", "id": 1, "attributes": {"type": "x"}}}`, []string{
+			`/data: "id" must be a string`,
+			`/data/attributes: "type" is reserved`,
+		}},
+		{`{"data": {"type": "articles", "id": "1", "relationships": {"author": {}, "a/b": {"data": {"type": "authors"}}}}}`, []string{
+			`/data/relationships/a~1b/data: a resource identifier must have "id" or "lid"`,
+			`/data/relationships/author: a relationship must have "data", "links" or "meta"`,
+		}},
+		{`{"data": [{"type": "articles", "id": "1"}, {"type": "articles", "id": "1"}], "included": [{"type": "authors", "id": "9"}]}`, []string{
+			"/data/1: duplicate resource articles 1, also at /data/0",
+			"/included/0: authors 9 is not linked to by any resource",
+		}},
+		{`{"errors": [{"status": 400}], "links": {"self": {"meta": {}}}}`, []string{
+			`/errors/0: "status" must be a string`,
+			`/links/self: a link object must have an "href" string`,
+		}},
+	} {
+		if problems := Validate([]byte(test.doc)); !reflect.DeepEqual(test.expected, problems) {
+			t.Fatalf("%s: was expecting %q, got %q", test.doc, test.expected, problems)
+		}
+	}
+}
+
+func TestRunValidateModels(t *testing.T) {
+	if status, stdout, _ := run(article, "validate"); status != 0 || stdout != "" {
+		t.Fatalf("Was expecting no problems, got %d: %s", status, stdout)
+	}
+
+	doc := strings.Replace(article, `"name": "Dan"`, `"name": "Dan", "age": 40`, 1)
+	doc = strings.Replace(doc, `"title": "JSON:API"`, `"title": 1`, 1)
+	status, stdout, _ := run(doc, "validate")
+	if status != 1 || !strings.Contains(stdout, "/data: ") {
+		t.Fatalf("Was expecting the invalid title to be reported, got %d: %s", status, stdout)
+	}
+
+	doc = strings.Replace(article, `"name": "Dan"`, `"name": "Dan", "age": 40`, 1)
+	status, stdout, _ = run(doc, "validate")
+	if expected := "/included/0/attributes/age: not held by the model of authors\n"; status != 1 || stdout != expected {
+		t.Fatalf("Was expecting %q, got %d: %q", expected, status, stdout)
+	}
+
+	doc = strings.Replace(article, `"articles",
+		"id": "1"`, `"comments",
+		"id": "1"`, 1)
+	status, stdout, _ = run(doc, "validate")
+	if expected := "/data: no model for the type \"comments\"\n"; status != 1 || stdout != expected {
+		t.Fatalf("Was expecting %q, got %d: %q", expected, status, stdout)
+	}
+}
+
+func TestRunFmt(t *testing.T) {
+	doc := `{"included": [{"type": "b", "id": "2"}, {"type": "a", "id": "3"}, {"type": "a", "id": "1"}],
+		"data": {"type": "c", "id": "1", "attributes": {"z": 1.50, "a": null}}}`
+
+	status, stdout, stderr := run(doc, "fmt", "-canonical")
+	expected := `{"data":{"attributes":{"a":null,"z":1.50},"id":"1","type":"c"},` +
+		`"included":[{"id":"1","type":"a"},{"id":"3","type":"a"},{"id":"2","type":"b"}]}` + "\n"
+	if status != 0 || stdout != expected {
+		t.Fatalf("Was expecting %s, got %d: %s%s", expected, status, stdout, stderr)
+	}
+
+	status, stdout, _ = run(`{"meta": {"a": [1]}}`, "fmt")
+	if expected := "{\n  \"meta\": {\n    \"a\": [\n      1\n    ]\n  }\n}\n"; status != 0 || stdout != expected {
+		t.Fatalf("Was expecting %q, got %d: %q", expected, status, stdout)
+	}
+}
+
+func TestRunFlatten(t *testing.T) {
+	status, stdout, stderr := run(article, "flatten")
+	expected := `{
+  "author": {
+    "articles": [
+      {
+        "id": "1",
+        "type": "articles"
+      }
+    ],
+    "id": "9",
+    "name": "Dan",
+    "type": "authors"
+  },
+  "id": "1",
+  "title": "JSON:API",
+  "type": "articles"
+}
+`
+	if status != 0 || stdout != expected {
+		t.Fatalf("Was expecting %s, got %d: %s%s", expected, status, stdout, stderr)
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonapicli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")
+	ioutil.WriteFile(a, []byte(`{"data": {"type": "a", "id": "1", "attributes": {"x/y": 1, "n": [1, 2]}}}`), 0644)
+	ioutil.WriteFile(b, []byte(`{"data": {"type": "a", "id": "2", "attributes": {"z": "1", "n": [1]}}}`), 0644)
+
+	status, stdout, stderr := run("", "diff", a, b)
+	expected := `- /data/attributes/n/1
+- /data/attributes/x~1y
++ /data/attributes/z
+~ /data/id: "1" -> "2"
+`
+	if status != 1 || stdout != expected {
+		t.Fatalf("Was expecting %s, got %d: %s%s", expected, status, stdout, stderr)
+	}
+
+	if status, stdout, _ := run(`{"meta": {}}`, "diff", a, a); status != 0 || stdout != "" {
+		t.Fatalf("Was expecting no differences, got %d: %s", status, stdout)
+	}
+	if status, _, _ := run("", "diff", a); status != 2 {
+		t.Fatalf("Was expecting a usage error, got %d", status)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"convert"}, {"fmt", "-indent"}} {
+		if status, _, stderr := run("", args...); status != 2 || stderr == "" {
+			t.Fatalf("%v: was expecting a usage error, got %d", args, status)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Command jsonapi validates, formats, flattens and diffs JSON:API documents:
+//
+//	jsonapi validate response.json
+//	jsonapi fmt -canonical response.json
+//	jsonapi flatten response.json
+//	jsonapi diff expected.json actual.json
+package main
+
+import "github.com/google/jsonapi/jsonapicli"
+
+func main() {
+	jsonapicli.Main()
+}
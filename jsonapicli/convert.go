@@ -0,0 +1,210 @@
+package jsonapicli
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flatten returns the primary data of doc, a document decoded as generic JSON
+// values, as plain JSON: each resource becomes an object holding its id, its
+// type, its attributes and its relationships, to-one relationships as an
+// object and to-many ones as an array. Related resources are resolved against
+// the primary data and the included resources, recursively; those missing
+// from the document, and those already being flattened higher up, are left
+// as their id and type. Links and meta are dropped.
+func Flatten(doc interface{}) interface{} {
+	obj, _ := doc.(map[string]interface{})
+
+	resources := make(map[string]map[string]interface{})
+	index := func(r interface{}) {
+		if resource, ok := r.(map[string]interface{}); ok {
+			t, id := identity(resource)
+			resources[t+","+id] = resource
+		}
+	}
+	forEachResource(obj["data"], index)
+	forEachResource(obj["included"], index)
+
+	f := &flattener{resources: resources, visiting: make(map[string]bool)}
+	switch data := obj["data"].(type) {
+	case []interface{}:
+		flat := make([]interface{}, len(data))
+		for i, r := range data {
+			flat[i] = f.resource(r)
+		}
+		return flat
+	case nil:
+		return nil
+	default:
+		return f.resource(data)
+	}
+}
+
+// forEachResource calls f with the resource object data, or each of them.
+func forEachResource(data interface{}, f func(interface{})) {
+	if resources, ok := data.([]interface{}); ok {
+		for _, r := range resources {
+			f(r)
+		}
+		return
+	}
+	if data != nil {
+		f(data)
+	}
+}
+
+// flattener holds the resources of a document being flattened.
+type flattener struct {
+	resources map[string]map[string]interface{}
+
+	// visiting holds the resources being flattened, so that cycles stop.
+	visiting map[string]bool
+}
+
+// resource returns the flattened resource object r.
+func (f *flattener) resource(r interface{}) interface{} {
+	obj, ok := r.(map[string]interface{})
+	if !ok {
+		return r
+	}
+
+	t, id := identity(obj)
+	key := t + "," + id
+	flat := map[string]interface{}{"type": t}
+	if id != "" {
+		flat["id"] = id
+	}
+	if f.visiting[key] {
+		return flat
+	}
+	f.visiting[key] = true
+	defer delete(f.visiting, key)
+
+	if attrs, ok := obj["attributes"].(map[string]interface{}); ok {
+		for name, value := range attrs {
+			flat[name] = value
+		}
+	}
+	if rels, ok := obj["relationships"].(map[string]interface{}); ok {
+		for name, rel := range rels {
+			rel, _ := rel.(map[string]interface{})
+			data, ok := rel["data"]
+			if !ok {
+				continue
+			}
+			flat[name] = f.linkage(data)
+		}
+	}
+	return flat
+}
+
+// linkage returns the resources that the resource linkage data points to,
+// flattened.
+func (f *flattener) linkage(data interface{}) interface{} {
+	switch data := data.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		flat := make([]interface{}, len(data))
+		for i, identifier := range data {
+			flat[i] = f.linkage(identifier)
+		}
+		return flat
+	}
+
+	t, id := identity(data)
+	if r, ok := f.resources[t+","+id]; ok {
+		return f.resource(r)
+	}
+	return map[string]interface{}{"type": t, "id": id}
+}
+
+// Diff returns the differences between a and b, two documents decoded as
+// generic JSON values, one per member, sorted by JSON Pointer: "- /data/id"
+// for a member of a only, "+ /data/id" for a member of b only, and
+// `~ /data/id: "1" -> "2"` for a member of both with different values.
+// Elements of arrays are compared by position.
+func Diff(a, b interface{}) []string {
+	var differences []string
+	diff(a, b, "", &differences)
+	return differences
+}
+
+func diff(a, b interface{}, pointer string, differences *[]string) {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		bObj, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		names := sortedKeys(a)
+		for name := range bObj {
+			if _, ok := a[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			member := pointer + "/" + escapePointer(name)
+			av, inA := a[name]
+			bv, inB := bObj[name]
+			switch {
+			case !inB:
+				*differences = append(*differences, "- "+member)
+			case !inA:
+				*differences = append(*differences, "+ "+member)
+			default:
+				diff(av, bv, member, differences)
+			}
+		}
+		return
+	case []interface{}:
+		bSlice, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(a) || i < len(bSlice); i++ {
+			element := pointer + "/" + strconv.Itoa(i)
+			switch {
+			case i >= len(bSlice):
+				*differences = append(*differences, "- "+element)
+			case i >= len(a):
+				*differences = append(*differences, "+ "+element)
+			default:
+				diff(a[i], bSlice[i], element, differences)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*differences = append(*differences, fmt.Sprintf("~ %s: %s -> %s", pointer, value(a), value(b)))
+	}
+}
+
+// value returns v, a generic JSON value, as a short string.
+func value(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case map[string]interface{}:
+		return "{...}"
+	case []interface{}:
+		return "[...]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapePointer escapes name as a reference token of a JSON Pointer.
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
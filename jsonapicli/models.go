@@ -0,0 +1,138 @@
+package jsonapicli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/google/jsonapi"
+)
+
+// modelTypes returns the struct types of models by the resource type of their
+// primary field.
+func modelTypes(models []interface{}) (map[string]reflect.Type, error) {
+	types := make(map[string]reflect.Type)
+	for _, model := range models {
+		t := reflect.TypeOf(model)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("%T is not a model", model)
+		}
+
+		resourceType := primaryType(t)
+		if resourceType == "" {
+			return nil, fmt.Errorf("%v has no primary annotated field", t)
+		}
+		types[resourceType] = t
+	}
+	return types, nil
+}
+
+// primaryType returns the resource type of the primary field of the struct
+// type t, or "" if it has none.
+func primaryType(t reflect.Type) string {
+	for i := 0; i < t.NumField(); i++ {
+		value, ok := t.Field(i).Tag.Lookup("jsonapi")
+		if !ok {
+			continue
+		}
+		if tag, err := jsonapi.ParseTag(value); err == nil && tag.Annotation == "primary" {
+			return tag.Name
+		}
+	}
+	return ""
+}
+
+// validateModels binds each primary resource of doc, a valid document, to the
+// model of its type, and returns the problems of the models and the members
+// of the resources they can't hold. Resources of types without a model are
+// reported.
+func validateModels(doc []byte, models []interface{}) ([]string, error) {
+	types, err := modelTypes(models)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Data     json.RawMessage `json:"data"`
+		Included []*jsonapi.Node `json:"included"`
+	}
+	if err := json.Unmarshal(doc, &payload); err != nil {
+		return nil, err
+	}
+
+	var primary []*jsonapi.Node
+	var pointers []string
+	if len(payload.Data) > 0 && payload.Data[0] == '[' {
+		if err := json.Unmarshal(payload.Data, &primary); err != nil {
+			return nil, err
+		}
+		for i := range primary {
+			pointers = append(pointers, fmt.Sprintf("/data/%d", i))
+		}
+	} else {
+		var node *jsonapi.Node
+		if err := json.Unmarshal(payload.Data, &node); err != nil {
+			return nil, err
+		}
+		if node != nil {
+			primary, pointers = []*jsonapi.Node{node}, []string{"/data"}
+		}
+	}
+
+	var problems []string
+	checked := make(map[reflect.Type]bool)
+	for i, node := range primary {
+		t, ok := types[node.Type]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: no model for the type %q", pointers[i], node.Type))
+			continue
+		}
+
+		model := reflect.New(t).Interface()
+		if !checked[t] {
+			checked[t] = true
+			if err := jsonapi.CheckModel(model); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", pointers[i], err))
+				continue
+			}
+		}
+
+		err := jsonapi.UnmarshalOne(&jsonapi.OnePayload{Data: node, Included: payload.Included},
+			model, jsonapi.WithFidelity())
+		var lossErr *jsonapi.LossError
+		switch {
+		case errors.As(err, &lossErr):
+			for _, loss := range lossErr.Losses {
+				// Included resources bound by the other primary resources
+				// are not lost.
+				if loss.Pointer == "" {
+					continue
+				}
+				problems = append(problems, fmt.Sprintf("%s%s: not held by the model of %s",
+					resourcePointer(loss, node, pointers[i], payload.Included), loss.Pointer,
+					loss.ResourceType))
+			}
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %v", pointers[i], err))
+		}
+	}
+	return problems, nil
+}
+
+// resourcePointer returns the JSON Pointer of the resource object of loss:
+// primary, found at pointer, or one of the included resources.
+func resourcePointer(loss jsonapi.Loss, primary *jsonapi.Node, pointer string, included []*jsonapi.Node) string {
+	if loss.ResourceType == primary.Type && loss.ResourceID == primary.ID {
+		return pointer
+	}
+	for i, n := range included {
+		if loss.ResourceType == n.Type && loss.ResourceID == n.ID {
+			return fmt.Sprintf("/included/%d", i)
+		}
+	}
+	return pointer
+}
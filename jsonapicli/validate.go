@@ -0,0 +1,299 @@
+package jsonapicli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// topLevelMembers are the members a document may have, besides the members
+// of extensions, whose names hold a colon.
+var topLevelMembers = map[string]bool{
+	"data": true, "errors": true, "meta": true, "jsonapi": true, "links": true, "included": true,
+}
+
+// validator collects the problems of a document.
+type validator struct {
+	problems []string
+
+	// resources holds the type/id of the resources of the document, and
+	// linked those that are the target of resource linkage.
+	resources map[string]string
+	linked    map[string]bool
+}
+
+// Validate returns the problems of doc, a JSON document, against the JSON:API
+// specification, each prefixed with the JSON Pointer of the member at fault,
+// e.g. `/data/attributes: "id" is reserved`. It returns no problems for a
+// valid document.
+func Validate(doc []byte) []string {
+	var top interface{}
+	if err := json.Unmarshal(doc, &top); err != nil {
+		return []string{fmt.Sprintf(": invalid JSON: %v", err)}
+	}
+
+	v := &validator{resources: make(map[string]string), linked: make(map[string]bool)}
+	v.document(top)
+	return v.problems
+}
+
+func (v *validator) report(pointer, format string, args ...interface{}) {
+	v.problems = append(v.problems, pointer+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) document(top interface{}) {
+	doc, ok := top.(map[string]interface{})
+	if !ok {
+		v.report("", "a document must be an object")
+		return
+	}
+
+	_, hasData := doc["data"]
+	_, hasErrors := doc["errors"]
+	_, hasMeta := doc["meta"]
+	switch {
+	case !hasData && !hasErrors && !hasMeta:
+		v.report("", `a document must have "data", "errors" or "meta"`)
+	case hasData && hasErrors:
+		v.report("", `a document can't have both "data" and "errors"`)
+	}
+	if _, ok := doc["included"]; ok && !hasData {
+		v.report("", `a document can't have "included" without "data"`)
+	}
+
+	for _, name := range sortedKeys(doc) {
+		if !topLevelMembers[name] && !strings.Contains(name, ":") {
+			v.report("", "unknown member %q", name)
+		}
+	}
+
+	if data, ok := doc["data"]; ok {
+		switch data := data.(type) {
+		case nil:
+		case []interface{}:
+			for i, r := range data {
+				v.resource(fmt.Sprintf("/data/%d", i), r)
+			}
+		default:
+			v.resource("/data", data)
+		}
+	}
+	if included, ok := doc["included"]; ok {
+		resources, ok := included.([]interface{})
+		if !ok {
+			v.report("/included", "must be an array")
+		}
+		for i, r := range resources {
+			v.resource(fmt.Sprintf("/included/%d", i), r)
+		}
+		v.fullLinkage(resources)
+	}
+	if errs, ok := doc["errors"]; ok {
+		v.errors(errs)
+	}
+	if meta, ok := doc["meta"]; ok {
+		v.object("/meta", meta)
+	}
+	if links, ok := doc["links"]; ok {
+		v.links("/links", links)
+	}
+	if obj, ok := doc["jsonapi"]; ok {
+		v.object("/jsonapi", obj)
+	}
+}
+
+// resource validates the resource object r found at pointer.
+func (v *validator) resource(pointer string, r interface{}) {
+	obj, ok := r.(map[string]interface{})
+	if !ok {
+		v.report(pointer, "a resource object must be an object")
+		return
+	}
+
+	t := v.identity(pointer, obj)
+	if id, ok := obj["id"].(string); ok && t != "" {
+		key := t + "," + id
+		if other, ok := v.resources[key]; ok {
+			v.report(pointer, "duplicate resource %s %s, also at %s", t, id, other)
+		} else {
+			v.resources[key] = pointer
+		}
+	}
+
+	fields := make(map[string]bool)
+	if attributes, ok := obj["attributes"]; ok {
+		if attrs, ok := v.object(pointer+"/attributes", attributes); ok {
+			for _, name := range sortedKeys(attrs) {
+				v.fieldName(pointer+"/attributes", name)
+				fields[name] = true
+			}
+		}
+	}
+	if relationships, ok := obj["relationships"]; ok {
+		if rels, ok := v.object(pointer+"/relationships", relationships); ok {
+			for _, name := range sortedKeys(rels) {
+				v.fieldName(pointer+"/relationships", name)
+				if fields[name] {
+					v.report(pointer, "%q is both an attribute and a relationship", name)
+				}
+				v.relationship(pointer+"/relationships/"+escapePointer(name), rels[name])
+			}
+		}
+	}
+	if links, ok := obj["links"]; ok {
+		v.links(pointer+"/links", links)
+	}
+	if meta, ok := obj["meta"]; ok {
+		v.object(pointer+"/meta", meta)
+	}
+}
+
+// identity validates the type and id, or lid, of obj, a resource object or a
+// resource identifier, and returns its type.
+func (v *validator) identity(pointer string, obj map[string]interface{}) string {
+	t, ok := obj["type"].(string)
+	if !ok || t == "" {
+		v.report(pointer, `"type" must be a non-empty string`)
+	}
+	if id, ok := obj["id"]; ok {
+		if _, ok := id.(string); !ok {
+			v.report(pointer, `"id" must be a string`)
+		}
+	}
+	return t
+}
+
+// fieldName validates name, an attribute or relationship name.
+func (v *validator) fieldName(pointer, name string) {
+	if name == "id" || name == "type" {
+		v.report(pointer, "%q is reserved", name)
+	}
+}
+
+// relationship validates the relationship object rel found at pointer.
+func (v *validator) relationship(pointer string, rel interface{}) {
+	obj, ok := v.object(pointer, rel)
+	if !ok {
+		return
+	}
+
+	data, hasData := obj["data"]
+	_, hasLinks := obj["links"]
+	_, hasMeta := obj["meta"]
+	if !hasData && !hasLinks && !hasMeta {
+		v.report(pointer, `a relationship must have "data", "links" or "meta"`)
+	}
+
+	switch data := data.(type) {
+	case nil:
+	case []interface{}:
+		for i, identifier := range data {
+			v.identifier(fmt.Sprintf("%s/data/%d", pointer, i), identifier)
+		}
+	default:
+		v.identifier(pointer+"/data", data)
+	}
+	if links, ok := obj["links"]; ok {
+		v.links(pointer+"/links", links)
+	}
+}
+
+// identifier validates the resource identifier found at pointer.
+func (v *validator) identifier(pointer string, identifier interface{}) {
+	obj, ok := identifier.(map[string]interface{})
+	if !ok {
+		v.report(pointer, "a resource identifier must be an object")
+		return
+	}
+
+	t := v.identity(pointer, obj)
+	_, hasID := obj["id"]
+	_, hasLocalID := obj["lid"]
+	if !hasID && !hasLocalID {
+		v.report(pointer, `a resource identifier must have "id" or "lid"`)
+	}
+	if id, ok := obj["id"].(string); ok {
+		v.linked[t+","+id] = true
+	}
+}
+
+// links validates the links object found at pointer.
+func (v *validator) links(pointer string, links interface{}) {
+	obj, ok := v.object(pointer, links)
+	if !ok {
+		return
+	}
+
+	for _, name := range sortedKeys(obj) {
+		switch link := obj[name].(type) {
+		case nil, string:
+		case map[string]interface{}:
+			if _, ok := link["href"].(string); !ok {
+				v.report(pointer+"/"+escapePointer(name), `a link object must have an "href" string`)
+			}
+		default:
+			v.report(pointer+"/"+escapePointer(name), "a link must be a string, an object or null")
+		}
+	}
+}
+
+// errors validates the "errors" member of a document.
+func (v *validator) errors(errs interface{}) {
+	objects, ok := errs.([]interface{})
+	if !ok {
+		v.report("/errors", "must be an array")
+		return
+	}
+
+	for i, e := range objects {
+		pointer := fmt.Sprintf("/errors/%d", i)
+		obj, ok := v.object(pointer, e)
+		if !ok {
+			continue
+		}
+		if status, ok := obj["status"]; ok {
+			if _, ok := status.(string); !ok {
+				v.report(pointer, `"status" must be a string`)
+			}
+		}
+		if links, ok := obj["links"]; ok {
+			v.links(pointer+"/links", links)
+		}
+	}
+}
+
+// fullLinkage reports the included resources that no resource linkage of the
+// document points to.
+func (v *validator) fullLinkage(included []interface{}) {
+	for i, r := range included {
+		obj, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t, _ := obj["type"].(string)
+		id, _ := obj["id"].(string)
+		if !v.linked[t+","+id] {
+			v.report(fmt.Sprintf("/included/%d", i), "%s %s is not linked to by any resource", t, id)
+		}
+	}
+}
+
+// object reports value, found at pointer, if it is not an object.
+func (v *validator) object(pointer string, value interface{}) (map[string]interface{}, bool) {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		v.report(pointer, "must be an object")
+	}
+	return obj, ok
+}
+
+// sortedKeys returns the names of the members of obj, sorted.
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
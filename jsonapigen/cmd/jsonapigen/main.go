@@ -0,0 +1,57 @@
+// Command jsonapigen generates the methods marshaling and unmarshaling the
+// models of a package without reflection:
+//
+//	jsonapigen -type Post,Comment -output models_jsonapi.go .
+//
+// Without -type, the methods of every supported model of the package are
+// generated; without -output, the source is written to the standard output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/jsonapi/jsonapigen"
+)
+
+func main() {
+	types := flag.String("type", "", "comma separated names of the models")
+	output := flag.String("output", "", "file to write, instead of the standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: jsonapigen [-type T,...] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		dir = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var names []string
+	if *types != "" {
+		names = strings.Split(*types, ",")
+	}
+
+	src, err := jsonapigen.Generate(dir, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
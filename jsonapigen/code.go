@@ -0,0 +1,362 @@
+package jsonapigen
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// generator writes the source of the generated file.
+type generator struct {
+	buf bytes.Buffer
+
+	// the imports and helpers used by the methods written
+	strconv, time, number bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate returns the unformatted source of the methods of models, in the
+// package pkg.
+func generate(pkg string, models []*model) ([]byte, error) {
+	g := new(generator)
+	for _, m := range models {
+		g.marshal(m)
+		g.related(m)
+		g.unmarshal(m)
+	}
+	if g.number {
+		g.numberHelper()
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "%s\n\npackage %s\n\nimport (\n", generatedHeader, pkg)
+	if g.strconv {
+		fmt.Fprintf(&src, "%q\n", "strconv")
+	}
+	if g.time {
+		fmt.Fprintf(&src, "%q\n", "time")
+	}
+	fmt.Fprintf(&src, "\n%q\n)\n", "github.com/google/jsonapi")
+	src.Write(g.buf.Bytes())
+	return src.Bytes(), nil
+}
+
+// marshal writes the MarshalJSONAPINode method of m.
+func (g *generator) marshal(m *model) {
+	g.printf("\n// MarshalJSONAPINode implements jsonapi.NodeMarshaler.\n")
+	g.printf("func (m *%s) MarshalJSONAPINode() (*jsonapi.Node, error) {\n", m.name)
+	g.printf("node := &jsonapi.Node{Type: %q, ID: %s}\n", m.rtype, g.formatID("m."+m.id, m.idType))
+	if m.clientID != "" {
+		g.printf("node.ClientID = m.%s\n", m.clientID)
+	}
+	if m.localID != "" {
+		g.printf("node.LocalID = m.%s\n", m.localID)
+	}
+
+	if len(m.attrs) > 0 {
+		g.printf("\nnode.Attributes = make(map[string]interface{}, %d)\n", len(m.attrs))
+		for _, attr := range m.attrs {
+			g.marshalAttribute(attr)
+		}
+		g.printf("if len(node.Attributes) == 0 {\nnode.Attributes = nil\n}\n")
+	}
+
+	if len(m.rels) > 0 {
+		g.printf("\nnode.Relationships = make(map[string]interface{}, %d)\n", len(m.rels))
+		for _, rel := range m.rels {
+			g.marshalRelation(m, rel)
+		}
+		g.printf("if len(node.Relationships) == 0 {\nnode.Relationships = nil\n}\n")
+	}
+
+	if m.methods["JSONAPILinks"] {
+		g.printf("node.Links = m.JSONAPILinks()\n")
+	}
+	if m.methods["JSONAPIMeta"] {
+		g.printf("node.Meta = m.JSONAPIMeta()\n")
+	}
+	g.printf("return node, nil\n}\n")
+}
+
+// marshalAttribute writes the statements rendering attr, as the jsonapi
+// package does: zero times and the empty values of omitempty attributes are
+// left out, and nil pointers rendered as null.
+func (g *generator) marshalAttribute(attr attribute) {
+	value := "m." + attr.field
+	set := fmt.Sprintf("node.Attributes[%q] = ", attr.name)
+	if attr.layout == "rfc3339" {
+		g.time = true
+	}
+
+	switch {
+	case attr.typ == "time.Time" && !attr.ptr:
+		g.printf("if !%s.IsZero() {\n%s%s\n}\n", value, set, timeValue(value, attr.layout))
+	case attr.typ == "time.Time":
+		if attr.omitEmpty {
+			g.printf("if %s != nil && !%s.IsZero() {\n", value, value)
+		} else {
+			g.printf("if %s == nil {\n%snil\n} else {\n", value, set)
+		}
+		g.printf("%s%s\n}\n", set, timeValue(value, attr.layout))
+	case attr.ptr:
+		if attr.omitEmpty {
+			g.printf("if %s != nil {\n%s*%s\n}\n", value, set, value)
+		} else {
+			g.printf("if %s == nil {\n%snil\n} else {\n%s*%s\n}\n", value, set, set, value)
+		}
+	case attr.omitEmpty:
+		g.printf("if %s {\n%s%s\n}\n", notEmpty(value, attr), set, value)
+	default:
+		g.printf("%s%s\n", set, value)
+	}
+}
+
+// timeValue returns the expression rendering the time value, or the time it
+// points to, with the time option layout.
+func timeValue(value, layout string) string {
+	switch layout {
+	case "iso8601":
+		return value + `.UTC().Format("2006-01-02T15:04:05Z")`
+	case "rfc3339":
+		return value + ".Format(time.RFC3339)"
+	}
+	return value + ".Unix()"
+}
+
+// notEmpty returns the condition of value not being empty, as omitempty
+// understands it.
+func notEmpty(value string, attr attribute) string {
+	switch {
+	case attr.slice:
+		return "len(" + value + ") > 0"
+	case attr.typ == "string":
+		return value + ` != ""`
+	case attr.typ == "bool":
+		return value
+	}
+	return value + " != 0"
+}
+
+// marshalRelation writes the statements rendering the linkage of rel, with
+// the relationship links and meta of m.
+func (g *generator) marshalRelation(m *model, rel relation) {
+	value := "m." + rel.field
+	var extra string
+	if m.methods["JSONAPIRelationshipLinks"] {
+		extra += fmt.Sprintf(", Links: m.JSONAPIRelationshipLinks(%q)", rel.name)
+	}
+	if m.methods["JSONAPIRelationshipMeta"] {
+		extra += fmt.Sprintf(", Meta: m.JSONAPIRelationshipMeta(%q)", rel.name)
+	}
+	identifier := fmt.Sprintf("&jsonapi.Node{Type: %q, ID: %s}", rel.related.rtype,
+		g.formatID("related."+rel.related.id, rel.related.idType))
+
+	if rel.many {
+		if rel.omitEmpty {
+			g.printf("if len(%s) > 0 {\n", value)
+		} else {
+			g.printf("{\n")
+		}
+		g.printf("data := make([]*jsonapi.Node, 0, len(%s))\n", value)
+		g.printf("for _, related := range %s {\nif related != nil {\n", value)
+		g.printf("data = append(data, %s)\n}\n}\n", identifier)
+		g.printf("node.Relationships[%q] = &jsonapi.RelationshipManyNode{Data: data%s}\n}\n", rel.name, extra)
+		return
+	}
+
+	if rel.omitEmpty {
+		g.printf("if related := %s; related != nil {\n", value)
+		g.printf("node.Relationships[%q] = &jsonapi.RelationshipOneNode{Data: %s%s}\n}\n", rel.name, identifier, extra)
+		return
+	}
+	g.printf("if related := %s; related != nil {\n", value)
+	g.printf("node.Relationships[%q] = &jsonapi.RelationshipOneNode{Data: %s%s}\n", rel.name, identifier, extra)
+	g.printf("} else {\nnode.Relationships[%q] = &jsonapi.RelationshipOneNode{%s}\n}\n", rel.name,
+		strings.TrimPrefix(extra, ", "))
+}
+
+// formatID returns the expression rendering the primary field value of type
+// typ as an id.
+func (g *generator) formatID(value, typ string) string {
+	switch {
+	case typ == "string":
+		return value
+	case strings.HasPrefix(typ, "uint"):
+		g.strconv = true
+		return "strconv.FormatUint(uint64(" + value + "), 10)"
+	}
+	g.strconv = true
+	return "strconv.FormatInt(int64(" + value + "), 10)"
+}
+
+// related writes the JSONAPIRelated method of m.
+func (g *generator) related(m *model) {
+	g.printf("\n// JSONAPIRelated implements jsonapi.RelatedMarshaler.\n")
+	g.printf("func (m *%s) JSONAPIRelated(relation string) interface{} {\n", m.name)
+	if len(m.rels) > 0 {
+		g.printf("switch relation {\n")
+		for _, rel := range m.rels {
+			g.printf("case %q:\nreturn m.%s\n", rel.name, rel.field)
+		}
+		g.printf("}\n")
+	}
+	g.printf("return nil\n}\n")
+}
+
+// unmarshal writes the UnmarshalJSONAPINode method of m.
+func (g *generator) unmarshal(m *model) {
+	g.printf("\n// UnmarshalJSONAPINode implements jsonapi.NodeUnmarshaler.\n")
+	g.printf("func (m *%s) UnmarshalJSONAPINode(node *jsonapi.Node) error {\n", m.name)
+	g.printf("if node.ID != \"\" {\n")
+	g.parseID("m."+m.id, "node.ID", m.idType)
+	g.printf("}\n")
+	if m.clientID != "" {
+		g.printf("m.%s = node.ClientID\n", m.clientID)
+	}
+	if m.localID != "" {
+		g.printf("m.%s = node.LocalID\n", m.localID)
+	}
+
+	for _, attr := range m.attrs {
+		g.printf("\nif value, ok := node.Attributes[%q]; ok && value != nil {\n", attr.name)
+		g.unmarshalAttribute(attr)
+		g.printf("}\n")
+	}
+
+	for _, rel := range m.rels {
+		g.printf("\nif rel, ok := node.Relationships[%q]; ok {\n", rel.name)
+		g.printf("linkage, ok, err := jsonapi.RelationshipLinkage(rel)\nif err != nil {\nreturn err\n}\n")
+		g.printf("if ok {\n")
+		if rel.many {
+			g.printf("m.%s = make([]*%s, 0, len(linkage))\n", rel.field, rel.related.name)
+			g.printf("for _, n := range linkage {\nrelated := new(%s)\n", rel.related.name)
+			g.parseID("related."+rel.related.id, "n.ID", rel.related.idType)
+			g.printf("m.%s = append(m.%s, related)\n}\n", rel.field, rel.field)
+		} else {
+			g.printf("m.%s = nil\nfor _, n := range linkage {\nrelated := new(%s)\n", rel.field, rel.related.name)
+			g.parseID("related."+rel.related.id, "n.ID", rel.related.idType)
+			g.printf("m.%s = related\n}\n", rel.field)
+		}
+		g.printf("}\n}\n")
+	}
+	g.printf("return nil\n}\n")
+}
+
+// parseID writes the statements setting dst, of type typ, to the id src.
+func (g *generator) parseID(dst, src, typ string) {
+	switch {
+	case typ == "string":
+		g.printf("%s = %s\n", dst, src)
+		return
+	case strings.HasPrefix(typ, "uint"):
+		g.printf("id, err := strconv.ParseUint(%s, 10, %d)\n", src, bitSize(typ))
+	default:
+		g.printf("id, err := strconv.ParseInt(%s, 10, %d)\n", src, bitSize(typ))
+	}
+	g.strconv = true
+	g.printf("if err != nil {\nreturn jsonapi.ErrBadJSONAPIID\n}\n%s = %s(id)\n", dst, typ)
+}
+
+// bitSize returns the size of the integer type typ, 0 for int and uint.
+func bitSize(typ string) int {
+	size, _ := strconv.Atoi(strings.TrimLeft(typ, "uint"))
+	return size
+}
+
+// unmarshalAttribute writes the statements binding value, a decoded attribute
+// other than null, to attr, returning the errors of the jsonapi package for
+// values of another type.
+func (g *generator) unmarshalAttribute(attr attribute) {
+	field := "m." + attr.field
+
+	if attr.typ == "time.Time" {
+		g.time = true
+		switch attr.layout {
+		case "":
+			g.number = true
+			g.printf("f, ok := jsonapigenNumber(value)\nif !ok {\nreturn jsonapi.ErrInvalidTime\n}\n")
+			g.printf("t := time.Unix(int64(f), 0)\n")
+		case "iso8601":
+			g.printf("s, ok := value.(string)\nif !ok {\nreturn jsonapi.ErrInvalidISO8601\n}\n")
+			g.printf("t, err := time.Parse(\"2006-01-02T15:04:05Z\", s)\nif err != nil {\nreturn jsonapi.ErrInvalidISO8601\n}\n")
+		case "rfc3339":
+			g.printf("s, ok := value.(string)\nif !ok {\nreturn jsonapi.ErrInvalidTimeLayout\n}\n")
+			g.printf("t, err := time.Parse(time.RFC3339, s)\nif err != nil {\nreturn jsonapi.ErrInvalidTimeLayout\n}\n")
+		}
+		if attr.ptr {
+			g.printf("%s = &t\n", field)
+		} else {
+			g.printf("%s = t\n", field)
+		}
+		return
+	}
+
+	if attr.slice {
+		g.printf("values, ok := value.([]interface{})\nif !ok {\nreturn jsonapi.ErrInvalidType\n}\n")
+		g.printf("s := make([]%s, len(values))\nfor i, value := range values {\n", attr.typ)
+		g.convert("s[i]", attr.typ)
+		g.printf("}\n%s = s\n", field)
+		return
+	}
+
+	if attr.ptr {
+		g.printf("var x %s\n", attr.typ)
+		g.convert("x", attr.typ)
+		g.printf("%s = &x\n", field)
+		return
+	}
+	g.convert(field, attr.typ)
+}
+
+// convert writes the statements setting dst, of the basic type typ, to value.
+func (g *generator) convert(dst, typ string) {
+	switch typ {
+	case "string", "bool":
+		g.printf("v, ok := value.(%s)\nif !ok {\nreturn jsonapi.ErrInvalidType\n}\n%s = v\n", typ, dst)
+	default:
+		g.number = true
+		g.printf("f, ok := jsonapigenNumber(value)\nif !ok {\nreturn jsonapi.ErrInvalidType\n}\n%s = %s(f)\n", dst, typ)
+	}
+}
+
+// numberHelper writes the function reading the numbers of the attributes,
+// decoded from a payload or as marshaled.
+func (g *generator) numberHelper() {
+	g.printf(`
+// jsonapigenNumber returns the number value, decoded from a payload as a
+// float64 or held by a Node as marshaled.
+func jsonapigenNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+`)
+}
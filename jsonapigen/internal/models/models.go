@@ -0,0 +1,51 @@
+// Package models holds the models whose methods are generated by jsonapigen,
+// to check them against the reflection of the jsonapi package.
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/jsonapi"
+)
+
+//go:generate jsonapigen -type Post,Comment,Author -output models_jsonapi.go .
+
+type Post struct {
+	ID          int64      `jsonapi:"primary,posts"`
+	ClientID    string     `jsonapi:"client-id"`
+	Title       string     `jsonapi:"attr,title"`
+	Body        string     `jsonapi:"attr,body,omitempty"`
+	Views       uint32     `jsonapi:"attr,views"`
+	Score       *float64   `jsonapi:"attr,score"`
+	Draft       bool       `jsonapi:"attr,draft,omitempty"`
+	Tags        []string   `jsonapi:"attr,tags"`
+	PublishedAt time.Time  `jsonapi:"attr,published_at,iso8601"`
+	EditedAt    *time.Time `jsonapi:"attr,edited_at,rfc3339,omitempty"`
+	CreatedAt   time.Time  `jsonapi:"attr,created_at"`
+	Author      *Author    `jsonapi:"relation,author"`
+	Comments    []*Comment `jsonapi:"relation,comments"`
+	Pinned      *Comment   `jsonapi:"relation,pinned,omitempty"`
+}
+
+func (p *Post) JSONAPILinks() *jsonapi.Links {
+	return &jsonapi.Links{"self": fmt.Sprintf("/posts/%d", p.ID)}
+}
+
+func (p *Post) JSONAPIRelationshipMeta(relation string) *jsonapi.Meta {
+	if relation == "comments" {
+		return &jsonapi.Meta{"count": len(p.Comments)}
+	}
+	return nil
+}
+
+type Comment struct {
+	ID     uint    `jsonapi:"primary,comments"`
+	Body   string  `jsonapi:"attr,body"`
+	Author *Author `jsonapi:"relation,author"`
+}
+
+type Author struct {
+	ID   string  `jsonapi:"primary,authors"`
+	Name *string `jsonapi:"attr,name"`
+}
@@ -0,0 +1,375 @@
+// Code generated by jsonapigen. DO NOT EDIT.
+
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/jsonapi"
+)
+
+// MarshalJSONAPINode implements jsonapi.NodeMarshaler.
+func (m *Post) MarshalJSONAPINode() (*jsonapi.Node, error) {
+	node := &jsonapi.Node{Type: "posts", ID: strconv.FormatInt(int64(m.ID), 10)}
+	node.ClientID = m.ClientID
+
+	node.Attributes = make(map[string]interface{}, 9)
+	node.Attributes["title"] = m.Title
+	if m.Body != "" {
+		node.Attributes["body"] = m.Body
+	}
+	node.Attributes["views"] = m.Views
+	if m.Score == nil {
+		node.Attributes["score"] = nil
+	} else {
+		node.Attributes["score"] = *m.Score
+	}
+	if m.Draft {
+		node.Attributes["draft"] = m.Draft
+	}
+	node.Attributes["tags"] = m.Tags
+	if !m.PublishedAt.IsZero() {
+		node.Attributes["published_at"] = m.PublishedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if m.EditedAt != nil && !m.EditedAt.IsZero() {
+		node.Attributes["edited_at"] = m.EditedAt.Format(time.RFC3339)
+	}
+	if !m.CreatedAt.IsZero() {
+		node.Attributes["created_at"] = m.CreatedAt.Unix()
+	}
+	if len(node.Attributes) == 0 {
+		node.Attributes = nil
+	}
+
+	node.Relationships = make(map[string]interface{}, 3)
+	if related := m.Author; related != nil {
+		node.Relationships["author"] = &jsonapi.RelationshipOneNode{Data: &jsonapi.Node{Type: "authors", ID: related.ID}, Meta: m.JSONAPIRelationshipMeta("author")}
+	} else {
+		node.Relationships["author"] = &jsonapi.RelationshipOneNode{Meta: m.JSONAPIRelationshipMeta("author")}
+	}
+	{
+		data := make([]*jsonapi.Node, 0, len(m.Comments))
+		for _, related := range m.Comments {
+			if related != nil {
+				data = append(data, &jsonapi.Node{Type: "comments", ID: strconv.FormatUint(uint64(related.ID), 10)})
+			}
+		}
+		node.Relationships["comments"] = &jsonapi.RelationshipManyNode{Data: data, Meta: m.JSONAPIRelationshipMeta("comments")}
+	}
+	if related := m.Pinned; related != nil {
+		node.Relationships["pinned"] = &jsonapi.RelationshipOneNode{Data: &jsonapi.Node{Type: "comments", ID: strconv.FormatUint(uint64(related.ID), 10)}, Meta: m.JSONAPIRelationshipMeta("pinned")}
+	}
+	if len(node.Relationships) == 0 {
+		node.Relationships = nil
+	}
+	node.Links = m.JSONAPILinks()
+	return node, nil
+}
+
+// JSONAPIRelated implements jsonapi.RelatedMarshaler.
+func (m *Post) JSONAPIRelated(relation string) interface{} {
+	switch relation {
+	case "author":
+		return m.Author
+	case "comments":
+		return m.Comments
+	case "pinned":
+		return m.Pinned
+	}
+	return nil
+}
+
+// UnmarshalJSONAPINode implements jsonapi.NodeUnmarshaler.
+func (m *Post) UnmarshalJSONAPINode(node *jsonapi.Node) error {
+	if node.ID != "" {
+		id, err := strconv.ParseInt(node.ID, 10, 64)
+		if err != nil {
+			return jsonapi.ErrBadJSONAPIID
+		}
+		m.ID = int64(id)
+	}
+	m.ClientID = node.ClientID
+
+	if value, ok := node.Attributes["title"]; ok && value != nil {
+		v, ok := value.(string)
+		if !ok {
+			return jsonapi.ErrInvalidType
+		}
+		m.Title = v
+	}
+
+	if value, ok := node.Attributes["body"]; ok && value != nil {
+		v, ok := value.(string)
+		if !ok {
+			return jsonapi.ErrInvalidType
+		}
+		m.Body = v
+	}
+
+	if value, ok := node.Attributes["views"]; ok && value != nil {
+		f, ok := jsonapigenNumber(value)
+		if !ok {
+			return jsonapi.ErrInvalidType
+		}
+		m.Views = uint32(f)
+	}
+
+	if value, ok := node.Attributes["score"]; ok && value != nil {
+		var x float64
+		f, ok := jsonapigenNumber(value)
+		if !ok {
+			return jsonapi.ErrInvalidType
+		}
+		x = float64(f)
+		m.Score = &x
+	}
+
+	if value, ok := node.Attributes["draft"]; ok && value != nil {
+		v, ok := value.(bool)
+		if !ok {
+			return jsonapi.ErrInvalidType
+		}
+		m.Draft = v
+	}
+
+	if value, ok := node.Attributes["tags"]; ok && value != nil {
+		values, ok := value.([]interface{})
+		if !ok {
+			return jsonapi.ErrInvalidType
+		}
+		s := make([]string, len(values))
+		for i, value := range values {
+			v, ok := value.(string)
+			if !ok {
+				return jsonapi.ErrInvalidType
+			}
+			s[i] = v
+		}
+		m.Tags = s
+	}
+
+	if value, ok := node.Attributes["published_at"]; ok && value != nil {
+		s, ok := value.(string)
+		if !ok {
+			return jsonapi.ErrInvalidISO8601
+		}
+		t, err := time.Parse("2006-01-02T15:04:05Z", s)
+		if err != nil {
+			return jsonapi.ErrInvalidISO8601
+		}
+		m.PublishedAt = t
+	}
+
+	if value, ok := node.Attributes["edited_at"]; ok && value != nil {
+		s, ok := value.(string)
+		if !ok {
+			return jsonapi.ErrInvalidTimeLayout
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return jsonapi.ErrInvalidTimeLayout
+		}
+		m.EditedAt = &t
+	}
+
+	if value, ok := node.Attributes["created_at"]; ok && value != nil {
+		f, ok := jsonapigenNumber(value)
+		if !ok {
+			return jsonapi.ErrInvalidTime
+		}
+		t := time.Unix(int64(f), 0)
+		m.CreatedAt = t
+	}
+
+	if rel, ok := node.Relationships["author"]; ok {
+		linkage, ok, err := jsonapi.RelationshipLinkage(rel)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.Author = nil
+			for _, n := range linkage {
+				related := new(Author)
+				related.ID = n.ID
+				m.Author = related
+			}
+		}
+	}
+
+	if rel, ok := node.Relationships["comments"]; ok {
+		linkage, ok, err := jsonapi.RelationshipLinkage(rel)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.Comments = make([]*Comment, 0, len(linkage))
+			for _, n := range linkage {
+				related := new(Comment)
+				id, err := strconv.ParseUint(n.ID, 10, 0)
+				if err != nil {
+					return jsonapi.ErrBadJSONAPIID
+				}
+				related.ID = uint(id)
+				m.Comments = append(m.Comments, related)
+			}
+		}
+	}
+
+	if rel, ok := node.Relationships["pinned"]; ok {
+		linkage, ok, err := jsonapi.RelationshipLinkage(rel)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.Pinned = nil
+			for _, n := range linkage {
+				related := new(Comment)
+				id, err := strconv.ParseUint(n.ID, 10, 0)
+				if err != nil {
+					return jsonapi.ErrBadJSONAPIID
+				}
+				related.ID = uint(id)
+				m.Pinned = related
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSONAPINode implements jsonapi.NodeMarshaler.
+func (m *Comment) MarshalJSONAPINode() (*jsonapi.Node, error) {
+	node := &jsonapi.Node{Type: "comments", ID: strconv.FormatUint(uint64(m.ID), 10)}
+
+	node.Attributes = make(map[string]interface{}, 1)
+	node.Attributes["body"] = m.Body
+	if len(node.Attributes) == 0 {
+		node.Attributes = nil
+	}
+
+	node.Relationships = make(map[string]interface{}, 1)
+	if related := m.Author; related != nil {
+		node.Relationships["author"] = &jsonapi.RelationshipOneNode{Data: &jsonapi.Node{Type: "authors", ID: related.ID}}
+	} else {
+		node.Relationships["author"] = &jsonapi.RelationshipOneNode{}
+	}
+	if len(node.Relationships) == 0 {
+		node.Relationships = nil
+	}
+	return node, nil
+}
+
+// JSONAPIRelated implements jsonapi.RelatedMarshaler.
+func (m *Comment) JSONAPIRelated(relation string) interface{} {
+	switch relation {
+	case "author":
+		return m.Author
+	}
+	return nil
+}
+
+// UnmarshalJSONAPINode implements jsonapi.NodeUnmarshaler.
+func (m *Comment) UnmarshalJSONAPINode(node *jsonapi.Node) error {
+	if node.ID != "" {
+		id, err := strconv.ParseUint(node.ID, 10, 0)
+		if err != nil {
+			return jsonapi.ErrBadJSONAPIID
+		}
+		m.ID = uint(id)
+	}
+
+	if value, ok := node.Attributes["body"]; ok && value != nil {
+		v, ok := value.(string)
+		if !ok {
+			return jsonapi.ErrInvalidType
+		}
+		m.Body = v
+	}
+
+	if rel, ok := node.Relationships["author"]; ok {
+		linkage, ok, err := jsonapi.RelationshipLinkage(rel)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.Author = nil
+			for _, n := range linkage {
+				related := new(Author)
+				related.ID = n.ID
+				m.Author = related
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSONAPINode implements jsonapi.NodeMarshaler.
+func (m *Author) MarshalJSONAPINode() (*jsonapi.Node, error) {
+	node := &jsonapi.Node{Type: "authors", ID: m.ID}
+
+	node.Attributes = make(map[string]interface{}, 1)
+	if m.Name == nil {
+		node.Attributes["name"] = nil
+	} else {
+		node.Attributes["name"] = *m.Name
+	}
+	if len(node.Attributes) == 0 {
+		node.Attributes = nil
+	}
+	return node, nil
+}
+
+// JSONAPIRelated implements jsonapi.RelatedMarshaler.
+func (m *Author) JSONAPIRelated(relation string) interface{} {
+	return nil
+}
+
+// UnmarshalJSONAPINode implements jsonapi.NodeUnmarshaler.
+func (m *Author) UnmarshalJSONAPINode(node *jsonapi.Node) error {
+	if node.ID != "" {
+		m.ID = node.ID
+	}
+
+	if value, ok := node.Attributes["name"]; ok && value != nil {
+		var x string
+		v, ok := value.(string)
+		if !ok {
+			return jsonapi.ErrInvalidType
+		}
+		x = v
+		m.Name = &x
+	}
+	return nil
+}
+
+// jsonapigenNumber returns the number value, decoded from a payload as a
+// float64 or held by a Node as marshaled.
+func jsonapigenNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/jsonapi"
+)
+
+// plainPost has the fields, tags and hooks of Post without its generated
+// methods, so that it is marshaled by reflection.
+type plainPost Post
+
+func (p *plainPost) JSONAPILinks() *jsonapi.Links {
+	return (*Post)(p).JSONAPILinks()
+}
+
+func (p *plainPost) JSONAPIRelationshipMeta(relation string) *jsonapi.Meta {
+	return (*Post)(p).JSONAPIRelationshipMeta(relation)
+}
+
+// hideTitle hides the title attribute of the posts.
+type hideTitle struct{}
+
+func (hideTitle) AttributeVisible(ctx context.Context, resourceType, name string) bool {
+	return resourceType != "posts" || name != "title"
+}
+
+func (hideTitle) RelationshipVisible(ctx context.Context, resourceType, name string) bool {
+	return true
+}
+
+func testPost() *Post {
+	name, score := "Ann", 4.5
+	edited := time.Date(2020, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	ann := &Author{ID: "ann", Name: &name}
+	return &Post{
+		ID:          1,
+		ClientID:    "c1",
+		Title:       "Generated",
+		Views:       30,
+		Score:       &score,
+		Tags:        []string{"go", "json"},
+		PublishedAt: time.Date(2020, 2, 1, 8, 0, 0, 0, time.UTC),
+		EditedAt:    &edited,
+		CreatedAt:   time.Unix(1580000000, 0),
+		Author:      ann,
+		Comments: []*Comment{
+			{ID: 2, Body: "First", Author: ann},
+			{ID: 3, Body: "Second", Author: &Author{ID: "bob"}},
+		},
+	}
+}
+
+func TestGeneratedMarshal(t *testing.T) {
+	for _, opts := range [][]jsonapi.MarshalOption{
+		nil,
+		{jsonapi.WithInclude("comments")},
+		{jsonapi.WithMaxDepth(1)},
+		// the options rewriting the members fall back to reflection
+		{jsonapi.WithFieldPolicy(hideTitle{})},
+		{jsonapi.WithFields(map[string][]string{"posts": {"body", "views"}})},
+		{jsonapi.WithConfig(jsonapi.Config{NamingStrategy: strings.ToUpper})},
+		{jsonapi.WithRenames(jsonapi.Renames{Members: map[string]string{"views": "hits"}})},
+		{jsonapi.WithLinkResolver(&jsonapi.LinkResolver{Related: "/{type}/{id}/{rel}"})},
+		{jsonapi.WithConfig(jsonapi.Config{TimeFormat: jsonapi.TimeFormatISO8601})},
+		{jsonapi.WithConfig(jsonapi.Config{BaseURL: "https://example.com"})},
+		{jsonapi.WithConfig(jsonapi.Config{EmitEmptyMembers: true})},
+	} {
+		post := testPost()
+
+		generated := bytes.NewBuffer(nil)
+		if err := jsonapi.MarshalOnePayload(generated, post, opts...); err != nil {
+			t.Fatal(err)
+		}
+		reflected := bytes.NewBuffer(nil)
+		if err := jsonapi.MarshalOnePayload(reflected, (*plainPost)(post), opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		if generated.String() != reflected.String() {
+			t.Fatalf("Was expecting %s, got %s", reflected, generated)
+		}
+	}
+}
+
+func TestGeneratedMarshalConfig(t *testing.T) {
+	config := jsonapi.Config{TimeFormat: jsonapi.TimeFormatISO8601, BaseURL: "https://example.com"}
+	payload, err := jsonapi.MarshalOne(testPost(), jsonapi.WithConfig(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "2020-01-26T00:53:20Z", payload.Data.Attributes["created_at"]; e != a {
+		t.Fatalf("Was expecting created_at %v, got %v", e, a)
+	}
+	if e, a := "https://example.com/posts/1", (*payload.Data.Links)["self"]; e != a {
+		t.Fatalf("Was expecting the self link %v, got %v", e, a)
+	}
+}
+
+func TestGeneratedMarshalFieldPolicy(t *testing.T) {
+	payload, err := jsonapi.MarshalOne(testPost(), jsonapi.WithFieldPolicy(hideTitle{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload.Data.Attributes["title"]; ok {
+		t.Fatalf("Was expecting the title to be hidden, got %v", payload.Data.Attributes)
+	}
+}
+
+func TestGeneratedUnmarshal(t *testing.T) {
+	post := testPost()
+	out := bytes.NewBuffer(nil)
+	if err := jsonapi.MarshalOnePayload(out, post); err != nil {
+		t.Fatal(err)
+	}
+	b := out.Bytes()
+
+	generated := new(Post)
+	if err := jsonapi.UnmarshalPayload(bytes.NewReader(b), generated); err != nil {
+		t.Fatal(err)
+	}
+	reflected := new(plainPost)
+	if err := jsonapi.UnmarshalPayload(bytes.NewReader(b), reflected); err != nil {
+		t.Fatal(err)
+	}
+
+	// the related records are bound from their resource identifiers only
+	if generated.Author.ID != "ann" || len(generated.Comments) != 2 || generated.Comments[1].ID != 3 {
+		t.Fatalf("Was expecting the linkage to be bound, got %+v", generated)
+	}
+	generated.Author, generated.Comments = nil, nil
+	reflected.Author, reflected.Comments = nil, nil
+
+	if !reflect.DeepEqual((*plainPost)(generated), reflected) {
+		t.Fatalf("Was expecting %+v, got %+v", reflected, generated)
+	}
+
+	bad := bytes.Replace(b, []byte(`"views":30`), []byte(`"views":"30"`), 1)
+	if err := jsonapi.UnmarshalPayload(bytes.NewReader(bad), new(Post)); err != jsonapi.ErrInvalidType {
+		t.Fatalf("Was expecting %v, got %v", jsonapi.ErrInvalidType, err)
+	}
+}
@@ -0,0 +1,448 @@
+/*
+Package jsonapigen generates the methods marshaling and unmarshaling the
+models of a package without reflection, from their jsonapi struct tags:
+
+	go install github.com/google/jsonapi/jsonapigen/cmd/jsonapigen
+	jsonapigen -type Post,Comment -output models_jsonapi.go .
+
+or, in the package of the models,
+
+	//go:generate jsonapigen -type Post,Comment -output models_jsonapi.go .
+
+Each model gets MarshalJSONAPINode, JSONAPIRelated and UnmarshalJSONAPINode
+methods, so that the jsonapi package uses them in place of reflection, as it
+does for any NodeMarshaler, RelatedMarshaler and NodeUnmarshaler. The
+Linkable, Metable, RelationshipLinkable and RelationshipMetable methods of the
+models are called by the generated ones; the models with methods taking the
+context of the call, such as JSONAPIMetaWithContext, are not supported.
+
+Only the models whose fields can be rendered without reflection are
+supported: primary, client-id, lid, attr and relation annotated fields, with
+the omitempty, iso8601 and rfc3339 options. Attributes are of the basic types,
+time.Time, pointers to them or slices of the basic types; relations point to
+models of the same package, or are slices of pointers to them. Without -type,
+the other models of the package are left to reflection.
+
+The generated methods render the members under their tagged names, and times
+as tagged. The jsonapi package falls back to reflection for the calls with
+options rewriting the members or links, such as WithFields, WithFieldPolicy,
+WithAttributeTransformer, WithLinkResolver or member renames, and with a
+Config setting a NamingStrategy, a TimeFormat, a BaseURL or EmitEmptyMembers.
+Related records
+are bound from their resource identifiers when unmarshaling, without the
+members of their included resource objects.
+*/
+package jsonapigen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// generatedHeader starts the files written by Generate, which are not read
+// back as the source of models.
+const generatedHeader = "// Code generated by jsonapigen. DO NOT EDIT."
+
+// basicTypes are the types of the attributes rendered as they are.
+var basicTypes = map[string]bool{
+	"string": true, "bool": true, "float32": true, "float64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+}
+
+// contextMethods are the methods of the models taking the context of the
+// Marshal call, which the generated methods can't pass.
+var contextMethods = []string{
+	"JSONAPILinksWithContext", "JSONAPIRelationshipLinksWithContext",
+	"JSONAPIMetaWithContext", "JSONAPIRelationshipMetaWithContext",
+	"JSONAPIRelatedMetaWithContext",
+}
+
+// model is a struct type of the package with a primary annotated field.
+type model struct {
+	name  string
+	decl  *ast.StructType
+	rtype string
+
+	id       string
+	idType   string
+	clientID string
+	localID  string
+
+	attrs []attribute
+	rels  []relation
+
+	// methods holds the names of the methods declared for the type.
+	methods map[string]bool
+}
+
+// attribute is an attr annotated field.
+type attribute struct {
+	field, name string
+
+	// typ is the basic type of the attribute, or "time.Time".
+	typ string
+	ptr bool
+
+	// slice is set for the slices of basic types.
+	slice bool
+
+	omitEmpty bool
+
+	// layout is the time option of a time attribute, iso8601 or rfc3339, or
+	// "" for unix timestamps.
+	layout string
+}
+
+// relation is a relation annotated field.
+type relation struct {
+	field, name string
+	related     *model
+	many        bool
+	omitEmpty   bool
+}
+
+// Generate returns the source of the methods of the models named by types,
+// declared in the Go files of the directory dir, or of every supported model
+// of its package when types is empty. It returns an error for the models
+// named by types that are not supported.
+func Generate(dir string, types []string) ([]byte, error) {
+	pkg, models, err := parseModels(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []*model
+	if len(types) == 0 {
+		for _, name := range sortedNames(models) {
+			if err := models[name].analyze(models); err == nil {
+				selected = append(selected, models[name])
+			}
+		}
+	} else {
+		for _, name := range types {
+			m, ok := models[name]
+			if !ok {
+				return nil, fmt.Errorf("jsonapigen: %s is not a model of package %s", name, pkg)
+			}
+			if err := m.analyze(models); err != nil {
+				return nil, err
+			}
+			selected = append(selected, m)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("jsonapigen: no supported model in package %s", pkg)
+	}
+
+	src, err := generate(pkg, selected)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("jsonapigen: %v", err)
+	}
+	return formatted, nil
+}
+
+// parseModels returns the name of the package of the Go files of dir and its
+// models, by name. The test files and those written by Generate are skipped.
+func parseModels(dir string) (string, map[string]*model, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	fset := token.NewFileSet()
+	var pkg string
+	models := make(map[string]*model)
+	methods := make(map[string]map[string]bool)
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		if bytes.HasPrefix(src, []byte(generatedHeader)) {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, src, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		if pkg == "" {
+			pkg = file.Name.Name
+		}
+
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					if s, ok := ts.Type.(*ast.StructType); ok && hasPrimary(s) {
+						models[ts.Name.Name] = &model{name: ts.Name.Name, decl: s}
+					}
+				}
+			case *ast.FuncDecl:
+				if recv := receiverName(d); recv != "" {
+					if methods[recv] == nil {
+						methods[recv] = make(map[string]bool)
+					}
+					methods[recv][d.Name.Name] = true
+				}
+			}
+		}
+	}
+	if pkg == "" {
+		return "", nil, fmt.Errorf("jsonapigen: no Go files in %s", dir)
+	}
+
+	for name, m := range models {
+		m.methods = methods[name]
+	}
+	return pkg, models, nil
+}
+
+// receiverName returns the name of the type of the receiver of the method d,
+// or "" for a function.
+func receiverName(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) != 1 {
+		return ""
+	}
+	t := d.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	if ident, ok := t.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// hasPrimary reports whether s has a primary annotated field.
+func hasPrimary(s *ast.StructType) bool {
+	for _, field := range s.Fields.List {
+		if args := tagArgs(field); len(args) > 0 && args[0] == "primary" {
+			return true
+		}
+	}
+	return false
+}
+
+// tagArgs returns the comma separated parts of the jsonapi tag of field.
+func tagArgs(field *ast.Field) []string {
+	if field.Tag == nil {
+		return nil
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return nil
+	}
+	value, ok := reflect.StructTag(tag).Lookup("jsonapi")
+	if !ok || value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// analyze reads the fields of m, returning an error for those that can't be
+// rendered without reflection.
+func (m *model) analyze(models map[string]*model) error {
+	m.attrs, m.rels = nil, nil
+
+	for _, name := range contextMethods {
+		if m.methods[name] {
+			return fmt.Errorf("jsonapigen: %s: unsupported %s method", m.name, name)
+		}
+	}
+
+	for _, field := range m.decl.Fields.List {
+		args := tagArgs(field)
+		if args == nil {
+			if len(field.Names) == 0 {
+				return m.unsupported(field, "embedded field")
+			}
+			continue
+		}
+		if len(field.Names) != 1 {
+			return m.unsupported(field, "tagged field without a single name")
+		}
+		name := field.Names[0].Name
+
+		var options []string
+		if len(args) > 2 {
+			options = args[2:]
+		}
+
+		switch args[0] {
+		case "primary":
+			typ, ok := field.Type.(*ast.Ident)
+			if !ok || !basicTypes[typ.Name] || typ.Name == "bool" || strings.HasPrefix(typ.Name, "float") {
+				return m.unsupported(field, "primary field type")
+			}
+			if len(args) != 2 {
+				return m.unsupported(field, "primary tag")
+			}
+			m.id, m.idType, m.rtype = name, typ.Name, args[1]
+		case "client-id", "lid":
+			if typ, ok := field.Type.(*ast.Ident); !ok || typ.Name != "string" || len(args) != 1 {
+				return m.unsupported(field, args[0]+" field")
+			}
+			if args[0] == "client-id" {
+				m.clientID = name
+			} else {
+				m.localID = name
+			}
+		case "attr":
+			if len(args) < 2 {
+				return m.unsupported(field, "attr tag")
+			}
+			attr, err := attributeOf(field.Type, options)
+			if err != nil {
+				return m.unsupported(field, err.Error())
+			}
+			attr.field, attr.name = name, args[1]
+			m.attrs = append(m.attrs, attr)
+		case "relation":
+			if len(args) < 2 {
+				return m.unsupported(field, "relation tag")
+			}
+			rel, err := relationOf(field.Type, options, models)
+			if err != nil {
+				return m.unsupported(field, err.Error())
+			}
+			rel.field, rel.name = name, args[1]
+			m.rels = append(m.rels, rel)
+		default:
+			return m.unsupported(field, fmt.Sprintf("%q annotation", args[0]))
+		}
+	}
+	return nil
+}
+
+func (m *model) unsupported(field *ast.Field, problem string) error {
+	name := "embedded"
+	if len(field.Names) > 0 {
+		name = field.Names[0].Name
+	}
+	return fmt.Errorf("jsonapigen: %s.%s: unsupported %s", m.name, name, problem)
+}
+
+// attributeOf returns the attribute of the type expr with the tag options.
+func attributeOf(expr ast.Expr, options []string) (attribute, error) {
+	var attr attribute
+	for _, option := range options {
+		switch option {
+		case "omitempty":
+			attr.omitEmpty = true
+		case "iso8601", "rfc3339":
+			attr.layout = option
+		default:
+			return attr, fmt.Errorf("option %q", option)
+		}
+	}
+
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		attr.ptr = true
+		expr = t.X
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return attr, fmt.Errorf("array attribute")
+		}
+		attr.slice = true
+		expr = t.Elt
+	}
+
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if basicTypes[t.Name] {
+			attr.typ = t.Name
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "time" && t.Sel.Name == "Time" && !attr.slice {
+			attr.typ = "time.Time"
+		}
+	}
+	if attr.typ == "" {
+		return attr, fmt.Errorf("attribute type")
+	}
+	if attr.layout != "" && attr.typ != "time.Time" {
+		return attr, fmt.Errorf("time option for a %s", attr.typ)
+	}
+	return attr, nil
+}
+
+// relationOf returns the relation of the type expr with the tag options.
+func relationOf(expr ast.Expr, options []string, models map[string]*model) (relation, error) {
+	var rel relation
+	for _, option := range options {
+		if option != "omitempty" {
+			return rel, fmt.Errorf("option %q", option)
+		}
+		rel.omitEmpty = true
+	}
+
+	if slice, ok := expr.(*ast.ArrayType); ok && slice.Len == nil {
+		rel.many = true
+		expr = slice.Elt
+	}
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return rel, fmt.Errorf("relation type")
+	}
+	ident, ok := star.X.(*ast.Ident)
+	if !ok || models[ident.Name] == nil {
+		return rel, fmt.Errorf("relation to a type that is not a model of the package")
+	}
+	rel.related = models[ident.Name]
+	if err := rel.related.analyzePrimary(); err != nil {
+		return rel, err
+	}
+	return rel, nil
+}
+
+// analyzePrimary reads the primary field of m only, as needed for the models
+// it is related to.
+func (m *model) analyzePrimary() error {
+	for _, field := range m.decl.Fields.List {
+		args := tagArgs(field)
+		if len(args) == 0 || args[0] != "primary" {
+			continue
+		}
+		typ, ok := field.Type.(*ast.Ident)
+		if !ok || len(field.Names) != 1 || len(args) != 2 || !basicTypes[typ.Name] ||
+			typ.Name == "bool" || strings.HasPrefix(typ.Name, "float") {
+			return fmt.Errorf("primary field type of %s", m.name)
+		}
+		m.id, m.idType, m.rtype = field.Names[0].Name, typ.Name, args[1]
+		return nil
+	}
+	return fmt.Errorf("primary field of %s", m.name)
+}
+
+func sortedNames(models map[string]*model) []string {
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,72 @@
+package jsonapigen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateUpToDate(t *testing.T) {
+	src, err := Generate(filepath.Join("internal", "models"), []string{"Post", "Comment", "Author"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := os.ReadFile(filepath.Join("internal", "models", "models_jsonapi.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != string(generated) {
+		t.Fatal("Was expecting internal/models/models_jsonapi.go to be up to date; run go generate")
+	}
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	models := `package models
+
+type Book struct {
+	ID    int    ` + "`jsonapi:\"primary,books\"`" + `
+	Title string ` + "`jsonapi:\"attr,title\"`" + `
+}
+
+type Shelf struct {
+	ID    int    ` + "`jsonapi:\"primary,shelves\"`" + `
+	Label string ` + "`jsonapi:\"attr,label,readonly\"`" + `
+}
+
+type Aisle struct {
+	ID int ` + "`jsonapi:\"primary,aisles\"`" + `
+}
+
+func (a *Aisle) JSONAPIMetaWithContext(ctx context.Context) *jsonapi.Meta {
+	return nil
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "models.go"), []byte(models), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Generate(dir, []string{"Shelf"})
+	if err == nil || !strings.Contains(err.Error(), `Shelf.Label: unsupported option "readonly"`) {
+		t.Fatalf("Was expecting the readonly option to be unsupported, got %v", err)
+	}
+	_, err = Generate(dir, []string{"Aisle"})
+	if err == nil || !strings.Contains(err.Error(), "Aisle: unsupported JSONAPIMetaWithContext method") {
+		t.Fatalf("Was expecting the context methods to be unsupported, got %v", err)
+	}
+	if _, err := Generate(dir, []string{"Shop"}); err == nil {
+		t.Fatal("Was expecting an error for a type that is not a model")
+	}
+
+	// without -type, the unsupported models are left to reflection
+	src, err := Generate(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "func (m *Book) MarshalJSONAPINode()") ||
+		strings.Contains(string(src), "func (m *Shelf)") || strings.Contains(string(src), "func (m *Aisle)") {
+		t.Fatalf("Was expecting the methods of Book only, got %s", src)
+	}
+}
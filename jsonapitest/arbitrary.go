@@ -0,0 +1,275 @@
+/*
+Package jsonapitest provides helpers to test the models of a service and the
+jsonapi package itself against randomized documents: Arbitrary builds a random
+valid model of a type, ArbitraryPayload its document, and RoundTrip checks that
+unmarshaling a document and marshaling the model back gives the same document,
+e.g.
+
+	func TestModelsRoundTrip(t *testing.T) {
+		jsonapitest.CheckRoundTrip(t, reflect.TypeOf(Blog{}), 100)
+	}
+*/
+package jsonapitest
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/google/jsonapi"
+)
+
+// runes are the runes of the random strings, including some that JSON
+// escapes.
+var runes = []rune("abcxyzABCXYZ 019_-é世😀\"\\<>&\n\t")
+
+// Arbitrary returns a new instance of modelType, a struct type or a pointer to
+// one, holding random values drawn from r: a random id, random attribute
+// values, null pointers and empty containers included, and random related
+// models, themselves without relations, or random ids for the relations with
+// the ids option. The ids are unique per resource type, so that the related
+// models don't collide once included.
+//
+// Fields the package doesn't read back from a document, such as readonly
+// attributes, client ids and interface typed members, are left zero.
+func Arbitrary(modelType reflect.Type, r *rand.Rand) interface{} {
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+
+	g := &generator{r: r, ids: make(map[string]int)}
+	return g.model(modelType, true).Interface()
+}
+
+// ArbitraryPayload returns the document of an Arbitrary model of modelType,
+// with its related models included.
+func ArbitraryPayload(modelType reflect.Type, r *rand.Rand, opts ...jsonapi.MarshalOption) ([]byte, error) {
+	out := new(bytes.Buffer)
+	if err := jsonapi.MarshalOnePayload(out, Arbitrary(modelType, r), opts...); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// generator draws random models, numbering the ids of each resource type.
+type generator struct {
+	r   *rand.Rand
+	ids map[string]int
+}
+
+// id returns a new id for the resource type name.
+func (g *generator) id(name string) string {
+	if _, ok := g.ids[name]; !ok {
+		g.ids[name] = g.r.Intn(1000)
+	}
+	g.ids[name]++
+	return strconv.Itoa(g.ids[name])
+}
+
+// model returns a pointer to a random model of the struct type t, with random
+// related models if related is set.
+func (g *generator) model(t reflect.Type, related bool) reflect.Value {
+	model := reflect.New(t)
+
+	for _, f := range jsonapi.TaggedFields(t) {
+		tag, err := jsonapi.ParseTag(f.Tag.Get("jsonapi"))
+		if err != nil {
+			continue
+		}
+
+		switch tag.Annotation {
+		case "primary":
+			setID(settableField(model.Elem(), f.Index), g.id(tag.Name))
+		case "attr":
+			if !tag.HasOption("readonly") {
+				settableField(model.Elem(), f.Index).Set(g.attribute(f.Type, tag))
+			}
+		case "relation":
+			if !related || tag.HasOption("mapkey") || tag.HasOption("nolinkage") {
+				continue
+			}
+			if tag.HasOption("ids") {
+				g.relationIDs(settableField(model.Elem(), f.Index), tag)
+				continue
+			}
+			g.relation(settableField(model.Elem(), f.Index))
+		}
+	}
+
+	return model
+}
+
+// settableField returns the field of the struct v at index, allocating the nil
+// embedded pointers it is promoted through.
+func settableField(v reflect.Value, index []int) reflect.Value {
+	for n, i := range index {
+		if n > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// relation sets random related models in the relation field, a pointer to a
+// model or a slice of them.
+func (g *generator) relation(field reflect.Value) {
+	t := field.Type()
+	switch {
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct:
+		if g.r.Intn(4) > 0 {
+			field.Set(g.model(t.Elem(), false))
+		}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Ptr &&
+		t.Elem().Elem().Kind() == reflect.Struct:
+		models := reflect.MakeSlice(t, 0, 3)
+		for n := g.r.Intn(4); n > 0; n-- {
+			models = reflect.Append(models, g.model(t.Elem().Elem(), false))
+		}
+		field.Set(models)
+	}
+}
+
+// relationIDs sets random ids in the field of a relation with the ids option,
+// an id or a slice of them.
+func (g *generator) relationIDs(field reflect.Value, tag *jsonapi.Tag) {
+	if field.Kind() != reflect.Slice {
+		setID(field, g.id(tag.Name))
+		return
+	}
+
+	ids := reflect.MakeSlice(field.Type(), 0, 3)
+	for n := g.r.Intn(4); n > 0; n-- {
+		id := reflect.New(field.Type().Elem()).Elem()
+		setID(id, g.id(tag.Name))
+		ids = reflect.Append(ids, id)
+	}
+	field.Set(ids)
+}
+
+// setID sets the string or numeric field v, or the value it points to, to id.
+func setID(v reflect.Value, id string) {
+	for v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(id)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, _ := strconv.ParseInt(id, 10, 64)
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, _ := strconv.ParseUint(id, 10, 64)
+		v.SetUint(n)
+	}
+}
+
+// attribute returns a random value of type t for the attribute tagged tag.
+func (g *generator) attribute(t reflect.Type, tag *jsonapi.Tag) reflect.Value {
+	if t == reflect.TypeOf(time.Time{}) {
+		return reflect.ValueOf(g.time(tag.HasOption("date")))
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Ptr:
+		if g.r.Intn(4) > 0 {
+			v.Set(reflect.New(t.Elem()))
+			v.Elem().Set(g.attribute(t.Elem(), tag))
+		}
+	case reflect.Slice:
+		// the elements of time slices are in the layout of the attribute
+		if g.r.Intn(4) > 0 {
+			v.Set(reflect.MakeSlice(t, 0, 3))
+			for n := g.r.Intn(4); n > 0; n-- {
+				v.Set(reflect.Append(v, g.attribute(t.Elem(), tag)))
+			}
+		}
+	default:
+		return g.value(t)
+	}
+	return v
+}
+
+// time returns a random time to the second, or a random day if date is set.
+func (g *generator) time(date bool) time.Time {
+	t := time.Unix(g.r.Int63n(4e9), 0).UTC()
+	if date {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t
+}
+
+// value returns a random value of type t, a member of an attribute, encoded
+// with encoding/json.
+func (g *generator) value(t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.String:
+		s := make([]rune, g.r.Intn(9))
+		for i := range s {
+			s[i] = runes[g.r.Intn(len(runes))]
+		}
+		v.SetString(string(s))
+	case reflect.Bool:
+		v.SetBool(g.r.Intn(2) == 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// JSON numbers are decoded as float64, exact up to 2^53
+		n := g.r.Int63n(1<<32) - 1<<31
+		if v.OverflowInt(n) {
+			n %= 128
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := uint64(g.r.Int63n(1 << 32))
+		if v.OverflowUint(n) {
+			n %= 256
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(g.r.NormFloat64() * 1000)
+	case reflect.Ptr:
+		if g.r.Intn(4) > 0 {
+			v.Set(reflect.New(t.Elem()))
+			v.Elem().Set(g.value(t.Elem()))
+		}
+	case reflect.Slice:
+		if g.r.Intn(4) > 0 {
+			v.Set(reflect.MakeSlice(t, 0, 3))
+			for n := g.r.Intn(4); n > 0; n-- {
+				v.Set(reflect.Append(v, g.value(t.Elem())))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			v.Index(i).Set(g.value(t.Elem()))
+		}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String && g.r.Intn(4) > 0 {
+			v.Set(reflect.MakeMap(t))
+			for n := g.r.Intn(3); n > 0; n-- {
+				v.SetMapIndex(g.value(t.Key()), g.value(t.Elem()))
+			}
+		}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(g.time(false)))
+			break
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" && f.Tag.Get("json") != "-" {
+				v.Field(i).Set(g.value(f.Type))
+			}
+		}
+	}
+
+	return v
+}
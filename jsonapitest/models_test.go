@@ -0,0 +1,65 @@
+package jsonapitest
+
+import "time"
+
+// The models cover the tags of attributes and relations, one per field.
+
+type Library struct {
+	ID        string             `jsonapi:"primary,libraries"`
+	Name      string             `jsonapi:"attr,name"`
+	Motto     *string            `jsonapi:"attr,motto"`
+	Nickname  string             `jsonapi:"attr,nickname,omitempty"`
+	Floors    int8               `jsonapi:"attr,floors"`
+	Visitors  uint64             `jsonapi:"attr,visitors"`
+	Rating    float32            `jsonapi:"attr,rating"`
+	Budget    *float64           `jsonapi:"attr,budget,omitempty"`
+	Open      bool               `jsonapi:"attr,open"`
+	Tags      []string           `jsonapi:"attr,tags"`
+	Hours     map[string][]int   `jsonapi:"attr,hours"`
+	Address   Address            `jsonapi:"attr,address"`
+	Branches  []*Address         `jsonapi:"attr,branches"`
+	Founded   time.Time          `jsonapi:"attr,founded"`
+	Renovated *time.Time         `jsonapi:"attr,renovated,iso8601"`
+	Opened    time.Time          `jsonapi:"attr,opened,date"`
+	Closures  []time.Time        `jsonapi:"attr,closures,date"`
+	Events    []*time.Time       `jsonapi:"attr,events,iso8601"`
+	Stock     map[string]float64 `jsonapi:"attr,stock,omitempty"`
+	Shelves   []*Shelf           `jsonapi:"relation,shelves"`
+	Librarian *Librarian         `jsonapi:"relation,librarian"`
+	Archive   *Shelf             `jsonapi:"relation,archive,omitempty"`
+	MemberIDs []string           `jsonapi:"relation,members,ids:members"`
+	CityID    int                `jsonapi:"relation,city,ids:cities"`
+}
+
+type Address struct {
+	Street string     `json:"street"`
+	Zip    *int       `json:"zip"`
+	Geo    [2]float64 `json:"geo"`
+	Since  time.Time  `json:"since"`
+	Secret string     `json:"-"`
+}
+
+type Shelf struct {
+	ID       int     `jsonapi:"primary,shelves"`
+	Label    string  `jsonapi:"attr,label"`
+	Capacity *uint16 `jsonapi:"attr,capacity"`
+}
+
+// Card has the attributes of an embedded struct promoted
+type Card struct {
+	*Stamp
+	ID     int    `jsonapi:"primary,cards"`
+	Holder string `jsonapi:"attr,holder"`
+}
+
+type Stamp struct {
+	Issued time.Time `jsonapi:"attr,issued,iso8601"`
+}
+
+type Librarian struct {
+	ID        uint       `jsonapi:"primary,librarians"`
+	Name      string     `jsonapi:"attr,name"`
+	Hired     *time.Time `jsonapi:"attr,hired,date,omitempty"`
+	Shelves   []*Shelf   `jsonapi:"relation,shelves"`
+	Assistant *Librarian `jsonapi:"relation,assistant"`
+}
@@ -0,0 +1,75 @@
+package jsonapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/google/jsonapi"
+)
+
+// RoundTripError is returned by RoundTrip when marshaling the model
+// unmarshaled from a document doesn't give the same document.
+type RoundTripError struct {
+	Type reflect.Type
+
+	// Payload is the document unmarshaled, Result the document marshaled.
+	Payload, Result []byte
+}
+
+// Error implements the `Error` interface.
+func (e *RoundTripError) Error() string {
+	return fmt.Sprintf("jsonapitest: the round trip of %s changed the document\n%s\nto\n%s",
+		e.Type, e.Payload, e.Result)
+}
+
+// RoundTrip unmarshals payload into a new instance of modelType, marshals it
+// back and compares both documents, member by member. It returns the error of
+// either step, or a *RoundTripError if the documents differ.
+func RoundTrip(payload []byte, modelType reflect.Type) error {
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+
+	model := reflect.New(modelType).Interface()
+	if err := jsonapi.UnmarshalOneBytes(payload, model); err != nil {
+		return err
+	}
+
+	out := new(bytes.Buffer)
+	if err := jsonapi.MarshalOnePayload(out, model); err != nil {
+		return err
+	}
+
+	var expected, actual interface{}
+	if err := json.Unmarshal(payload, &expected); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out.Bytes(), &actual); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		return &RoundTripError{Type: modelType, Payload: payload, Result: out.Bytes()}
+	}
+	return nil
+}
+
+// CheckRoundTrip runs RoundTrip on the ArbitraryPayload of modelType drawn
+// from the seeds 0 to n-1, failing tb with the seed of the first document
+// that doesn't survive it.
+func CheckRoundTrip(tb testing.TB, modelType reflect.Type, n int) {
+	tb.Helper()
+
+	for seed := int64(0); seed < int64(n); seed++ {
+		payload, err := ArbitraryPayload(modelType, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			tb.Fatalf("seed %d: %v", seed, err)
+		}
+		if err := RoundTrip(payload, modelType); err != nil {
+			tb.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
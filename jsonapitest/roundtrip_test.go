@@ -0,0 +1,61 @@
+package jsonapitest
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, model := range []interface{}{Library{}, new(Shelf), new(Librarian), new(Card)} {
+		CheckRoundTrip(t, reflect.TypeOf(model), 200)
+	}
+}
+
+func TestArbitrary(t *testing.T) {
+	a := Arbitrary(reflect.TypeOf(Library{}), rand.New(rand.NewSource(1)))
+	b := Arbitrary(reflect.TypeOf(new(Library)), rand.New(rand.NewSource(1)))
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("Was expecting the same model for the same seed")
+	}
+
+	library, ok := a.(*Library)
+	if !ok {
+		t.Fatalf("Was expecting a *Library, got %T", a)
+	}
+	if library.ID == "" {
+		t.Fatal("Was expecting an id")
+	}
+
+	ids := make(map[int]bool)
+	for _, shelf := range append(library.Shelves, library.Archive) {
+		if shelf == nil {
+			continue
+		}
+		if ids[shelf.ID] {
+			t.Fatalf("Was expecting unique shelf ids, got %d twice", shelf.ID)
+		}
+		ids[shelf.ID] = true
+	}
+	if library.Librarian != nil && library.Librarian.Shelves != nil {
+		t.Fatal("Was expecting the related models to have no relations")
+	}
+
+	card := Arbitrary(reflect.TypeOf(Card{}), rand.New(rand.NewSource(1))).(*Card)
+	if card.Stamp == nil || card.Issued.IsZero() {
+		t.Fatalf("Was expecting the promoted attributes to be set, got %+v", card)
+	}
+}
+
+func TestRoundTripError(t *testing.T) {
+	payload := []byte(`{"data":{"type":"shelves","id":"1","attributes":{"label":"A","capacity":null,"extra":true}}}`)
+
+	err := RoundTrip(payload, reflect.TypeOf(Shelf{}))
+	rtErr, ok := err.(*RoundTripError)
+	if !ok {
+		t.Fatalf("Was expecting a *RoundTripError, got %v", err)
+	}
+	if e, a := reflect.TypeOf(Shelf{}), rtErr.Type; e != a {
+		t.Fatalf("Was expecting type %v, got %v", e, a)
+	}
+}
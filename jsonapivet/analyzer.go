@@ -0,0 +1,251 @@
+/*
+Package jsonapivet provides an analyzer reporting the problems of the jsonapi
+struct tags of a package, as CheckModel does at run time, for go vet:
+
+	go install github.com/google/jsonapi/jsonapivet/cmd/jsonapivet
+	go vet -vettool=$(which jsonapivet) ./...
+
+It reports malformed tags and unknown options, structs with jsonapi tags but
+no primary annotated field, other than those embedded in models, relations holding structs rather than pointers to
+them, relations to structs without a primary annotated field, omitempty on
+time attributes, where it has no effect, and attributes of types that can't be
+encoded.
+*/
+package jsonapivet
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strconv"
+
+	"github.com/google/jsonapi"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports the problems of the jsonapi struct tags of a package.
+var Analyzer = &analysis.Analyzer{
+	Name: "jsonapitags",
+	Doc:  "check the jsonapi struct tags of models",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	embedded := embeddedStructs(pass)
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if s, ok := n.(*ast.StructType); ok {
+				checkStruct(pass, s, embedded)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// embeddedStructs returns the structs embedded in the structs of the package,
+// such as a group of attributes shared by models, which need no primary
+// annotated field of their own.
+func embeddedStructs(pass *analysis.Pass) map[*types.Struct]bool {
+	embedded := make(map[*types.Struct]bool)
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			s, ok := n.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, field := range s.Fields.List {
+				if len(field.Names) > 0 {
+					continue
+				}
+				if t := pass.TypesInfo.TypeOf(field.Type); t != nil {
+					if st, ok := deref(t).Underlying().(*types.Struct); ok {
+						embedded[st] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+	return embedded
+}
+
+// checkStruct reports the problems of the jsonapi tags of the fields of s.
+// The structs in embedded are not required to have a primary annotated field.
+func checkStruct(pass *analysis.Pass, s *ast.StructType, embedded map[*types.Struct]bool) {
+	var tagged, primary bool
+
+	for _, field := range s.Fields.List {
+		tag, ok := jsonapiTag(field)
+		if !ok {
+			continue
+		}
+		tagged = true
+
+		parsed, err := jsonapi.ParseTag(tag)
+		if tagErr, ok := err.(*jsonapi.TagError); ok {
+			pass.Reportf(field.Tag.Pos(), "jsonapi tag %q: %s", tag, tagErr.Problem)
+		}
+		if parsed == nil {
+			continue
+		}
+
+		t := pass.TypesInfo.TypeOf(field.Type)
+		if t == nil {
+			continue
+		}
+
+		switch parsed.Annotation {
+		case "primary":
+			primary = true
+		case "attr":
+			checkAttribute(pass, field, parsed, t)
+		case "relation":
+			if !parsed.HasOption("ids") {
+				checkRelation(pass, field, t)
+			}
+		}
+	}
+
+	if tagged && !primary {
+		// the primary field may be promoted from an embedded struct
+		if st, ok := pass.TypesInfo.TypeOf(s).(*types.Struct); ok && (embedded[st] || hasPrimary(st)) {
+			return
+		}
+		pass.Reportf(s.Pos(), "struct with jsonapi tags has no primary annotated field")
+	}
+}
+
+// checkAttribute reports the problems of an attribute field of type t.
+func checkAttribute(pass *analysis.Pass, field *ast.Field, tag *jsonapi.Tag, t types.Type) {
+	if tag.HasOption("omitempty") && isTime(t) {
+		pass.Reportf(field.Tag.Pos(), "omitempty has no effect on a time.Time attribute, zero times are always left out")
+	}
+
+	if kind, ok := unsupportedType(t); ok {
+		pass.Reportf(field.Type.Pos(), "attribute of type %s: %s values can't be encoded", t, kind)
+	}
+}
+
+// checkRelation reports the problems of a relation field of type t.
+func checkRelation(pass *analysis.Pass, field *ast.Field, t types.Type) {
+	base := deref(t)
+
+	var target types.Type
+	switch c := base.Underlying().(type) {
+	case *types.Slice:
+		target = c.Elem()
+	case *types.Map:
+		target = c.Elem()
+	default:
+		if _, ok := t.Underlying().(*types.Struct); ok {
+			pass.Reportf(field.Type.Pos(), "relation of type %s should be a pointer, *%s", t, t)
+			return
+		}
+		target = base
+	}
+	if base != target {
+		if _, ok := target.Underlying().(*types.Struct); ok {
+			pass.Reportf(field.Type.Pos(), "relation of type %s should hold pointers, *%s", t, target)
+			return
+		}
+		target = deref(target)
+	}
+
+	named, ok := target.(*types.Named)
+	if !ok {
+		return
+	}
+	s, ok := named.Underlying().(*types.Struct)
+	if !ok || hasPrimary(s) || isNodeMarshaler(named) {
+		return
+	}
+	pass.Reportf(field.Type.Pos(), "relation to %s, which has no primary annotated field", named)
+}
+
+// deref follows the pointers of t, however many levels.
+func deref(t types.Type) types.Type {
+	for {
+		p, ok := t.Underlying().(*types.Pointer)
+		if !ok {
+			return t
+		}
+		t = p.Elem()
+	}
+}
+
+// jsonapiTag returns the jsonapi tag of field, if any.
+func jsonapiTag(field *ast.Field) (string, bool) {
+	if field.Tag == nil {
+		return "", false
+	}
+	tags, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+	tag := reflect.StructTag(tags).Get("jsonapi")
+	return tag, tag != ""
+}
+
+// hasPrimary reports whether s has a primary annotated field, its own or
+// promoted from an embedded struct.
+func hasPrimary(s *types.Struct) bool {
+	return hasPromotedPrimary(s, make(map[*types.Struct]bool))
+}
+
+func hasPromotedPrimary(s *types.Struct, seen map[*types.Struct]bool) bool {
+	if seen[s] {
+		return false
+	}
+	seen[s] = true
+
+	for i := 0; i < s.NumFields(); i++ {
+		tag, err := jsonapi.ParseTag(reflect.StructTag(s.Tag(i)).Get("jsonapi"))
+		if err == nil && tag.Annotation == "primary" {
+			return true
+		}
+		if !s.Field(i).Embedded() {
+			continue
+		}
+		if e, ok := deref(s.Field(i).Type()).Underlying().(*types.Struct); ok && hasPromotedPrimary(e, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNodeMarshaler reports whether pointers to named marshal themselves.
+func isNodeMarshaler(named *types.Named) bool {
+	methods := types.NewMethodSet(types.NewPointer(named))
+	return methods.Lookup(nil, "MarshalJSONAPINode") != nil
+}
+
+func isTime(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Time"
+}
+
+// unsupportedType returns the kind of the values held by t, however deeply,
+// that encoding/json can't encode, if any.
+func unsupportedType(t types.Type) (string, bool) {
+	switch u := t.Underlying().(type) {
+	case *types.Chan:
+		return "chan", true
+	case *types.Signature:
+		return "func", true
+	case *types.Basic:
+		if u.Info()&types.IsComplex != 0 || u.Kind() == types.UnsafePointer {
+			return u.Name(), true
+		}
+	case *types.Pointer:
+		return unsupportedType(u.Elem())
+	case *types.Slice:
+		return unsupportedType(u.Elem())
+	case *types.Array:
+		return unsupportedType(u.Elem())
+	case *types.Map:
+		return unsupportedType(u.Elem())
+	}
+	return "", false
+}
@@ -0,0 +1,106 @@
+package jsonapivet
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const models = `package models
+
+import "time"
+
+type Blog struct {
+	ID        int        ` + "`jsonapi:\"primary,blogs\"`" + `
+	Title     string     ` + "`jsonapi:\"attr,title,omitempty,sorted\"`" + `
+	CreatedAt time.Time  ` + "`jsonapi:\"attr,created_at,omitempty\"`" + `
+	Posts     []Post     ` + "`jsonapi:\"relation,posts\"`" + `
+	Featured  Post       ` + "`jsonapi:\"relation,featured\"`" + `
+	Authors   []*Author  ` + "`jsonapi:\"relation,authors\"`" + `
+	Editor    *Post      ` + "`jsonapi:\"relation,editor\"`" + `
+	PostIDs   []int      ` + "`jsonapi:\"relation,post_ids,ids:posts\"`" + `
+	Notify    chan int   ` + "`jsonapi:\"attr,notify\"`" + `
+	Color     string     ` + "`jsonapi:\"colour,color\"`" + `
+	Links     string     ` + "`jsonapi:\"links,self\"`" + `
+}
+
+type Post struct {
+	ID int ` + "`jsonapi:\"primary,posts\"`" + `
+}
+
+type Author struct {
+	Name string ` + "`jsonapi:\"attr,name\"`" + `
+}
+
+type Timestamps struct {
+	CreatedAt time.Time ` + "`jsonapi:\"attr,created_at\"`" + `
+}
+
+type Base struct {
+	ID string ` + "`jsonapi:\"primary,comments\"`" + `
+}
+
+type Comment struct {
+	Base
+	*Timestamps
+	Body string ` + "`jsonapi:\"attr,body\"`" + `
+}
+
+type Reply struct {
+	ID     int      ` + "`jsonapi:\"primary,replies\"`" + `
+	Parent *Comment ` + "`jsonapi:\"relation,parent\"`" + `
+}
+`
+
+func TestAnalyzer(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "models.go", models, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("models", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var diagnostics []string
+	pass := &analysis.Pass{
+		Analyzer:  Analyzer,
+		Fset:      fset,
+		Files:     []*ast.File{file},
+		Pkg:       pkg,
+		TypesInfo: info,
+		Report: func(d analysis.Diagnostic) {
+			diagnostics = append(diagnostics, d.Message)
+		},
+	}
+	if _, err := Analyzer.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(diagnostics)
+
+	expected := []string{
+		`attribute of type chan int: chan values can't be encoded`,
+		`jsonapi tag "attr,title,omitempty,sorted": unknown option "sorted"`,
+		`jsonapi tag "colour,color": unknown annotation "colour"`,
+		`jsonapi tag "links,self": "links" takes no name`,
+		`omitempty has no effect on a time.Time attribute, zero times are always left out`,
+		`relation of type []models.Post should hold pointers, *models.Post`,
+		`relation of type models.Post should be a pointer, *models.Post`,
+		`relation to models.Author, which has no primary annotated field`,
+		`struct with jsonapi tags has no primary annotated field`,
+	}
+	if e, a := strings.Join(expected, "\n"), strings.Join(diagnostics, "\n"); e != a {
+		t.Fatalf("Was expecting the diagnostics\n%s\ngot\n%s", e, a)
+	}
+}
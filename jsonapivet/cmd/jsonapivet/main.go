@@ -0,0 +1,14 @@
+// Command jsonapivet checks the jsonapi struct tags of packages, run by go
+// vet:
+//
+//	go vet -vettool=$(which jsonapivet) ./...
+package main
+
+import (
+	"github.com/google/jsonapi/jsonapivet"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(jsonapivet.Analyzer)
+}
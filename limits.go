@@ -0,0 +1,87 @@
+package jsonapi
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	// ErrNestingTooDeep is returned when unmarshaling, with WithMaxNesting, a
+	// payload whose objects and arrays are nested too deeply.
+	ErrNestingTooDeep = errors.New("The payload exceeds the maximum nesting depth")
+
+	// ErrRelationshipTooDeep is returned when unmarshaling, with
+	// WithMaxRelationshipDepth, a payload whose relationships resolve to
+	// included resources too many levels away from the primary data.
+	ErrRelationshipTooDeep = errors.New("The payload exceeds the maximum relationship depth")
+
+	// ErrPayloadTooLarge is returned when unmarshaling, with WithMaxSize, a
+	// payload larger than the maximum size.
+	ErrPayloadTooLarge = errors.New("The payload exceeds the maximum size")
+)
+
+// nestingLimitReader reads a JSON document from r, failing with
+// ErrNestingTooDeep as soon as its objects and arrays are nested more than max
+// levels deep, before the decoder recurses into them.
+type nestingLimitReader struct {
+	r   io.Reader
+	max int
+
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+func (l *nestingLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i, b := range p[:n] {
+		if l.inString {
+			switch {
+			case l.escaped:
+				l.escaped = false
+			case b == '\\':
+				l.escaped = true
+			case b == '"':
+				l.inString = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			l.inString = true
+		case '{', '[':
+			l.depth++
+			if l.depth > l.max {
+				return i, ErrNestingTooDeep
+			}
+		case '}', ']':
+			l.depth--
+		}
+	}
+	return n, err
+}
+
+// sizeLimitReader reads from r, failing with ErrPayloadTooLarge once more than
+// remaining bytes have been read.
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrPayloadTooLarge
+	}
+	// read one byte more than allowed, to tell a payload of exactly the
+	// maximum size from a larger one
+	if len(p) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= n
+	if l.remaining < 0 {
+		return n + l.remaining, ErrPayloadTooLarge
+	}
+	return n, err
+}
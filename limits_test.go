@@ -0,0 +1,70 @@
+package jsonapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalWithMaxNesting(t *testing.T) {
+	data := `{"data": {"type": "blogs", "id": "5", "attributes": {"title": "Title 1"},
+		"meta": {"deep": ` + strings.Repeat("[", 100) + strings.Repeat("]", 100) + `}}}`
+
+	if err := UnmarshalPayload(strings.NewReader(data), new(Blog), WithMaxNesting(32)); err != ErrNestingTooDeep {
+		t.Fatalf("Was expecting ErrNestingTooDeep, got %v", err)
+	}
+
+	// brackets within strings don't count
+	data = `{"data": {"type": "blogs", "id": "5", "attributes": {"title": "` +
+		strings.Repeat("[", 100) + `\"{"}}}`
+	blog := new(Blog)
+	if err := UnmarshalPayload(strings.NewReader(data), blog, WithMaxNesting(4)); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := strings.Repeat("[", 100)+`"{`, blog.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalWithMaxSize(t *testing.T) {
+	data := `{"data": {"type": "blogs", "id": "5", "attributes": {"title": "Title 1"}}}`
+
+	if err := UnmarshalPayload(strings.NewReader(data), new(Blog), WithMaxSize(len(data)-1)); err != ErrPayloadTooLarge {
+		t.Fatalf("Was expecting ErrPayloadTooLarge, got %v", err)
+	}
+	if err := UnmarshalOneBytes([]byte(data), new(Blog), WithMaxSize(len(data)-1)); err != ErrPayloadTooLarge {
+		t.Fatalf("Was expecting ErrPayloadTooLarge, got %v", err)
+	}
+
+	// a payload of exactly the maximum size is read
+	blog := new(Blog)
+	if err := UnmarshalPayload(strings.NewReader(data), blog, WithMaxSize(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "Title 1", blog.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalWithMaxRelationshipDepth(t *testing.T) {
+	data := `{
+		"data": {"type": "blogs", "id": "5",
+			"relationships": {"current_post": {"data": {"type": "posts", "id": "1"}}}},
+		"included": [
+			{"type": "posts", "id": "1", "attributes": {"title": "Foo"},
+			 "relationships": {"latest_comment": {"data": {"type": "comments", "id": "1"}}}},
+			{"type": "comments", "id": "1", "attributes": {"body": "foo"}}
+		]
+	}`
+
+	if err := UnmarshalPayload(strings.NewReader(data), new(Blog), WithMaxRelationshipDepth(1)); err != ErrRelationshipTooDeep {
+		t.Fatalf("Was expecting ErrRelationshipTooDeep, got %v", err)
+	}
+
+	blog := new(Blog)
+	if err := UnmarshalPayload(strings.NewReader(data), blog, WithMaxRelationshipDepth(2)); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "foo", blog.CurrentPost.LatestComment.Body; e != a {
+		t.Fatalf("Was expecting body %q, got %q", e, a)
+	}
+}
@@ -0,0 +1,109 @@
+package jsonapi
+
+import (
+	"net/url"
+	"strings"
+)
+
+// LinkResolver derives the links of the resources and relationships of a
+// document from URL templates, so that the models don't each implement
+// Linkable and RelationshipLinkable for the same URL scheme, e.g.
+//
+//	jsonapi.MarshalOnePayload(w, post, jsonapi.WithLinkResolver(&jsonapi.LinkResolver{
+//		BaseURL:      "https://example.com/api",
+//		Collection:   "/{type}",
+//		Self:         "/{type}/{id}",
+//		Relationship: "/{type}/{id}/relationships/{rel}",
+//		Related:      "/{type}/{id}/{rel}",
+//	}))
+//
+// In the templates, {type} is replaced by the rendered resource type, {id} by
+// the id of the resource and {rel} by the rendered name of the relationship,
+// each escaped as a path segment. The links of a template left empty are not
+// rendered.
+type LinkResolver struct {
+	// BaseURL is prepended to the links; without it, the BaseURL of the
+	// Config applies as it does to relative links.
+	BaseURL string
+
+	// Collection is the top-level self link of a many payload.
+	Collection string
+
+	// Self is the self link of each resource, and the top-level self link of
+	// a one payload.
+	Self string
+
+	// Relationship and Related are the self and related links of each
+	// relationship.
+	Relationship string
+	Related      string
+}
+
+// expand returns the href of template for the resource of type resourceType
+// and id, and for its relationship rel, if any.
+func (r *LinkResolver) expand(template, resourceType, id, rel string) string {
+	href := strings.NewReplacer(
+		"{type}", url.PathEscape(resourceType),
+		"{id}", url.PathEscape(id),
+		"{rel}", url.PathEscape(rel),
+	).Replace(template)
+	return strings.TrimSuffix(r.BaseURL, "/") + href
+}
+
+// WithLinkResolver renders the links derived by r for the resources and
+// relationships that have none of their own, from Linkable, a links annotated
+// field, RelationshipLinkable and the like, and the top-level self link. The
+// links given with WithLinks, and those of a pagination, take precedence over
+// the top-level self link. No links are derived for create payloads, whose
+// resources have no id yet.
+func WithLinkResolver(r *LinkResolver) MarshalOption {
+	return func(c *marshalConfig) {
+		c.linkResolver = r
+	}
+}
+
+// resolvedResourceLinks returns the self link of the LinkResolver, if any, for
+// the resource of the tagged type resourceType and id.
+func (c *marshalConfig) resolvedResourceLinks(resourceType, id string) *Links {
+	r := c.linkResolver
+	if r == nil || r.Self == "" || id == "" || c.create {
+		return nil
+	}
+	return c.defaults.resolveLinks(&Links{
+		"self": r.expand(r.Self, c.renderedType(resourceType), id, ""),
+	})
+}
+
+// resolvedRelationshipLinks returns the self and related links of the
+// LinkResolver, if any, for the relationship rel of the resource of the
+// tagged type resourceType and id.
+func (c *marshalConfig) resolvedRelationshipLinks(resourceType, id, rel string) *Links {
+	r := c.linkResolver
+	if r == nil || id == "" || c.create {
+		return nil
+	}
+
+	links := Links{}
+	if r.Relationship != "" {
+		links["self"] = r.expand(r.Relationship, c.renderedType(resourceType), id, rel)
+	}
+	if r.Related != "" {
+		links["related"] = r.expand(r.Related, c.renderedType(resourceType), id, rel)
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	return c.defaults.resolveLinks(&links)
+}
+
+// resolvedCollectionLinks returns the self link of the LinkResolver, if any,
+// for a collection of resources of the tagged type resourceType.
+func (c *marshalConfig) resolvedCollectionLinks(resourceType string) *Links {
+	r := c.linkResolver
+	if r == nil || r.Collection == "" || resourceType == "" {
+		return nil
+	}
+	return c.defaults.resolveLinks(&Links{
+		"self": r.expand(r.Collection, c.renderedType(resourceType), "", ""),
+	})
+}
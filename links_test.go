@@ -0,0 +1,129 @@
+package jsonapi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var testLinkResolver = &LinkResolver{
+	BaseURL:      "https://example.com/api/",
+	Collection:   "/{type}",
+	Self:         "/{type}/{id}",
+	Relationship: "/{type}/{id}/relationships/{rel}",
+	Related:      "/{type}/{id}/{rel}",
+}
+
+func TestLinkResolver(t *testing.T) {
+	person := &Person{ID: 1, Name: "Ann", Pets: []*Pet{{ID: 2, Name: "Rex"}}}
+
+	payload, err := MarshalOne(person, WithLinkResolver(testLinkResolver),
+		WithTypeNames(map[string]string{"people": "persons"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := payload.Links, (&Links{"self": "https://example.com/api/persons/1"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Was expecting top-level links %v, got %v", want, got)
+	}
+	if got, want := payload.Data.Links, (&Links{"self": "https://example.com/api/persons/1"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Was expecting resource links %v, got %v", want, got)
+	}
+
+	pets := payload.Data.Relationships["pets"].(*RelationshipManyNode)
+	want := &Links{
+		"self":    "https://example.com/api/persons/1/relationships/pets",
+		"related": "https://example.com/api/persons/1/pets",
+	}
+	if !reflect.DeepEqual(pets.Links, want) {
+		t.Fatalf("Was expecting relationship links %v, got %v", want, pets.Links)
+	}
+
+	if len(payload.Included) != 1 {
+		t.Fatalf("Was expecting 1 included record, got %d", len(payload.Included))
+	}
+	pet := payload.Included[0]
+	if got, want := pet.Links, (&Links{"self": "https://example.com/api/pets/2"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Was expecting included links %v, got %v", want, got)
+	}
+	owner := pet.Relationships["owner"].(*RelationshipOneNode)
+	if got := (*owner.Links)["related"]; got != "https://example.com/api/pets/2/owner" {
+		t.Fatalf("Was expecting the related link of the owner, got %v", got)
+	}
+}
+
+func TestLinkResolverPrecedence(t *testing.T) {
+	blog := &Blog{ID: 5, Posts: []*Post{}}
+
+	payload, err := MarshalOne(blog, WithLinkResolver(testLinkResolver), WithInclude())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Linkable and RelationshipLinkable take precedence
+	if got := (*payload.Data.Links)["self"]; got != "https://example.com/api/blogs/5" {
+		t.Fatalf("Was expecting the self link of Linkable, got %v", got)
+	}
+	if _, ok := (*payload.Data.Links)["comments"]; !ok {
+		t.Fatal("Was expecting the links of Linkable")
+	}
+	posts := payload.Data.Relationships["posts"].(*RelationshipManyNode)
+	if _, ok := (*posts.Links)["self"]; ok {
+		t.Fatal("Was expecting the links of RelationshipLinkable only")
+	}
+
+	// WithLinks takes precedence over the top-level self link
+	payload, err = MarshalOne(&Person{ID: 1}, WithLinkResolver(testLinkResolver),
+		WithLinks(&Links{"self": "/me", "describedby": "/schema"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Links{"self": "/me", "describedby": "/schema"}
+	if !reflect.DeepEqual(payload.Links, want) {
+		t.Fatalf("Was expecting top-level links %v, got %v", want, payload.Links)
+	}
+}
+
+func TestLinkResolverMany(t *testing.T) {
+	people := []interface{}{&Person{ID: 1}, &Person{ID: 2}}
+
+	payload, err := MarshalMany(people, WithLinkResolver(testLinkResolver))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := payload.Links, (&Links{"self": "https://example.com/api/people"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Was expecting top-level links %v, got %v", want, got)
+	}
+	if got := (*payload.Data[1].Links)["self"]; got != "https://example.com/api/people/2" {
+		t.Fatalf("Was expecting the self link of the resource, got %v", got)
+	}
+
+	// the streamed document is the same
+	marshaled := bytes.NewBuffer(nil)
+	if err := MarshalManyPayload(marshaled, people, WithLinkResolver(testLinkResolver)); err != nil {
+		t.Fatal(err)
+	}
+	streamed := bytes.NewBuffer(nil)
+	enc := NewManyPayloadEncoder(streamed, WithLinkResolver(testLinkResolver))
+	for _, p := range people {
+		if err := enc.Encode(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if marshaled.String() != streamed.String() {
+		t.Fatalf("Was expecting %s, got %s", marshaled, streamed)
+	}
+}
+
+func TestLinkResolverCreatePayload(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalCreatePayload(out, &Person{Name: "Ann"}, WithLinkResolver(testLinkResolver)); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out.Bytes(), []byte("links")) {
+		t.Fatalf("Was expecting no links for a new resource, got %s", out)
+	}
+}
@@ -0,0 +1,48 @@
+package jsonapi
+
+import "context"
+
+// AnomalyKind identifies a non-fatal decision made while shaping a payload.
+type AnomalyKind string
+
+// The anomalies reported to a Logger.
+const (
+	// AnomalyUnknownAttribute is an attribute of the payload without a
+	// matching attr annotated field in the model; it was skipped.
+	AnomalyUnknownAttribute AnomalyKind = "unknown_attribute"
+
+	// AnomalyZeroTimeDropped is a zero time.Time attribute that was left out
+	// of the payload.
+	AnomalyZeroTimeDropped AnomalyKind = "zero_time_dropped"
+
+	// AnomalyIncludeTruncated is a relationship whose related records were not
+	// sideloaded because of the max depth, include paths or include func.
+	AnomalyIncludeTruncated AnomalyKind = "include_truncated"
+
+	// AnomalyAttributeDropped is an attribute of the payload that is not
+	// allowed by AllowAttributes; it was dropped.
+	AnomalyAttributeDropped AnomalyKind = "attribute_dropped"
+
+	// AnomalyAttributeCoerced is an attribute of the payload that was
+	// converted to the type of its field by WithCoercion.
+	AnomalyAttributeCoerced AnomalyKind = "attribute_coerced"
+)
+
+// Anomaly describes a single non-fatal decision made while marshaling or
+// unmarshaling.
+type Anomaly struct {
+	Kind AnomalyKind
+
+	// ResourceType and ResourceID identify the resource concerned.
+	ResourceType string
+	ResourceID   string
+
+	// Field is the name of the attribute or relationship concerned.
+	Field string
+}
+
+// Logger receives the anomalies met while marshaling or unmarshaling, so that
+// otherwise silent data shaping decisions can be observed in production.
+type Logger interface {
+	LogAnomaly(ctx context.Context, a Anomaly)
+}
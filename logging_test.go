@@ -0,0 +1,76 @@
+package jsonapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type recordingLogger struct {
+	anomalies []Anomaly
+}
+
+func (l *recordingLogger) LogAnomaly(ctx context.Context, a Anomaly) {
+	l.anomalies = append(l.anomalies, a)
+}
+
+func TestMarshalLoggerZeroTime(t *testing.T) {
+	logger := new(recordingLogger)
+
+	if _, err := MarshalOne(&Blog{ID: 1}, WithLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Anomaly{
+		Kind:         AnomalyZeroTimeDropped,
+		ResourceType: "blogs",
+		ResourceID:   "1",
+		Field:        "created_at",
+	}
+	if len(logger.anomalies) != 1 || logger.anomalies[0] != expected {
+		t.Fatalf("Was expecting %v, got %v", expected, logger.anomalies)
+	}
+}
+
+func TestMarshalLoggerIncludeTruncated(t *testing.T) {
+	logger := new(recordingLogger)
+
+	if _, err := MarshalOne(testBlog(), WithInclude("posts"), WithLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := map[string]bool{}
+	for _, a := range logger.anomalies {
+		if a.Kind == AnomalyIncludeTruncated {
+			truncated[a.ResourceType+"."+a.Field] = true
+		}
+	}
+
+	for _, e := range []string{"blogs.current_post", "posts.comments", "posts.latest_comment"} {
+		if !truncated[e] {
+			t.Fatalf("Was expecting %s to be reported as truncated, got %v", e, truncated)
+		}
+	}
+	if truncated["blogs.posts"] {
+		t.Fatal("Was not expecting the included posts to be reported as truncated")
+	}
+}
+
+func TestUnmarshalLoggerUnknownAttribute(t *testing.T) {
+	logger := new(recordingLogger)
+	data := `{"data": {"type": "comments", "id": "1", "attributes": {"body": "Hi", "mood": "happy"}}}`
+
+	if err := UnmarshalPayload(strings.NewReader(data), new(Comment), WithUnmarshalLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Anomaly{
+		Kind:         AnomalyUnknownAttribute,
+		ResourceType: "comments",
+		ResourceID:   "1",
+		Field:        "mood",
+	}
+	if len(logger.anomalies) != 1 || logger.anomalies[0] != expected {
+		t.Fatalf("Was expecting %v, got %v", expected, logger.anomalies)
+	}
+}
@@ -1,7 +1,10 @@
 package jsonapi
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -155,3 +158,661 @@ func (bc *BadComment) JSONAPILinks() *Links {
 		"self": []string{"invalid", "should error"},
 	}
 }
+
+type Category struct {
+	ID       int         `jsonapi:"primary,categories"`
+	Name     string      `jsonapi:"attr,name"`
+	Parent   *Category   `jsonapi:"relation,parent,omitempty"`
+	Children []*Category `jsonapi:"relation,children,omitempty"`
+}
+
+type Person struct {
+	ID         int     `jsonapi:"primary,people"`
+	Name       string  `jsonapi:"attr,name"`
+	BestFriend *Person `jsonapi:"relation,best_friend,omitempty"`
+	Pets       []*Pet  `jsonapi:"relation,pets"`
+}
+
+type Pet struct {
+	ID    int     `jsonapi:"primary,pets"`
+	Name  string  `jsonapi:"attr,name"`
+	Owner *Person `jsonapi:"relation,owner"`
+}
+
+type Article struct {
+	ID    int    `jsonapi:"primary,articles"`
+	Title string `jsonapi:"attr,title"`
+	Links *Links `jsonapi:"links"`
+}
+
+type ArticlePermissions struct {
+	CanEdit   bool `json:"can_edit"`
+	CanDelete bool `json:"can_delete"`
+}
+
+type Document struct {
+	ID          int                 `jsonapi:"primary,documents"`
+	Title       string              `jsonapi:"attr,title"`
+	Permissions *ArticlePermissions `jsonapi:"meta"`
+}
+
+type Score struct {
+	ID   int   `jsonapi:"primary,scores"`
+	Meta *Meta `jsonapi:"meta"`
+}
+
+// Subscription is rendered as a tombstone once cancelled
+type Subscription struct {
+	ID        int      `jsonapi:"primary,subscriptions"`
+	Plan      string   `jsonapi:"attr,plan"`
+	Account   *Account `jsonapi:"relation,account"`
+	Cancelled bool
+	Meta      *Meta `jsonapi:"meta"`
+}
+
+func (s *Subscription) JSONAPIMetaOnly() bool {
+	return s.Cancelled
+}
+
+func (s *Subscription) JSONAPILinks() *Links {
+	return &Links{"self": fmt.Sprintf("/subscriptions/%d", s.ID)}
+}
+
+// Team holds the role of each of its members
+type Team struct {
+	ID          int       `jsonapi:"primary,teams"`
+	Members     []*Person `jsonapi:"relation,members"`
+	MemberMeta  []*Meta   `jsonapi:"linkage-meta,members"`
+	Captain     *Person   `jsonapi:"relation,captain"`
+	CaptainMeta *Meta     `jsonapi:"linkage-meta,captain"`
+}
+
+// Library links to, and counts, the books it holds
+type Library struct {
+	ID       int     `jsonapi:"primary,libraries"`
+	Books    []*Book `jsonapi:"relation,books"`
+	Featured *Book   `jsonapi:"relation,featured"`
+}
+
+func (l *Library) JSONAPIRelatedLinks(relation string, related interface{}) *Links {
+	if book, ok := related.(*Book); ok && book != nil {
+		return &Links{"related": fmt.Sprintf("/books/%d", book.ID)}
+	}
+	return nil
+}
+
+func (l *Library) JSONAPIRelatedMeta(relation string, related interface{}) *Meta {
+	if books, ok := related.([]*Book); ok {
+		return &Meta{"count": len(books)}
+	}
+	return nil
+}
+
+// JSONAPIRelationshipMeta is ignored in favour of JSONAPIRelatedMeta
+func (l *Library) JSONAPIRelationshipMeta(relation string) *Meta {
+	return &Meta{"ignored": true}
+}
+
+// Tenant links are relative to the host of the request
+type Tenant struct {
+	ID    int     `jsonapi:"primary,tenants"`
+	Owner *Person `jsonapi:"relation,owner"`
+}
+
+func (t *Tenant) JSONAPILinksWithContext(ctx context.Context) *Links {
+	return &Links{"self": fmt.Sprintf("https://%s/tenants/%d", ctx.Value(ctxKey("host")), t.ID)}
+}
+
+func (t *Tenant) JSONAPIRelationshipLinksWithContext(ctx context.Context, relation string) *Links {
+	return &Links{"related": fmt.Sprintf("https://%s/tenants/%d/%s", ctx.Value(ctxKey("host")), t.ID, relation)}
+}
+
+func (t *Tenant) JSONAPIMetaWithContext(ctx context.Context) *Meta {
+	return &Meta{"host": ctx.Value(ctxKey("host"))}
+}
+
+func (t *Tenant) JSONAPIRelationshipMetaWithContext(ctx context.Context, relation string) *Meta {
+	return &Meta{"host": ctx.Value(ctxKey("host"))}
+}
+
+// Workspace meta depends on its members and the host of the request
+type Workspace struct {
+	ID      int       `jsonapi:"primary,workspaces"`
+	Members []*Person `jsonapi:"relation,members"`
+}
+
+func (w *Workspace) JSONAPIRelatedMetaWithContext(ctx context.Context, relation string, related interface{}) *Meta {
+	return &Meta{"host": ctx.Value(ctxKey("host")), "count": len(related.([]*Person))}
+}
+
+// JSONAPIRelatedMeta is ignored in favour of JSONAPIRelatedMetaWithContext
+func (w *Workspace) JSONAPIRelatedMeta(relation string, related interface{}) *Meta {
+	return &Meta{"ignored": true}
+}
+
+// Playlist holds its tracks by value
+type Playlist struct {
+	ID     int     `jsonapi:"primary,playlists"`
+	Tracks []Track `jsonapi:"relation,tracks"`
+}
+
+func (p Playlist) JSONAPIMeta() *Meta {
+	return &Meta{"tracks": len(p.Tracks)}
+}
+
+type Track struct {
+	ID    int    `jsonapi:"primary,tracks"`
+	Title string `jsonapi:"attr,title"`
+}
+
+func (t *Track) JSONAPILinks() *Links {
+	return &Links{"self": fmt.Sprintf("/tracks/%d", t.ID)}
+}
+
+// Product has a fixed name and dynamic properties
+type Product struct {
+	ID         int    `jsonapi:"primary,products"`
+	Name       string `jsonapi:"attr,name"`
+	Properties map[string]interface{}
+}
+
+func (p *Product) JSONAPIAttributes() map[string]interface{} {
+	return p.Properties
+}
+
+func (p *Product) SetJSONAPIAttributes(attributes map[string]interface{}) error {
+	p.Properties = attributes
+	return nil
+}
+
+// Coordinate renders itself as a single "lat,lng" attribute
+type Coordinate struct {
+	ID       string
+	Lat, Lng float64
+}
+
+func (c *Coordinate) MarshalJSONAPINode() (*Node, error) {
+	return &Node{
+		Type:       "coordinates",
+		ID:         c.ID,
+		Attributes: map[string]interface{}{"position": fmt.Sprintf("%g,%g", c.Lat, c.Lng)},
+	}, nil
+}
+
+func (c *Coordinate) UnmarshalJSONAPINode(node *Node) error {
+	position, _ := node.Attributes["position"].(string)
+	if _, err := fmt.Sscanf(position, "%g,%g", &c.Lat, &c.Lng); err != nil {
+		return err
+	}
+	c.ID = node.ID
+	return nil
+}
+
+// Trip relates to a custom marshaled model
+type Trip struct {
+	ID          int         `jsonapi:"primary,trips"`
+	Destination *Coordinate `jsonapi:"relation,destination"`
+}
+
+// Album sideloads its artist, but not its tracks, by default
+type Album struct {
+	ID     int      `jsonapi:"primary,albums"`
+	Artist *Person  `jsonapi:"relation,artist"`
+	Tracks []*Track `jsonapi:"relation,tracks"`
+}
+
+func (a *Album) JSONAPIDefaultIncludes() []string {
+	return []string{"artist"}
+}
+
+// Draft is created by clients, which assign its local id
+type Draft struct {
+	ID        int       `jsonapi:"primary,drafts"`
+	LocalID   string    `jsonapi:"lid"`
+	ClientID  string    `jsonapi:"client-id"`
+	Title     string    `jsonapi:"attr,title"`
+	CreatedAt time.Time `jsonapi:"attr,created_at,readonly"`
+	Author    *Person   `jsonapi:"relation,author"`
+	Tags      []*Draft  `jsonapi:"relation,tags,omitempty"`
+}
+
+// Thread holds its primary and relationships through extra pointers
+type Thread struct {
+	ID       **int       `jsonapi:"primary,threads"`
+	Title    string      `jsonapi:"attr,title"`
+	Comments *[]*Comment `jsonapi:"relation,comments"`
+	Pinned   **Comment   `jsonapi:"relation,pinned"`
+}
+
+// Roster holds its players by position
+type Roster struct {
+	ID      int                `jsonapi:"primary,rosters"`
+	Players map[string]*Person `jsonapi:"relation,players,mapkey:position"`
+	Coaches map[string]*Person `jsonapi:"relation,coaches"`
+}
+
+// Leaderboard orders its players by name
+type Leaderboard struct {
+	ID      int       `jsonapi:"primary,leaderboards"`
+	Players []*Person `jsonapi:"relation,players"`
+}
+
+func (l *Leaderboard) JSONAPILessRelated(relation string, a, b interface{}) bool {
+	return a.(*Person).Name < b.(*Person).Name
+}
+
+// Period is zero when it has no start
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (p Period) IsZero() bool {
+	return p.Start.IsZero()
+}
+
+type Booking struct {
+	ID       int      `jsonapi:"primary,bookings"`
+	Period   Period   `jsonapi:"attr,period,omitzero"`
+	Tags     []string `jsonapi:"attr,tags,omitzero"`
+	Guests   int      `jsonapi:"attr,guests,omitzero"`
+	Location *string  `jsonapi:"attr,location,omitzero"`
+
+	Notes  []string          `jsonapi:"attr,notes,omitempty"`
+	Labels map[string]string `jsonapi:"attr,labels,omitempty"`
+}
+
+// Notification holds payloads of several registered types
+type Notification struct {
+	ID      int         `jsonapi:"primary,notifications"`
+	Payload interface{} `jsonapi:"attr,payload,discriminator:kind"`
+	Extra   interface{} `jsonapi:"attr,extra,omitempty"`
+}
+
+type EmailPayload struct {
+	To string `json:"to"`
+}
+
+type SMSPayload struct {
+	Number string `json:"number"`
+}
+
+// Money is empty only when it has no currency; zero USD is a price
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) JSONAPIIsEmpty() bool {
+	return m.Currency == ""
+}
+
+type Quote struct {
+	ID       int    `jsonapi:"primary,quotes"`
+	Price    Money  `jsonapi:"attr,price,omitempty"`
+	Discount *Money `jsonapi:"attr,discount,omitempty"`
+}
+
+// Listing has members kept for older clients
+type Listing struct {
+	ID       int     `jsonapi:"primary,listings"`
+	Title    string  `jsonapi:"attr,title"`
+	Headline string  `jsonapi:"attr,headline,deprecated"`
+	Agent    *Person `jsonapi:"relation,agent,deprecated"`
+}
+
+// Assignment tells which relationships a payload mentions
+type Assignment struct {
+	ID               int       `jsonapi:"primary,assignments"`
+	Assignee         *Person   `jsonapi:"relation,assignee"`
+	Reviewers        []*Person `jsonapi:"relation,reviewers"`
+	AssigneePresent  bool      `jsonapi:"relation-present,assignee"`
+	ReviewersPresent bool      `jsonapi:"relation-present,reviewers"`
+}
+
+// Course and its lessons point at each other once unmarshaled
+// WithInverseRelationships
+type Course struct {
+	ID      int       `jsonapi:"primary,courses"`
+	Lessons []*Lesson `jsonapi:"relation,lessons,inverse:course"`
+}
+
+type Lesson struct {
+	ID     int     `jsonapi:"primary,lessons"`
+	Title  string  `jsonapi:"attr,title"`
+	Course *Course `jsonapi:"relation,course,inverse:lessons"`
+}
+
+// Reservation checks its party size once unmarshaled
+type Reservation struct {
+	ID    int    `jsonapi:"primary,reservations"`
+	Name  string `jsonapi:"attr,name"`
+	Party int    `jsonapi:"attr,party"`
+}
+
+func (r *Reservation) ValidateJSONAPI() []*ErrorObject {
+	var errs []*ErrorObject
+	if r.Name == "" {
+		errs = append(errs, &ErrorObject{Title: "Invalid name", Detail: "name is required", Status: "422"})
+	}
+	if r.Party < 1 {
+		errs = append(errs, &ErrorObject{Title: "Invalid party", Detail: "party must be positive", Status: "422"})
+	}
+	return errs
+}
+
+type Review struct {
+	ID         int      `jsonapi:"primary,reviews"`
+	Body       string   `jsonapi:"attr,body"`
+	AuthorID   string   `jsonapi:"relation,author,ids:people"`
+	BookID     *uint64  `jsonapi:"relation,book,ids:books,omitempty"`
+	CommentIDs []int    `jsonapi:"relation,comments,ids:comments"`
+	TagIDs     []string `jsonapi:"relation,tags,ids"`
+}
+
+// LazyPost loads its comments on demand when marshaled
+type LazyPost struct {
+	ID       int        `jsonapi:"primary,posts"`
+	Title    string     `jsonapi:"attr,title"`
+	Comments []*Comment `jsonapi:"relation,comments,omitempty"`
+	Author   *Person    `jsonapi:"relation,author,omitempty"`
+
+	loaded []string
+}
+
+func (p *LazyPost) LoadJSONAPIRelationship(ctx context.Context, relation string) (interface{}, error) {
+	p.loaded = append(p.loaded, relation)
+
+	switch relation {
+	case "comments":
+		return []*Comment{{ID: 1, Body: "foo"}, {ID: 2, Body: "bar"}}, nil
+	case "author":
+		return &Person{ID: 1, Name: ctx.Value(ctxKey("author")).(string)}, nil
+	}
+	return nil, nil
+}
+
+type ctxKey string
+
+// Account normalizes and validates itself around (un)marshaling
+type Account struct {
+	ID          int    `jsonapi:"primary,accounts"`
+	Email       string `jsonapi:"attr,email"`
+	DisplayName string `jsonapi:"attr,display_name"`
+}
+
+var errAccountEmail = errors.New("account email is required")
+
+func (a *Account) BeforeJSONAPIMarshal(ctx context.Context) error {
+	if a.Email == "" {
+		return errAccountEmail
+	}
+	if a.DisplayName == "" {
+		a.DisplayName = strings.SplitN(a.Email, "@", 2)[0]
+	}
+	return nil
+}
+
+func (a *Account) AfterJSONAPIUnmarshal(ctx context.Context) error {
+	if a.Email == "" {
+		return errAccountEmail
+	}
+	a.Email = strings.ToLower(a.Email)
+	return nil
+}
+
+// Warehouse has attributes nesting structs in maps and slices
+type Warehouse struct {
+	ID       int                               `jsonapi:"primary,warehouses"`
+	Aisles   map[string][]StockItem            `jsonapi:"attr,aisles"`
+	Shelves  []map[string]StockItem            `jsonapi:"attr,shelves"`
+	Zones    map[string]map[string][]StockItem `jsonapi:"attr,zones"`
+	Pallets  [][]*StockItem                    `jsonapi:"attr,pallets"`
+	Featured StockItem                         `jsonapi:"attr,featured"`
+	Counters map[string][]int                  `jsonapi:"attr,counters"`
+}
+
+type StockItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"qty"`
+}
+
+// Contact has attributes pointing to nested structs
+type Contact struct {
+	ID       int                    `jsonapi:"primary,contacts"`
+	Home     *Address               `jsonapi:"attr,home"`
+	Previous *[]Address             `jsonapi:"attr,previous,omitempty"`
+	ByLabel  map[string]*Address    `jsonapi:"attr,by_label"`
+	Settings map[string]interface{} `jsonapi:"attr,settings"`
+}
+
+type Address struct {
+	Street  string    `json:"street"`
+	Since   time.Time `json:"since"`
+	Note    string    `json:"note,omitempty"`
+	Billing *Address  `json:"billing,omitempty"`
+}
+
+type Timetable struct {
+	ID         int         `jsonapi:"primary,timetables"`
+	Departures []time.Time `jsonapi:"attr,departures"`
+	Arrivals   []time.Time `jsonapi:"attr,arrivals,iso8601"`
+}
+
+// ColumnUpdate holds the columns of a bulk update, where null cells are left
+// untouched
+type ColumnUpdate struct {
+	ID        int          `jsonapi:"primary,column-updates"`
+	Names     []*string    `jsonapi:"attr,names"`
+	Scores    []*int       `jsonapi:"attr,scores"`
+	CheckedAt []*time.Time `jsonapi:"attr,checked_at,iso8601"`
+}
+
+type Patient struct {
+	ID         int        `jsonapi:"primary,patients"`
+	BirthDate  time.Time  `jsonapi:"attr,birth_date,date"`
+	NextVisit  *time.Time `jsonapi:"attr,next_visit,date,omitempty"`
+	AdmittedAt time.Time  `jsonapi:"attr,admitted_at,iso8601"`
+}
+
+// Shipment has the times of an external API, each in its own format
+type Shipment struct {
+	ID          int         `jsonapi:"primary,shipments"`
+	ShippedAt   time.Time   `jsonapi:"attr,shipped_at,rfc3339"`
+	DeliveredAt *time.Time  `jsonapi:"attr,delivered_at,unixmilli"`
+	Scans       []time.Time `jsonapi:"attr,scans,format:02/01/2006 15:04"`
+}
+
+// Letter is stamped in a named layout, which holds a comma
+type Letter struct {
+	ID      int       `jsonapi:"primary,letters"`
+	Stamped time.Time `jsonapi:"attr,stamped,format:rfc1123"`
+}
+
+// Parcel has a layout cut by its comma
+type Parcel struct {
+	ID       int       `jsonapi:"primary,parcels"`
+	Received time.Time `jsonapi:"attr,received,format:Mon, 02 Jan 2006,omitempty"`
+}
+
+// Invoice relates to a LineItem, which lacks a primary annotation
+type Invoice struct {
+	ID    int         `jsonapi:"primary,invoices"`
+	Lines []*LineItem `jsonapi:"relation,lines"`
+}
+
+type LineItem struct {
+	Label string `jsonapi:"attr,label"`
+}
+
+// Misfit has a problem in each of its jsonapi tags
+type Misfit struct {
+	ID       float64           `jsonapi:"primary,misfits"`
+	Key      string            `jsonapi:"primary,misfits"`
+	Name     string            `jsonapi:"attr,name,omitempty,sorted"`
+	Label    string            `jsonapi:"attr,name"`
+	Type     string            `jsonapi:"attr,type"`
+	Callback func()            `jsonapi:"attr,callback"`
+	Links    string            `jsonapi:"links"`
+	Owner    string            `jsonapi:"relation,owner"`
+	Invoice  *Invoice          `jsonapi:"relation,invoice"`
+	Color    string            `jsonapi:"colour,color"`
+	Present  string            `jsonapi:"relation-present,owner"`
+	Extra    []interface{}     `jsonapi:"attr"`
+	Squad    map[string]Person `jsonapi:"relation,squad"`
+}
+
+// Bench holds its players by value, which can't be unmarshaled
+type Bench struct {
+	ID      int               `jsonapi:"primary,benches"`
+	Players map[string]Person `jsonapi:"relation,players"`
+}
+
+// Topic renders some of its fields as members of its meta, and of the meta
+// of its relationships
+type Topic struct {
+	ID           int        `jsonapi:"primary,topics"`
+	Title        string     `jsonapi:"attr,title"`
+	Comments     []*Comment `jsonapi:"relation,comments"`
+	Views        int        `jsonapi:"meta,views"`
+	Pinned       bool       `jsonapi:"meta,pinned,omitempty"`
+	CommentCount int        `jsonapi:"meta,count,relation:comments"`
+}
+
+// Member has attributes rendered only for the scopes granted to the call
+type Member struct {
+	ID    int    `jsonapi:"primary,members"`
+	Name  string `jsonapi:"attr,name"`
+	Email string `jsonapi:"attr,email,scope:admin"`
+	Notes string `jsonapi:"attr,notes,scope:admin,scope:support"`
+}
+
+// Forum exposes the count of its threads without their linkage
+type Forum struct {
+	ID          int     `jsonapi:"primary,forums"`
+	Name        string  `jsonapi:"attr,name"`
+	Threads     []*Post `jsonapi:"relation,threads,nolinkage"`
+	Archive     []*Post `jsonapi:"relation,archive,nolinkage"`
+	ThreadCount int
+}
+
+func (f *Forum) JSONAPIRelationshipMeta(relation string) *Meta {
+	if relation == "threads" {
+		return &Meta{"count": f.ThreadCount}
+	}
+	return nil
+}
+
+// Gauge has its numbers and booleans encoded as strings
+type Gauge struct {
+	ID       int      `jsonapi:"primary,gauges"`
+	Reading  float64  `jsonapi:"attr,reading,string"`
+	Count    uint16   `jsonapi:"attr,count,string"`
+	Offset   *int64   `jsonapi:"attr,offset,string"`
+	Limit    *int     `jsonapi:"attr,limit,string"`
+	Enabled  bool     `jsonapi:"attr,enabled,string"`
+	Label    string   `jsonapi:"attr,label,string"`
+	Readings []string `jsonapi:"attr,readings,string"`
+}
+
+// Proxied keeps the attributes and relationships it doesn't declare
+type Proxied struct {
+	ID        int                    `jsonapi:"primary,proxied"`
+	Name      string                 `jsonapi:"attr,name"`
+	Owner     *Person                `jsonapi:"relation,owner"`
+	Extras    map[string]interface{} `jsonapi:"extras"`
+	Relations map[string]interface{} `jsonapi:"extra-relationships"`
+}
+
+// Decimal renders itself as a string, e.g. "12.50"
+type Decimal struct {
+	Cents int64
+}
+
+func (d Decimal) MarshalJSONAPIAttribute() (interface{}, error) {
+	return fmt.Sprintf("%d.%02d", d.Cents/100, d.Cents%100), nil
+}
+
+func (d *Decimal) UnmarshalJSONAPIAttribute(value interface{}) error {
+	s, ok := value.(string)
+	if !ok {
+		return ErrInvalidType
+	}
+	var units, cents int64
+	if _, err := fmt.Sscanf(s, "%d.%02d", &units, &cents); err != nil {
+		return err
+	}
+	d.Cents = units*100 + cents
+	return nil
+}
+
+// Severity is rendered by its name through encoding.TextMarshaler
+type Severity int
+
+var severities = []string{"low", "high"}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(severities[s]), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severities {
+		if name == string(text) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+type Ticket struct {
+	ID       int       `jsonapi:"primary,tickets"`
+	Price    Decimal   `jsonapi:"attr,price"`
+	Refund   *Decimal  `jsonapi:"attr,refund,omitempty"`
+	Severity Severity  `jsonapi:"attr,severity"`
+	Escalate *Severity `jsonapi:"attr,escalate"`
+}
+
+// Named is implemented by the models that can be the actor of an Activity
+type Named interface {
+	DisplayName() string
+}
+
+func (p *Person) DisplayName() string {
+	return p.Name
+}
+
+type Activity struct {
+	ID      int           `jsonapi:"primary,activities"`
+	Actor   Named         `jsonapi:"relation,actor"`
+	Subject interface{}   `jsonapi:"relation,subject"`
+	Targets []interface{} `jsonapi:"relation,targets"`
+}
+
+// Timestamps is embedded by the models that are timestamped
+type Timestamps struct {
+	CreatedAt time.Time `jsonapi:"attr,created_at,iso8601"`
+	UpdatedAt time.Time `jsonapi:"attr,updated_at,iso8601"`
+}
+
+type Byline struct {
+	Author string `jsonapi:"attr,author"`
+	Title  string `jsonapi:"attr,title"`
+}
+
+// Audit is embedded with a bad tag
+type Audit struct {
+	CreatedBy string `jsonapi:"attr,created_by,bogus"`
+}
+
+type Audited struct {
+	Audit
+	ID int `jsonapi:"primary,audited"`
+}
+
+type Story struct {
+	Timestamps
+	*Byline
+	ID    int    `jsonapi:"primary,stories"`
+	Title string `jsonapi:"attr,title"`
+}
@@ -1,23 +1,39 @@
 package jsonapi
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
 
 // OnePayload is used to represent a generic JSON API payload where a single
 // resource (Node) was included as an {} in the "data" key
 type OnePayload struct {
-	Data     *Node   `json:"data"`
-	Included []*Node `json:"included,omitempty"`
-	Links    *Links  `json:"links,omitempty"`
-	Meta     *Meta   `json:"meta,omitempty"`
+	Data     *Node          `json:"data"`
+	Included []*Node        `json:"included,omitempty"`
+	Links    *Links         `json:"links,omitempty"`
+	Meta     *Meta          `json:"meta,omitempty"`
+	JSONAPI  *JSONAPIObject `json:"jsonapi,omitempty"`
 }
 
 // ManyPayload is used to represent a generic JSON API payload where many
 // resources (Nodes) were included in an [] in the "data" key
 type ManyPayload struct {
-	Data     []*Node `json:"data"`
-	Included []*Node `json:"included,omitempty"`
-	Links    *Links  `json:"links,omitempty"`
-	Meta     *Meta   `json:"meta,omitempty"`
+	Data     []*Node        `json:"data"`
+	Included []*Node        `json:"included,omitempty"`
+	Links    *Links         `json:"links,omitempty"`
+	Meta     *Meta          `json:"meta,omitempty"`
+	JSONAPI  *JSONAPIObject `json:"jsonapi,omitempty"`
+}
+
+// JSONAPIObject is the top-level "jsonapi" member of a document, describing
+// the implementation: the version of the specification and, from version
+// 1.1, the URIs of the extensions and profiles applied to the document.
+type JSONAPIObject struct {
+	Version string   `json:"version,omitempty"`
+	Ext     []string `json:"ext,omitempty"`
+	Profile []string `json:"profile,omitempty"`
+	Meta    *Meta    `json:"meta,omitempty"`
 }
 
 // Node is used to represent a generic JSON API Resource
@@ -25,10 +41,49 @@ type Node struct {
 	Type          string                 `json:"type"`
 	ID            string                 `json:"id"`
 	ClientID      string                 `json:"client-id,omitempty"`
+	LocalID       string                 `json:"lid,omitempty"`
 	Attributes    map[string]interface{} `json:"attributes,omitempty"`
 	Relationships map[string]interface{} `json:"relationships,omitempty"`
 	Links         *Links                 `json:"links,omitempty"`
 	Meta          *Meta                  `json:"meta,omitempty"`
+
+	// emptyMembers renders the attributes and relationships as {} when they
+	// are empty but not nil, see Config.EmitEmptyMembers.
+	emptyMembers bool
+
+	// omitEmptyID leaves out an empty id, as for a resource created by the
+	// client, see MarshalCreatePayload.
+	omitEmptyID bool
+}
+
+// MarshalJSON renders the Node as its struct tags describe, unless the
+// Marshal call that built it asked for empty members or the omission of an
+// empty id.
+func (n Node) MarshalJSON() ([]byte, error) {
+	type node Node
+
+	if !n.emptyMembers && !n.omitEmptyID {
+		return json.Marshal(node(n))
+	}
+
+	out := struct {
+		node
+		ID            *string                 `json:"id,omitempty"`
+		Attributes    *map[string]interface{} `json:"attributes,omitempty"`
+		Relationships *map[string]interface{} `json:"relationships,omitempty"`
+	}{node: node(n)}
+
+	if n.ID != "" || !n.omitEmptyID {
+		out.ID = &n.ID
+	}
+	if len(n.Attributes) > 0 || (n.emptyMembers && n.Attributes != nil) {
+		out.Attributes = &n.Attributes
+	}
+	if len(n.Relationships) > 0 || (n.emptyMembers && n.Relationships != nil) {
+		out.Relationships = &n.Relationships
+	}
+
+	return json.Marshal(out)
 }
 
 // RelationshipOneNode is used to represent a generic has one JSON API relation
@@ -46,6 +101,13 @@ type RelationshipManyNode struct {
 	Meta  *Meta   `json:"meta,omitempty"`
 }
 
+// RelationshipLinksNode is used to represent a relationship rendered without
+// resource linkage, with only its links and meta
+type RelationshipLinksNode struct {
+	Links *Links `json:"links,omitempty"`
+	Meta  *Meta  `json:"meta,omitempty"`
+}
+
 // Links is used to represent a `links` object.
 // http://jsonapi.org/format/#document-links
 type Links map[string]interface{}
@@ -58,11 +120,16 @@ func (l *Links) validate() (err error) {
 	//    - href: a string containing the link’s URL.
 	//    - meta: a meta object containing non-standard meta-information about the
 	//            link.
+	//  - null, from version 1.1, if the link doesn't exist, e.g. the next page
+	//    of the last one.
+	// Link objects decoded from a payload, e.g. into a links annotated field,
+	// are held as maps.
 	for k, v := range *l {
 		_, isString := v.(string)
 		_, isLink := v.(Link)
+		_, isLinkPtr := v.(*Link)
 
-		if !(isString || isLink) {
+		if !(isString || isLink || isLinkPtr || isLinkObject(v) || v == nil) {
 			return fmt.Errorf(
 				"The %s member of the links object was not a string or link object",
 				k,
@@ -72,6 +139,48 @@ func (l *Links) validate() (err error) {
 	return
 }
 
+// isLinkObject reports whether v is a link object decoded from a payload: a
+// map with a string href and, if any, an object meta.
+func isLinkObject(v interface{}) bool {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := obj["href"].(string); !ok {
+		return false
+	}
+	if meta, ok := obj["meta"]; ok && meta != nil {
+		if _, ok := meta.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Href returns the URL of the key member of the links object, whether it is
+// a string or a link object. An empty string is returned when the member is
+// absent, e.g. there is no "next" link on the last page.
+func (l *Links) Href(key string) string {
+	if l == nil {
+		return ""
+	}
+
+	switch v := (*l)[key].(type) {
+	case string:
+		return v
+	case Link:
+		return v.Href
+	case *Link:
+		return v.Href
+	case map[string]interface{}:
+		// a link object decoded from a payload
+		href, _ := v["href"].(string)
+		return href
+	}
+
+	return ""
+}
+
 // Link is used to represent a member of the `links` object.
 type Link struct {
 	Href string `json:"href"`
@@ -91,6 +200,28 @@ type RelationshipLinkable interface {
 	JSONAPIRelationshipLinks(relation string) *Links
 }
 
+// LinkableWithContext is used, in place of Linkable, by models whose links
+// depend on the request, e.g. its host or tenant. ctx is the context given by
+// WithContext or to the Context variants of the Marshal functions.
+type LinkableWithContext interface {
+	JSONAPILinksWithContext(ctx context.Context) *Links
+}
+
+// RelationshipLinkableWithContext is used, in place of RelationshipLinkable,
+// by models whose relationship links depend on the request.
+type RelationshipLinkableWithContext interface {
+	JSONAPIRelationshipLinksWithContext(ctx context.Context, relation string) *Links
+}
+
+// RelatedLinkable is used, in place of RelationshipLinkable, by models whose
+// relationship links depend on the related records.
+type RelatedLinkable interface {
+	// JSONAPIRelatedLinks will be invoked for each relationship with the
+	// corresponding relation name and the value of the relation field: a
+	// pointer to the related struct, or a slice of them.
+	JSONAPIRelatedLinks(relation string, related interface{}) *Links
+}
+
 // Meta is used to represent a `meta` object.
 // http://jsonapi.org/format/#document-meta
 type Meta map[string]interface{}
@@ -106,3 +237,141 @@ type RelationshipMetable interface {
 	// JSONRelationshipMeta will be invoked for each relationship with the corresponding relation name (e.g. `comments`)
 	JSONAPIRelationshipMeta(relation string) *Meta
 }
+
+// RelatedMetable is used, in place of RelationshipMetable, by models whose
+// relationship meta depends on the related records, e.g. their count.
+type RelatedMetable interface {
+	// JSONAPIRelatedMeta will be invoked for each relationship with the
+	// corresponding relation name and the value of the relation field.
+	JSONAPIRelatedMeta(relation string, related interface{}) *Meta
+}
+
+// MetableWithContext is used, in place of Metable, by models whose meta
+// depends on the request, e.g. the permissions of the authenticated user. ctx
+// is the context given by WithContext or to the Context variants of the
+// Marshal functions.
+type MetableWithContext interface {
+	JSONAPIMetaWithContext(ctx context.Context) *Meta
+}
+
+// RelationshipMetableWithContext is used, in place of RelationshipMetable, by
+// models whose relationship meta depends on the request.
+type RelationshipMetableWithContext interface {
+	JSONAPIRelationshipMetaWithContext(ctx context.Context, relation string) *Meta
+}
+
+// RelatedMetableWithContext is used, in place of RelatedMetable, by models
+// whose relationship meta depends on the related records and the request.
+type RelatedMetableWithContext interface {
+	JSONAPIRelatedMetaWithContext(ctx context.Context, relation string, related interface{}) *Meta
+}
+
+// Attributer is implemented by models with dynamic or computed attributes,
+// e.g. entity-attribute-value records. The returned attributes are rendered
+// along with, and take precedence over, the attr annotated fields.
+type Attributer interface {
+	JSONAPIAttributes() map[string]interface{}
+}
+
+// AttributeSetter is the unmarshaling counterpart of Attributer. It receives
+// the attributes of the payload that are not bound to an attr annotated field,
+// which may be empty.
+type AttributeSetter interface {
+	SetJSONAPIAttributes(attributes map[string]interface{}) error
+}
+
+// NodeMarshaler is implemented by models that build their own Node rather
+// than relying on their jsonapi tags, e.g. for shapes the tags can't express.
+// The Node is rendered as returned; the relationships of the model are not
+// traversed, so its related records are not sideloaded. A model with jsonapi
+// tags too is rendered from its tags instead when the options or the Config
+// rewrite its members or links, e.g. WithFields or a BaseURL.
+type NodeMarshaler interface {
+	MarshalJSONAPINode() (*Node, error)
+}
+
+// RelatedMarshaler is implemented, along with NodeMarshaler, by models whose
+// related records are sideloaded, e.g. by the methods generated by jsonapigen.
+// JSONAPIRelated is invoked for each relationship with linkage of the Node
+// returned by MarshalJSONAPINode that is on an include path, and returns the
+// related model, a slice of them, or nil.
+type RelatedMarshaler interface {
+	JSONAPIRelated(relation string) interface{}
+}
+
+// NodeUnmarshaler is implemented by models that bind themselves from their
+// Node rather than relying on their jsonapi tags, e.g. to coerce attributes or
+// handle several versions of a payload.
+type NodeUnmarshaler interface {
+	UnmarshalJSONAPINode(node *Node) error
+}
+
+// MetaOnly is implemented by models that may be rendered as meta-only
+// resource objects, holding just their type, id and meta, e.g. tombstones of
+// deleted records or records the caller may not see the details of. When
+// JSONAPIMetaOnly returns true the attributes, relationships and links of the
+// model are left out, and its related records are not sideloaded.
+type MetaOnly interface {
+	JSONAPIMetaOnly() bool
+}
+
+// DefaultIncluder is implemented by models that declare the relationship
+// paths sideloaded when they are the primary data and WithInclude isn't used,
+// e.g. because the request has no "include" parameter. Returning no paths
+// sideloads nothing.
+type DefaultIncluder interface {
+	JSONAPIDefaultIncludes() []string
+}
+
+// Emptier is implemented by attribute types that decide whether they are
+// empty, and so left out of the attributes when tagged omitempty, rather than
+// being compared with their zero value, e.g. a Money type whose zero amount is
+// still a meaningful value.
+type Emptier interface {
+	JSONAPIIsEmpty() bool
+}
+
+// RelationshipSorter is implemented by models that decide the order of the
+// members of their to-many relationships when marshaling with
+// WithSortedRelationships. a and b are members of the relation field, e.g. two
+// *Comment.
+type RelationshipSorter interface {
+	JSONAPILessRelated(relation string, a, b interface{}) bool
+}
+
+// RelationshipLoader is used to load related records on demand while
+// marshaling, rather than preloading the whole object graph. It is invoked
+// only for the relationships that will be traversed, i.e. those selected by
+// WithInclude (all of them by default) that are not "noinclude" or beyond
+// WithMaxDepth.
+//
+// The returned value is marshaled in place of the relation field: a pointer to
+// a struct for to-one relations, or a slice of them for to-many relations.
+// Returning nil marshals the field as it is.
+type RelationshipLoader interface {
+	LoadJSONAPIRelationship(ctx context.Context, relation string) (interface{}, error)
+}
+
+// BeforeMarshaler is implemented by models that need to prepare themselves,
+// e.g. compute derived fields, before they are marshaled. It is invoked for the
+// primary data and every related model that is traversed; an error aborts the
+// marshaling.
+type BeforeMarshaler interface {
+	BeforeJSONAPIMarshal(ctx context.Context) error
+}
+
+// AfterUnmarshaler is implemented by models that need to normalize themselves
+// or check their invariants once they are unmarshaled. It is invoked for the
+// primary data and every related model bound from the payload; an error is
+// returned by the Unmarshal function.
+type AfterUnmarshaler interface {
+	AfterJSONAPIUnmarshal(ctx context.Context) error
+}
+
+// Validator is implemented by models that check their semantic rules once they
+// are unmarshaled, after AfterUnmarshaler. When it reports errors, the
+// Unmarshal function returns them as a *ValidationError, ready to be written
+// with MarshalErrors.
+type Validator interface {
+	ValidateJSONAPI() []*ErrorObject
+}
@@ -0,0 +1,895 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// MarshalOption configures a single call to one of the Marshal functions,
+// e.g.
+//
+//	jsonapi.MarshalOnePayload(w, blog, jsonapi.WithMaxDepth(2))
+type MarshalOption func(*marshalConfig)
+
+// marshalConfig holds the settings applied by MarshalOptions.
+type marshalConfig struct {
+	ctx context.Context
+
+	// defaults holds the Config applied to the call.
+	defaults Config
+
+	// maxDepth is the number of relationship levels that are traversed and
+	// sideloaded; 0 means unlimited.
+	maxDepth int
+
+	// include holds every relationship path, and each of its prefixes, that
+	// should be traversed. A nil include traverses every relationship.
+	include map[string]bool
+
+	// includeFunc decides whether each related record is sideloaded.
+	includeFunc IncludeFunc
+
+	// attributeTransformer rewrites or drops attribute values.
+	attributeTransformer AttributeTransformer
+
+	// fieldPolicy decides which attributes and relationships are rendered.
+	fieldPolicy FieldPolicy
+
+	// scopes holds the scopes granted with WithScopes.
+	scopes map[string]bool
+
+	// fields holds the sparse fieldsets, the names of the attributes and
+	// relationships rendered for each resource type.
+	fields map[string]map[string]bool
+
+	// relationshipLimit is the number of members above which to-many
+	// relationships are rendered as links only; 0 means unlimited.
+	relationshipLimit int
+
+	// includedLimit is the maximum number of included records; 0 means
+	// unlimited.
+	includedLimit int
+
+	// withoutIncluded leaves "included" out of the payload.
+	withoutIncluded bool
+
+	// sortRelationships orders the to-many relationships and the included
+	// records.
+	sortRelationships bool
+
+	// payloadHook is applied to the assembled payload.
+	payloadHook PayloadHook
+
+	// links and meta are the top-level links and meta of the document.
+	links *Links
+	meta  *Meta
+
+	// linkResolver derives the links the models don't render themselves.
+	linkResolver *LinkResolver
+
+	// query is echoed in the top-level meta.
+	query *Query
+
+	// deprecation is stamped on the top-level meta.
+	deprecation *Deprecation
+
+	// tracer starts a span around the Marshal call.
+	tracer Tracer
+
+	// logger receives the anomalies met while marshaling.
+	logger Logger
+
+	// typePrefix is prepended to every resource type of the payload.
+	typePrefix string
+
+	// renames holds the resource type and member names of the API version.
+	renames Renames
+
+	// typeNames holds the resource type names given by WithTypeNames, which
+	// take precedence over those of renames.
+	typeNames map[string]string
+
+	// create is set when building a create request payload.
+	create bool
+
+	// jsonapi is the top-level jsonapi object given with WithJSONAPI.
+	jsonapi *JSONAPIObject
+
+	// extensions and profiles are the URIs listed in the top-level jsonapi
+	// object.
+	extensions []string
+	profiles   []string
+
+	// pagination renders the cursors, links and meta of a CursorPagination or
+	// a Paginator.
+	pagination *pagination
+}
+
+func newMarshalConfig(opts []MarshalOption) *marshalConfig {
+	c := &marshalConfig{ctx: context.Background(), defaults: DefaultConfig()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// maxDepthReached reports whether the relationships of a model found depth
+// levels away from the primary data should be rendered as linkage only.
+func (c *marshalConfig) maxDepthReached(depth int) bool {
+	return c.maxDepth > 0 && depth >= c.maxDepth
+}
+
+// WithMaxDepth limits sideloading to depth levels of relationships. Models
+// beyond the limit are rendered as resource identifiers (type and id) in the
+// relationships of their parent and are not added to "included". A depth of 0,
+// the default, traverses the whole object graph.
+func WithMaxDepth(depth int) MarshalOption {
+	return func(c *marshalConfig) {
+		c.maxDepth = depth
+	}
+}
+
+// WithRelationshipLimit renders the to-many relationships with more than n
+// members without their resource linkage, as their links and a "count" meta
+// only, e.g.
+//
+//	"comments": {
+//		"links": {"related": "/posts/1/comments"},
+//		"meta": {"count": 2500}
+//	}
+//
+// so that a few large relationships don't blow up the size of the payload.
+// Their related records are not sideloaded either.
+func WithRelationshipLimit(n int) MarshalOption {
+	return func(c *marshalConfig) {
+		c.relationshipLimit = n
+	}
+}
+
+// relationshipLimitExceeded reports whether the to-many relationship held by
+// fieldValue should be rendered as links only.
+func (c *marshalConfig) relationshipLimitExceeded(fieldValue reflect.Value) bool {
+	return c.relationshipLimit > 0 && fieldValue.Len() > c.relationshipLimit
+}
+
+// WithIncludedLimit caps the number of records sideloaded into "included" to
+// n. Once the limit is reached, further related records are only rendered as
+// resource linkage, and the top-level meta of the payload records how many
+// were left out, e.g.
+//
+//	"meta": {"included_truncated": true, "omitted": 12}
+//
+// so that clients know to fetch the rest through the related links.
+func WithIncludedLimit(n int) MarshalOption {
+	return func(c *marshalConfig) {
+		c.includedLimit = n
+	}
+}
+
+// WithoutIncluded leaves the "included" array out of the payload, the related
+// records being rendered as resource linkage only, as
+// MarshalOnePayloadWithoutIncluded does. Unlike WithInclude without paths, the
+// relationships are still traversed, e.g. for the hooks of the related models.
+func WithoutIncluded() MarshalOption {
+	return func(c *marshalConfig) {
+		c.withoutIncluded = true
+	}
+}
+
+// WithSortedRelationships renders the members of the to-many relationships in
+// a stable order, whatever the order of the slices of the models, e.g. as
+// returned by the database: by id, or as decided by the models implementing
+// RelationshipSorter. The included records are ordered by type and id.
+func WithSortedRelationships() MarshalOption {
+	return func(c *marshalConfig) {
+		c.sortRelationships = true
+	}
+}
+
+// includes reports whether the relationship at path should be traversed and
+// its related records sideloaded.
+func (c *marshalConfig) includes(path string) bool {
+	return c.include == nil || c.include[path]
+}
+
+// WithInclude limits sideloading to the given relationship paths, using the
+// same dot-separated syntax as the "include" query parameter, e.g.
+//
+//	jsonapi.MarshalOnePayload(w, post, jsonapi.WithInclude("author", "comments.author"))
+//
+// Relationships that are not on one of the paths are still rendered as resource
+// linkage, but are not traversed. Calling WithInclude without any paths
+// sideloads nothing.
+func WithInclude(paths ...string) MarshalOption {
+	return func(c *marshalConfig) {
+		if c.include == nil {
+			c.include = make(map[string]bool)
+		}
+		addIncludePaths(c.include, paths)
+	}
+}
+
+// addIncludePaths adds each of paths, and each of their prefixes, to include.
+func addIncludePaths(include map[string]bool, paths []string) {
+	for _, path := range paths {
+		// include=comments.author implies include=comments
+		segments := strings.Split(path, ".")
+		for i := range segments {
+			include[strings.Join(segments[:i+1], ".")] = true
+		}
+	}
+}
+
+// IncludeFunc reports whether candidate, a record related to parent through
+// the relation named relation, should be sideloaded. parent may not have all
+// of its members set yet.
+type IncludeFunc func(parent *Node, relation string, candidate *Node) bool
+
+// WithIncludeFunc sideloads only the related records for which f returns true,
+// e.g. those the caller is authorized to see. Rejected records, and anything
+// only reachable through them, are left out of "included" while their resource
+// linkage is still rendered. It complements WithInclude, which is applied
+// first.
+func WithIncludeFunc(f IncludeFunc) MarshalOption {
+	return func(c *marshalConfig) {
+		c.includeFunc = f
+	}
+}
+
+// AttributeTransformer returns the value to render for the attribute
+// attrName of a resource of type resourceType, or false to drop the attribute.
+// value is the attribute as it would otherwise be rendered, e.g. a unix
+// timestamp for a time.Time field.
+type AttributeTransformer func(resourceType, attrName string, value interface{}) (interface{}, bool)
+
+// WithAttributeTransformer applies f to every attribute of the primary data and
+// the included records, e.g. to mask emails or truncate large values.
+func WithAttributeTransformer(f AttributeTransformer) MarshalOption {
+	return func(c *marshalConfig) {
+		c.attributeTransformer = f
+	}
+}
+
+// FieldPolicy decides which attributes and relationships of a resource are
+// rendered, typically based on a value held by ctx such as the role of the
+// authenticated user, so that one model can serve several audiences.
+type FieldPolicy interface {
+	AttributeVisible(ctx context.Context, resourceType, name string) bool
+	RelationshipVisible(ctx context.Context, resourceType, name string) bool
+}
+
+// WithFieldPolicy renders only the attributes and relationships that p allows,
+// for the primary data and the included records. The context given by
+// WithContext is passed to p. Hidden relationships are not traversed, so their
+// related records are not sideloaded either.
+func WithFieldPolicy(p FieldPolicy) MarshalOption {
+	return func(c *marshalConfig) {
+		c.fieldPolicy = p
+	}
+}
+
+// WithScopes grants the scopes to the call, so that the attributes tagged
+// with one of them, e.g. `jsonapi:"attr,email,scope:admin"`, are rendered;
+// those are left out otherwise. The scopes held by the context given with
+// WithContext, see ContextWithScopes, are granted as well.
+func WithScopes(scopes ...string) MarshalOption {
+	return func(c *marshalConfig) {
+		if c.scopes == nil {
+			c.scopes = make(map[string]bool, len(scopes))
+		}
+		for _, scope := range scopes {
+			c.scopes[scope] = true
+		}
+	}
+}
+
+// scopesKey is the key of the scopes held by a context.
+type scopesKey struct{}
+
+// ContextWithScopes returns a copy of ctx holding the scopes, in addition to
+// those it already holds, e.g. for a middleware to grant the scopes of the
+// role of the authenticated user to the Marshal calls of a request:
+//
+//	ctx := jsonapi.ContextWithScopes(r.Context(), "admin")
+//	jsonapi.MarshalOnePayloadContext(ctx, w, user)
+func ContextWithScopes(ctx context.Context, scopes ...string) context.Context {
+	held, _ := ctx.Value(scopesKey{}).([]string)
+	all := make([]string, 0, len(held)+len(scopes))
+	all = append(append(all, held...), scopes...)
+	return context.WithValue(ctx, scopesKey{}, all)
+}
+
+// inScope reports whether the call is granted one of the scopes of a field,
+// or the field has none.
+func (c *marshalConfig) inScope(scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	held, _ := c.ctx.Value(scopesKey{}).([]string)
+	for _, scope := range scopes {
+		if c.scopes[scope] {
+			return true
+		}
+		for _, h := range held {
+			if h == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WithFields renders only the attributes and relationships listed in fields
+// for the resources of their type, in the primary data and the included
+// records, as the sparse fieldsets of the fields[TYPE] query parameters ask,
+// e.g.
+//
+//	jsonapi.MarshalOnePayload(w, blog, jsonapi.WithFields(query.Fields))
+//
+// fields is keyed by the rendered resource types, once WithTypePrefix and
+// WithRenames are applied, and lists rendered member names. The resources of
+// the types it doesn't list are rendered in full, and an empty list renders
+// none of their fields. Relationships left out are not traversed, so their
+// related records are not sideloaded either. WithFields can be combined with
+// WithFieldPolicy; a field is then rendered only if both allow it.
+func WithFields(fields map[string][]string) MarshalOption {
+	return func(c *marshalConfig) {
+		c.fields = make(map[string]map[string]bool, len(fields))
+		for t, names := range fields {
+			set := make(map[string]bool, len(names))
+			for _, name := range names {
+				set[name] = true
+			}
+			c.fields[t] = set
+		}
+	}
+}
+
+func (c *marshalConfig) attributeVisible(resourceType, name string) bool {
+	return c.inFieldset(resourceType, name) && (c.fieldPolicy == nil ||
+		c.fieldPolicy.AttributeVisible(c.ctx, resourceType, name))
+}
+
+func (c *marshalConfig) relationshipVisible(resourceType, name string) bool {
+	return c.inFieldset(resourceType, name) && (c.fieldPolicy == nil ||
+		c.fieldPolicy.RelationshipVisible(c.ctx, resourceType, name))
+}
+
+// inFieldset reports whether the member name of the resources of the tagged
+// type resourceType is in the sparse fieldset of their rendered type, if any.
+func (c *marshalConfig) inFieldset(resourceType, name string) bool {
+	if c.fields == nil {
+		return true
+	}
+	fieldset, ok := c.fields[c.renderedType(resourceType)]
+	return !ok || fieldset[name]
+}
+
+// PayloadHook is applied to the payload assembled by a Marshal call, a
+// *OnePayload or a *ManyPayload, before it is encoded or returned. It may
+// modify the payload in place; an error aborts the call.
+type PayloadHook func(payload interface{}) error
+
+// WithPayloadHook applies h to the assembled payload, so that the final
+// document can be amended in one place, e.g. to inject meta, rewrite links or
+// strip members, rather than by decoding and encoding the output again:
+//
+//	jsonapi.WithPayloadHook(func(payload interface{}) error {
+//		if p, ok := payload.(*jsonapi.ManyPayload); ok {
+//			p.Meta = &jsonapi.Meta{"total": total}
+//		}
+//		return nil
+//	})
+func WithPayloadHook(h PayloadHook) MarshalOption {
+	return func(c *marshalConfig) {
+		c.payloadHook = h
+	}
+}
+
+// applyPayloadHook applies the payload hook, if any, to payload.
+func (c *marshalConfig) applyPayloadHook(payload interface{}) error {
+	if c.payloadHook == nil {
+		return nil
+	}
+	return c.payloadHook(payload)
+}
+
+// WithLinks sets the top-level links of the document, e.g. its self link or
+// the pagination links, which Write also sends as Link headers.
+func WithLinks(links *Links) MarshalOption {
+	return func(c *marshalConfig) {
+		c.links = links
+	}
+}
+
+// WithMeta sets the top-level meta of the document, e.g. the id of the request
+// or the time taken to handle it. The members added by the other options,
+// such as WithQueryMeta, are merged into it.
+func WithMeta(meta *Meta) MarshalOption {
+	return func(c *marshalConfig) {
+		c.meta = meta
+	}
+}
+
+// documentLinks returns the links of WithLinks, once validated.
+func (c *marshalConfig) documentLinks() (*Links, error) {
+	if c.links == nil {
+		return nil, nil
+	}
+	if err := c.links.validate(); err != nil {
+		return nil, err
+	}
+	return c.links, nil
+}
+
+// WithQueryMeta renders the include paths, sparse fieldsets, sort, pagination
+// and filter of q in the "query" member of the top-level meta, so that API
+// consumers can see how their request was interpreted:
+//
+//	query := jsonapi.ParseQuery(r.URL.Query())
+//	jsonapi.MarshalManyPayload(w, blogs, jsonapi.WithQueryMeta(query))
+func WithQueryMeta(q *Query) MarshalOption {
+	return func(c *marshalConfig) {
+		c.query = q
+	}
+}
+
+// queryMeta returns meta with the query member of WithQueryMeta, if any.
+func (c *marshalConfig) queryMeta(meta *Meta) *Meta {
+	if c.query == nil {
+		return meta
+	}
+	return mergeMeta(meta, &Meta{"query": c.query.meta()})
+}
+
+// WithDeprecation marks the whole document as deprecated, with
+// "deprecated": true and the "sunset" time, if known, in the top-level meta.
+// Write also sets the matching headers (see SetDeprecationHeaders). Single
+// attributes and relationships are marked with the deprecated tag option,
+// e.g. `jsonapi:"attr,legacy_name,deprecated"`, which lists them in the
+// "deprecated" member of the resource meta.
+func WithDeprecation(d Deprecation) MarshalOption {
+	return func(c *marshalConfig) {
+		c.deprecation = &d
+	}
+}
+
+// deprecationMeta returns meta with the members of WithDeprecation, if any.
+func (c *marshalConfig) deprecationMeta(meta *Meta) *Meta {
+	if c.deprecation == nil {
+		return meta
+	}
+	return mergeMeta(meta, c.deprecation.meta())
+}
+
+// WithContext sets the context passed to the model hooks, such as
+// RelationshipLoader, and to the FieldPolicy invoked while marshaling.
+func WithContext(ctx context.Context) MarshalOption {
+	return func(c *marshalConfig) {
+		c.ctx = ctx
+	}
+}
+
+// WithConfig applies c, instead of the package level DefaultConfig, to the
+// Marshal call.
+func WithConfig(c Config) MarshalOption {
+	return func(mc *marshalConfig) {
+		mc.defaults = c
+	}
+}
+
+// WithTracer records a span for the Marshal call using t, carrying the
+// resource type and the number of primary and included resources.
+func WithTracer(t Tracer) MarshalOption {
+	return func(c *marshalConfig) {
+		c.tracer = t
+	}
+}
+
+// WithLogger reports the anomalies met while marshaling, such as dropped zero
+// times or relationships that were not sideloaded, to l.
+func WithLogger(l Logger) MarshalOption {
+	return func(c *marshalConfig) {
+		c.logger = l
+	}
+}
+
+func (c *marshalConfig) logAnomaly(a Anomaly) {
+	if c.logger != nil {
+		c.logger.LogAnomaly(c.ctx, a)
+	}
+}
+
+// WithTypePrefix prepends prefix to every resource type of the payload, in the
+// primary data, the resource linkage and the included records, e.g.
+//
+//	jsonapi.MarshalOnePayload(w, blog, jsonapi.WithTypePrefix("acme:"))
+//
+// renders a Blog as a resource of type "acme:blogs". Use
+// WithUnmarshalTypePrefix to read such payloads back.
+func WithTypePrefix(prefix string) MarshalOption {
+	return func(c *marshalConfig) {
+		c.typePrefix = prefix
+	}
+}
+
+// WithRenames renders the resource types and members of the models under the
+// names given by r, e.g. so that the same models serve both /v1 and /v2 of an
+// API that renamed some attributes. Use WithUnmarshalRenames to read such
+// payloads back.
+func WithRenames(r Renames) MarshalOption {
+	return func(c *marshalConfig) {
+		c.renames = r
+	}
+}
+
+// WithTypeNames renders the resource types of the models under the names given
+// by names, in the primary data, the resource linkage and the included
+// records, e.g. to expose the internal "db_users" type as "users" in a single
+// response:
+//
+//	jsonapi.MarshalOnePayload(w, user, jsonapi.WithTypeNames(map[string]string{"db_users": "users"}))
+//
+// The names are added to those given by WithRenames, whatever the order of the
+// options, and take precedence over them.
+func WithTypeNames(names map[string]string) MarshalOption {
+	return func(c *marshalConfig) {
+		types := make(map[string]string, len(c.typeNames)+len(names))
+		for k, v := range c.typeNames {
+			types[k] = v
+		}
+		for k, v := range names {
+			types[k] = v
+		}
+		c.typeNames = types
+	}
+}
+
+// rewritesMembers reports whether the options or the Config change the members
+// rendered for the tagged fields of a model, or its links, which the Node of a
+// NodeMarshaler doesn't account for.
+func (c *marshalConfig) rewritesMembers() bool {
+	return c.fieldPolicy != nil || c.fields != nil || c.attributeTransformer != nil ||
+		c.defaults.NamingStrategy != nil || len(c.renames.Members) > 0 || c.linkResolver != nil ||
+		c.defaults.TimeFormat != TimeFormatUnix || c.defaults.BaseURL != "" || c.defaults.EmitEmptyMembers
+}
+
+// memberName returns the rendered name of a tagged attribute or relationship
+// name.
+func (c *marshalConfig) memberName(name string) string {
+	return c.renames.memberName(c.defaults.memberName(name))
+}
+
+// renameTypes applies the type renaming options to the nodes of a payload.
+func (c *marshalConfig) renameTypes(nodes ...[]*Node) error {
+	if c.typePrefix == "" && len(c.renames.Types) == 0 && len(c.typeNames) == 0 {
+		return nil
+	}
+	return renameTypes(func(t string) (string, error) {
+		return c.renderedType(t), nil
+	}, nodes...)
+}
+
+// renderedType returns the resource type rendered for the tagged type t.
+func (c *marshalConfig) renderedType(t string) string {
+	if renamed, ok := c.typeNames[t]; ok {
+		return c.typePrefix + renamed
+	}
+	return c.typePrefix + c.renames.typeName(t)
+}
+
+// withContextOption prepends WithContext(ctx) to opts, so that an explicit
+// WithContext option still takes precedence.
+func withContextOption(ctx context.Context, opts []MarshalOption) []MarshalOption {
+	return append([]MarshalOption{WithContext(ctx)}, opts...)
+}
+
+// joinIncludePath appends the relationship name to path.
+func joinIncludePath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// includePathDepth returns the number of relationships in path.
+func includePathDepth(path string) int {
+	if path == "" {
+		return 0
+	}
+	return strings.Count(path, ".") + 1
+}
+
+// UnmarshalOption configures a single call to one of the Unmarshal functions.
+type UnmarshalOption func(*unmarshalConfig)
+
+// unmarshalConfig holds the settings applied by UnmarshalOptions.
+type unmarshalConfig struct {
+	ctx context.Context
+
+	// defaults holds the Config applied to the call.
+	defaults Config
+
+	// tracer starts a span around the Unmarshal call.
+	tracer Tracer
+
+	// logger receives the anomalies met while unmarshaling.
+	logger Logger
+
+	// typePrefix is removed from every resource type of the payload.
+	typePrefix string
+
+	// renames holds the resource type and member names of the API version.
+	renames Renames
+
+	// maxNesting is the maximum nesting depth of the objects and arrays of
+	// the payload; 0 means unlimited.
+	maxNesting int
+
+	// maxSize is the maximum size of the payload in bytes; 0 means
+	// unlimited.
+	maxSize int
+
+	// maxRelationshipDepth is the number of relationship levels that are
+	// resolved from the included resources; 0 means unlimited.
+	maxRelationshipDepth int
+
+	// documentHook is applied to the decoded document before binding.
+	documentHook DocumentHook
+
+	// allowedAttributes maps resource types to the attributes that can be
+	// written; the types absent from it are not restricted.
+	allowedAttributes map[string]map[string]bool
+
+	// dropDisallowedAttributes drops the attributes not allowed rather than
+	// failing.
+	dropDisallowedAttributes bool
+
+	// inverseRelationships sets the back-references of the related models.
+	inverseRelationships bool
+
+	// coerce converts the scalar attributes of the payload to the types of
+	// the fields when they obviously can be.
+	coerce bool
+
+	// fidelity checks that the models render every member of the resource
+	// objects of the payload.
+	fidelity bool
+}
+
+func newUnmarshalConfig(opts []UnmarshalOption) *unmarshalConfig {
+	c := &unmarshalConfig{ctx: context.Background(), defaults: DefaultConfig()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithUnmarshalConfig applies c, instead of the package level DefaultConfig,
+// to the Unmarshal call.
+func WithUnmarshalConfig(c Config) UnmarshalOption {
+	return func(uc *unmarshalConfig) {
+		uc.defaults = c
+	}
+}
+
+// WithUnmarshalTracer records a span for the Unmarshal call using t, carrying
+// the resource type and the number of primary and included resources.
+func WithUnmarshalTracer(t Tracer) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.tracer = t
+	}
+}
+
+// WithUnmarshalLogger reports the anomalies met while unmarshaling, such as
+// skipped unknown attributes, to l.
+func WithUnmarshalLogger(l Logger) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.logger = l
+	}
+}
+
+func (c *unmarshalConfig) logAnomaly(a Anomaly) {
+	if c.logger != nil {
+		c.logger.LogAnomaly(c.ctx, a)
+	}
+}
+
+// WithUnmarshalTypePrefix removes prefix from every resource type of the
+// payload before it is bound to the models. The Unmarshal functions return
+// ErrBadTypePrefix if a resource type doesn't have the prefix.
+func WithUnmarshalTypePrefix(prefix string) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.typePrefix = prefix
+	}
+}
+
+// WithUnmarshalRenames reads payloads whose resource types and members are
+// named as given by r, see WithRenames.
+func WithUnmarshalRenames(r Renames) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.renames = r
+	}
+}
+
+// memberName returns the rendered name of a tagged attribute or relationship
+// name.
+func (c *unmarshalConfig) memberName(name string) string {
+	return c.renames.memberName(c.defaults.memberName(name))
+}
+
+// renameTypes restores the tagged resource types of the nodes of a payload.
+func (c *unmarshalConfig) renameTypes(nodes ...[]*Node) error {
+	if c.typePrefix == "" && len(c.renames.Types) == 0 {
+		return nil
+	}
+	return renameTypes(func(t string) (string, error) {
+		if !strings.HasPrefix(t, c.typePrefix) {
+			return "", ErrBadTypePrefix
+		}
+		return c.renames.tagTypeName(strings.TrimPrefix(t, c.typePrefix)), nil
+	}, nodes...)
+}
+
+// WithMaxNesting makes the Unmarshal functions fail with ErrNestingTooDeep,
+// without decoding it further, when the payload nests objects and arrays more
+// than depth levels deep, e.g. to guard public endpoints against documents
+// crafted to exhaust the stack.
+func WithMaxNesting(depth int) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.maxNesting = depth
+	}
+}
+
+// WithMaxSize makes the Unmarshal functions fail with ErrPayloadTooLarge when
+// the payload is larger than size bytes. UnmarshalOneBytes and
+// UnmarshalManyBytes check it before decoding anything; the functions reading
+// from an io.Reader stop reading once size bytes have been read.
+func WithMaxSize(size int) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.maxSize = size
+	}
+}
+
+// WithMaxRelationshipDepth makes the Unmarshal functions fail with
+// ErrRelationshipTooDeep when the relationships of the primary data resolve to
+// included resources more than depth levels away.
+func WithMaxRelationshipDepth(depth int) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.maxRelationshipDepth = depth
+	}
+}
+
+// AllowAttributes makes the Unmarshal functions fail with
+// ErrAttributeNotAllowed when a resource of the payload has an attribute that
+// is not listed for its type in allowed, e.g. to keep clients from setting
+// the role of a user when updating their profile:
+//
+//	jsonapi.UnmarshalPayload(r.Body, user, jsonapi.AllowAttributes(map[string][]string{
+//		"users": {"name", "email"},
+//	}))
+//
+// The resource types absent from allowed are not restricted. With
+// DropDisallowedAttributes, the attributes are dropped rather than rejected.
+func AllowAttributes(allowed map[string][]string) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.allowedAttributes = make(map[string]map[string]bool, len(allowed))
+		for t, names := range allowed {
+			c.allowedAttributes[t] = make(map[string]bool, len(names))
+			for _, name := range names {
+				c.allowedAttributes[t][name] = true
+			}
+		}
+	}
+}
+
+// DropDisallowedAttributes makes the Unmarshal functions silently drop the
+// attributes rejected by AllowAttributes, reporting them to the Logger as
+// AnomalyAttributeDropped.
+func DropDisallowedAttributes() UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.dropDisallowedAttributes = true
+	}
+}
+
+// WithInverseRelationships makes the Unmarshal functions point the related
+// models back to the model holding them, through the relationship named by
+// the inverse:<name> argument of the relation tag, so that decoded graphs can
+// be navigated both ways:
+//
+//	type Course struct {
+//		ID      int       `jsonapi:"primary,courses"`
+//		Lessons []*Lesson `jsonapi:"relation,lessons,inverse:course"`
+//	}
+//
+//	type Lesson struct {
+//		ID     int     `jsonapi:"primary,lessons"`
+//		Course *Course `jsonapi:"relation,course"`
+//	}
+//
+// A to-one inverse relationship is set to the model; the model is appended to
+// a to-many one unless it already holds it.
+func WithInverseRelationships() UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.inverseRelationships = true
+	}
+}
+
+// WithCoercion makes the Unmarshal functions convert the scalar attributes of
+// the payload that obviously fit the type of their field rather than failing
+// with ErrInvalidType, e.g. for gateways fronting sloppy clients:
+//
+//   - strings holding numbers, e.g. "42", into number fields;
+//   - strings holding booleans, e.g. "true", and the numbers 1 and 0 into
+//     bool fields;
+//   - numbers and booleans into string fields.
+//
+// Each conversion is reported to the Logger as AnomalyAttributeCoerced.
+func WithCoercion() UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.coerce = true
+	}
+}
+
+// DocumentHook is applied to the document decoded by an Unmarshal call, as
+// generic JSON values, before it is bound to the models. It may modify the
+// document in place; an error aborts the call.
+type DocumentHook func(doc map[string]interface{}) error
+
+// WithDocumentHook applies h to the decoded document, with its "data",
+// "included" and "meta" members, before it is bound to the models, e.g. to
+// migrate payloads of older clients that use legacy member names:
+//
+//	jsonapi.WithDocumentHook(func(doc map[string]interface{}) error {
+//		data, _ := doc["data"].(map[string]interface{})
+//		attrs, _ := data["attributes"].(map[string]interface{})
+//		if name, ok := attrs["name"]; ok {
+//			attrs["title"] = name
+//			delete(attrs, "name")
+//		}
+//		return nil
+//	})
+//
+// It is not applied by ManyPayloadDecoder, which never holds the whole
+// document.
+func WithDocumentHook(h DocumentHook) UnmarshalOption {
+	return func(c *unmarshalConfig) {
+		c.documentHook = h
+	}
+}
+
+// decode decodes the document read from in into payload, applying the
+// document hook if any.
+func (c *unmarshalConfig) decode(in io.Reader, payload interface{}) error {
+	if c.documentHook == nil {
+		return json.NewDecoder(c.reader(in)).Decode(payload)
+	}
+
+	var doc map[string]interface{}
+	if err := json.NewDecoder(c.reader(in)).Decode(&doc); err != nil {
+		return err
+	}
+	if err := c.documentHook(doc); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, payload)
+}
+
+// reader returns in, limited to the maximum size and nesting depth if any.
+func (c *unmarshalConfig) reader(in io.Reader) io.Reader {
+	if c.maxSize > 0 {
+		in = &sizeLimitReader{r: in, remaining: c.maxSize}
+	}
+	if c.maxNesting > 0 {
+		in = &nestingLimitReader{r: in, max: c.maxNesting}
+	}
+	return in
+}
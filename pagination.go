@@ -0,0 +1,308 @@
+package jsonapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+)
+
+// CursorPaginationProfile is the URI of the cursor pagination profile,
+// https://jsonapi.org/profiles/ethanresnick/cursor-pagination/, implemented
+// by Query.CursorPage and CursorPagination.
+const CursorPaginationProfile = "https://jsonapi.org/profiles/ethanresnick/cursor-pagination/"
+
+// The types of the errors of the cursor pagination profile, rendered as the
+// "type" link of the error objects returned by Query.CursorPage.
+const (
+	CursorPaginationMaxSizeExceeded   = CursorPaginationProfile + "max-size-exceeded"
+	CursorPaginationRangeNotSupported = CursorPaginationProfile + "range-pagination-not-supported"
+)
+
+// The members of the page family used by the cursor pagination profile.
+const (
+	cursorPageSize   = "size"
+	cursorPageAfter  = "after"
+	cursorPageBefore = "before"
+)
+
+// CursorPage is a page requested with the parameters of the cursor
+// pagination profile: page[size], page[after] and page[before].
+type CursorPage struct {
+	// Size is the maximum number of results of the page.
+	Size int
+
+	// After and Before are the cursors of the results the page starts after,
+	// or ends before.
+	After  string
+	Before string
+}
+
+// CursorPage returns the page requested by q with the cursor pagination
+// profile, of defaultSize results when page[size] isn't given, e.g.
+//
+//	page, err := query.CursorPage(20, 100)
+//	if err != nil {
+//		jsonapi.WriteError(w, err)
+//		return
+//	}
+//
+// As the profile mandates, it returns a *ValidationError holding a 400 Bad
+// Request error object when page[size] isn't a positive integer, when it
+// exceeds maxSize, with the max-size-exceeded type and the maximum in
+// meta.page.maxSize, and when both page[after] and page[before] are given,
+// with the range-pagination-not-supported type.
+func (q *Query) CursorPage(defaultSize, maxSize int) (*CursorPage, error) {
+	page := &CursorPage{
+		Size:   defaultSize,
+		After:  q.Page[cursorPageAfter],
+		Before: q.Page[cursorPageBefore],
+	}
+
+	if size, ok := q.Page[cursorPageSize]; ok {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 1 {
+			return nil, cursorPageError(pageParameter(cursorPageSize), "",
+				"page[size] must be a positive integer", nil)
+		}
+		if maxSize > 0 && n > maxSize {
+			return nil, cursorPageError(pageParameter(cursorPageSize), CursorPaginationMaxSizeExceeded,
+				fmt.Sprintf("page[size] must not exceed %d", maxSize),
+				&map[string]interface{}{"page": map[string]interface{}{"maxSize": maxSize}})
+		}
+		page.Size = n
+	}
+
+	if page.After != "" && page.Before != "" {
+		return nil, cursorPageError(pageParameter(cursorPageBefore), CursorPaginationRangeNotSupported,
+			"page[after] and page[before] can't be used together", nil)
+	}
+
+	return page, nil
+}
+
+// pageParameter returns the query parameter of the member of the page family.
+func pageParameter(member string) string {
+	return "page[" + member + "]"
+}
+
+// cursorPageError returns the *ValidationError of a problem with the query
+// parameter, with the error type of the profile if it is not empty.
+func cursorPageError(parameter, errorType, detail string, meta *map[string]interface{}) error {
+	obj := statusErrorObject(http.StatusBadRequest, detail)
+	obj.Source = &ErrorSource{Parameter: parameter}
+	obj.Meta = meta
+	if errorType != "" {
+		obj.Links = &Links{"type": errorType}
+	}
+	return &ValidationError{Errors: []*ErrorObject{obj}}
+}
+
+// CursorPagination renders a page of results the way the cursor pagination
+// profile mandates:
+//
+//   - the cursor of each result in its meta, as meta.page.cursor;
+//   - the prev and next links, which are null when there are no results
+//     before or after the page;
+//   - the total number of results, if known, in meta.page.total;
+//   - the profile in the top-level jsonapi object.
+//
+// For example:
+//
+//	pagination := jsonapi.CursorPagination{
+//		URL:     r.URL,
+//		Page:    page,
+//		Cursor:  func(model interface{}) string { return model.(*Blog).Cursor() },
+//		HasNext: hasMore,
+//	}
+//	jsonapi.Write(w, http.StatusOK, blogs, pagination.MarshalOptions(blogs)...)
+type CursorPagination struct {
+	// URL is the URL of the request; the links keep its other parameters.
+	URL *url.URL
+
+	// Page is the page requested.
+	Page *CursorPage
+
+	// Cursor returns the cursor of a result of the page.
+	Cursor func(model interface{}) string
+
+	// HasPrev and HasNext tell whether there are results before and after
+	// the page.
+	HasPrev, HasNext bool
+
+	// Total is the total number of results, when it is known.
+	Total *int
+}
+
+// MarshalOptions returns the options rendering models, the slice of the
+// results of the page, with their cursors, the pagination links and meta,
+// and the profile. They can be combined with the other options, including
+// WithLinks and WithMeta, whose members are kept.
+func (p CursorPagination) MarshalOptions(models interface{}) []MarshalOption {
+	v := reflect.ValueOf(models)
+	cursors := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		cursors = append(cursors, p.Cursor(v.Index(i).Interface()))
+	}
+
+	pagination := &pagination{
+		cursors: cursors,
+		links:   Links{KeyPreviousPage: nil, KeyNextPage: nil},
+	}
+	if p.Total != nil {
+		pagination.meta = &Meta{"page": map[string]interface{}{"total": *p.Total}}
+	}
+	if len(cursors) > 0 {
+		if p.HasPrev {
+			pagination.links[KeyPreviousPage] = p.link(cursorPageBefore, cursors[0])
+		}
+		if p.HasNext {
+			pagination.links[KeyNextPage] = p.link(cursorPageAfter, cursors[len(cursors)-1])
+		}
+	}
+
+	return []MarshalOption{
+		WithProfiles(CursorPaginationProfile),
+		func(c *marshalConfig) {
+			c.pagination = pagination
+		},
+	}
+}
+
+// link returns the URL of the request, paginated from cursor with the page
+// member, "before" or "after".
+func (p CursorPagination) link(member, cursor string) string {
+	u := url.URL{}
+	if p.URL != nil {
+		u = *p.URL
+	}
+
+	query := u.Query()
+	query.Del(pageParameter(cursorPageAfter))
+	query.Del(pageParameter(cursorPageBefore))
+	query.Set(pageParameter(member), cursor)
+	if p.Page != nil {
+		query.Set(pageParameter(cursorPageSize), strconv.Itoa(p.Page.Size))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// pagination holds the members rendered by the options of CursorPagination
+// and Paginator.
+type pagination struct {
+	// cursors are the cursors of the results, rendered in their meta.
+	cursors []string
+
+	// links and meta are merged into the top-level links and meta.
+	links Links
+	meta  *Meta
+}
+
+// apply renders the cursors, links and meta of the pagination in payload.
+func (p *pagination) apply(payload *ManyPayload) {
+	for i, node := range payload.Data {
+		if i >= len(p.cursors) {
+			break
+		}
+		node.Meta = mergeMeta(node.Meta, &Meta{
+			"page": map[string]interface{}{"cursor": p.cursors[i]},
+		})
+	}
+
+	links := Links{}
+	if payload.Links != nil {
+		for k, v := range *payload.Links {
+			links[k] = v
+		}
+	}
+	for k, v := range p.links {
+		links[k] = v
+	}
+	payload.Links = &links
+
+	if p.meta != nil {
+		payload.Meta = mergeMeta(payload.Meta, p.meta)
+	}
+}
+
+// Paginator renders a page of results paginated with the page[number] and
+// page[size] query parameters: the self, first, last, prev and next links,
+// and the total number of results in meta.total, e.g.
+//
+//	paginator := jsonapi.Paginator{URL: r.URL, Number: 2, Size: 20, Total: total}
+//	jsonapi.MarshalManyPayload(w, blogs, paginator.MarshalOptions()...)
+//
+// The prev and next links are left out on the first and last pages.
+type Paginator struct {
+	// URL is the URL of the request; the links keep its other parameters.
+	URL *url.URL
+
+	// Number is the number of the page, from 1, and Size its maximum number
+	// of results.
+	Number int
+	Size   int
+
+	// Total is the total number of results.
+	Total int
+}
+
+// Links returns the pagination links of the page, e.g. for a OnePayload or a
+// Link header.
+func (p Paginator) Links() *Links {
+	number := p.Number
+	if number < 1 {
+		number = 1
+	}
+	last := 1
+	if p.Size > 0 && p.Total > 0 {
+		last = (p.Total + p.Size - 1) / p.Size
+	}
+
+	links := Links{
+		"self":       p.link(number),
+		KeyFirstPage: p.link(1),
+		KeyLastPage:  p.link(last),
+	}
+	if number > 1 {
+		links[KeyPreviousPage] = p.link(number - 1)
+	}
+	if number < last {
+		links[KeyNextPage] = p.link(number + 1)
+	}
+	return &links
+}
+
+// MarshalOptions returns the options rendering the pagination links and
+// meta.total in the document of the page. They can be combined with the other
+// options, including WithLinks and WithMeta, whose members are kept.
+func (p Paginator) MarshalOptions() []MarshalOption {
+	pagination := &pagination{
+		links: *p.Links(),
+		meta:  &Meta{"total": p.Total},
+	}
+	return []MarshalOption{
+		func(c *marshalConfig) {
+			c.pagination = pagination
+		},
+	}
+}
+
+// link returns the URL of the request for the page number.
+func (p Paginator) link(number int) string {
+	u := url.URL{}
+	if p.URL != nil {
+		u = *p.URL
+	}
+
+	query := u.Query()
+	query.Set(QueryParamPageNumber, strconv.Itoa(number))
+	if p.Size > 0 {
+		query.Set(QueryParamPageSize, strconv.Itoa(p.Size))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
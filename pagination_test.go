@@ -0,0 +1,201 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestQueryCursorPage(t *testing.T) {
+	for _, test := range []struct {
+		query    string
+		expected *CursorPage
+	}{
+		{"", &CursorPage{Size: 20}},
+		{"page[size]=5&page[after]=abc", &CursorPage{Size: 5, After: "abc"}},
+		{"page[before]=xyz", &CursorPage{Size: 20, Before: "xyz"}},
+	} {
+		values, _ := url.ParseQuery(test.query)
+		page, err := ParseQuery(values).CursorPage(20, 100)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if !reflect.DeepEqual(test.expected, page) {
+			t.Fatalf("%s: was expecting %+v, got %+v", test.query, test.expected, page)
+		}
+	}
+}
+
+func TestQueryCursorPageErrors(t *testing.T) {
+	for _, test := range []struct {
+		query     string
+		parameter string
+		errorType string
+		meta      *map[string]interface{}
+	}{
+		{"page[size]=0", "page[size]", "", nil},
+		{"page[size]=many", "page[size]", "", nil},
+		{"page[size]=101", "page[size]", CursorPaginationMaxSizeExceeded,
+			&map[string]interface{}{"page": map[string]interface{}{"maxSize": 100}}},
+		{"page[after]=a&page[before]=b", "page[before]", CursorPaginationRangeNotSupported, nil},
+	} {
+		values, _ := url.ParseQuery(test.query)
+		_, err := ParseQuery(values).CursorPage(20, 100)
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) || len(verr.Errors) != 1 {
+			t.Fatalf("%s: was expecting a *ValidationError, got %v", test.query, err)
+		}
+		obj := verr.Errors[0]
+		if obj.Status != "400" || obj.Source == nil || obj.Source.Parameter != test.parameter {
+			t.Fatalf("%s: was expecting a 400 error on %s, got %+v", test.query, test.parameter, obj)
+		}
+		if test.errorType == "" && obj.Links != nil {
+			t.Fatalf("%s: was expecting no type link, got %v", test.query, *obj.Links)
+		}
+		if test.errorType != "" && (obj.Links == nil || (*obj.Links)["type"] != test.errorType) {
+			t.Fatalf("%s: was expecting the type link %s, got %v", test.query, test.errorType, obj.Links)
+		}
+		if !reflect.DeepEqual(test.meta, obj.Meta) {
+			t.Fatalf("%s: was expecting the meta %v, got %v", test.query, test.meta, obj.Meta)
+		}
+	}
+}
+
+func TestCursorPaginationMarshalOptions(t *testing.T) {
+	u, _ := url.Parse("http://example.com/blogs?sort=title&page[after]=2")
+	blogs := []*Blog{{ID: 3, Title: "Three"}, {ID: 4, Title: "Four"}}
+	total := 10
+	pagination := CursorPagination{
+		URL:     u,
+		Page:    &CursorPage{Size: 2, After: "2"},
+		Cursor:  func(model interface{}) string { return strconv.Itoa(model.(*Blog).ID) },
+		HasPrev: true,
+		Total:   &total,
+	}
+
+	opts := append(pagination.MarshalOptions(blogs),
+		WithLinks(&Links{"self": "http://example.com/blogs"}),
+		WithMeta(&Meta{"count": 2}))
+	out := new(bytes.Buffer)
+	if err := MarshalManyPayload(out, blogs, opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		JSONAPI map[string]interface{}   `json:"jsonapi"`
+		Data    []map[string]interface{} `json:"data"`
+		Links   map[string]interface{}   `json:"links"`
+		Meta    map[string]interface{}   `json:"meta"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, cursor := range []string{"3", "4"} {
+		meta := doc.Data[i]["meta"].(map[string]interface{})
+		if e, a := map[string]interface{}{"cursor": cursor}, meta["page"]; !reflect.DeepEqual(e, a) {
+			t.Fatalf("Was expecting the page meta %v, got %v", e, a)
+		}
+		if meta["detail"] == nil {
+			t.Fatalf("Was expecting the meta of the model to be kept, got %v", meta)
+		}
+	}
+
+	expectedLinks := map[string]interface{}{
+		"self":          "http://example.com/blogs",
+		KeyPreviousPage: "http://example.com/blogs?page%5Bbefore%5D=3&page%5Bsize%5D=2&sort=title",
+		KeyNextPage:     nil,
+	}
+	if !reflect.DeepEqual(expectedLinks, doc.Links) {
+		t.Fatalf("Was expecting the links %v, got %v", expectedLinks, doc.Links)
+	}
+	if _, ok := doc.Links[KeyNextPage]; !ok {
+		t.Fatal("Was expecting a null next link")
+	}
+
+	expectedMeta := map[string]interface{}{
+		"count": float64(2),
+		"page":  map[string]interface{}{"total": float64(10)},
+	}
+	if !reflect.DeepEqual(expectedMeta, doc.Meta) {
+		t.Fatalf("Was expecting the meta %v, got %v", expectedMeta, doc.Meta)
+	}
+
+	if e, a := []interface{}{CursorPaginationProfile}, doc.JSONAPI["profile"]; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the profiles %v, got %v", e, a)
+	}
+}
+
+func TestCursorPaginationEmptyPage(t *testing.T) {
+	pagination := CursorPagination{
+		Page:    &CursorPage{Size: 2},
+		Cursor:  func(model interface{}) string { return strconv.Itoa(model.(*Blog).ID) },
+		HasPrev: true,
+		HasNext: true,
+	}
+
+	payload, err := MarshalMany([]interface{}{}, pagination.MarshalOptions([]*Blog{})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Links{KeyPreviousPage: nil, KeyNextPage: nil}
+	if !reflect.DeepEqual(expected, payload.Links) {
+		t.Fatalf("Was expecting null links, got %v", payload.Links)
+	}
+}
+
+func TestPaginatorLinks(t *testing.T) {
+	u, _ := url.Parse("http://example.com/blogs?sort=title&page[number]=9")
+	for _, test := range []struct {
+		number   int
+		expected Links
+	}{
+		{1, Links{
+			"self":       "http://example.com/blogs?page%5Bnumber%5D=1&page%5Bsize%5D=10&sort=title",
+			KeyFirstPage: "http://example.com/blogs?page%5Bnumber%5D=1&page%5Bsize%5D=10&sort=title",
+			KeyLastPage:  "http://example.com/blogs?page%5Bnumber%5D=3&page%5Bsize%5D=10&sort=title",
+			KeyNextPage:  "http://example.com/blogs?page%5Bnumber%5D=2&page%5Bsize%5D=10&sort=title",
+		}},
+		{3, Links{
+			"self":          "http://example.com/blogs?page%5Bnumber%5D=3&page%5Bsize%5D=10&sort=title",
+			KeyFirstPage:    "http://example.com/blogs?page%5Bnumber%5D=1&page%5Bsize%5D=10&sort=title",
+			KeyLastPage:     "http://example.com/blogs?page%5Bnumber%5D=3&page%5Bsize%5D=10&sort=title",
+			KeyPreviousPage: "http://example.com/blogs?page%5Bnumber%5D=2&page%5Bsize%5D=10&sort=title",
+		}},
+	} {
+		links := Paginator{URL: u, Number: test.number, Size: 10, Total: 25}.Links()
+		if !reflect.DeepEqual(&test.expected, links) {
+			t.Fatalf("%d: was expecting the links %v, got %v", test.number, test.expected, *links)
+		}
+	}
+
+	links := Paginator{Number: 1, Size: 10}.Links()
+	if (*links)[KeyLastPage] != "?page%5Bnumber%5D=1&page%5Bsize%5D=10" || links.Href(KeyNextPage) != "" {
+		t.Fatalf("Was expecting a single page without results, got %v", *links)
+	}
+}
+
+func TestPaginatorMarshalOptions(t *testing.T) {
+	u, _ := url.Parse("http://example.com/blogs")
+	paginator := Paginator{URL: u, Number: 2, Size: 1, Total: 3}
+
+	opts := append(paginator.MarshalOptions(), WithMeta(&Meta{"count": 1}))
+	payload, err := MarshalMany([]interface{}{testBlog()}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := (Meta{"count": 1, "total": 3}), *payload.Meta; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the meta %v, got %v", e, a)
+	}
+	for _, key := range []string{"self", KeyFirstPage, KeyLastPage, KeyPreviousPage, KeyNextPage} {
+		if payload.Links.Href(key) == "" {
+			t.Fatalf("Was expecting the %s link, got %v", key, *payload.Links)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package jsonapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Query holds the JSON API query parameters of a request: the include paths,
+// sparse fieldsets, sort fields, pagination and filter.
+type Query struct {
+	// Include lists the include paths, e.g. "comments.author".
+	Include []string
+
+	// Fields maps resource types to the names of their sparse fieldset.
+	Fields map[string][]string
+
+	// Sort lists the sort fields, prefixed with "-" when descending.
+	Sort []string
+
+	// Page holds the members of the page family, e.g. "number" and "size".
+	Page map[string]string
+
+	// Filter holds the members of the filter family, e.g. "title", followed
+	// by their operator when given, e.g. "view_count[gt]" for the parameter
+	// filter[view_count][gt].
+	Filter map[string]string
+}
+
+// ParseQuery returns the Query of the query parameters of a request, e.g.
+//
+//	query := jsonapi.ParseQuery(r.URL.Query())
+//	jsonapi.MarshalManyPayload(w, blogs, jsonapi.WithInclude(query.Include...))
+func ParseQuery(values url.Values) *Query {
+	q := &Query{
+		Fields: make(map[string][]string),
+		Page:   make(map[string]string),
+		Filter: make(map[string]string),
+	}
+
+	for key, vals := range values {
+		if len(vals) == 0 {
+			continue
+		}
+		value := vals[len(vals)-1]
+
+		if member, ok := familyMember(key, "fields"); ok {
+			q.Fields[member] = splitList(value)
+			continue
+		}
+		if member, ok := familyMember(key, "page"); ok {
+			q.Page[member] = value
+			continue
+		}
+		if member, ok := familyMember(key, "filter"); ok {
+			if i := strings.Index(member, "]["); i >= 0 {
+				member = member[:i] + "[" + member[i+2:] + "]"
+			}
+			q.Filter[member] = value
+			continue
+		}
+
+		switch key {
+		case "include":
+			q.Include = splitList(value)
+		case "sort":
+			q.Sort = splitList(value)
+		}
+	}
+
+	return q
+}
+
+// CheckSort returns a *ValidationError holding a 400 Bad Request error object,
+// whose source is the "sort" parameter, for each sort field of q that is not
+// allowed, e.g.
+//
+//	if err := query.CheckSort(jsonapi.SortFields(new(Blog))); err != nil {
+//		jsonapi.WriteError(w, err)
+//		return
+//	}
+func (q *Query) CheckSort(allowed []string) error {
+	permitted := make(map[string]bool, len(allowed))
+	for _, field := range allowed {
+		permitted[field] = true
+	}
+
+	var objects []*ErrorObject
+	for _, field := range q.Sort {
+		name := strings.TrimPrefix(field, "-")
+		if permitted[name] {
+			continue
+		}
+
+		obj := statusErrorObject(http.StatusBadRequest,
+			fmt.Sprintf("The resource can't be sorted by %q", name))
+		obj.Source = &ErrorSource{Parameter: "sort"}
+		objects = append(objects, obj)
+	}
+
+	if len(objects) > 0 {
+		return &ValidationError{Errors: objects}
+	}
+	return nil
+}
+
+// SortFields returns the sort fields declared by the jsonapi tags of model, a
+// struct or a pointer to one: "id" and the names of its attributes, as
+// rendered with the default Config.
+func SortFields(model interface{}) []string {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	config := DefaultConfig()
+
+	var fields []string
+	for _, f := range schemaOf(t).fields {
+		args := f.args
+		switch {
+		case args[0] == annotationPrimary:
+			fields = append(fields, "id")
+		case args[0] == annotationAttribute && len(args) > 1:
+			fields = append(fields, config.memberName(args[1]))
+		}
+	}
+
+	return fields
+}
+
+// meta returns the members of q that were given, as rendered by
+// WithQueryMeta.
+func (q *Query) meta() map[string]interface{} {
+	m := make(map[string]interface{})
+	if len(q.Include) > 0 {
+		m["include"] = q.Include
+	}
+	if len(q.Fields) > 0 {
+		m["fields"] = q.Fields
+	}
+	if len(q.Sort) > 0 {
+		m["sort"] = q.Sort
+	}
+	if len(q.Page) > 0 {
+		m["page"] = q.Page
+	}
+	if len(q.Filter) > 0 {
+		m["filter"] = q.Filter
+	}
+	return m
+}
+
+// familyMember returns the member of a family parameter, e.g. "blogs" for the
+// key "fields[blogs]" of the family "fields".
+func familyMember(key, family string) (string, bool) {
+	if !strings.HasPrefix(key, family+"[") || !strings.HasSuffix(key, "]") {
+		return "", false
+	}
+	return key[len(family)+1 : len(key)-1], true
+}
+
+// splitList splits a comma separated parameter value, leaving out empty
+// items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,95 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	values, err := url.ParseQuery("include=posts,current_post.comments&fields[blogs]=title,posts" +
+		"&sort=-created_at,title&page[number]=2&page[size]=10&filter[title]=Go&other=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := ParseQuery(values)
+
+	expected := &Query{
+		Include: []string{"posts", "current_post.comments"},
+		Fields:  map[string][]string{"blogs": {"title", "posts"}},
+		Sort:    []string{"-created_at", "title"},
+		Page:    map[string]string{"number": "2", "size": "10"},
+		Filter:  map[string]string{"title": "Go"},
+	}
+	if !reflect.DeepEqual(expected, q) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, q)
+	}
+}
+
+func TestWithQueryMeta(t *testing.T) {
+	values, err := url.ParseQuery("include=posts&page[size]=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := MarshalMany([]interface{}{testBlog()}, WithQueryMeta(ParseQuery(values)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"include": []string{"posts"},
+		"page":    map[string]string{"size": "10"},
+	}
+	if payload.Meta == nil || !reflect.DeepEqual(expected, (*payload.Meta)["query"]) {
+		t.Fatalf("Was expecting query meta %v, got %v", expected, payload.Meta)
+	}
+
+	payload, err = MarshalMany([]interface{}{testBlog()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Meta != nil {
+		t.Fatalf("Was expecting no meta without WithQueryMeta, got %v", *payload.Meta)
+	}
+}
+
+func TestSortFields(t *testing.T) {
+	expected := []string{"id", "title", "current_post_id", "created_at", "view_count"}
+	if a := SortFields(new(Blog)); !reflect.DeepEqual(expected, a) {
+		t.Fatalf("Was expecting sort fields %v, got %v", expected, a)
+	}
+}
+
+func TestCheckSort(t *testing.T) {
+	q := &Query{Sort: []string{"-created_at", "id"}}
+	if err := q.CheckSort(SortFields(new(Blog))); err != nil {
+		t.Fatalf("Was expecting no error, got %v", err)
+	}
+
+	q = &Query{Sort: []string{"title", "-secret", "posts"}}
+	rec := httptest.NewRecorder()
+	if err := WriteError(rec, q.CheckSort(SortFields(new(Blog)))); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := http.StatusBadRequest, rec.Code; e != a {
+		t.Fatalf("Was expecting status %d, got %d", e, a)
+	}
+	errs := new(ErrorsPayload)
+	if err := json.NewDecoder(rec.Body).Decode(errs); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 2, len(errs.Errors); e != a {
+		t.Fatalf("Was expecting %d errors, got %d", e, a)
+	}
+	for _, obj := range errs.Errors {
+		if obj.Source == nil || obj.Source.Parameter != "sort" {
+			t.Fatalf("Was expecting the sort parameter as source, got %+v", obj.Source)
+		}
+	}
+}
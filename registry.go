@@ -0,0 +1,57 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrUnregisteredType is returned when unmarshaling an interface typed
+// relation to a resource whose type is not registered with RegisterType.
+var ErrUnregisteredType = errors.New("The resource type names no registered model")
+
+var (
+	modelTypesMu sync.RWMutex
+	modelTypes   = make(map[string]func() interface{})
+)
+
+// RegisterType registers newModel, returning a new model as a pointer to a
+// struct, for the resources of type typ, e.g.
+//
+//	jsonapi.RegisterType("comments", func() interface{} { return new(Comment) })
+//
+// The Unmarshal functions call it to bind the related resources of that type
+// to relations declared as interfaces, e.g.
+//
+//	type Notification struct {
+//		ID      int           `jsonapi:"primary,notifications"`
+//		Subject interface{}   `jsonapi:"relation,subject"`
+//		Targets []interface{} `jsonapi:"relation,targets"`
+//	}
+//
+// Such relations are marshaled after the dynamic types of their values. It is
+// meant to be called while the application starts.
+func RegisterType(typ string, newModel func() interface{}) {
+	modelTypesMu.Lock()
+	defer modelTypesMu.Unlock()
+
+	modelTypes[typ] = newModel
+}
+
+// newRegisteredModel returns a new model for the resources of type typ,
+// registered with RegisterType, which must implement the interface t.
+func newRegisteredModel(typ string, t reflect.Type) (reflect.Value, error) {
+	modelTypesMu.RLock()
+	newModel, ok := modelTypes[typ]
+	modelTypesMu.RUnlock()
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("%w: %s", ErrUnregisteredType, typ)
+	}
+
+	m := reflect.ValueOf(newModel())
+	if m.Kind() != reflect.Ptr || m.Elem().Kind() != reflect.Struct || !m.Type().Implements(t) {
+		return reflect.Value{}, ErrInvalidType
+	}
+	return m, nil
+}
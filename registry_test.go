@@ -0,0 +1,66 @@
+package jsonapi
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func init() {
+	RegisterType("people", func() interface{} { return new(Person) })
+	RegisterType("comments", func() interface{} { return new(Comment) })
+	RegisterType("posts", func() interface{} { return new(Post) })
+}
+
+func TestPolymorphicRelationsRoundTrip(t *testing.T) {
+	activity := &Activity{
+		ID:      1,
+		Actor:   &Person{ID: 4, Name: "alice"},
+		Subject: &Comment{ID: 2, Body: "first"},
+		Targets: []interface{}{&Post{ID: 3, Title: "Foo"}, &Comment{ID: 2, Body: "first"}},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, activity); err != nil {
+		t.Fatal(err)
+	}
+
+	back := new(Activity)
+	if err := UnmarshalPayload(out, back); err != nil {
+		t.Fatal(err)
+	}
+
+	if back.Actor == nil || back.Actor.DisplayName() != "alice" {
+		t.Fatalf("Was expecting the actor to be a person, got %#v", back.Actor)
+	}
+	subject, ok := back.Subject.(*Comment)
+	if !ok || subject.Body != "first" {
+		t.Fatalf("Was expecting the subject to be a comment, got %#v", back.Subject)
+	}
+	if e, a := []reflect.Type{reflect.TypeOf(new(Post)), reflect.TypeOf(new(Comment))},
+		[]reflect.Type{reflect.TypeOf(back.Targets[0]), reflect.TypeOf(back.Targets[1])}; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the targets to be of types %v, got %v", e, a)
+	}
+	if back.Targets[1] != back.Subject {
+		t.Fatal("Was expecting the same resource to be bound to a single model")
+	}
+}
+
+func TestPolymorphicRelationErrors(t *testing.T) {
+	for _, test := range []struct {
+		relationship string
+		data         *Node
+		expected     error
+	}{
+		{"subject", &Node{Type: "widgets", ID: "1"}, ErrUnregisteredType},
+		{"actor", &Node{Type: "comments", ID: "1"}, ErrInvalidType},
+	} {
+		payload := &OnePayload{Data: &Node{Type: "activities", ID: "1", Relationships: map[string]interface{}{
+			test.relationship: &RelationshipOneNode{Data: test.data},
+		}}}
+		if err := UnmarshalOne(payload, new(Activity)); !errors.Is(err, test.expected) {
+			t.Fatalf("%s: was expecting %v, got %v", test.data.Type, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+var (
+	// ErrNoRelationship is returned when marshaling a relationship that the
+	// model doesn't declare, or that is not visible with the options given.
+	ErrNoRelationship = errors.New("The model has no such relationship")
+	// ErrRelationshipCardinality is returned by MarshalOneRelationship for a
+	// to-many relationship, and by MarshalManyRelationship for a to-one one.
+	ErrRelationshipCardinality = errors.New("The relationship is not of the expected cardinality")
+)
+
+// MarshalOneRelationship writes the document of the to-one relationship of
+// model named relation, as served by a relationship endpoint such as
+// GET /posts/1/relationships/latest_comment: the resource identifier object of
+// the related model, or null, and the links and meta of the relationship.
+//
+//	jsonapi.MarshalOneRelationship(w, post, "latest_comment")
+//
+// The top-level links and meta given with WithLinks and WithMeta are merged
+// into those of the relationship.
+func MarshalOneRelationship(w io.Writer, model interface{}, relation string,
+	opts ...MarshalOption) error {
+	return marshalRelationshipPayload(w, model, relation, false, opts)
+}
+
+// MarshalManyRelationship writes the document of the to-many relationship of
+// model named relation, e.g. for GET /posts/1/relationships/comments: the
+// resource identifier objects of the related models, and the links and meta of
+// the relationship. See MarshalOneRelationship.
+func MarshalManyRelationship(w io.Writer, model interface{}, relation string,
+	opts ...MarshalOption) error {
+	return marshalRelationshipPayload(w, model, relation, true, opts)
+}
+
+func marshalRelationshipPayload(w io.Writer, model interface{}, relation string,
+	toMany bool, opts []MarshalOption) error {
+	relationship, err := MarshalRelationship(model, relation, opts...)
+	if err != nil {
+		return err
+	}
+
+	switch relationship.(type) {
+	case *RelationshipOneNode:
+		if toMany {
+			return ErrRelationshipCardinality
+		}
+	case *RelationshipManyNode:
+		if !toMany {
+			return ErrRelationshipCardinality
+		}
+	}
+
+	return json.NewEncoder(w).Encode(relationship)
+}
+
+// MarshalRelationship returns the relationship object of model named relation,
+// with resource linkage only: a *RelationshipOneNode, a *RelationshipManyNode,
+// or a *RelationshipLinksNode for the relationships rendered without linkage.
+// Related models are not visited beyond their identifiers.
+func MarshalRelationship(model interface{}, relation string,
+	opts ...MarshalOption) (interface{}, error) {
+	config := newMarshalConfig(append(opts, WithInclude()))
+
+	node, err := newVisitor(NewIncludedSet(), true, config).visitModelNode(model, "")
+	if err != nil {
+		return nil, err
+	}
+
+	name := config.memberName(relation)
+	relationship, ok := node.Relationships[name]
+	if !ok {
+		// an empty relation left out with omitempty
+		if relationship, ok = emptyRelationship(model, relation); !ok ||
+			!config.relationshipVisible(node.Type, name) {
+			return nil, ErrNoRelationship
+		}
+	}
+
+	if err := config.renameTypes([]*Node{node}); err != nil {
+		return nil, err
+	}
+
+	links, err := config.documentLinks()
+	if err != nil {
+		return nil, err
+	}
+	switch r := relationship.(type) {
+	case *RelationshipOneNode:
+		r.Links, r.Meta = mergeLinks(r.Links, links), mergeRelationshipMeta(r.Meta, config.meta)
+	case *RelationshipManyNode:
+		r.Links, r.Meta = mergeLinks(r.Links, links), mergeRelationshipMeta(r.Meta, config.meta)
+	case *RelationshipLinksNode:
+		r.Links, r.Meta = mergeLinks(r.Links, links), mergeRelationshipMeta(r.Meta, config.meta)
+	}
+	return relationship, nil
+}
+
+// emptyRelationship returns the relationship object with empty linkage of the
+// relation of model named relation, if model declares it.
+func emptyRelationship(model interface{}, relation string) (interface{}, bool) {
+	modelValue := reflect.Indirect(reflect.ValueOf(model))
+	if modelValue.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for _, f := range schemaOf(modelValue.Type()).fields {
+		if f.args[0] != annotationRelation || len(f.args) < 2 || f.args[1] != relation {
+			continue
+		}
+		switch derefType(f.field.Type).Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return &RelationshipManyNode{Data: []*Node{}}, true
+		default:
+			return &RelationshipOneNode{}, true
+		}
+	}
+	return nil, false
+}
+
+// mergeRelationshipMeta returns the meta of meta and extra, or nil when
+// neither is given.
+func mergeRelationshipMeta(meta, extra *Meta) *Meta {
+	if extra == nil {
+		return meta
+	}
+	return mergeMeta(meta, extra)
+}
+
+// mergeLinks returns the links of links and extra, those of extra taking
+// precedence.
+func mergeLinks(links, extra *Links) *Links {
+	if extra == nil {
+		return links
+	}
+	if links == nil {
+		return extra
+	}
+
+	merged := Links{}
+	for k, v := range *links {
+		merged[k] = v
+	}
+	for k, v := range *extra {
+		merged[k] = v
+	}
+	return &merged
+}
@@ -0,0 +1,78 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalManyRelationship(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := MarshalManyRelationship(out, testBlog(), "posts",
+		WithMeta(&Meta{"total": 2})); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"type": "posts", "id": "1"},
+		map[string]interface{}{"type": "posts", "id": "2"},
+	}
+	if !reflect.DeepEqual(expected, doc["data"]) {
+		t.Fatalf("Was expecting the linkage %v, got %v", expected, doc["data"])
+	}
+	if _, ok := doc["included"]; ok {
+		t.Fatal("Was expecting no included resources")
+	}
+	links, _ := doc["links"].(map[string]interface{})
+	if _, ok := links["related"]; !ok {
+		t.Fatalf("Was expecting the related link of the relationship, got %v", doc["links"])
+	}
+	meta, _ := doc["meta"].(map[string]interface{})
+	if e, a := float64(2), meta["total"]; e != a {
+		t.Fatalf("Was expecting the total %v merged into the meta, got %v", e, doc["meta"])
+	}
+}
+
+func TestMarshalOneRelationship(t *testing.T) {
+	blog := testBlog()
+
+	out := new(bytes.Buffer)
+	if err := MarshalOneRelationship(out, blog, "current_post"); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Data *Node `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Data == nil || doc.Data.Type != "posts" || doc.Data.ID != "1" || doc.Data.Attributes != nil {
+		t.Fatalf("Was expecting the identifier of post 1, got %+v", doc.Data)
+	}
+
+	blog.CurrentPost = nil
+	out.Reset()
+	if err := MarshalOneRelationship(out, blog, "current_post"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte(`{"data":null`)) {
+		t.Fatalf("Was expecting null linkage, got %s", out)
+	}
+}
+
+func TestMarshalRelationshipErrors(t *testing.T) {
+	if err := MarshalOneRelationship(new(bytes.Buffer), testBlog(), "authors"); err != ErrNoRelationship {
+		t.Fatalf("Was expecting ErrNoRelationship, got %v", err)
+	}
+	if err := MarshalOneRelationship(new(bytes.Buffer), testBlog(), "posts"); err != ErrRelationshipCardinality {
+		t.Fatalf("Was expecting ErrRelationshipCardinality, got %v", err)
+	}
+	if err := MarshalManyRelationship(new(bytes.Buffer), testBlog(), "current_post"); err != ErrRelationshipCardinality {
+		t.Fatalf("Was expecting ErrRelationshipCardinality, got %v", err)
+	}
+}
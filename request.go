@@ -2,13 +2,14 @@ package jsonapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -23,6 +24,13 @@ var (
 	// ErrInvalidISO8601 is returned when a struct has a time.Time type field and includes
 	// "iso8601" in the tag spec, but the JSON value was not an ISO8601 timestamp string.
 	ErrInvalidISO8601 = errors.New("Only strings can be parsed as dates, ISO8601 timestamps")
+	// ErrInvalidDate is returned when a struct has a time.Time type field
+	// tagged "date", but the JSON value was not a YYYY-MM-DD date string.
+	ErrInvalidDate = errors.New("Only strings can be parsed as dates, YYYY-MM-DD dates")
+	// ErrInvalidTimeLayout is returned when a struct has a time.Time type
+	// field tagged "rfc3339" or "format:<layout>", but the JSON value was not a
+	// string in that layout.
+	ErrInvalidTimeLayout = errors.New("Only strings in the layout of the tag can be parsed as dates")
 	// ErrUnknownFieldNumberType is returned when the JSON value was a float
 	// (numeric) but the Struct field was a non numeric type (i.e. not int, uint,
 	// float, etc)
@@ -32,6 +40,16 @@ var (
 	ErrUnsupportedPtrType = errors.New("Pointer type in struct is not supported")
 	// ErrInvalidType is returned when the given type is incompatible with the expected type.
 	ErrInvalidType = errors.New("Invalid type provided") // I wish we used punctuation.
+	// ErrUnknownAttribute is returned in strict mode (see Config) when the
+	// payload has an attribute that the model doesn't declare.
+	ErrUnknownAttribute = errors.New("The payload has an attribute unknown to the model")
+	// ErrUnknownRelationship is returned, with UnknownRelationshipsReject
+	// (see Config), when the payload has a relationship that the model
+	// doesn't declare.
+	ErrUnknownRelationship = errors.New("The payload has a relationship unknown to the model")
+	// ErrAttributeNotAllowed is returned, with AllowAttributes, when the
+	// payload has an attribute that can't be written.
+	ErrAttributeNotAllowed = errors.New("The payload has an attribute that is not allowed")
 )
 
 // UnmarshalPayload converts an io into a struct instance using jsonapi tags on
@@ -67,78 +85,720 @@ var (
 // Visit https://github.com/google/jsonapi#create for more info.
 //
 // model interface{} should be a pointer to a struct.
-func UnmarshalPayload(in io.Reader, model interface{}) error {
-	payload := new(OnePayload)
+func UnmarshalPayload(in io.Reader, model interface{}, opts ...UnmarshalOption) error {
+	_, err := unmarshalOne(in, model, newUnmarshalConfig(opts))
+	return err
+}
+
+// UnmarshalPayloadContext does the same as UnmarshalPayload, passing ctx to
+// the AfterUnmarshaler hooks of the models.
+func UnmarshalPayloadContext(ctx context.Context, in io.Reader, model interface{},
+	opts ...UnmarshalOption) error {
+	config := newUnmarshalConfig(opts)
+	config.ctx = ctx
+
+	_, err := unmarshalOne(in, model, config)
+	return err
+}
+
+// UnmarshalPayloadWithMeta does the same as UnmarshalPayload and also decodes
+// the top-level "meta" object of the document into meta, which should be a
+// pointer to a Meta, a map or a struct, e.g.
+//
+//	var meta struct {
+//		Total int `json:"total"`
+//	}
+//	err := jsonapi.UnmarshalPayloadWithMeta(resp.Body, blog, &meta)
+//
+// meta is left untouched when the document has no top-level "meta".
+func UnmarshalPayloadWithMeta(in io.Reader, model interface{}, meta interface{},
+	opts ...UnmarshalOption) error {
+	payload, err := unmarshalOne(in, model, newUnmarshalConfig(opts))
+	if err != nil {
+		return err
+	}
+
+	return unmarshalMeta(payload.Meta, meta)
+}
+
+// UnmarshalPayloadWithLinks does the same as UnmarshalPayload and also stores
+// the top-level "links" object of the document in links.
+func UnmarshalPayloadWithLinks(in io.Reader, model interface{}, links *Links,
+	opts ...UnmarshalOption) error {
+	payload, err := unmarshalOne(in, model, newUnmarshalConfig(opts))
+	if err != nil {
+		return err
+	}
+
+	if links != nil && payload.Links != nil {
+		*links = *payload.Links
+	}
+
+	return nil
+}
+
+// UnmarshalPayloadWithJSONAPI does the same as UnmarshalPayload and also
+// stores the top-level "jsonapi" object of the document in obj, e.g. for a
+// middleware to read the version of the specification or the meta of the
+// implementation of the client. obj is left untouched when the document has
+// no "jsonapi" object.
+func UnmarshalPayloadWithJSONAPI(in io.Reader, model interface{}, obj *JSONAPIObject,
+	opts ...UnmarshalOption) error {
+	payload, err := unmarshalOne(in, model, newUnmarshalConfig(opts))
+	if err != nil {
+		return err
+	}
+
+	if obj != nil && payload.JSONAPI != nil {
+		*obj = *payload.JSONAPI
+	}
+
+	return nil
+}
+
+// UnmarshalPayloadWithChanged does the same as UnmarshalPayload and also
+// returns the attributes and relationships of model present in the document,
+// by their tagged names, so that a member sent as null can be told apart from
+// one left out, e.g. when patching:
+//
+//	changed, err := jsonapi.UnmarshalPayloadWithChanged(r.Body, blog)
+//	...
+//	if changed["title"] {
+//		// update the title, even if it was set to null
+//	}
+func UnmarshalPayloadWithChanged(in io.Reader, model interface{},
+	opts ...UnmarshalOption) (map[string]bool, error) {
+	config := newUnmarshalConfig(opts)
+	payload, err := unmarshalOne(in, model, config)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	if payload.Data == nil {
+		return changed, nil
+	}
+	for _, f := range schemaOf(reflect.Indirect(reflect.ValueOf(model)).Type()).fields {
+		if len(f.args) < 2 {
+			continue
+		}
+		name := config.memberName(f.args[1])
+		switch f.args[0] {
+		case annotationAttribute:
+			if _, ok := payload.Data.Attributes[name]; ok {
+				changed[f.args[1]] = true
+			}
+		case annotationRelation:
+			if _, ok := payload.Data.Relationships[name]; ok {
+				changed[f.args[1]] = true
+			}
+		}
+	}
+	return changed, nil
+}
+
+// UnmarshalOneBytes does the same as UnmarshalPayload for a document held in
+// b, e.g. the body of a message read from a queue or a json.RawMessage. With
+// WithMaxSize, a document that is too large is rejected before being decoded.
+func UnmarshalOneBytes(b []byte, model interface{}, opts ...UnmarshalOption) error {
+	config := newUnmarshalConfig(opts)
+	if config.maxSize > 0 && len(b) > config.maxSize {
+		return ErrPayloadTooLarge
+	}
+
+	_, err := unmarshalOne(bytes.NewReader(b), model, config)
+	return err
+}
 
-	if err := json.NewDecoder(in).Decode(payload); err != nil {
+// unmarshalOne decodes a single resource payload from in and binds it to model.
+func unmarshalOne(in io.Reader, model interface{},
+	config *unmarshalConfig) (payload *OnePayload, err error) {
+	var span *tracedSpan
+	config.ctx, span = startSpan(config.ctx, config.tracer, "jsonapi.UnmarshalPayload")
+	defer func() {
+		if payload != nil && payload.Data != nil {
+			span.finish(payload.Data.Type, 1, len(payload.Included), err)
+		} else {
+			span.finish("", 0, 0, err)
+		}
+	}()
+
+	payload = new(OnePayload)
+
+	if err := config.decode(in, payload); err != nil {
+		return nil, err
+	}
+
+	if err := config.renameTypes([]*Node{payload.Data}, payload.Included); err != nil {
+		return nil, err
+	}
+
+	if err := unmarshalOnePayload(payload, model, config); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func unmarshalOnePayload(payload *OnePayload, model interface{}, config *unmarshalConfig) error {
+	if err := config.checkExtensions(payload.JSONAPI); err != nil {
 		return err
 	}
 
+	var includedMap *map[string]*Node
 	if payload.Included != nil {
-		includedMap := make(map[string]*Node)
+		m := make(map[string]*Node)
 		for _, included := range payload.Included {
 			key := fmt.Sprintf("%s,%s", included.Type, included.ID)
-			includedMap[key] = included
+			m[key] = included
 		}
+		includedMap = &m
+	}
+
+	u := newUnmarshaler(config, includedMap)
+	if err := u.unmarshalNode(payload.Data, reflect.ValueOf(model)); err != nil {
+		return err
+	}
 
-		return unmarshalNode(payload.Data, reflect.ValueOf(model), &includedMap)
+	if config.fidelity {
+		return u.checkFidelity([]*Node{payload.Data}, []reflect.Value{reflect.ValueOf(model)}, payload.Included)
 	}
-	return unmarshalNode(payload.Data, reflect.ValueOf(model), nil)
+	return nil
+}
+
+// UnmarshalOne binds the primary data of payload, a document that is already
+// decoded, e.g. read from a message queue or a cache, to model, resolving its
+// relationships from the included resources, without encoding it back to JSON
+// first. It is the counterpart of MarshalOne. The type renames of the options
+// are applied to the Nodes of payload in place, as is the normalization of the
+// pointers MarshalOne leaves in the attributes.
+func UnmarshalOne(payload *OnePayload, model interface{}, opts ...UnmarshalOption) error {
+	config := newUnmarshalConfig(opts)
+
+	if err := config.renameTypes([]*Node{payload.Data}, payload.Included); err != nil {
+		return err
+	}
+	normalizeAttributes([]*Node{payload.Data}, payload.Included)
+
+	return unmarshalOnePayload(payload, model, config)
+}
+
+// UnmarshalNode binds node to model, resolving its relationships from
+// included, as UnmarshalOne does for a payload.
+func UnmarshalNode(node *Node, model interface{}, included []*Node, opts ...UnmarshalOption) error {
+	return UnmarshalOne(&OnePayload{Data: node, Included: included}, model, opts...)
 }
 
 // UnmarshalManyPayload converts an io into a set of struct instances using
 // jsonapi tags on the type's struct fields.
-func UnmarshalManyPayload(in io.Reader, t reflect.Type) ([]interface{}, error) {
-	payload := new(ManyPayload)
+func UnmarshalManyPayload(in io.Reader, t reflect.Type,
+	opts ...UnmarshalOption) ([]interface{}, error) {
+	models, _, err := unmarshalMany(in, t, newUnmarshalConfig(opts))
+	return models, err
+}
 
-	if err := json.NewDecoder(in).Decode(payload); err != nil {
+// UnmarshalManyPayloadContext does the same as UnmarshalManyPayload, passing
+// ctx to the AfterUnmarshaler hooks of the models.
+func UnmarshalManyPayloadContext(ctx context.Context, in io.Reader,
+	t reflect.Type, opts ...UnmarshalOption) ([]interface{}, error) {
+	config := newUnmarshalConfig(opts)
+	config.ctx = ctx
+
+	models, _, err := unmarshalMany(in, t, config)
+	return models, err
+}
+
+// UnmarshalManyPayloadWithMeta does the same as UnmarshalManyPayload and also
+// decodes the top-level "meta" object of the document into meta. See
+// UnmarshalPayloadWithMeta.
+func UnmarshalManyPayloadWithMeta(in io.Reader, t reflect.Type,
+	meta interface{}, opts ...UnmarshalOption) ([]interface{}, error) {
+	models, payload, err := unmarshalMany(in, t, newUnmarshalConfig(opts))
+	if err != nil {
 		return nil, err
 	}
 
-	models := []interface{}{}         // will be populated from the "data"
-	includedMap := map[string]*Node{} // will be populate from the "included"
+	if err := unmarshalMeta(payload.Meta, meta); err != nil {
+		return nil, err
+	}
 
-	if payload.Included != nil {
-		for _, included := range payload.Included {
-			key := fmt.Sprintf("%s,%s", included.Type, included.ID)
-			includedMap[key] = included
+	return models, nil
+}
+
+// UnmarshalManyPayloadWithLinks does the same as UnmarshalManyPayload and also
+// stores the top-level "links" object of the document in links, so clients can
+// follow the pagination links, e.g.
+//
+//	var links jsonapi.Links
+//	posts, err := jsonapi.UnmarshalManyPayloadWithLinks(resp.Body, reflect.TypeOf(new(Post)), &links)
+//	...
+//	next := links.Href(jsonapi.KeyNextPage)
+func UnmarshalManyPayloadWithLinks(in io.Reader, t reflect.Type,
+	links *Links, opts ...UnmarshalOption) ([]interface{}, error) {
+	models, payload, err := unmarshalMany(in, t, newUnmarshalConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	if links != nil && payload.Links != nil {
+		*links = *payload.Links
+	}
+
+	return models, nil
+}
+
+// UnmarshalManyPayloadWithJSONAPI does the same as UnmarshalManyPayload and
+// also stores the top-level "jsonapi" object of the document in obj. See
+// UnmarshalPayloadWithJSONAPI.
+func UnmarshalManyPayloadWithJSONAPI(in io.Reader, t reflect.Type,
+	obj *JSONAPIObject, opts ...UnmarshalOption) ([]interface{}, error) {
+	models, payload, err := unmarshalMany(in, t, newUnmarshalConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	if obj != nil && payload.JSONAPI != nil {
+		*obj = *payload.JSONAPI
+	}
+
+	return models, nil
+}
+
+// UnmarshalManyBytes does the same as UnmarshalManyPayload for a document held
+// in b. See UnmarshalOneBytes.
+func UnmarshalManyBytes(b []byte, t reflect.Type, opts ...UnmarshalOption) ([]interface{}, error) {
+	config := newUnmarshalConfig(opts)
+	if config.maxSize > 0 && len(b) > config.maxSize {
+		return nil, ErrPayloadTooLarge
+	}
+
+	models, _, err := unmarshalMany(bytes.NewReader(b), t, config)
+	return models, err
+}
+
+// unmarshalMany decodes a many resource payload from in and binds it to new
+// instances of t.
+func unmarshalMany(in io.Reader, t reflect.Type,
+	config *unmarshalConfig) (models []interface{}, payload *ManyPayload, err error) {
+	var span *tracedSpan
+	config.ctx, span = startSpan(config.ctx, config.tracer, "jsonapi.UnmarshalManyPayload")
+	defer func() {
+		if payload != nil && len(payload.Data) > 0 {
+			span.finish(payload.Data[0].Type, len(payload.Data), len(payload.Included), err)
+		} else {
+			span.finish("", 0, 0, err)
+		}
+	}()
+
+	payload = new(ManyPayload)
+
+	if err := config.decode(in, payload); err != nil {
+		return nil, nil, err
+	}
+
+	if err := config.renameTypes(payload.Data, payload.Included); err != nil {
+		return nil, nil, err
+	}
+
+	models, err = unmarshalManyPayload(payload, t, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return models, payload, nil
+}
+
+// UnmarshalMany binds the primary data of payload, a document that is already
+// decoded, to new instances of t, as UnmarshalOne does for a single resource.
+// It is the counterpart of MarshalMany.
+func UnmarshalMany(payload *ManyPayload, t reflect.Type, opts ...UnmarshalOption) ([]interface{}, error) {
+	config := newUnmarshalConfig(opts)
+
+	if err := config.renameTypes(payload.Data, payload.Included); err != nil {
+		return nil, err
+	}
+	normalizeAttributes(payload.Data, payload.Included)
+
+	return unmarshalManyPayload(payload, t, config)
+}
+
+// normalizeAttributes replaces the values held by the attributes of nodes as
+// MarshalOne leaves them with those decoded from their JSON form: pointers are
+// followed, nil ones being null, and numbers are float64s.
+func normalizeAttributes(nodes ...[]*Node) {
+	for _, ns := range nodes {
+		for _, n := range ns {
+			if n == nil {
+				continue
+			}
+			for name, value := range n.Attributes {
+				n.Attributes[name] = normalizedValue(value)
+			}
+		}
+	}
+}
+
+func normalizedValue(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
 		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+func unmarshalManyPayload(payload *ManyPayload, t reflect.Type,
+	config *unmarshalConfig) ([]interface{}, error) {
+	if err := config.checkExtensions(payload.JSONAPI); err != nil {
+		return nil, err
 	}
 
+	models := []interface{}{} // will be populated from the "data"
+	includedMap := includedNodeMap(payload.Data, payload.Included)
+
+	u := newUnmarshaler(config, &includedMap)
+	values := make([]reflect.Value, 0, len(payload.Data))
 	for _, data := range payload.Data {
-		model := reflect.New(t.Elem())
-		err := unmarshalNode(data, model, &includedMap)
-		if err != nil {
-			return nil, err
+		model, ok := u.primaryModel(data, t)
+		if !ok {
+			if err := u.unmarshalNode(data, model); err != nil {
+				return nil, err
+			}
 		}
 		models = append(models, model.Interface())
+		values = append(values, model)
+	}
+
+	if config.fidelity {
+		if err := u.checkFidelity(payload.Data, values, payload.Included); err != nil {
+			return nil, err
+		}
 	}
 
 	return models, nil
 }
 
-func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node) (err error) {
+// includedNodeMap indexes by type and id the Nodes that relationships are
+// resolved against: the included Nodes, and the primary data, which the
+// relationships of a many payload may point to as it is not repeated in
+// included.
+func includedNodeMap(nodes ...[]*Node) map[string]*Node {
+	includedMap := make(map[string]*Node)
+	for _, ns := range nodes {
+		for _, n := range ns {
+			key := fmt.Sprintf("%s,%s", n.Type, n.ID)
+			includedMap[key] = n
+		}
+	}
+	return includedMap
+}
+
+// unmarshalMeta decodes a document meta object into target.
+func unmarshalMeta(meta *Meta, target interface{}) error {
+	if meta == nil || target == nil {
+		return nil
+	}
+
+	if m, ok := target.(*Meta); ok {
+		*m = *meta
+		return nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(meta); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(buf).Decode(target)
+}
+
+// unmarshalMetaMember binds value, a member of a meta object, to fieldValue as
+// encoding/json does.
+func unmarshalMetaMember(value interface{}, fieldValue reflect.Value) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	target := reflect.New(fieldValue.Type())
+	if err := json.Unmarshal(b, target.Interface()); err != nil {
+		return ErrInvalidType
+	}
+	fieldValue.Set(target.Elem())
+	return nil
+}
+
+// relationshipMetaOf returns the meta of relationship, a relationship object
+// of a payload, if any.
+func relationshipMetaOf(relationship interface{}) (*Meta, error) {
+	if relationship == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	var r struct {
+		Meta *Meta `json:"meta"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return r.Meta, nil
+}
+
+// parseTimeAttribute parses the decoded value of a time attribute: a string
+// in layout if it is set, or else a unix timestamp.
+func parseTimeAttribute(val interface{}, layout string) (time.Time, error) {
+	if layout == unixMilliLayout {
+		switch at := val.(type) {
+		case float64:
+			return time.Unix(0, int64(at)*int64(time.Millisecond)), nil
+		case int:
+			return time.Unix(0, int64(at)*int64(time.Millisecond)), nil
+		case int64:
+			return time.Unix(0, at*int64(time.Millisecond)), nil
+		default:
+			return time.Time{}, ErrInvalidTime
+		}
+	}
+
+	if layout != "" {
+		invalid := ErrInvalidTimeLayout
+		switch layout {
+		case dateFormat:
+			invalid = ErrInvalidDate
+		case iso8601TimeFormat:
+			invalid = ErrInvalidISO8601
+		}
+
+		tm, ok := val.(string)
+		if !ok {
+			return time.Time{}, invalid
+		}
+
+		t, err := time.Parse(layout, tm)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		return t, nil
+	}
+
+	switch at := val.(type) {
+	case float64:
+		return time.Unix(int64(at), 0), nil
+	case int:
+		return time.Unix(int64(at), 0), nil
+	case int64:
+		// as marshaled, when the payload wasn't encoded
+		return time.Unix(at, 0), nil
+	default:
+		return time.Time{}, ErrInvalidTime
+	}
+}
+
+func isContainerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// decodeAttributeValue converts the decoded attribute val to a value of type
+// t, as encoding/json would decode it, so that the json tags of the structs it
+// holds apply.
+func decodeAttributeValue(val interface{}, t reflect.Type) (reflect.Value, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(val); err != nil {
+		return reflect.Value{}, err
+	}
+
+	value := reflect.New(t)
+	if err := json.NewDecoder(buf).Decode(value.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return value.Elem(), nil
+}
+
+// unmarshalLinkageMeta stores the meta of the resource identifiers of
+// relationship in a linkage-meta annotated field, which must be a []*Meta for
+// a to-many relationship or a *Meta for a to-one relationship.
+func unmarshalLinkageMeta(relationship interface{}, fieldValue reflect.Value) error {
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(relationship); err != nil {
+		return err
+	}
+
+	switch fieldValue.Type() {
+	case reflect.TypeOf([]*Meta{}):
+		r := new(RelationshipManyNode)
+		if err := json.NewDecoder(buf).Decode(r); err != nil {
+			return err
+		}
+
+		metas := make([]*Meta, len(r.Data))
+		for i, n := range r.Data {
+			metas[i] = n.Meta
+		}
+		fieldValue.Set(reflect.ValueOf(metas))
+	case reflect.TypeOf(new(Meta)):
+		r := new(RelationshipOneNode)
+		if err := json.NewDecoder(buf).Decode(r); err != nil {
+			return err
+		}
+
+		if r.Data != nil {
+			fieldValue.Set(reflect.ValueOf(r.Data.Meta))
+		}
+	default:
+		return ErrBadJSONAPIStructTag
+	}
+
+	return nil
+}
+
+// unmarshaler binds the Nodes of a single payload to models, resolving
+// relationships from the included Nodes.
+type unmarshaler struct {
+	config   *unmarshalConfig
+	included *map[string]*Node
+
+	// resolving holds the type/id keys of the Nodes on the path currently
+	// being unmarshaled, used to detect cycles between included resources.
+	resolving map[string]bool
+
+	// depth is the number of relationships between the primary data and the
+	// Node currently being unmarshaled.
+	depth int
+
+	// models holds the model bound to each type/id, shared by all the
+	// relationships to the same resource.
+	models map[string]reflect.Value
+
+	// probing skips the AfterUnmarshaler and Validator hooks, for the members
+	// bound alone to a scratch model to report their errors.
+	probing bool
+}
+
+func newUnmarshaler(config *unmarshalConfig, included *map[string]*Node) *unmarshaler {
+	return &unmarshaler{
+		config:    config,
+		included:  included,
+		resolving: make(map[string]bool),
+		models:    make(map[string]reflect.Value),
+	}
+}
+
+// logUnknownAttributes reports the attributes of data that are not in attrs,
+// and so were skipped.
+func (u *unmarshaler) logUnknownAttributes(data *Node, attrs map[string]bool) {
+	names := make([]string, 0, len(data.Attributes))
+	for name := range data.Attributes {
+		if !attrs[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		u.config.logAnomaly(Anomaly{
+			Kind:         AnomalyUnknownAttribute,
+			ResourceType: data.Type,
+			ResourceID:   data.ID,
+			Field:        name,
+		})
+	}
+}
+
+func (u *unmarshaler) unmarshalNode(data *Node, model reflect.Value) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("data is not a jsonapi representation of '%v'", model.Type())
 		}
 	}()
 
+	if err := u.checkAllowedAttributes(data); err != nil {
+		return err
+	}
+
+	if m, ok := model.Interface().(NodeUnmarshaler); ok {
+		if err := m.UnmarshalJSONAPINode(data); err != nil {
+			return err
+		}
+		return u.afterUnmarshal(model)
+	}
+
+	if max := u.config.maxRelationshipDepth; max > 0 && u.depth > max {
+		return ErrRelationshipTooDeep
+	}
+	u.depth++
+	defer func() { u.depth-- }()
+
+	key := fmt.Sprintf("%s,%s", data.Type, data.ID)
+	if !u.resolving[key] {
+		u.resolving[key] = true
+		defer delete(u.resolving, key)
+	}
+	if _, ok := u.models[key]; !ok && data.ID != "" {
+		u.models[key] = model
+		defer func() {
+			// a model that failed is not shared with the later references
+			if err != nil {
+				delete(u.models, key)
+			}
+		}()
+	}
+
 	modelValue := model.Elem()
 	modelType := model.Type().Elem()
 
-	var er error
+	// attrs holds the attribute names known to the model
+	attrs := make(map[string]bool)
 
-	for i := 0; i < modelValue.NumField(); i++ {
-		fieldType := modelType.Field(i)
-		tag := fieldType.Tag.Get("jsonapi")
-		if tag == "" {
-			continue
-		}
+	// rels holds the relationship names known to the model
+	rels := make(map[string]bool)
+
+	// extraRelations is the field collecting the unknown relationships
+	var extraRelations reflect.Value
 
-		fieldValue := modelValue.Field(i)
+	// extras is the field collecting the unknown attributes
+	var extras reflect.Value
 
-		args := strings.Split(tag, ",")
+	// inverses holds the relationship fields whose related models have an
+	// inverse relationship, set once the relationships are bound
+	var inverses []inverseField
+
+	// promoted holds the fields promoted through nil embedded pointers, bound
+	// to scratch values, so that the pointers are only allocated for the
+	// values actually bound
+	var promoted []promotedField
+
+	var er error
+
+	for _, f := range schemaOf(modelType).fields {
+		fieldType, args := f.field, f.args
+
+		fieldValue := f.value(modelValue)
+		if !fieldValue.IsValid() {
+			fieldValue = reflect.New(fieldType.Type).Elem()
+			promoted = append(promoted, promotedField{field: f, value: fieldValue})
+		}
 
 		if len(args) < 1 {
 			er = ErrBadJSONAPIStructTag
@@ -147,12 +807,19 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 
 		annotation := args[0]
 
-		if (annotation == annotationClientID && len(args) != 1) ||
-			(annotation != annotationClientID && len(args) < 2) {
+		if !validTagArgs(args) {
 			er = ErrBadJSONAPIStructTag
 			break
 		}
 
+		if annotation == annotationAttribute || annotation == annotationRelation ||
+			annotation == annotationLinkageMeta || annotation == annotationRelationPresent {
+			// args are shared by every call; rename a copy
+			if name := u.config.memberName(args[1]); name != args[1] {
+				args = append([]string{args[0], name}, args[2:]...)
+			}
+		}
+
 		if annotation == annotationPrimary {
 			if data.ID == "" {
 				continue
@@ -168,140 +835,227 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 				break
 			}
 
-			// ID will have to be transmitted as astring per the JSON API spec
-			v := reflect.ValueOf(data.ID)
+			// Deal with PTRS, however many levels
+			kind := fieldType.Type.Kind()
+			for t := fieldType.Type; t.Kind() == reflect.Ptr; t = t.Elem() {
+				kind = t.Elem().Kind()
+			}
 
-			// Deal with PTRS
-			var kind reflect.Kind
-			if fieldValue.Kind() == reflect.Ptr {
-				kind = fieldType.Type.Elem().Kind()
-			} else {
-				kind = fieldType.Type.Kind()
+			idValue, err := parseIDValue(data.ID, kind)
+			if err != nil {
+				er = err
+				break
 			}
 
-			// Handle String case
-			if kind == reflect.String {
-				assign(fieldValue, v)
+			assign(fieldValue, idValue)
+		} else if annotation == annotationClientID {
+			if data.ClientID == "" {
 				continue
 			}
 
-			// Value was not a string... only other supported type was a numeric,
-			// which would have been sent as a float value.
-			floatValue, err := strconv.ParseFloat(data.ID, 64)
-			if err != nil {
-				// Could not convert the value in the "id" attr to a float
-				er = ErrBadJSONAPIID
-				break
+			fieldValue.Set(reflect.ValueOf(data.ClientID))
+		} else if annotation == annotationLocalID {
+			if data.LocalID == "" {
+				continue
 			}
 
-			// Convert the numeric float to one of the supported ID numeric types
-			// (int[8,16,32,64] or uint[8,16,32,64])
-			var idValue reflect.Value
-			switch kind {
-			case reflect.Int:
-				n := int(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Int8:
-				n := int8(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Int16:
-				n := int16(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Int32:
-				n := int32(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Int64:
-				n := int64(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Uint:
-				n := uint(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Uint8:
-				n := uint8(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Uint16:
-				n := uint16(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Uint32:
-				n := uint32(floatValue)
-				idValue = reflect.ValueOf(&n)
-			case reflect.Uint64:
-				n := uint64(floatValue)
-				idValue = reflect.ValueOf(&n)
+			fieldValue.Set(reflect.ValueOf(data.LocalID))
+		} else if annotation == annotationLinks {
+			if data.Links == nil {
+				continue
+			}
+
+			switch fieldValue.Type() {
+			case reflect.TypeOf(new(Links)):
+				links := *data.Links
+				fieldValue.Set(reflect.ValueOf(&links))
+			case reflect.TypeOf(Links{}):
+				fieldValue.Set(reflect.ValueOf(*data.Links))
 			default:
-				// We had a JSON float (numeric), but our field was not one of the
-				// allowed numeric types
-				er = ErrBadJSONAPIID
+				er = ErrBadJSONAPIStructTag
+			}
+		} else if annotation == annotationMeta && len(args) > 1 {
+			meta := data.Meta
+			if rel := metaRelation(args); rel != "" {
+				if meta, er = relationshipMetaOf(data.Relationships[u.config.memberName(rel)]); er != nil {
+					break
+				}
+			}
+			if meta == nil {
+				continue
+			}
+			value, ok := (*meta)[args[1]]
+			if !ok {
+				continue
+			}
+
+			if err := unmarshalMetaMember(value, fieldValue); err != nil {
+				er = err
 				break
 			}
+		} else if annotation == annotationMeta {
+			if data.Meta == nil {
+				continue
+			}
 
-			assign(fieldValue, idValue)
-		} else if annotation == annotationClientID {
-			if data.ClientID == "" {
+			if err := unmarshalMeta(data.Meta, fieldValue.Addr().Interface()); err != nil {
+				er = err
+				break
+			}
+		} else if annotation == annotationLinkageMeta {
+			if data.Relationships == nil || data.Relationships[args[1]] == nil {
 				continue
 			}
 
-			fieldValue.Set(reflect.ValueOf(data.ClientID))
+			if err := unmarshalLinkageMeta(data.Relationships[args[1]], fieldValue); err != nil {
+				er = err
+				break
+			}
+		} else if annotation == annotationRelationPresent {
+			// The member counts even when its data is null or empty, so that
+			// clearing a relationship can be told from leaving it out
+			if _, ok := data.Relationships[args[1]]; !ok {
+				continue
+			}
+
+			if fieldValue.Kind() != reflect.Bool {
+				er = ErrBadJSONAPIStructTag
+				break
+			}
+			fieldValue.SetBool(true)
 		} else if annotation == annotationAttribute {
+			attrs[args[1]] = true
+
 			attributes := data.Attributes
 			if attributes == nil || len(data.Attributes) == 0 {
 				continue
 			}
 
-			var iso8601 bool
+			layout, err := attributeTimeLayout(args, u.config.defaults.TimeFormat)
+			if err != nil {
+				er = err
+				break
+			}
+
+			val := attributes[args[1]]
+
+			// continue if the attribute was not included in the request
+			if val == nil {
+				continue
+			}
+
+			v := reflect.ValueOf(val)
+
+			// Handle types parsing their own representation
+			if ok, err := unmarshalCustomAttribute(val, fieldValue); ok {
+				if err != nil {
+					er = err
+					break
+				}
+				continue
+			}
+
+			// Handle numbers and booleans encoded as strings with the
+			// "string" option; values that are not strings are bound as usual
+			if s, ok := val.(string); ok && attributeStringEncoded(args) {
+				value, ok, err := parseStringAttribute(s, fieldValue.Type())
+				if err != nil {
+					er = err
+					break
+				}
+				if ok {
+					assign(fieldValue, value)
+					continue
+				}
+			}
+
+			if u.config.coerce {
+				if value, ok := coerceAttribute(val, fieldValue.Type()); ok {
+					assign(fieldValue, value)
+					u.config.logAnomaly(Anomaly{
+						Kind:         AnomalyAttributeCoerced,
+						ResourceType: data.Type,
+						ResourceID:   data.ID,
+						Field:        args[1],
+					})
+					continue
+				}
+			}
+
+			// Handle interface typed fields, e.g. interface{}
+			if fieldValue.Kind() == reflect.Interface {
+				value, err := interfaceAttributeValue(val, fieldValue.Type(), attributeDiscriminator(args))
+				if err != nil {
+					er = err
+					break
+				}
+				fieldValue.Set(value)
+				continue
+			}
+
+			// Handle field of type time.Time
+			if fieldValue.Type() == reflect.TypeOf(time.Time{}) {
+				t, err := parseTimeAttribute(val, layout)
+				if err != nil {
+					er = err
+					break
+				}
+
+				fieldValue.Set(reflect.ValueOf(t))
+
+				continue
+			}
+
+			if fieldValue.Type() == reflect.TypeOf([]time.Time{}) {
+				if v.Kind() != reflect.Slice {
+					er = ErrInvalidType
+					break
+				}
 
-			if len(args) > 2 {
-				for _, arg := range args[2:] {
-					if arg == annotationISO8601 {
-						iso8601 = true
+				times := make([]time.Time, v.Len())
+				for i := 0; i < v.Len(); i++ {
+					t, err := parseTimeAttribute(v.Index(i).Interface(), layout)
+					if err != nil {
+						er = err
+						break
 					}
+					times[i] = t
+				}
+				if er != nil {
+					break
 				}
-			}
 
-			val := attributes[args[1]]
+				fieldValue.Set(reflect.ValueOf(times))
 
-			// continue if the attribute was not included in the request
-			if val == nil {
 				continue
 			}
 
-			v := reflect.ValueOf(val)
+			if fieldValue.Type() == reflect.TypeOf([]*time.Time{}) {
+				if v.Kind() != reflect.Slice {
+					er = ErrInvalidType
+					break
+				}
 
-			// Handle field of type time.Time
-			if fieldValue.Type() == reflect.TypeOf(time.Time{}) {
-				if iso8601 {
-					var tm string
-					if v.Kind() == reflect.String {
-						tm = v.Interface().(string)
-					} else {
-						er = ErrInvalidISO8601
-						break
+				// null elements are restored as nil
+				times := make([]*time.Time, v.Len())
+				for i := 0; i < v.Len(); i++ {
+					elem := v.Index(i).Interface()
+					if elem == nil {
+						continue
 					}
 
-					t, err := time.Parse(iso8601TimeFormat, tm)
+					t, err := parseTimeAttribute(elem, layout)
 					if err != nil {
-						er = ErrInvalidISO8601
+						er = err
 						break
 					}
-
-					fieldValue.Set(reflect.ValueOf(t))
-
-					continue
+					times[i] = &t
 				}
-
-				var at int64
-
-				if v.Kind() == reflect.Float64 {
-					at = int64(v.Interface().(float64))
-				} else if v.Kind() == reflect.Int {
-					at = v.Int()
-				} else {
-					return ErrInvalidTime
+				if er != nil {
+					break
 				}
 
-				t := time.Unix(at, 0)
-
-				fieldValue.Set(reflect.ValueOf(t))
+				fieldValue.Set(reflect.ValueOf(times))
 
 				continue
 			}
@@ -318,42 +1072,13 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 			}
 
 			if fieldValue.Type() == reflect.TypeOf(new(time.Time)) {
-				if iso8601 {
-					var tm string
-					if v.Kind() == reflect.String {
-						tm = v.Interface().(string)
-					} else {
-						er = ErrInvalidISO8601
-						break
-					}
-
-					v, err := time.Parse(iso8601TimeFormat, tm)
-					if err != nil {
-						er = ErrInvalidISO8601
-						break
-					}
-
-					t := &v
-
-					fieldValue.Set(reflect.ValueOf(t))
-
-					continue
-				}
-
-				var at int64
-
-				if v.Kind() == reflect.Float64 {
-					at = int64(v.Interface().(float64))
-				} else if v.Kind() == reflect.Int {
-					at = v.Int()
-				} else {
-					return ErrInvalidTime
+				t, err := parseTimeAttribute(val, layout)
+				if err != nil {
+					er = err
+					break
 				}
 
-				v := time.Unix(at, 0)
-				t := &v
-
-				fieldValue.Set(reflect.ValueOf(t))
+				fieldValue.Set(reflect.ValueOf(&t))
 
 				continue
 			}
@@ -418,6 +1143,20 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 				continue
 			}
 
+			// Structs, and maps, slices and arrays that may hold them, e.g.
+			// map[string][]Item, or pointers to them, e.g. *Address, are
+			// decoded from their JSON form
+			if isContainerKind(derefType(fieldValue.Type()).Kind()) &&
+				!v.Type().AssignableTo(fieldValue.Type()) {
+				value, err := decodeAttributeValue(val, fieldValue.Type())
+				if err != nil {
+					er = err
+					break
+				}
+				fieldValue.Set(value)
+				continue
+			}
+
 			// Field was a Pointer type
 			if fieldValue.Kind() == reflect.Ptr {
 				var concreteVal reflect.Value
@@ -451,14 +1190,50 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 			}
 			fieldValue.Set(reflect.ValueOf(val))
 
+		} else if annotation == annotationExtraRelations {
+			extraRelations = fieldValue
+		} else if annotation == annotationExtras {
+			extras = fieldValue
 		} else if annotation == annotationRelation {
-			isSlice := fieldValue.Type().Kind() == reflect.Slice
+			rels[args[1]] = true
 
 			if data.Relationships == nil || data.Relationships[args[1]] == nil {
 				continue
 			}
+			if !hasResourceLinkage(data.Relationships[args[1]]) {
+				// links or meta only; the related records are unknown
+				continue
+			}
+
+			fieldValue = relationField(fieldValue)
+			isSlice := fieldValue.Type().Kind() == reflect.Slice
+
+			if inverse := relationInverse(args); inverse != "" && u.config.inverseRelationships {
+				inverses = append(inverses, inverseField{fieldValue, inverse})
+			}
+
+			if _, idsOnly := relationIDsType(args); idsOnly {
+				if err := unmarshalRelationshipIDs(
+					data.Relationships[args[1]],
+					fieldValue,
+				); err != nil {
+					er = err
+					break
+				}
+				continue
+			}
 
-			if isSlice {
+			if fieldValue.Kind() == reflect.Map {
+				// to-many relationship held by a map
+				if err := u.unmarshalRelationshipMap(
+					data.Relationships[args[1]],
+					fieldValue,
+					relationMapKeyMeta(args),
+				); err != nil {
+					er = err
+					break
+				}
+			} else if isSlice {
 				// to-many relationship
 				relationship := new(RelationshipManyNode)
 
@@ -471,13 +1246,8 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 				models := reflect.New(fieldValue.Type()).Elem()
 
 				for _, n := range data {
-					m := reflect.New(fieldValue.Type().Elem().Elem())
-
-					if err := unmarshalNode(
-						fullNode(n, included),
-						m,
-						included,
-					); err != nil {
+					m, err := u.relatedModel(n, fieldValue.Type().Elem())
+					if err != nil {
 						er = err
 						break
 					}
@@ -507,12 +1277,8 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 					continue
 				}
 
-				m := reflect.New(fieldValue.Type().Elem())
-				if err := unmarshalNode(
-					fullNode(relationship.Data, included),
-					m,
-					included,
-				); err != nil {
+				m, err := u.relatedModel(relationship.Data, fieldValue.Type())
+				if err != nil {
 					er = err
 					break
 				}
@@ -526,22 +1292,538 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 		}
 	}
 
-	return er
+	if er != nil {
+		return er
+	}
+
+	for _, f := range inverses {
+		setInverseRelationships(model, f.field, f.inverse)
+	}
+
+	// The attributes without a field are handed to an AttributeSetter
+	if setter, ok := model.Interface().(AttributeSetter); ok {
+		rest := make(map[string]interface{})
+		for name, value := range data.Attributes {
+			if !attrs[name] {
+				rest[name] = value
+				attrs[name] = true
+			}
+		}
+
+		if err := setter.SetJSONAPIAttributes(rest); err != nil {
+			return err
+		}
+	}
+
+	// The attributes still without a field are collected by the extras
+	// annotated field
+	if extras.IsValid() {
+		if extras.Type() != reflect.TypeOf(map[string]interface{}{}) {
+			return ErrBadJSONAPIStructTag
+		}
+
+		rest := make(map[string]interface{})
+		for name, value := range data.Attributes {
+			if !attrs[name] {
+				rest[name] = value
+				attrs[name] = true
+			}
+		}
+		if len(rest) > 0 {
+			extras.Set(reflect.ValueOf(rest))
+		}
+	}
+
+	if u.config.logger != nil {
+		u.logUnknownAttributes(data, attrs)
+	}
+
+	if u.config.defaults.Strict {
+		for name := range data.Attributes {
+			if !attrs[name] {
+				return ErrUnknownAttribute
+			}
+		}
+	}
+
+	if err := u.unknownRelationships(data, rels, extraRelations); err != nil {
+		return err
+	}
+
+	for _, p := range promoted {
+		if !p.value.IsZero() {
+			p.field.settable(modelValue).Set(p.value)
+		}
+	}
+
+	return u.afterUnmarshal(model)
+}
+
+// promotedField is a field promoted through a nil embedded pointer, bound by
+// unmarshalNode to value.
+type promotedField struct {
+	field modelField
+	value reflect.Value
+}
+
+// unknownRelationships handles the relationships of data that are not in
+// rels as told by the UnknownRelationships of the Config, collecting them in
+// field, the extra-relationships annotated field if the model has one.
+func (u *unmarshaler) unknownRelationships(data *Node, rels map[string]bool, field reflect.Value) error {
+	policy := u.config.defaults.UnknownRelationships
+	if policy == UnknownRelationshipsIgnore {
+		return nil
+	}
+
+	for name, rel := range data.Relationships {
+		if rels[name] {
+			continue
+		}
+
+		switch policy {
+		case UnknownRelationshipsReject:
+			return fmt.Errorf("%w: %s", ErrUnknownRelationship, name)
+		case UnknownRelationshipsCollect:
+			if !field.IsValid() {
+				return nil
+			}
+			if field.Type() != reflect.TypeOf(map[string]interface{}{}) {
+				return ErrBadJSONAPIStructTag
+			}
+
+			relationship, err := genericRelationship(rel)
+			if err != nil {
+				return err
+			}
+			if field.IsNil() {
+				field.Set(reflect.ValueOf(make(map[string]interface{})))
+			}
+			field.SetMapIndex(reflect.ValueOf(name), reflect.ValueOf(relationship))
+		}
+	}
+	return nil
+}
+
+// genericRelationship returns the relationship object rel, as decoded, as a
+// *RelationshipManyNode when its data is an array, a *RelationshipOneNode
+// when its data is an object or null, and a *RelationshipLinksNode when it
+// has no data.
+func genericRelationship(rel interface{}) (interface{}, error) {
+	b, err := json.Marshal(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	var relationship struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &relationship); err != nil {
+		return nil, err
+	}
+
+	var node interface{}
+	switch data := bytes.TrimSpace(relationship.Data); {
+	case len(data) == 0:
+		node = new(RelationshipLinksNode)
+	case data[0] == '[':
+		node = new(RelationshipManyNode)
+	default:
+		node = new(RelationshipOneNode)
+	}
+	if err := json.Unmarshal(b, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// RelationshipLinkage returns the resource identifiers of the linkage of
+// relationship, a relationship object of a Node as decoded from a payload,
+// e.g. for the NodeUnmarshaler methods generated by jsonapigen: none when its
+// data is null, and false when it has no data.
+func RelationshipLinkage(relationship interface{}) ([]*Node, bool, error) {
+	rel, err := genericRelationship(relationship)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch r := rel.(type) {
+	case *RelationshipManyNode:
+		return r.Data, true, nil
+	case *RelationshipOneNode:
+		if r.Data == nil {
+			return nil, true, nil
+		}
+		return []*Node{r.Data}, true, nil
+	}
+	return nil, false, nil
+}
+
+// checkAllowedAttributes enforces the attributes allowed by AllowAttributes
+// for the type of data: the others are dropped, with
+// DropDisallowedAttributes, or rejected with ErrAttributeNotAllowed.
+func (u *unmarshaler) checkAllowedAttributes(data *Node) error {
+	allowed, ok := u.config.allowedAttributes[data.Type]
+	if !ok {
+		return nil
+	}
+
+	names := make([]string, 0, len(data.Attributes))
+	for name := range data.Attributes {
+		if !allowed[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !u.config.dropDisallowedAttributes {
+			return ErrAttributeNotAllowed
+		}
+
+		delete(data.Attributes, name)
+		u.config.logAnomaly(Anomaly{
+			Kind:         AnomalyAttributeDropped,
+			ResourceType: data.Type,
+			ResourceID:   data.ID,
+			Field:        name,
+		})
+	}
+
+	return nil
+}
+
+// afterUnmarshal invokes the AfterUnmarshaler hook of model, if any.
+func (u *unmarshaler) afterUnmarshal(model reflect.Value) error {
+	if u.probing {
+		return nil
+	}
+
+	if hook, ok := model.Interface().(AfterUnmarshaler); ok {
+		if err := hook.AfterJSONAPIUnmarshal(u.config.ctx); err != nil {
+			return err
+		}
+	}
+
+	if validator, ok := model.Interface().(Validator); ok {
+		if errs := validator.ValidateJSONAPI(); len(errs) > 0 {
+			return &ValidationError{Errors: errs}
+		}
+	}
+
+	return nil
+}
+
+// primaryModel returns the model of type t, a pointer to a struct, for the
+// primary resource data, and whether it is the model already bound to data as
+// the related resource of a previous primary resource, in which case it is not
+// unmarshaled again.
+func (u *unmarshaler) primaryModel(data *Node, t reflect.Type) (reflect.Value, bool) {
+	key := fmt.Sprintf("%s,%s", data.Type, data.ID)
+	if m, ok := u.models[key]; ok && data.ID != "" && m.Type() == t {
+		return m, true
+	}
+	return reflect.New(t.Elem()), false
+}
+
+// relatedModel returns the model of type t, a pointer to a struct, bound to
+// the related resource n. The model already bound to the same resource, e.g.
+// as the primary data or through another relationship, is reused, so that the
+// graph holds a single pointer per resource. For an interface t, the model is
+// built by the function registered for the type of n with RegisterType.
+func (u *unmarshaler) relatedModel(n *Node, t reflect.Type) (reflect.Value, error) {
+	key := fmt.Sprintf("%s,%s", n.Type, n.ID)
+	if m, ok := u.models[key]; ok && (m.Type() == t ||
+		t.Kind() == reflect.Interface && m.Type().Implements(t)) {
+		return m, nil
+	}
+
+	var m reflect.Value
+	if t.Kind() == reflect.Interface {
+		var err error
+		if m, err = newRegisteredModel(n.Type, t); err != nil {
+			return m, err
+		}
+	} else {
+		m = reflect.New(t.Elem())
+	}
+	if err := u.unmarshalNode(u.fullNode(n), m); err != nil {
+		return m, err
+	}
+	return m, nil
 }
 
-func fullNode(n *Node, included *map[string]*Node) *Node {
+// fullNode returns the included resource for the resource identifier n. A
+// resource that is already being unmarshaled further up the graph is a cycle,
+// so n itself is returned and only its identifier is bound.
+func (u *unmarshaler) fullNode(n *Node) *Node {
 	includedKey := fmt.Sprintf("%s,%s", n.Type, n.ID)
 
-	if included != nil && (*included)[includedKey] != nil {
-		return (*included)[includedKey]
+	if u.resolving[includedKey] {
+		return n
+	}
+
+	if u.included != nil && (*u.included)[includedKey] != nil {
+		return (*u.included)[includedKey]
 	}
 
 	return n
 }
 
+// hasResourceLinkage reports whether relationship, a relationship object as
+// decoded or as built, has a "data" member, which may be null.
+func hasResourceLinkage(relationship interface{}) bool {
+	switch r := relationship.(type) {
+	case map[string]interface{}:
+		_, ok := r["data"]
+		return ok
+	case *RelationshipLinksNode:
+		return false
+	}
+	return true
+}
+
+// unmarshalRelationshipIDs stores the ID(s) of the relationship linkage in a
+// relation field annotated with the "ids" option.
+func unmarshalRelationshipIDs(relationship interface{}, fieldValue reflect.Value) error {
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(relationship); err != nil {
+		return err
+	}
+
+	if fieldValue.Kind() == reflect.Slice {
+		r := new(RelationshipManyNode)
+		if err := json.NewDecoder(buf).Decode(r); err != nil {
+			return err
+		}
+
+		ids := reflect.MakeSlice(fieldValue.Type(), 0, len(r.Data))
+		for _, n := range r.Data {
+			id, err := parseIDValue(n.ID, fieldValue.Type().Elem().Kind())
+			if err != nil {
+				return err
+			}
+			ids = reflect.Append(ids, id.Elem())
+		}
+		fieldValue.Set(ids)
+
+		return nil
+	}
+
+	r := new(RelationshipOneNode)
+	if err := json.NewDecoder(buf).Decode(r); err != nil {
+		return err
+	}
+	if r.Data == nil {
+		return nil
+	}
+
+	kind := fieldValue.Kind()
+	if kind == reflect.Ptr {
+		kind = fieldValue.Type().Elem().Kind()
+	}
+
+	id, err := parseIDValue(r.Data.ID, kind)
+	if err != nil {
+		return err
+	}
+	assign(fieldValue, id)
+
+	return nil
+}
+
+// parseIDValue converts id into a pointer to a value of the given kind, which
+// must be a string or one of the supported numeric ID types.
+func parseIDValue(id string, kind reflect.Kind) (reflect.Value, error) {
+	// ID will have to be transmitted as astring per the JSON API spec
+	if kind == reflect.String {
+		return reflect.ValueOf(&id), nil
+	}
+
+	// Value was not a string... only other supported type was a numeric,
+	// which would have been sent as a float value.
+	floatValue, err := strconv.ParseFloat(id, 64)
+	if err != nil {
+		// Could not convert the value in the "id" attr to a float
+		return reflect.Value{}, ErrBadJSONAPIID
+	}
+
+	// Convert the numeric float to one of the supported ID numeric types
+	// (int[8,16,32,64] or uint[8,16,32,64])
+	var idValue reflect.Value
+	switch kind {
+	case reflect.Int:
+		n := int(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Int8:
+		n := int8(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Int16:
+		n := int16(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Int32:
+		n := int32(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Int64:
+		n := int64(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Uint:
+		n := uint(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Uint8:
+		n := uint8(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Uint16:
+		n := uint16(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Uint32:
+		n := uint32(floatValue)
+		idValue = reflect.ValueOf(&n)
+	case reflect.Uint64:
+		n := uint64(floatValue)
+		idValue = reflect.ValueOf(&n)
+	default:
+		// We had a JSON float (numeric), but our field was not one of the
+		// allowed numeric types
+		return reflect.Value{}, ErrBadJSONAPIID
+	}
+
+	return idValue, nil
+}
+
+// unmarshalRelationshipMap binds the related resources of a to-many
+// relationship to a map-typed field, keyed by the metaName member of their
+// resource identifier meta if given and present, or else by their id.
+func (u *unmarshaler) unmarshalRelationshipMap(relationship interface{},
+	fieldValue reflect.Value, metaName string) error {
+	if !isRelationMapType(fieldValue.Type()) {
+		return ErrBadJSONAPIStructTag
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(relationship); err != nil {
+		return err
+	}
+	r := new(RelationshipManyNode)
+	if err := json.NewDecoder(buf).Decode(r); err != nil {
+		return err
+	}
+
+	models := reflect.MakeMapWithSize(fieldValue.Type(), len(r.Data))
+	for _, n := range r.Data {
+		key := n.ID
+		if n.Meta != nil && metaName != "" {
+			if k, ok := (*n.Meta)[metaName].(string); ok {
+				key = k
+			}
+		}
+
+		m, err := u.relatedModel(n, fieldValue.Type().Elem())
+		if err != nil {
+			return err
+		}
+
+		models.SetMapIndex(reflect.ValueOf(key).Convert(fieldValue.Type().Key()), m)
+	}
+	fieldValue.Set(models)
+
+	return nil
+}
+
+// isRelationMapType reports whether the map type t can hold a to-many
+// relationship: it must be keyed by strings and hold pointers to models, or
+// interfaces for polymorphic relationships.
+func isRelationMapType(t reflect.Type) bool {
+	elem := t.Elem()
+	return t.Key().Kind() == reflect.String && (elem.Kind() == reflect.Interface ||
+		elem.Kind() == reflect.Ptr && elem.Elem().Kind() == reflect.Struct)
+}
+
+// inverseField is a relationship field, bound by unmarshalNode, whose related
+// models point back to the model through their inverse relationship.
+type inverseField struct {
+	field   reflect.Value
+	inverse string
+}
+
+// setInverseRelationships points each related model held by fieldValue, a
+// pointer, slice or map, back to model through its inverse relationship.
+func setInverseRelationships(model, fieldValue reflect.Value, inverse string) {
+	var related []reflect.Value
+	switch fieldValue.Kind() {
+	case reflect.Slice:
+		for i := 0; i < fieldValue.Len(); i++ {
+			related = append(related, fieldValue.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range fieldValue.MapKeys() {
+			related = append(related, fieldValue.MapIndex(k))
+		}
+	default:
+		related = append(related, fieldValue)
+	}
+
+	for _, r := range related {
+		if r.Kind() != reflect.Ptr || r.IsNil() || r.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		setInverseRelationship(r.Elem(), inverse, model)
+	}
+}
+
+// setInverseRelationship sets the inverse relation annotated field of
+// relatedValue to model, or appends model to it when it is to-many.
+func setInverseRelationship(relatedValue reflect.Value, inverse string, model reflect.Value) {
+	for _, f := range schemaOf(relatedValue.Type()).fields {
+		args := f.args
+		if args[0] != annotationRelation || len(args) < 2 || args[1] != inverse {
+			continue
+		}
+
+		field := f.settable(relatedValue)
+		switch {
+		case field.Type() == model.Type():
+			field.Set(model)
+		case field.Kind() == reflect.Slice && field.Type().Elem() == model.Type():
+			for j := 0; j < field.Len(); j++ {
+				if field.Index(j).Pointer() == model.Pointer() {
+					return
+				}
+			}
+			field.Set(reflect.Append(field, model))
+		}
+		return
+	}
+}
+
+// relationField returns the slice, or the pointer to a struct, a relationship
+// is bound to through any levels of pointers of a relation annotated field,
+// e.g. for *[]*Comment or **Comment fields, allocating the pointers leading to
+// it.
+func relationField(fieldValue reflect.Value) reflect.Value {
+	for fieldValue.Kind() == reflect.Ptr {
+		elemKind := fieldValue.Type().Elem().Kind()
+		if elemKind != reflect.Ptr && elemKind != reflect.Slice {
+			break
+		}
+		if fieldValue.IsNil() {
+			fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+		}
+		fieldValue = fieldValue.Elem()
+	}
+	return fieldValue
+}
+
 // assign will take the value specified and assign it to the field; if
 // field is expecting a ptr assign will assign a ptr.
 func assign(field, value reflect.Value) {
+	// allocate the pointers leading to a field of the type of value
+	for field.Kind() == reflect.Ptr && field.Type() != value.Type() {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+
 	if field.Kind() == reflect.Ptr {
 		field.Set(value)
 	} else {
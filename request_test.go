@@ -2,7 +2,9 @@ package jsonapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -945,3 +947,1571 @@ func sampleSerializedEmbeddedTestModel() *Blog {
 
 	return blog
 }
+
+func TestUnmarshalIncludedCycles(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(buf, testCategoryTree()); err != nil {
+		t.Fatal(err)
+	}
+
+	root := new(Category)
+	if err := UnmarshalPayload(buf, root); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 2, len(root.Children); e != a {
+		t.Fatalf("Was expecting %d children, got %d", e, a)
+	}
+
+	left := root.Children[0]
+	if e, a := "left", left.Name; e != a {
+		t.Fatalf("Was expecting child name %q, got %q", e, a)
+	}
+	if left.Parent == nil || left.Parent.ID != root.ID {
+		t.Fatalf("Was expecting the child's parent to be resolved to %d", root.ID)
+	}
+
+	// leaf is two levels below the primary data
+	if len(left.Children) != 1 || left.Children[0].Name != "leaf" {
+		t.Fatal("Was expecting the grandchild to be resolved from included")
+	}
+	// the grandchild's parent is an ancestor, bound to the same model
+	if left.Children[0].Parent != left {
+		t.Fatalf("Was expecting the grandchild's parent to be resolved to %d", left.ID)
+	}
+}
+
+func TestUnmarshalManyIncludedCycles(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := MarshalManyPayload(buf, testPersonGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	people, err := UnmarshalManyPayload(buf, reflect.TypeOf(new(Person)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := people[0].(*Person)
+	if alice.BestFriend == nil || alice.BestFriend.Name != "bob" {
+		t.Fatal("Was expecting alice's best friend to be resolved from included")
+	}
+	if e, a := 2, len(alice.BestFriend.Pets); e != a {
+		t.Fatalf("Was expecting %d pets for bob, got %d", e, a)
+	}
+	if alice.BestFriend.Pets[0].Name != "tom" {
+		t.Fatal("Was expecting bob's pets to be resolved from included")
+	}
+}
+
+func TestUnmarshalManyIncludedGraph(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := MarshalManyPayload(buf, []*Blog{testBlog()}, WithInclude("posts.comments")); err != nil {
+		t.Fatal(err)
+	}
+
+	blogs, err := UnmarshalManyPayload(buf, reflect.TypeOf(new(Blog)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blog := blogs[0].(*Blog)
+	if e, a := 2, len(blog.Posts); e != a {
+		t.Fatalf("Was expecting %d posts, got %d", e, a)
+	}
+	for _, post := range blog.Posts {
+		if post.Title == "" || len(post.Comments) != 2 {
+			t.Fatalf("Was expecting post %d and its comments to be resolved from included, got %+v", post.ID, post)
+		}
+		for _, comment := range post.Comments {
+			if comment.Body == "" {
+				t.Fatalf("Was expecting comment %d to be resolved from included", comment.ID)
+			}
+		}
+	}
+	// post 1 is included as one of the posts
+	if post := blog.CurrentPost; post == nil || post.Title != "Foo" {
+		t.Fatalf("Was expecting current_post to be resolved from included, got %+v", post)
+	}
+}
+
+func TestUnmarshalPayloadWithMeta(t *testing.T) {
+	sample := samplePayloadWithoutIncluded()
+	sample["meta"] = map[string]interface{}{
+		"total":    42,
+		"trace_id": "abc",
+	}
+	data, err := payload(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var meta struct {
+		Total   int    `json:"total"`
+		TraceID string `json:"trace_id"`
+	}
+	post := new(Post)
+	if err := UnmarshalPayloadWithMeta(bytes.NewReader(data), post, &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "World", post.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+	if e, a := 42, meta.Total; e != a {
+		t.Fatalf("Was expecting meta.total %d, got %d", e, a)
+	}
+	if e, a := "abc", meta.TraceID; e != a {
+		t.Fatalf("Was expecting meta.trace_id %q, got %q", e, a)
+	}
+
+	var m Meta
+	if err := UnmarshalPayloadWithMeta(bytes.NewReader(data), new(Post), &m); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "abc", m["trace_id"]; e != a {
+		t.Fatalf("Was expecting meta.trace_id %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalManyPayloadWithMeta(t *testing.T) {
+	sample := map[string]interface{}{
+		"data": []interface{}{
+			map[string]interface{}{
+				"type":       "posts",
+				"id":         "1",
+				"attributes": map[string]interface{}{"title": "Post"},
+			},
+		},
+		"meta": map[string]interface{}{"total": 11},
+	}
+	data, err := payload(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var meta Meta
+	posts, err := UnmarshalManyPayloadWithMeta(bytes.NewReader(data), reflect.TypeOf(new(Post)), &meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 1, len(posts); e != a {
+		t.Fatalf("Was expecting %d posts, got %d", e, a)
+	}
+	if e, a := float64(11), meta["total"]; e != a {
+		t.Fatalf("Was expecting meta.total %v, got %v", e, a)
+	}
+}
+
+func TestUnmarshalManyPayloadWithLinks(t *testing.T) {
+	sample := map[string]interface{}{
+		"data": []interface{}{
+			map[string]interface{}{
+				"type":       "posts",
+				"id":         "1",
+				"attributes": map[string]interface{}{"title": "Post"},
+			},
+		},
+		"links": map[string]interface{}{
+			"self":       "http://somesite.com/movies?page[limit]=50&page[offset]=50",
+			KeyNextPage:  map[string]interface{}{"href": "http://somesite.com/movies?page[limit]=50&page[offset]=100"},
+			KeyFirstPage: "http://somesite.com/movies?page[limit]=50",
+		},
+	}
+	data, err := payload(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var links Links
+	posts, err := UnmarshalManyPayloadWithLinks(bytes.NewReader(data), reflect.TypeOf(new(Post)), &links)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 1, len(posts); e != a {
+		t.Fatalf("Was expecting %d posts, got %d", e, a)
+	}
+	if e, a := "http://somesite.com/movies?page[limit]=50", links.Href(KeyFirstPage); e != a {
+		t.Fatalf("Was expecting links.%s to be %q, got %q", KeyFirstPage, e, a)
+	}
+	if e, a := "http://somesite.com/movies?page[limit]=50&page[offset]=100", links.Href(KeyNextPage); e != a {
+		t.Fatalf("Was expecting links.%s to be %q, got %q", KeyNextPage, e, a)
+	}
+	if a := links.Href(KeyPreviousPage); a != "" {
+		t.Fatalf("Was expecting no links.%s, got %q", KeyPreviousPage, a)
+	}
+}
+
+func TestUnmarshalPayloadWithLinks(t *testing.T) {
+	sample := samplePayloadWithoutIncluded()
+	sample["links"] = map[string]interface{}{"self": "http://example.com/posts/1"}
+	data, err := payload(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var links Links
+	if err := UnmarshalPayloadWithLinks(bytes.NewReader(data), new(Post), &links); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "http://example.com/posts/1", links.Href("self"); e != a {
+		t.Fatalf("Was expecting links.self to be %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalPayloadWithChanged(t *testing.T) {
+	data := `{"data": {"type": "posts", "id": "1",
+		"attributes": {"title": null, "unknown": "ignored"},
+		"relationships": {"latest_comment": {"data": null}}}}`
+
+	post := &Post{Title: "Untouched", Body: "Kept"}
+	changed, err := UnmarshalPayloadWithChanged(strings.NewReader(data), post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{"title": true, "latest_comment": true}
+	if !reflect.DeepEqual(expected, changed) {
+		t.Fatalf("Was expecting the changed members %v, got %v", expected, changed)
+	}
+	if e, a := "Kept", post.Body; e != a {
+		t.Fatalf("Was expecting the body %q to be kept, got %q", e, a)
+	}
+}
+
+func TestUnmarshalLinksAnnotation(t *testing.T) {
+	sample := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "articles",
+			"id":         "1",
+			"attributes": map[string]interface{}{"title": "JSON API paints my bikeshed!"},
+			"links": map[string]interface{}{
+				"self": "http://example.com/articles/1",
+			},
+		},
+	}
+	data, err := payload(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	article := new(Article)
+	if err := UnmarshalPayload(bytes.NewReader(data), article); err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Links == nil {
+		t.Fatal("Was expecting the links field to be set")
+	}
+	if e, a := "http://example.com/articles/1", article.Links.Href("self"); e != a {
+		t.Fatalf("Was expecting links.self to be %q, got %q", e, a)
+	}
+}
+
+func TestLinksAnnotationRoundTrip(t *testing.T) {
+	in := `{"data":{"type":"articles","id":"1","attributes":{"title":"Title"},
+		"links":{"self":{"href":"/articles/1","meta":{"count":2}},"related":"/articles/1/author"}}}`
+
+	article := new(Article)
+	if err := UnmarshalPayload(strings.NewReader(in), article); err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, article, WithConfig(Config{BaseURL: "https://example.com"})); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Data struct {
+			Links map[string]interface{} `json:"links"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"self": map[string]interface{}{
+			"href": "https://example.com/articles/1",
+			"meta": map[string]interface{}{"count": float64(2)},
+		},
+		"related": "https://example.com/articles/1/author",
+	}
+	if !reflect.DeepEqual(expected, doc.Data.Links) {
+		t.Fatalf("Was expecting the links %v, got %v", expected, doc.Data.Links)
+	}
+
+	article.Links = &Links{"self": map[string]interface{}{"meta": map[string]interface{}{}}}
+	if err := MarshalOnePayload(out, article); err == nil {
+		t.Fatal("Was expecting an error for a link object without href")
+	}
+}
+
+func TestUnmarshalMetaAnnotation(t *testing.T) {
+	sample := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "documents",
+			"id":         "1",
+			"attributes": map[string]interface{}{"title": "Minutes"},
+			"meta": map[string]interface{}{
+				"can_edit":   true,
+				"can_delete": false,
+			},
+		},
+	}
+	data, err := payload(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := new(Document)
+	if err := UnmarshalPayload(bytes.NewReader(data), doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Permissions == nil {
+		t.Fatal("Was expecting the meta field to be set")
+	}
+	if !doc.Permissions.CanEdit || doc.Permissions.CanDelete {
+		t.Fatalf("Was expecting meta to be decoded, got %+v", doc.Permissions)
+	}
+
+	sample["data"] = map[string]interface{}{
+		"type": "scores",
+		"id":   "1",
+		"meta": map[string]interface{}{"score": 0.75},
+	}
+	if data, err = payload(sample); err != nil {
+		t.Fatal(err)
+	}
+
+	score := new(Score)
+	if err := UnmarshalPayload(bytes.NewReader(data), score); err != nil {
+		t.Fatal(err)
+	}
+	if score.Meta == nil || (*score.Meta)["score"] != 0.75 {
+		t.Fatalf("Was expecting meta.score to be decoded, got %v", score.Meta)
+	}
+}
+
+func TestUnmarshalRelationshipIDs(t *testing.T) {
+	sample := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "reviews",
+			"id":         "1",
+			"attributes": map[string]interface{}{"body": "Great"},
+			"relationships": map[string]interface{}{
+				"author": map[string]interface{}{
+					"data": map[string]interface{}{"type": "people", "id": "abc"},
+				},
+				"book": map[string]interface{}{
+					"data": map[string]interface{}{"type": "books", "id": "7"},
+				},
+				"comments": map[string]interface{}{
+					"data": []interface{}{
+						map[string]interface{}{"type": "comments", "id": "1"},
+						map[string]interface{}{"type": "comments", "id": "2"},
+					},
+				},
+				"tags": map[string]interface{}{
+					"data": []interface{}{
+						map[string]interface{}{"type": "tags", "id": "fiction"},
+					},
+				},
+			},
+		},
+	}
+	data, err := payload(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	review := new(Review)
+	if err := UnmarshalPayload(bytes.NewReader(data), review); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "abc", review.AuthorID; e != a {
+		t.Fatalf("Was expecting author id %q, got %q", e, a)
+	}
+	if review.BookID == nil || *review.BookID != 7 {
+		t.Fatalf("Was expecting book id 7, got %v", review.BookID)
+	}
+	if e, a := []int{1, 2}, review.CommentIDs; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting comment ids %v, got %v", e, a)
+	}
+	if e, a := []string{"fiction"}, review.TagIDs; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting tag ids %v, got %v", e, a)
+	}
+}
+
+func TestUnmarshalAfterUnmarshaler(t *testing.T) {
+	sample := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "accounts",
+			"id":         "1",
+			"attributes": map[string]interface{}{"email": "Alice@Example.com"},
+		},
+	}
+	data, err := payload(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := new(Account)
+	if err := UnmarshalPayload(bytes.NewReader(data), account); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "alice@example.com", account.Email; e != a {
+		t.Fatalf("Was expecting email %q, got %q", e, a)
+	}
+
+	sample["data"].(map[string]interface{})["attributes"] = map[string]interface{}{}
+	if data, err = payload(sample); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalPayload(bytes.NewReader(data), new(Account)); err != errAccountEmail {
+		t.Fatalf("Was expecting `%v`, got `%v`", errAccountEmail, err)
+	}
+}
+
+type contextComment struct {
+	ID     int    `jsonapi:"primary,comments"`
+	Body   string `jsonapi:"attr,body"`
+	Locale string
+}
+
+func (c *contextComment) AfterJSONAPIUnmarshal(ctx context.Context) error {
+	c.Locale, _ = ctx.Value(ctxKey("locale")).(string)
+	return nil
+}
+
+func TestUnmarshalPayloadContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey("locale"), "en-GB")
+	data := `{"data": {"type": "comments", "id": "1", "attributes": {"body": "Hi"}}}`
+
+	comment := new(contextComment)
+	if err := UnmarshalPayloadContext(ctx, strings.NewReader(data), comment); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "en-GB", comment.Locale; e != a {
+		t.Fatalf("Was expecting locale %q, got %q", e, a)
+	}
+
+	many := `{"data": [{"type": "comments", "id": "1", "attributes": {"body": "Hi"}}]}`
+	comments, err := UnmarshalManyPayloadContext(ctx, strings.NewReader(many), reflect.TypeOf(new(contextComment)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "en-GB", comments[0].(*contextComment).Locale; e != a {
+		t.Fatalf("Was expecting locale %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalMetaOnly(t *testing.T) {
+	data := `{"data": {"type": "subscriptions", "id": "1", "meta": {"deleted": true}}}`
+
+	subscription := new(Subscription)
+	if err := UnmarshalPayload(strings.NewReader(data), subscription); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 1, subscription.ID; e != a {
+		t.Fatalf("Was expecting id %d, got %d", e, a)
+	}
+	if subscription.Meta == nil || (*subscription.Meta)["deleted"] != true {
+		t.Fatalf("Was expecting the meta to be set, got %v", subscription.Meta)
+	}
+	if subscription.Plan != "" || subscription.Account != nil {
+		t.Fatalf("Was not expecting attributes or relationships, got %+v", subscription)
+	}
+}
+
+func TestUnmarshalMetaMembers(t *testing.T) {
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "topics",
+			"id":         "1",
+			"attributes": map[string]interface{}{"title": "Release notes"},
+			"relationships": map[string]interface{}{
+				"comments": map[string]interface{}{
+					"data": []interface{}{map[string]interface{}{"type": "comments", "id": "2"}},
+					"meta": map[string]interface{}{"count": 12},
+				},
+			},
+			"meta": map[string]interface{}{"views": 40, "pinned": true},
+		},
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topic := new(Topic)
+	if err := UnmarshalPayload(bytes.NewReader(b), topic); err != nil {
+		t.Fatal(err)
+	}
+
+	if topic.Views != 40 || !topic.Pinned || topic.CommentCount != 12 {
+		t.Fatalf("Was expecting the meta members to be set, got %+v", topic)
+	}
+
+	data["data"].(map[string]interface{})["meta"] = map[string]interface{}{"views": "many"}
+	if b, err = json.Marshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalPayload(bytes.NewReader(b), new(Topic)); err != ErrInvalidType {
+		t.Fatalf("Was expecting %v, got %v", ErrInvalidType, err)
+	}
+}
+
+func TestUnmarshalLinkageMeta(t *testing.T) {
+	data := `{"data": {"type": "teams", "id": "1", "relationships": {
+		"members": {"data": [
+			{"type": "people", "id": "1", "meta": {"role": "lead"}},
+			{"type": "people", "id": "2"}
+		]},
+		"captain": {"data": {"type": "people", "id": "1", "meta": {"since": 2019}}}
+	}}}`
+
+	team := new(Team)
+	if err := UnmarshalPayload(strings.NewReader(data), team); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 2, len(team.MemberMeta); e != a {
+		t.Fatalf("Was expecting %d member meta, got %d", e, a)
+	}
+	if e, a := "lead", (*team.MemberMeta[0])["role"]; e != a {
+		t.Fatalf("Was expecting role %q, got %v", e, a)
+	}
+	if team.MemberMeta[1] != nil {
+		t.Fatalf("Was expecting no meta for the second member, got %v", team.MemberMeta[1])
+	}
+	if team.CaptainMeta == nil || (*team.CaptainMeta)["since"] != float64(2019) {
+		t.Fatalf("Was expecting the captain meta, got %v", team.CaptainMeta)
+	}
+}
+
+func TestUnmarshalAttributeSetter(t *testing.T) {
+	data := `{"data": {"type": "products", "id": "1", "attributes": {"name": "Chair", "color": "red"}}}`
+
+	product := new(Product)
+	err := UnmarshalPayload(strings.NewReader(data), product, WithUnmarshalConfig(Config{Strict: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "Chair", product.Name; e != a {
+		t.Fatalf("Was expecting name %q, got %q", e, a)
+	}
+	expected := map[string]interface{}{"color": "red"}
+	if !reflect.DeepEqual(expected, product.Properties) {
+		t.Fatalf("Was expecting properties %v, got %v", expected, product.Properties)
+	}
+}
+
+func TestUnmarshalNodeUnmarshaler(t *testing.T) {
+	data := `{
+		"data": {"type": "trips", "id": "1", "relationships": {
+			"destination": {"data": {"type": "coordinates", "id": "home"}}
+		}},
+		"included": [{"type": "coordinates", "id": "home", "attributes": {"position": "51.5,-0.12"}}]
+	}`
+
+	trip := new(Trip)
+	if err := UnmarshalPayload(strings.NewReader(data), trip); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Coordinate{ID: "home", Lat: 51.5, Lng: -0.12}
+	if !reflect.DeepEqual(expected, trip.Destination) {
+		t.Fatalf("Was expecting destination %+v, got %+v", expected, trip.Destination)
+	}
+}
+
+func TestUnmarshalLocalID(t *testing.T) {
+	data := `{"data": {"type": "drafts", "lid": "draft-1", "attributes": {"title": "Hello"}}}`
+
+	draft := new(Draft)
+	if err := UnmarshalPayload(strings.NewReader(data), draft); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "draft-1", draft.LocalID; e != a {
+		t.Fatalf("Was expecting lid %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalPointerIndirection(t *testing.T) {
+	data := `{
+		"data": {"type": "threads", "id": "7", "attributes": {"title": "Thread"},
+			"relationships": {
+				"comments": {"data": [{"type": "comments", "id": "1"}, {"type": "comments", "id": "2"}]},
+				"pinned": {"data": {"type": "comments", "id": "1"}}
+			}},
+		"included": [{"type": "comments", "id": "1", "attributes": {"body": "foo"}}]
+	}`
+
+	thread := new(Thread)
+	if err := UnmarshalPayload(strings.NewReader(data), thread); err != nil {
+		t.Fatal(err)
+	}
+
+	if thread.ID == nil || *thread.ID == nil || **thread.ID != 7 {
+		t.Fatalf("Was expecting id 7, got %v", thread.ID)
+	}
+	if thread.Comments == nil || len(*thread.Comments) != 2 {
+		t.Fatalf("Was expecting 2 comments, got %v", thread.Comments)
+	}
+	if e, a := "foo", (*thread.Comments)[0].Body; e != a {
+		t.Fatalf("Was expecting body %q, got %q", e, a)
+	}
+	if thread.Pinned == nil || *thread.Pinned == nil || (*thread.Pinned).ID != 1 {
+		t.Fatalf("Was expecting the pinned comment, got %v", thread.Pinned)
+	}
+
+	// absent relationships leave the pointers nil
+	thread = new(Thread)
+	if err := UnmarshalPayload(strings.NewReader(`{"data": {"type": "threads", "id": "7"}}`), thread); err != nil {
+		t.Fatal(err)
+	}
+	if thread.Comments != nil || thread.Pinned != nil {
+		t.Fatalf("Was expecting nil relationships, got %v and %v", thread.Comments, thread.Pinned)
+	}
+}
+
+func TestUnmarshalMapRelationship(t *testing.T) {
+	data := `{
+		"data": {"type": "rosters", "id": "1", "relationships": {
+			"players": {"data": [
+				{"type": "people", "id": "1", "meta": {"position": "keeper"}},
+				{"type": "people", "id": "9", "meta": {"position": "striker"}}
+			]},
+			"coaches": {"data": [{"type": "people", "id": "20"}]}
+		}},
+		"included": [{"type": "people", "id": "9", "attributes": {"name": "Nine"}}]
+	}`
+
+	roster := new(Roster)
+	if err := UnmarshalPayload(strings.NewReader(data), roster); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 2, len(roster.Players); e != a {
+		t.Fatalf("Was expecting %d players, got %d", e, a)
+	}
+	if e, a := "Nine", roster.Players["striker"].Name; e != a {
+		t.Fatalf("Was expecting the striker %q, got %q", e, a)
+	}
+	if e, a := 1, roster.Players["keeper"].ID; e != a {
+		t.Fatalf("Was expecting the keeper %d, got %d", e, a)
+	}
+	// keyed by id without the mapkey option
+	if e, a := 20, roster.Coaches["20"].ID; e != a {
+		t.Fatalf("Was expecting coach %d, got %d", e, a)
+	}
+}
+
+func TestUnmarshalMapRelationshipOfValues(t *testing.T) {
+	data := `{
+		"data": {"type": "benches", "id": "1", "relationships": {
+			"players": {"data": [{"type": "people", "id": "1"}]}
+		}}
+	}`
+
+	err := UnmarshalPayload(strings.NewReader(data), new(Bench))
+	if err != ErrBadJSONAPIStructTag {
+		t.Fatalf("Was expecting ErrBadJSONAPIStructTag, got %v", err)
+	}
+}
+
+func TestUnmarshalWithDocumentHook(t *testing.T) {
+	data := `{"data": {"type": "blogs", "id": "5", "attributes": {"name": "Legacy"}}}`
+
+	rename := func(resource interface{}) {
+		attrs := resource.(map[string]interface{})["attributes"].(map[string]interface{})
+		attrs["title"] = attrs["name"]
+		delete(attrs, "name")
+	}
+	migrate := WithDocumentHook(func(doc map[string]interface{}) error {
+		switch data := doc["data"].(type) {
+		case []interface{}:
+			for _, resource := range data {
+				rename(resource)
+			}
+		default:
+			rename(data)
+		}
+		return nil
+	})
+
+	blog := new(Blog)
+	if err := UnmarshalPayload(strings.NewReader(data), blog, migrate); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "Legacy", blog.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+
+	many := `{"data": [{"type": "blogs", "id": "5", "attributes": {"name": "Legacy"}}]}`
+	models, err := UnmarshalManyPayload(strings.NewReader(many), reflect.TypeOf(new(Blog)), migrate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "Legacy", models[0].(*Blog).Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+
+	errHook := errors.New("hook failed")
+	err = UnmarshalPayload(strings.NewReader(data), new(Blog),
+		WithDocumentHook(func(map[string]interface{}) error { return errHook }))
+	if err != errHook {
+		t.Fatalf("Was expecting `%v`, got `%v`", errHook, err)
+	}
+}
+
+func TestUnmarshalValidator(t *testing.T) {
+	data := `{"data": {"type": "reservations", "attributes": {"party": 0}}}`
+
+	err := UnmarshalPayload(strings.NewReader(data), new(Reservation))
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Was expecting a *ValidationError, got %v", err)
+	}
+	if e, a := 2, len(validationErr.Errors); e != a {
+		t.Fatalf("Was expecting %d error objects, got %d", e, a)
+	}
+	if e, a := "Invalid party", validationErr.Errors[1].Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+
+	data = `{"data": {"type": "reservations", "attributes": {"name": "Smith", "party": 4}}}`
+	if err := UnmarshalPayload(strings.NewReader(data), new(Reservation)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalAllowAttributes(t *testing.T) {
+	data := `{"data": {"type": "blogs", "id": "5", "attributes": {"title": "Hi", "view_count": 1000}}}`
+	allow := AllowAttributes(map[string][]string{"blogs": {"title"}})
+
+	err := UnmarshalPayload(strings.NewReader(data), new(Blog), allow)
+	if err != ErrAttributeNotAllowed {
+		t.Fatalf("Was expecting `%v`, got `%v`", ErrAttributeNotAllowed, err)
+	}
+
+	logger := new(recordingLogger)
+	blog := new(Blog)
+	err = UnmarshalPayload(strings.NewReader(data), blog, allow, DropDisallowedAttributes(),
+		WithUnmarshalLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blog.Title != "Hi" || blog.ViewCount != 0 {
+		t.Fatalf("Was expecting only the title to be set, got %+v", blog)
+	}
+	expected := Anomaly{Kind: AnomalyAttributeDropped, ResourceType: "blogs", ResourceID: "5", Field: "view_count"}
+	if len(logger.anomalies) != 1 || logger.anomalies[0] != expected {
+		t.Fatalf("Was expecting %v, got %v", expected, logger.anomalies)
+	}
+
+	// other resource types are not restricted
+	err = UnmarshalPayload(strings.NewReader(data), new(Blog),
+		AllowAttributes(map[string][]string{"posts": {"title"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalRelationPresent(t *testing.T) {
+	data := `{"data": {"type": "assignments", "id": "1", "relationships": {
+		"assignee": {"data": null}
+	}}}`
+
+	assignment := new(Assignment)
+	if err := UnmarshalPayload(strings.NewReader(data), assignment); err != nil {
+		t.Fatal(err)
+	}
+	if !assignment.AssigneePresent || assignment.Assignee != nil {
+		t.Fatalf("Was expecting a cleared assignee, got %+v", assignment)
+	}
+	if assignment.ReviewersPresent {
+		t.Fatal("Was expecting the reviewers not to be present")
+	}
+
+	data = `{"data": {"type": "assignments", "id": "1", "relationships": {
+		"reviewers": {"data": []}
+	}}}`
+
+	assignment = new(Assignment)
+	if err := UnmarshalPayload(strings.NewReader(data), assignment); err != nil {
+		t.Fatal(err)
+	}
+	if assignment.AssigneePresent || !assignment.ReviewersPresent {
+		t.Fatalf("Was expecting only the reviewers to be present, got %+v", assignment)
+	}
+
+	// the flags are not rendered
+	payload, err := MarshalOne(&Assignment{ID: 1, AssigneePresent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 2, len(payload.Data.Relationships); e != a {
+		t.Fatalf("Was expecting %d relationships, got %d", e, a)
+	}
+}
+
+func TestUnmarshalInverseRelationships(t *testing.T) {
+	data := `{
+		"data": {"type": "courses", "id": "1", "relationships": {"lessons": {"data": [
+			{"type": "lessons", "id": "10"},
+			{"type": "lessons", "id": "11"}
+		]}}},
+		"included": [
+			{"type": "lessons", "id": "10", "attributes": {"title": "Intro"}},
+			{"type": "lessons", "id": "11", "attributes": {"title": "Types"}}
+		]
+	}`
+
+	course := new(Course)
+	if err := UnmarshalPayload(strings.NewReader(data), course, WithInverseRelationships()); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 2, len(course.Lessons); e != a {
+		t.Fatalf("Was expecting %d lessons, got %d", e, a)
+	}
+	for _, lesson := range course.Lessons {
+		if lesson.Course != course {
+			t.Fatalf("Was expecting lesson %d to point back to the course", lesson.ID)
+		}
+	}
+
+	// a to-many inverse relationship gets the model appended
+	data = `{
+		"data": {"type": "lessons", "id": "10", "relationships": {"course": {"data": {"type": "courses", "id": "1"}}}},
+		"included": [{"type": "courses", "id": "1"}]
+	}`
+
+	lesson := new(Lesson)
+	if err := UnmarshalPayload(strings.NewReader(data), lesson, WithInverseRelationships()); err != nil {
+		t.Fatal(err)
+	}
+	if lesson.Course == nil || len(lesson.Course.Lessons) != 1 || lesson.Course.Lessons[0] != lesson {
+		t.Fatalf("Was expecting the course to hold the lesson, got %+v", lesson.Course)
+	}
+
+	// back-references are only set on request
+	course = new(Course)
+	if err := UnmarshalPayload(strings.NewReader(`{"data": {"type": "courses", "id": "1",
+		"relationships": {"lessons": {"data": [{"type": "lessons", "id": "10"}]}}}}`), course); err != nil {
+		t.Fatal(err)
+	}
+	if course.Lessons[0].Course != nil {
+		t.Fatal("Was expecting no back-reference without WithInverseRelationships")
+	}
+}
+
+func TestUnmarshalSharedResources(t *testing.T) {
+	data := `{
+		"data": {"type": "blogs", "id": "1", "relationships": {
+			"posts": {"data": [{"type": "posts", "id": "2"}, {"type": "posts", "id": "3"}]},
+			"current_post": {"data": {"type": "posts", "id": "2"}}
+		}},
+		"included": [
+			{"type": "posts", "id": "2", "attributes": {"title": "Two"}, "relationships": {
+				"latest_comment": {"data": {"type": "comments", "id": "9"}}
+			}},
+			{"type": "posts", "id": "3", "attributes": {"title": "Three"}, "relationships": {
+				"latest_comment": {"data": {"type": "comments", "id": "9"}}
+			}},
+			{"type": "comments", "id": "9", "attributes": {"body": "Shared"}}
+		]
+	}`
+
+	blog := new(Blog)
+	if err := UnmarshalPayload(strings.NewReader(data), blog); err != nil {
+		t.Fatal(err)
+	}
+
+	if blog.CurrentPost != blog.Posts[0] {
+		t.Fatal("Was expecting the current post to be the first post")
+	}
+	if blog.Posts[0].LatestComment == nil || blog.Posts[0].LatestComment != blog.Posts[1].LatestComment {
+		t.Fatal("Was expecting the posts to share their latest comment")
+	}
+	if e, a := "Shared", blog.Posts[1].LatestComment.Body; e != a {
+		t.Fatalf("Was expecting body %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalOne(t *testing.T) {
+	data := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(data, testModel()); err != nil {
+		t.Fatal(err)
+	}
+
+	// e.g. a payload read from a message queue
+	payload := new(OnePayload)
+	if err := json.NewDecoder(data).Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	blog := new(Blog)
+	if err := UnmarshalOne(payload, blog); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "Title 1", blog.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+	if blog.CurrentPost == nil || len(blog.CurrentPost.Comments) != 2 {
+		t.Fatal("Was expecting the current post and its comments to be resolved from included")
+	}
+}
+
+func TestUnmarshalOneMarshaledPayload(t *testing.T) {
+	model := testModel()
+	payload, err := MarshalOne(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blog := new(Blog)
+	if err := UnmarshalOne(payload, blog); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := model.CreatedAt.Unix(), blog.CreatedAt.Unix(); e != a {
+		t.Fatalf("Was expecting created_at %d, got %d", e, a)
+	}
+	if blog.CurrentPost == nil || len(blog.CurrentPost.Comments) != 2 {
+		t.Fatal("Was expecting the current post and its comments to be resolved from included")
+	}
+}
+
+func TestUnmarshalOneMarshaledPointers(t *testing.T) {
+	id, name, active, count, ratio := uint64(1), "Name", true, 5, float32(1.5)
+	for _, model := range []*WithPointer{
+		{ID: &id, Name: &name, IsActive: &active, IntVal: &count, FloatVal: &ratio},
+		// typed nil pointers are left in the attributes
+		{ID: &id},
+	} {
+		payload, err := MarshalOne(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := new(WithPointer)
+		if err := UnmarshalOne(payload, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(model, decoded) {
+			t.Fatalf("Was expecting %+v, got %+v", model, decoded)
+		}
+	}
+}
+
+func TestUnmarshalNode(t *testing.T) {
+	node := &Node{
+		Type:       "posts",
+		ID:         "1",
+		Attributes: map[string]interface{}{"title": "Post"},
+		Relationships: map[string]interface{}{
+			"latest_comment": &RelationshipOneNode{Data: &Node{Type: "comments", ID: "2"}},
+		},
+	}
+	included := []*Node{
+		{Type: "comments", ID: "2", Attributes: map[string]interface{}{"body": "Comment"}},
+	}
+
+	post := new(Post)
+	if err := UnmarshalNode(node, post, included); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "Post", post.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+	if post.LatestComment == nil || post.LatestComment.Body != "Comment" {
+		t.Fatal("Was expecting the latest comment to be resolved from included")
+	}
+}
+
+func TestUnmarshalMany(t *testing.T) {
+	payload := &ManyPayload{
+		Data: []*Node{
+			{Type: "posts", ID: "1", Attributes: map[string]interface{}{"title": "First"}},
+			{Type: "posts", ID: "2", Attributes: map[string]interface{}{"title": "Second"}},
+		},
+	}
+
+	posts, err := UnmarshalMany(payload, reflect.TypeOf(new(Post)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 2, len(posts); e != a {
+		t.Fatalf("Was expecting %d posts, got %d", e, a)
+	}
+	if e, a := "Second", posts[1].(*Post).Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalOneBytes(t *testing.T) {
+	data := json.RawMessage(`{"data": {"type": "blogs", "id": "5", "attributes": {"title": "Title 1"}}}`)
+
+	blog := new(Blog)
+	if err := UnmarshalOneBytes(data, blog); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 5, blog.ID; e != a {
+		t.Fatalf("Was expecting id %d, got %d", e, a)
+	}
+	if e, a := "Title 1", blog.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalManyBytes(t *testing.T) {
+	data := []byte(`{"data": [
+		{"type": "posts", "id": "1", "attributes": {"title": "First"}},
+		{"type": "posts", "id": "2", "attributes": {"title": "Second"}}
+	]}`)
+
+	posts, err := UnmarshalManyBytes(data, reflect.TypeOf(new(Post)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 2, len(posts); e != a {
+		t.Fatalf("Was expecting %d posts, got %d", e, a)
+	}
+	if e, a := "Second", posts[1].(*Post).Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestUnmarshalContainerAttributes(t *testing.T) {
+	warehouse := &Warehouse{
+		ID: 1,
+		Aisles: map[string][]StockItem{
+			"a": {{SKU: "bolt", Quantity: 10}, {SKU: "nut", Quantity: 20}},
+		},
+		Shelves: []map[string]StockItem{
+			{"top": {SKU: "screw", Quantity: 5}},
+			{"bottom": {SKU: "washer", Quantity: 1}},
+		},
+		Zones: map[string]map[string][]StockItem{
+			"north": {"a": {{SKU: "nail", Quantity: 100}}},
+		},
+		Pallets:  [][]*StockItem{{{SKU: "beam", Quantity: 2}, nil}},
+		Featured: StockItem{SKU: "drill", Quantity: 1},
+		Counters: map[string][]int{"daily": {1, 2, 3}},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, warehouse); err != nil {
+		t.Fatal(err)
+	}
+
+	// the attributes are rendered with the json tags of StockItem
+	if !strings.Contains(out.String(), `"qty":10`) {
+		t.Fatalf("Was expecting the json tags to apply, got %s", out.String())
+	}
+
+	got := new(Warehouse)
+	if err := UnmarshalPayload(out, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(warehouse, got) {
+		t.Fatalf("Was expecting %+v, got %+v", warehouse, got)
+	}
+}
+
+func TestUnmarshalNestedStructPointerAttributes(t *testing.T) {
+	since := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	previous := []Address{{Street: "Old Road", Since: since.AddDate(-5, 0, 0)}}
+	contact := &Contact{
+		ID:       1,
+		Home:     &Address{Street: "High Street", Since: since, Billing: &Address{Street: "PO Box 1"}},
+		Previous: &previous,
+		ByLabel:  map[string]*Address{"work": {Street: "Main Square", Note: "3rd floor"}},
+		Settings: map[string]interface{}{"theme": "dark", "alerts": map[string]interface{}{"email": true}},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, contact); err != nil {
+		t.Fatal(err)
+	}
+
+	got := new(Contact)
+	if err := UnmarshalPayload(out, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(contact, got) {
+		t.Fatalf("Was expecting %+v, got %+v", contact, got)
+	}
+}
+
+func TestUnmarshalContainerAttributesInvalid(t *testing.T) {
+	data := `{"data": {"type": "warehouses", "id": "1",
+		"attributes": {"aisles": {"a": [{"sku": "bolt", "qty": "ten"}]}}}}`
+
+	err := UnmarshalPayload(strings.NewReader(data), new(Warehouse))
+	if _, ok := err.(*json.UnmarshalTypeError); !ok {
+		t.Fatalf("Was expecting a *json.UnmarshalTypeError, got %v", err)
+	}
+}
+
+func TestTimeSliceAttributes(t *testing.T) {
+	first := time.Date(2020, 1, 2, 8, 30, 0, 0, time.UTC)
+	second := time.Date(2020, 1, 2, 9, 45, 0, 0, time.UTC)
+	timetable := &Timetable{
+		ID:         1,
+		Departures: []time.Time{first, second},
+		Arrivals:   []time.Time{second},
+	}
+
+	payload, err := MarshalOne(timetable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	departures := payload.Data.Attributes["departures"].([]interface{})
+	if e, a := first.Unix(), departures[0]; e != a {
+		t.Fatalf("Was expecting departure %d, got %v", e, a)
+	}
+	arrivals := payload.Data.Attributes["arrivals"].([]interface{})
+	if e, a := "2020-01-02T09:45:00Z", arrivals[0]; e != a {
+		t.Fatalf("Was expecting arrival %q, got %v", e, a)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(out).Encode(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	got := new(Timetable)
+	if err := UnmarshalPayload(out, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Departures) != 2 || !got.Departures[1].Equal(second) {
+		t.Fatalf("Was expecting departures %v, got %v", timetable.Departures, got.Departures)
+	}
+	if len(got.Arrivals) != 1 || !got.Arrivals[0].Equal(second) {
+		t.Fatalf("Was expecting arrivals %v, got %v", timetable.Arrivals, got.Arrivals)
+	}
+
+	data := `{"data": {"type": "timetables", "id": "1", "attributes": {"arrivals": [1577955900]}}}`
+	if err := UnmarshalPayload(strings.NewReader(data), new(Timetable)); err != ErrInvalidISO8601 {
+		t.Fatalf("Was expecting ErrInvalidISO8601, got %v", err)
+	}
+}
+
+func TestPointerElementSliceAttributes(t *testing.T) {
+	name, score := "Alice", 0
+	checkedAt := time.Date(2020, 1, 2, 8, 30, 0, 0, time.UTC)
+	update := &ColumnUpdate{
+		ID:        1,
+		Names:     []*string{&name, nil},
+		Scores:    []*int{nil, &score},
+		CheckedAt: []*time.Time{nil, &checkedAt},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, update); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, attr := range []string{`"names":["Alice",null]`, `"scores":[null,0]`,
+		`"checked_at":[null,"2020-01-02T08:30:00Z"]`} {
+		if !strings.Contains(out.String(), attr) {
+			t.Fatalf("Was expecting %s in %s", attr, out.String())
+		}
+	}
+
+	got := new(ColumnUpdate)
+	if err := UnmarshalPayload(out, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Names[1] != nil || got.Names[0] == nil || *got.Names[0] != "Alice" {
+		t.Fatalf("Was expecting names [Alice nil], got %v", got.Names)
+	}
+	if got.Scores[0] != nil || got.Scores[1] == nil || *got.Scores[1] != 0 {
+		t.Fatalf("Was expecting scores [nil 0], got %v", got.Scores)
+	}
+	if got.CheckedAt[0] != nil || got.CheckedAt[1] == nil || !got.CheckedAt[1].Equal(checkedAt) {
+		t.Fatalf("Was expecting checked_at [nil %v], got %v", checkedAt, got.CheckedAt)
+	}
+}
+
+func TestDateAttributes(t *testing.T) {
+	// a birth date late in the day west of UTC stays on its calendar day
+	birthDate := time.Date(1990, 5, 17, 23, 0, 0, 0, time.FixedZone("PDT", -7*3600))
+	nextVisit := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	patient := &Patient{ID: 1, BirthDate: birthDate, NextVisit: &nextVisit, AdmittedAt: nextVisit}
+
+	payload, err := MarshalOne(patient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "1990-05-17", payload.Data.Attributes["birth_date"]; e != a {
+		t.Fatalf("Was expecting birth_date %q, got %v", e, a)
+	}
+	if e, a := "2021-03-01", payload.Data.Attributes["next_visit"]; e != a {
+		t.Fatalf("Was expecting next_visit %q, got %v", e, a)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(out).Encode(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	got := new(Patient)
+	if err := UnmarshalPayload(out, got); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), got.BirthDate; !e.Equal(a) {
+		t.Fatalf("Was expecting birth_date %v, got %v", e, a)
+	}
+	if got.NextVisit == nil || !got.NextVisit.Equal(nextVisit) {
+		t.Fatalf("Was expecting next_visit %v, got %v", nextVisit, got.NextVisit)
+	}
+
+	for _, value := range []string{`"1990-05-17T00:00:00Z"`, `"17/05/1990"`, `643593600`} {
+		data := `{"data": {"type": "patients", "id": "1", "attributes": {"birth_date": ` + value + `}}}`
+		if err := UnmarshalPayload(strings.NewReader(data), new(Patient)); err != ErrInvalidDate {
+			t.Fatalf("Was expecting ErrInvalidDate for %s, got %v", value, err)
+		}
+	}
+}
+
+func TestTimeLayoutAttributes(t *testing.T) {
+	shippedAt := time.Date(2021, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	deliveredAt := time.Date(2021, 3, 2, 14, 0, 0, 250*int(time.Millisecond), time.UTC)
+	scan := time.Date(2021, 3, 1, 12, 15, 0, 0, time.UTC)
+	shipment := &Shipment{ID: 1, ShippedAt: shippedAt, DeliveredAt: &deliveredAt, Scans: []time.Time{scan}}
+
+	payload, err := MarshalOne(shipment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "2021-03-01T09:30:00+01:00", payload.Data.Attributes["shipped_at"]; e != a {
+		t.Fatalf("Was expecting shipped_at %q, got %v", e, a)
+	}
+	if e, a := deliveredAt.UnixNano()/int64(time.Millisecond), payload.Data.Attributes["delivered_at"]; e != a {
+		t.Fatalf("Was expecting delivered_at %v, got %v", e, a)
+	}
+	if e, a := []interface{}{"01/03/2021 12:15"}, payload.Data.Attributes["scans"]; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting scans %v, got %v", e, a)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(out).Encode(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	got := new(Shipment)
+	if err := UnmarshalPayload(out, got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.ShippedAt.Equal(shippedAt) {
+		t.Fatalf("Was expecting shipped_at %v, got %v", shippedAt, got.ShippedAt)
+	}
+	if got.DeliveredAt == nil || !got.DeliveredAt.Equal(deliveredAt) {
+		t.Fatalf("Was expecting delivered_at %v, got %v", deliveredAt, got.DeliveredAt)
+	}
+	if len(got.Scans) != 1 || !got.Scans[0].Equal(scan) {
+		t.Fatalf("Was expecting scans %v, got %v", scan, got.Scans)
+	}
+
+	data := `{"data": {"type": "shipments", "id": "1", "attributes": {"shipped_at": "2021-03-01"}}}`
+	if err := UnmarshalPayload(strings.NewReader(data), new(Shipment)); err != ErrInvalidTimeLayout {
+		t.Fatalf("Was expecting ErrInvalidTimeLayout, got %v", err)
+	}
+}
+
+func TestTimeLayoutWithComma(t *testing.T) {
+	if _, err := MarshalOne(&Parcel{ID: 1}); err != ErrBadJSONAPIStructTag {
+		t.Fatalf("Was expecting ErrBadJSONAPIStructTag, got %v", err)
+	}
+	data := `{"data": {"type": "parcels", "id": "1", "attributes": {"received": "Mon, 01 Mar 2021"}}}`
+	if err := UnmarshalPayload(strings.NewReader(data), new(Parcel)); err != ErrBadJSONAPIStructTag {
+		t.Fatalf("Was expecting ErrBadJSONAPIStructTag, got %v", err)
+	}
+
+	// the named layouts may hold one
+	stamped := time.Date(2021, 3, 1, 9, 30, 0, 0, time.UTC)
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, &Letter{ID: 1, Stamped: stamped}); err != nil {
+		t.Fatal(err)
+	}
+	if e := `"stamped":"Mon, 01 Mar 2021 09:30:00 UTC"`; !strings.Contains(out.String(), e) {
+		t.Fatalf("Was expecting %s, got %s", e, out)
+	}
+	letter := new(Letter)
+	if err := UnmarshalPayload(out, letter); err != nil {
+		t.Fatal(err)
+	}
+	if !letter.Stamped.Equal(stamped) {
+		t.Fatalf("Was expecting stamped %v, got %v", stamped, letter.Stamped)
+	}
+}
+
+func TestRelationshipLinkage(t *testing.T) {
+	for _, test := range []struct {
+		relationship interface{}
+		ids          []string
+		ok           bool
+	}{
+		{map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"type": "comments", "id": "1"},
+			map[string]interface{}{"type": "comments", "id": "2"},
+		}}, []string{"1", "2"}, true},
+		{map[string]interface{}{"data": map[string]interface{}{"type": "people", "id": "3"}}, []string{"3"}, true},
+		{map[string]interface{}{"data": nil}, nil, true},
+		{map[string]interface{}{"links": map[string]interface{}{"related": "/posts/1/author"}}, nil, false},
+	} {
+		linkage, ok, err := RelationshipLinkage(test.relationship)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, n := range linkage {
+			ids = append(ids, n.ID)
+		}
+		if ok != test.ok || !reflect.DeepEqual(ids, test.ids) {
+			t.Fatalf("Was expecting %v, %v for %v, got %v, %v", test.ids, test.ok, test.relationship, ids, ok)
+		}
+	}
+}
+
+func TestUnmarshalRelationWithoutLinkage(t *testing.T) {
+	data := `{"data": {"type": "forums", "id": "1",
+		"attributes": {"name": "General"},
+		"relationships": {"threads": {"meta": {"count": 12}}}}}`
+
+	forum := &Forum{Threads: []*Post{{ID: 1}}}
+	if err := UnmarshalPayload(strings.NewReader(data), forum); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(forum.Threads) != 1 || forum.Threads[0].ID != 1 {
+		t.Fatalf("Was expecting the threads to be left untouched, got %v", forum.Threads)
+	}
+
+	blog := &Blog{CurrentPost: &Post{ID: 2}}
+	data = `{"data": {"type": "blogs", "id": "5",
+		"relationships": {"current_post": {"links": {"related": "/blogs/5/current_post"}}}}}`
+	if err := UnmarshalPayload(strings.NewReader(data), blog); err != nil {
+		t.Fatal(err)
+	}
+	if blog.CurrentPost == nil || blog.CurrentPost.ID != 2 {
+		t.Fatalf("Was expecting the current post to be left untouched, got %v", blog.CurrentPost)
+	}
+}
+
+func TestUnmarshalWithCoercion(t *testing.T) {
+	data := `{"data": {"type": "with-pointers", "id": "2",
+		"attributes": {"name": 42, "is-active": 1, "int-val": " 7 ", "float-val": "1.5"}}}`
+
+	logger := new(recordingLogger)
+	model := new(WithPointer)
+	if err := UnmarshalPayload(strings.NewReader(data), model,
+		WithCoercion(), WithUnmarshalLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+
+	if *model.Name != "42" || !*model.IsActive || *model.IntVal != 7 || *model.FloatVal != 1.5 {
+		t.Fatalf("Was expecting the attributes to be coerced, got %+v", model)
+	}
+
+	fields := []string{}
+	for _, a := range logger.anomalies {
+		if a.Kind != AnomalyAttributeCoerced || a.ResourceType != "with-pointers" || a.ResourceID != "2" {
+			t.Fatalf("Was expecting coercion anomalies, got %v", a)
+		}
+		fields = append(fields, a.Field)
+	}
+	sort.Strings(fields)
+	if e, a := []string{"float-val", "int-val", "is-active", "name"}, fields; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the coerced fields %v, got %v", e, a)
+	}
+
+	// well-typed values are not reported
+	logger = new(recordingLogger)
+	data = `{"data": {"type": "books", "id": "1", "attributes": {"author": "Ann", "pages": "12"}}}`
+	book := new(Book)
+	if err := UnmarshalPayload(strings.NewReader(data), book,
+		WithCoercion(), WithUnmarshalLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+	if book.Author != "Ann" || *book.Pages != 12 || len(logger.anomalies) != 1 {
+		t.Fatalf("Was expecting only pages to be coerced, got %+v %v", book, logger.anomalies)
+	}
+}
+
+func TestUnmarshalWithCoercionInvalid(t *testing.T) {
+	for _, attributes := range []string{
+		`{"int-val": "seven"}`,
+		`{"is-active": 2}`,
+		`{"is-active": "maybe"}`,
+	} {
+		data := `{"data": {"type": "with-pointers", "id": "2", "attributes": ` + attributes + `}}`
+		err := UnmarshalPayload(strings.NewReader(data), new(WithPointer), WithCoercion())
+		if err == nil {
+			t.Fatalf("%s: was expecting an error", attributes)
+		}
+	}
+
+	// without the option, strings are not coerced
+	data := `{"data": {"type": "with-pointers", "id": "2", "attributes": {"int-val": "7"}}}`
+	if err := UnmarshalPayload(strings.NewReader(data), new(WithPointer)); err == nil {
+		t.Fatal("Was expecting an error without WithCoercion")
+	}
+}
+
+func TestUnmarshalUnknownRelationships(t *testing.T) {
+	data := `{"data": {"type": "proxied", "id": "1",
+		"attributes": {"name": "Proxy"},
+		"relationships": {
+			"owner": {"data": {"type": "people", "id": "2"}},
+			"tags": {"data": [{"type": "tags", "id": "3"}], "meta": {"count": 1}},
+			"parent": {"data": null},
+			"history": {"links": {"related": "/proxied/1/history"}}
+		}}}`
+
+	// ignored by default
+	model := new(Proxied)
+	if err := UnmarshalPayload(strings.NewReader(data), model); err != nil {
+		t.Fatal(err)
+	}
+	if model.Relations != nil {
+		t.Fatalf("Was expecting the unknown relationships to be ignored, got %v", model.Relations)
+	}
+
+	err := UnmarshalPayload(strings.NewReader(data), new(Proxied),
+		WithUnmarshalConfig(Config{UnknownRelationships: UnknownRelationshipsReject}))
+	if !errors.Is(err, ErrUnknownRelationship) {
+		t.Fatalf("Was expecting ErrUnknownRelationship, got %v", err)
+	}
+
+	model = new(Proxied)
+	if err := UnmarshalPayload(strings.NewReader(data), model,
+		WithUnmarshalConfig(Config{UnknownRelationships: UnknownRelationshipsCollect})); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"tags": &RelationshipManyNode{
+			Data: []*Node{{Type: "tags", ID: "3"}},
+			Meta: &Meta{"count": float64(1)},
+		},
+		"parent":  &RelationshipOneNode{},
+		"history": &RelationshipLinksNode{Links: &Links{"related": "/proxied/1/history"}},
+	}
+	if !reflect.DeepEqual(expected, model.Relations) {
+		t.Fatalf("Was expecting the relationships %v, got %v", expected, model.Relations)
+	}
+	if model.Owner == nil || model.Owner.ID != 2 {
+		t.Fatalf("Was expecting the owner to be bound, got %v", model.Owner)
+	}
+
+	// the collected relationships are rendered back
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayloadEmbedded(out, model); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Data struct {
+			Relationships map[string]interface{} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	var in struct {
+		Data struct {
+			Relationships map[string]interface{} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(data), &in); err != nil {
+		t.Fatal(err)
+	}
+	delete(doc.Data.Relationships, "owner")
+	delete(in.Data.Relationships, "owner")
+	if !reflect.DeepEqual(in.Data.Relationships, doc.Data.Relationships) {
+		t.Fatalf("Was expecting the relationships %v, got %v", in.Data.Relationships, doc.Data.Relationships)
+	}
+}
+
+func TestUnmarshalExtras(t *testing.T) {
+	data := `{"data": {"type": "proxied", "id": "1",
+		"attributes": {"name": "Proxy", "color": "red", "size": 3, "tags": ["a", "b"]}}}`
+
+	logger := new(recordingLogger)
+	model := new(Proxied)
+	if err := UnmarshalPayload(strings.NewReader(data), model,
+		WithUnmarshalConfig(Config{Strict: true}), WithUnmarshalLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"color": "red",
+		"size":  float64(3),
+		"tags":  []interface{}{"a", "b"},
+	}
+	if model.Name != "Proxy" || !reflect.DeepEqual(expected, model.Extras) {
+		t.Fatalf("Was expecting the extras %v, got %+v", expected, model)
+	}
+	if len(logger.anomalies) != 0 {
+		t.Fatalf("Was expecting no unknown attributes, got %v", logger.anomalies)
+	}
+
+	// the extras are rendered back, after the declared attributes
+	model.Extras["name"] = "ignored"
+	payload, err := MarshalOne(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected["name"] = "Proxy"
+	if !reflect.DeepEqual(expected, payload.Data.Attributes) {
+		t.Fatalf("Was expecting the attributes %v, got %v", expected, payload.Data.Attributes)
+	}
+}
+
+func TestUnmarshalManySharedPrimaryResources(t *testing.T) {
+	data := `{"data": [
+		{"type": "people", "id": "1", "attributes": {"name": "alice"}, "relationships": {
+			"best_friend": {"data": {"type": "people", "id": "2"}}
+		}},
+		{"type": "people", "id": "2", "attributes": {"name": "bob"}, "relationships": {
+			"best_friend": {"data": {"type": "people", "id": "1"}}
+		}}
+	]}`
+
+	models, err := UnmarshalManyPayload(strings.NewReader(data), reflect.TypeOf(new(Person)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, bob := models[0].(*Person), models[1].(*Person)
+	if alice.BestFriend != bob {
+		t.Fatal("Was expecting the forward reference to be the second resource")
+	}
+	if bob.BestFriend != alice {
+		t.Fatal("Was expecting the back reference to be the first resource")
+	}
+	if e, a := "bob", bob.Name; e != a {
+		t.Fatalf("Was expecting name %q, got %q", e, a)
+	}
+}
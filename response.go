@@ -1,13 +1,14 @@
 package jsonapi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -23,8 +24,47 @@ var (
 	// be a slice of *Structs; MarshalMany will return this error when its
 	// interface{} argument is invalid.
 	ErrExpectedSlice = errors.New("models should be a slice of struct pointers")
+	// ErrBadLoadedRelationship is returned when a RelationshipLoader returns a
+	// single model for a to-many relation, or a slice for a to-one relation.
+	ErrBadLoadedRelationship = errors.New("loaded relationship does not match the relation field")
 )
 
+// MissingPrimaryError is returned when marshaling a relation whose related
+// models have no primary annotated field, so that they can't be identified.
+type MissingPrimaryError struct {
+	// Field is the relation field, e.g. "Post.Comments".
+	Field string
+
+	// Type is the struct type of the related models.
+	Type reflect.Type
+}
+
+// Error implements the `Error` interface.
+func (e *MissingPrimaryError) Error() string {
+	return fmt.Sprintf("The relation %s relates to %s, which has no primary annotated field",
+		e.Field, e.Type)
+}
+
+// Marshal writes the document of models, a pointer to a struct or a slice of
+// them, with the options given; a nil pointer is written as {"data":null}, e.g.
+//
+//	jsonapi.Marshal(w, posts,
+//		jsonapi.WithInclude("author"),
+//		jsonapi.WithFields(query.Fields),
+//		jsonapi.WithMeta(&jsonapi.Meta{"total": total}),
+//		jsonapi.WithLinks(&jsonapi.Links{"self": "/posts"}))
+//
+// The MarshalOnePayload and MarshalManyPayload variants are kept as wrappers
+// of the same options, e.g. WithoutIncluded for the WithoutIncluded ones.
+func Marshal(w io.Writer, models interface{}, opts ...MarshalOption) error {
+	payload, _, _, err := marshalResponse(models, opts)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(payload)
+}
+
 // MarshalOnePayload writes a jsonapi response with one, with related records
 // sideloaded, into "included" array. This method encodes a response for a
 // single record only. Hence, data will be a single record rather than an array
@@ -33,8 +73,8 @@ var (
 // See UnmarshalPayload for usage example.
 //
 // model interface{} should be a pointer to a struct.
-func MarshalOnePayload(w io.Writer, model interface{}) error {
-	payload, err := MarshalOne(model)
+func MarshalOnePayload(w io.Writer, model interface{}, opts ...MarshalOption) error {
+	payload, err := MarshalOne(model, opts...)
 	if err != nil {
 		return err
 	}
@@ -46,39 +86,136 @@ func MarshalOnePayload(w io.Writer, model interface{}) error {
 	return nil
 }
 
+// MarshalOnePayloadContext does the same as MarshalOnePayload, passing ctx to
+// the hooks invoked while marshaling (see WithContext), so request scoped
+// values such as the tenant or base URL are available to them.
+func MarshalOnePayloadContext(ctx context.Context, w io.Writer, model interface{},
+	opts ...MarshalOption) error {
+	return MarshalOnePayload(w, model, withContextOption(ctx, opts)...)
+}
+
 // MarshalOnePayloadWithoutIncluded writes a jsonapi response with one object,
 // without the related records sideloaded into "included" array. If you want to
 // serialize the relations into the "included" array see MarshalOnePayload.
 //
 // model interface{} should be a pointer to a struct.
-func MarshalOnePayloadWithoutIncluded(w io.Writer, model interface{}) error {
-	included := make(map[string]*Node)
+func MarshalOnePayloadWithoutIncluded(w io.Writer, model interface{},
+	opts ...MarshalOption) error {
+	return MarshalOnePayload(w, model, append(opts, WithoutIncluded())...)
+}
+
+// MarshalOnePayloadWithFields does the same as MarshalOnePayload, rendering
+// only the attributes and relationships of the sparse fieldsets in fields,
+// keyed by resource type, e.g. the Fields of a Query (see WithFields).
+func MarshalOnePayloadWithFields(w io.Writer, model interface{}, fields map[string][]string,
+	opts ...MarshalOption) error {
+	return MarshalOnePayload(w, model, append(opts, WithFields(fields))...)
+}
+
+// MarshalOnePayloadWithMeta does the same as MarshalOnePayload, rendering meta
+// as the top-level meta of the document (see WithMeta).
+func MarshalOnePayloadWithMeta(w io.Writer, model interface{}, meta *Meta,
+	opts ...MarshalOption) error {
+	return MarshalOnePayload(w, model, append(opts, WithMeta(meta))...)
+}
+
+// MarshalWithIncludes writes the document of models, marshaled with
+// MarshalManyPayload when it is a slice and MarshalOnePayload otherwise,
+// sideloading only the related records along the given dot-separated
+// relationship paths, as found in the "include" query parameter, e.g.
+//
+//	jsonapi.MarshalWithIncludes(w, post, "author", "comments.author")
+//
+// Without paths, nothing is sideloaded. Use WithInclude to combine the paths
+// with other options.
+func MarshalWithIncludes(w io.Writer, models interface{}, paths ...string) error {
+	return Marshal(w, models, WithInclude(paths...))
+}
+
+// MarshalCreatePayload writes the payload of a request creating model, as a
+// client would send it: its relationships are rendered as resource linkage
+// only, with nothing in "included", the id is left out while the primary field
+// is empty (its zero value), its "client-id" and "lid" are rendered when set,
+// and the attributes tagged "readonly" are left out, e.g.
+//
+//	type Post struct {
+//		ID        int       `jsonapi:"primary,posts"`
+//		LocalID   string    `jsonapi:"lid"`
+//		Title     string    `jsonapi:"attr,title"`
+//		CreatedAt time.Time `jsonapi:"attr,created_at,readonly"`
+//		Author    *Person   `jsonapi:"relation,author"`
+//	}
+//
+// model interface{} should be a pointer to a struct.
+func MarshalCreatePayload(w io.Writer, model interface{}, opts ...MarshalOption) error {
+	config := newMarshalConfig(append(opts, WithInclude()))
+	config.create = true
 
-	rootNode, err := VisitModelNode(model, &included, true)
+	included := NewIncludedSet()
+
+	rootNode, err := newVisitor(included, true, config).visitModelNode(model, "")
 	if err != nil {
 		return err
 	}
 
-	if err := json.NewEncoder(w).Encode(&OnePayload{Data: rootNode}); err != nil {
+	if err := config.renameTypes([]*Node{rootNode}); err != nil {
 		return err
 	}
+	omitEmptyIDs(rootNode)
 
-	return nil
+	payload := &OnePayload{Data: rootNode}
+	if err := config.applyPayloadHook(payload); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(payload)
 }
 
 // MarshalOne does the same as MarshalOnePayload except it just returns the
 // payload and doesn't write out results. Useful is you use your JSON rendering
 // library.
-func MarshalOne(model interface{}) (*OnePayload, error) {
-	included := make(map[string]*Node)
+func MarshalOne(model interface{}, opts ...MarshalOption) (payload *OnePayload, err error) {
+	config := newMarshalConfig(opts)
+	var span *tracedSpan
+	config.ctx, span = startSpan(config.ctx, config.tracer, "jsonapi.MarshalOne")
+	defer func() {
+		if payload != nil {
+			span.finish(payload.Data.Type, 1, len(payload.Included), err)
+		} else {
+			span.finish("", 0, 0, err)
+		}
+	}()
 
-	rootNode, err := VisitModelNode(model, &included, true)
+	included := NewIncludedSet()
+	v := newVisitor(included, true, config)
+
+	rootNode, err := v.visitModelNode(model, "")
 	if err != nil {
 		return nil, err
 	}
-	payload := &OnePayload{Data: rootNode}
+	payload = &OnePayload{Data: rootNode}
+
+	payload.Included = withoutPrimary(included.Nodes(), []*Node{rootNode})
+	if payload.Links, err = config.documentLinks(); err != nil {
+		return nil, err
+	}
+	payload.Links = mergeLinks(config.resolvedResourceLinks(rootNode.Type, rootNode.ID), payload.Links)
+	payload.Meta = config.deprecationMeta(config.queryMeta(v.truncationMeta(config.meta)))
+	payload.JSONAPI = config.jsonapiObject()
+	if config.sortRelationships {
+		sortNodes(payload.Included)
+	}
+
+	if err := config.renameTypes([]*Node{payload.Data}, payload.Included); err != nil {
+		return nil, err
+	}
+	if config.withoutIncluded {
+		payload.Included = nil
+	}
 
-	payload.Included = nodeMapValues(&included)
+	if err := config.applyPayloadHook(payload); err != nil {
+		return nil, err
+	}
 
 	return payload, nil
 }
@@ -98,24 +235,9 @@ func AddIncludedToManyPayload(payload *ManyPayload, node *Node) {
 // serialize the relations into the "included" array see MarshalManyPayload.
 //
 // models interface{} should be a slice of struct pointers.
-func MarshalManyPayloadWithoutIncluded(w io.Writer, models interface{}) error {
-	m, err := convertToSliceInterface(&models)
-	if err != nil {
-		return err
-	}
-	payload, err := MarshalMany(m)
-	if err != nil {
-		return err
-	}
-
-	// Empty the included
-	payload.Included = []*Node{}
-
-	if err := json.NewEncoder(w).Encode(payload); err != nil {
-		return err
-	}
-
-	return nil
+func MarshalManyPayloadWithoutIncluded(w io.Writer, models interface{},
+	opts ...MarshalOption) error {
+	return MarshalManyPayload(w, models, append(opts, WithoutIncluded())...)
 }
 
 // MarshalManyPayload writes a jsonapi response with many records, with related
@@ -144,12 +266,12 @@ func MarshalManyPayloadWithoutIncluded(w io.Writer, models interface{}) error {
 // Visit https://github.com/google/jsonapi#list for more info.
 //
 // models interface{} should be a slice of struct pointers.
-func MarshalManyPayload(w io.Writer, models interface{}) error {
+func MarshalManyPayload(w io.Writer, models interface{}, opts ...MarshalOption) error {
 	m, err := convertToSliceInterface(&models)
 	if err != nil {
 		return err
 	}
-	payload, err := MarshalMany(m)
+	payload, err := MarshalMany(m, opts...)
 	if err != nil {
 		return err
 	}
@@ -161,27 +283,115 @@ func MarshalManyPayload(w io.Writer, models interface{}) error {
 	return nil
 }
 
+// MarshalManyPayloadContext does the same as MarshalManyPayload, passing ctx
+// to the hooks invoked while marshaling (see WithContext).
+func MarshalManyPayloadContext(ctx context.Context, w io.Writer, models interface{},
+	opts ...MarshalOption) error {
+	return MarshalManyPayload(w, models, withContextOption(ctx, opts)...)
+}
+
+// MarshalManyPayloadWithFields does the same as MarshalManyPayload, rendering
+// only the attributes and relationships of the sparse fieldsets in fields,
+// keyed by resource type (see WithFields).
+func MarshalManyPayloadWithFields(w io.Writer, models interface{}, fields map[string][]string,
+	opts ...MarshalOption) error {
+	return MarshalManyPayload(w, models, append(opts, WithFields(fields))...)
+}
+
+// MarshalManyPayloadWithMeta does the same as MarshalManyPayload, rendering
+// meta as the top-level meta of the document (see WithMeta).
+func MarshalManyPayloadWithMeta(w io.Writer, models interface{}, meta *Meta,
+	opts ...MarshalOption) error {
+	return MarshalManyPayload(w, models, append(opts, WithMeta(meta))...)
+}
+
 // MarshalMany does the same as MarshalManyPayload except it just returns the
 // payload and doesn't write out results. Useful is you use your JSON rendering
 // library.
-func MarshalMany(models []interface{}) (*ManyPayload, error) {
-	payload := &ManyPayload{
+func MarshalMany(models []interface{}, opts ...MarshalOption) (payload *ManyPayload, err error) {
+	config := newMarshalConfig(opts)
+	var span *tracedSpan
+	config.ctx, span = startSpan(config.ctx, config.tracer, "jsonapi.MarshalMany")
+	defer func() {
+		if payload != nil && len(payload.Data) > 0 {
+			span.finish(payload.Data[0].Type, len(payload.Data), len(payload.Included), err)
+		} else {
+			span.finish("", 0, 0, err)
+		}
+	}()
+
+	payload = &ManyPayload{
 		Data: []*Node{},
 	}
-	included := map[string]*Node{}
+	included := NewIncludedSet()
+	v := newVisitor(included, true, config)
 
 	for _, model := range models {
-		node, err := VisitModelNode(model, &included, true)
+		node, err := v.visitModelNode(model, "")
 		if err != nil {
 			return nil, err
 		}
 		payload.Data = append(payload.Data, node)
 	}
-	payload.Included = nodeMapValues(&included)
+	payload.Included = withoutPrimary(included.Nodes(), payload.Data)
+	if payload.Links, err = config.documentLinks(); err != nil {
+		return nil, err
+	}
+	if len(payload.Data) > 0 {
+		payload.Links = mergeLinks(config.resolvedCollectionLinks(payload.Data[0].Type), payload.Links)
+	}
+	payload.Meta = config.deprecationMeta(config.queryMeta(v.truncationMeta(config.meta)))
+	payload.JSONAPI = config.jsonapiObject()
+	if config.pagination != nil {
+		config.pagination.apply(payload)
+	}
+	if config.sortRelationships {
+		sortNodes(payload.Included)
+	}
+
+	if err := config.renameTypes(payload.Data, payload.Included); err != nil {
+		return nil, err
+	}
+	if config.withoutIncluded {
+		payload.Included = nil
+	}
+
+	if err := config.applyPayloadHook(payload); err != nil {
+		return nil, err
+	}
 
 	return payload, nil
 }
 
+// MarshalOneWith does the same as MarshalOne, with the top-level links and
+// meta of the document, e.g. its self link and the id of the request, so that
+// they don't have to be provided by the models. Either can be nil.
+func MarshalOneWith(model interface{}, links *Links, meta *Meta,
+	opts ...MarshalOption) (*OnePayload, error) {
+	return MarshalOne(model, append([]MarshalOption{WithLinks(links), WithMeta(meta)}, opts...)...)
+}
+
+// MarshalManyWith does the same as MarshalMany, with the top-level links and
+// meta of the document. See MarshalOneWith.
+func MarshalManyWith(models []interface{}, links *Links, meta *Meta,
+	opts ...MarshalOption) (*ManyPayload, error) {
+	return MarshalMany(models, append([]MarshalOption{WithLinks(links), WithMeta(meta)}, opts...)...)
+}
+
+// MarshalOneContext does the same as MarshalOne, passing ctx to the hooks
+// invoked while marshaling (see WithContext).
+func MarshalOneContext(ctx context.Context, model interface{},
+	opts ...MarshalOption) (*OnePayload, error) {
+	return MarshalOne(model, withContextOption(ctx, opts)...)
+}
+
+// MarshalManyContext does the same as MarshalMany, passing ctx to the hooks
+// invoked while marshaling (see WithContext).
+func MarshalManyContext(ctx context.Context, models []interface{},
+	opts ...MarshalOption) (*ManyPayload, error) {
+	return MarshalMany(models, withContextOption(ctx, opts)...)
+}
+
 // MarshalOnePayloadEmbedded - This method not meant to for use in
 // implementation code, although feel free.  The purpose of this method is for
 // use in tests.  In most cases, your request payloads for create will be
@@ -195,13 +405,33 @@ func MarshalMany(models []interface{}) (*ManyPayload, error) {
 // produced by the client.  This is what this method is intended for.
 //
 // model interface{} should be a pointer to a struct.
-func MarshalOnePayloadEmbedded(w io.Writer, model interface{}) error {
-	rootNode, err := VisitModelNode(model, nil, false)
+func MarshalOnePayloadEmbedded(w io.Writer, model interface{},
+	opts ...MarshalOption) (err error) {
+	config := newMarshalConfig(opts)
+	var span *tracedSpan
+	config.ctx, span = startSpan(config.ctx, config.tracer, "jsonapi.MarshalOnePayloadEmbedded")
+	var rootNode *Node
+	defer func() {
+		if rootNode != nil {
+			span.finish(rootNode.Type, 1, 0, err)
+		} else {
+			span.finish("", 0, 0, err)
+		}
+	}()
+
+	rootNode, err = newVisitor(nil, false, config).visitModelNode(model, "")
 	if err != nil {
 		return err
 	}
 
+	if err := config.renameTypes([]*Node{rootNode}); err != nil {
+		return err
+	}
+
 	payload := &OnePayload{Data: rootNode}
+	if err := config.applyPayloadHook(payload); err != nil {
+		return err
+	}
 
 	if err := json.NewEncoder(w).Encode(payload); err != nil {
 		return err
@@ -210,27 +440,139 @@ func MarshalOnePayloadEmbedded(w io.Writer, model interface{}) error {
 	return nil
 }
 
-func VisitModelNode(model interface{}, included *map[string]*Node,
+// VisitModelNode builds the Node for model. Related records are sideloaded
+// into included when sideload is true, otherwise they are embedded in the
+// relationships of the returned Node.
+func VisitModelNode(model interface{}, included *IncludedSet,
 	sideload bool) (*Node, error) {
+	return newVisitor(included, sideload, newMarshalConfig(nil)).visitModelNode(model, "")
+}
+
+// visitor walks a graph of models building Nodes according to the
+// marshalConfig of a single Marshal call.
+type visitor struct {
+	config   *marshalConfig
+	included *IncludedSet
+	sideload bool
+
+	// visiting holds the type/id keys of the models on the path currently
+	// being traversed, used to detect cycles in the object graph.
+	visiting map[string]bool
+
+	// root is the included set of the payload, which included points to
+	// unless records are sideloaded into a scratch set.
+	root *IncludedSet
+
+	// omitted holds the type/id keys of the records left out of included
+	// because of the included limit.
+	omitted map[string]bool
+}
+
+func newVisitor(included *IncludedSet, sideload bool,
+	config *marshalConfig) *visitor {
+	return &visitor{
+		config:   config,
+		included: included,
+		sideload: sideload,
+		visiting: make(map[string]bool),
+		root:     included,
+		omitted:  make(map[string]bool),
+	}
+}
+
+// visitModelNode builds the Node for a model found at path, the dot-separated
+// relationship names leading to it from the primary data ("" for the primary
+// data itself).
+func (v *visitor) visitModelNode(model interface{}, path string) (*Node, error) {
+	model = modelPointer(model)
+
+	// The models with jsonapi tags, e.g. those of jsonapigen, are rendered
+	// from their tags when the options rewrite their members
+	if m, ok := model.(NodeMarshaler); ok && !(v.config.rewritesMembers() && isTaggedModel(model)) {
+		node, err := m.MarshalJSONAPINode()
+		if err != nil {
+			return nil, err
+		}
+		if r, ok := model.(RelatedMarshaler); ok && v.sideload {
+			if err := v.sideloadRelated(model, r, node, path); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	}
+
+	// The primary data may declare what to sideload when WithInclude isn't used
+	if includer, ok := model.(DefaultIncluder); ok && path == "" && v.config.include == nil {
+		v.config.include = make(map[string]bool)
+		addIncludePaths(v.config.include, includer.JSONAPIDefaultIncludes())
+		defer func() { v.config.include = nil }()
+	}
+
+	identifier, err := visitModelIdentifier(model)
+	if err != nil {
+		return nil, err
+	}
+
+	// A model that is already being visited further up the graph is a cycle;
+	// render it as a resource identifier rather than recursing forever.
+	key := visitingKey(model, identifier)
+	if v.visiting[key] {
+		return identifier, nil
+	}
+	v.visiting[key] = true
+	defer delete(v.visiting, key)
+
+	if hook, ok := model.(BeforeMarshaler); ok {
+		if err := hook.BeforeJSONAPIMarshal(v.config.ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var metaOnly bool
+	if m, ok := model.(MetaOnly); ok {
+		metaOnly = m.JSONAPIMetaOnly()
+	}
+
+	// linkageMeta holds the linkage-meta annotated fields by relationship
+	// name, applied once the relationships are built
+	linkageMeta := make(map[string]reflect.Value)
+
+	// mapKeyMeta holds the resource identifier meta carrying the keys of the
+	// map-typed relationships, by relationship name
+	mapKeyMeta := make(map[string][]*Meta)
+
+	// memberMeta holds the members of the resource meta given by the meta
+	// annotated fields naming one, and relationMeta those of the meta of each
+	// relationship, by relationship name
+	memberMeta := Meta{}
+	relationMeta := make(map[string]Meta)
+
+	// extraRelations holds the relationships collected by the
+	// extra-relationships annotated field
+	var extraRelations map[string]interface{}
+
+	// extras holds the attributes collected by the extras annotated field
+	var extras map[string]interface{}
+
+	// deprecated holds the names of the deprecated attributes and
+	// relationships, listed in the resource meta
+	var deprecated []string
+
 	node := new(Node)
 
 	var er error
 
 	modelValue := reflect.ValueOf(model).Elem()
-	modelType := reflect.ValueOf(model).Type().Elem()
 
-	for i := 0; i < modelValue.NumField(); i++ {
-		structField := modelValue.Type().Field(i)
-		tag := structField.Tag.Get(annotationJSONAPI)
-		if tag == "" {
+	for _, f := range schemaOf(modelValue.Type()).fields {
+		structField, args := f.field, f.args
+
+		fieldValue := f.value(modelValue)
+		if !fieldValue.IsValid() {
+			// promoted through a nil embedded pointer
 			continue
 		}
 
-		fieldValue := modelValue.Field(i)
-		fieldType := modelType.Field(i)
-
-		args := strings.Split(tag, annotationSeperator)
-
 		if len(args) < 1 {
 			er = ErrBadJSONAPIStructTag
 			break
@@ -238,75 +580,121 @@ func VisitModelNode(model interface{}, included *map[string]*Node,
 
 		annotation := args[0]
 
-		if (annotation == annotationClientID && len(args) != 1) ||
-			(annotation != annotationClientID && len(args) < 2) {
-			panic("weird args for model")
+		if !validTagArgs(args) {
 			er = ErrBadJSONAPIStructTag
 			break
 		}
 
-		if annotation == annotationPrimary {
-			v := fieldValue
+		if metaOnly && annotation != annotationPrimary && annotation != annotationMeta {
+			continue
+		}
 
-			// Deal with PTRS
-			var kind reflect.Kind
-			if fieldValue.Kind() == reflect.Ptr {
-				kind = fieldType.Type.Elem().Kind()
-				v = reflect.Indirect(fieldValue)
-			} else {
-				kind = fieldType.Type.Kind()
-			}
-
-			// Handle allowed types
-			switch kind {
-			case reflect.String:
-				node.ID = v.Interface().(string)
-			case reflect.Int:
-				node.ID = strconv.FormatInt(int64(v.Interface().(int)), 10)
-			case reflect.Int8:
-				node.ID = strconv.FormatInt(int64(v.Interface().(int8)), 10)
-			case reflect.Int16:
-				node.ID = strconv.FormatInt(int64(v.Interface().(int16)), 10)
-			case reflect.Int32:
-				node.ID = strconv.FormatInt(int64(v.Interface().(int32)), 10)
-			case reflect.Int64:
-				node.ID = strconv.FormatInt(v.Interface().(int64), 10)
-			case reflect.Uint:
-				node.ID = strconv.FormatUint(uint64(v.Interface().(uint)), 10)
-			case reflect.Uint8:
-				node.ID = strconv.FormatUint(uint64(v.Interface().(uint8)), 10)
-			case reflect.Uint16:
-				node.ID = strconv.FormatUint(uint64(v.Interface().(uint16)), 10)
-			case reflect.Uint32:
-				node.ID = strconv.FormatUint(uint64(v.Interface().(uint32)), 10)
-			case reflect.Uint64:
-				node.ID = strconv.FormatUint(v.Interface().(uint64), 10)
-			default:
-				// We had a JSON float (numeric), but our field was not one of the
-				// allowed numeric types
-				er = ErrBadJSONAPIID
+		if annotation == annotationPrimary {
+			id, err := formatPrimaryID(fieldValue)
+			if err != nil {
+				er = err
 				break
 			}
-
+			// A new record has no server assigned id yet
+			if v.config.create && fieldValue.IsZero() {
+				id = ""
+			}
+			node.ID = id
 			node.Type = args[1]
 		} else if annotation == annotationClientID {
 			clientID := fieldValue.String()
 			if clientID != "" {
 				node.ClientID = clientID
 			}
+		} else if annotation == annotationLocalID {
+			node.LocalID = fieldValue.String()
+		} else if annotation == annotationLinks {
+			links, err := linksFieldValue(fieldValue)
+			if err != nil {
+				er = err
+				break
+			}
+			node.Links = v.config.defaults.resolveLinks(links)
+		} else if annotation == annotationMeta && len(args) > 1 {
+			omitEmpty := false
+			for _, arg := range args[2:] {
+				if arg == annotationOmitEmpty {
+					omitEmpty = true
+				}
+			}
+			if omitEmpty && isEmptyValue(fieldValue) {
+				continue
+			}
+
+			if rel := metaRelation(args); rel != "" {
+				name := v.config.memberName(rel)
+				if relationMeta[name] == nil {
+					relationMeta[name] = Meta{}
+				}
+				relationMeta[name][args[1]] = fieldValue.Interface()
+			} else {
+				memberMeta[args[1]] = fieldValue.Interface()
+			}
+		} else if annotation == annotationMeta {
+			meta, err := metaFieldValue(fieldValue)
+			if err != nil {
+				er = err
+				break
+			}
+			node.Meta = meta
+		} else if annotation == annotationLinkageMeta {
+			linkageMeta[v.config.memberName(args[1])] = fieldValue
+		} else if annotation == annotationRelationPresent {
+			// only set when unmarshaling
+			continue
+		} else if annotation == annotationExtraRelations {
+			extra, ok := fieldValue.Interface().(map[string]interface{})
+			if !ok {
+				er = ErrBadJSONAPIStructTag
+				break
+			}
+			extraRelations = extra
+		} else if annotation == annotationExtras {
+			extra, ok := fieldValue.Interface().(map[string]interface{})
+			if !ok {
+				er = ErrBadJSONAPIStructTag
+				break
+			}
+			extras = extra
 		} else if annotation == annotationAttribute {
-			var omitEmpty, iso8601 bool
+			name := v.config.memberName(args[1])
+
+			if !v.config.attributeVisible(identifier.Type, name) || !v.config.inScope(attributeScopes(args)) {
+				continue
+			}
+
+			var omitEmpty, omitZero, readOnly bool
 
 			if len(args) > 2 {
 				for _, arg := range args[2:] {
 					switch arg {
 					case annotationOmitEmpty:
 						omitEmpty = true
-					case annotationISO8601:
-						iso8601 = true
+					case annotationOmitZero:
+						omitZero = true
+					case annotationReadOnly:
+						readOnly = true
+					case annotationDeprecated:
+						deprecated = append(deprecated, name)
 					}
 				}
 			}
+			if readOnly && v.config.create {
+				continue
+			}
+			if omitZero && isZeroValue(fieldValue) {
+				continue
+			}
+			layout, err := attributeTimeLayout(args, v.config.defaults.TimeFormat)
+			if err != nil {
+				er = err
+				break
+			}
 
 			if node.Attributes == nil {
 				node.Attributes = make(map[string]interface{})
@@ -316,14 +704,16 @@ func VisitModelNode(model interface{}, included *map[string]*Node,
 				t := fieldValue.Interface().(time.Time)
 
 				if t.IsZero() {
+					v.config.logAnomaly(Anomaly{
+						Kind:         AnomalyZeroTimeDropped,
+						ResourceType: identifier.Type,
+						ResourceID:   identifier.ID,
+						Field:        name,
+					})
 					continue
 				}
 
-				if iso8601 {
-					node.Attributes[args[1]] = t.UTC().Format(iso8601TimeFormat)
-				} else {
-					node.Attributes[args[1]] = t.Unix()
-				}
+				node.Attributes[name] = timeAttribute(t, layout)
 			} else if fieldValue.Type() == reflect.TypeOf(new(time.Time)) {
 				// A time pointer may be nil
 				if fieldValue.IsNil() {
@@ -331,7 +721,7 @@ func VisitModelNode(model interface{}, included *map[string]*Node,
 						continue
 					}
 
-					node.Attributes[args[1]] = nil
+					node.Attributes[name] = nil
 				} else {
 					tm := fieldValue.Interface().(*time.Time)
 
@@ -339,161 +729,996 @@ func VisitModelNode(model interface{}, included *map[string]*Node,
 						continue
 					}
 
-					if iso8601 {
-						node.Attributes[args[1]] = tm.UTC().Format(iso8601TimeFormat)
-					} else {
-						node.Attributes[args[1]] = tm.Unix()
-					}
+					node.Attributes[name] = timeAttribute(*tm, layout)
 				}
 			} else {
 				// Dealing with a fieldValue that is not a time
-				emptyValue := reflect.Zero(fieldValue.Type())
 
 				// See if we need to omit this field
-				if omitEmpty && fieldValue.Interface() == emptyValue.Interface() {
+				if omitEmpty && isEmptyValue(fieldValue) {
+					continue
+				}
+
+				if attributeStringEncoded(args) {
+					if s, ok := stringAttribute(fieldValue); ok {
+						node.Attributes[name] = s
+						continue
+					}
+				}
+
+				if value, ok, err := customAttribute(fieldValue); ok {
+					if err != nil {
+						er = err
+						break
+					}
+					node.Attributes[name] = value
+					continue
+				}
+
+				if times, ok := fieldValue.Interface().([]time.Time); ok && times != nil {
+					values := make([]interface{}, len(times))
+					for i, t := range times {
+						values[i] = timeAttribute(t, layout)
+					}
+					node.Attributes[name] = values
+					continue
+				}
+				if times, ok := fieldValue.Interface().([]*time.Time); ok && times != nil {
+					values := make([]interface{}, len(times))
+					for i, t := range times {
+						if t != nil {
+							values[i] = timeAttribute(*t, layout)
+						}
+					}
+					node.Attributes[name] = values
+					continue
+				}
+
+				if fieldValue.Kind() == reflect.Interface {
+					value, err := discriminatedAttribute(fieldValue.Interface(), attributeDiscriminator(args))
+					if err != nil {
+						er = err
+						break
+					}
+					node.Attributes[name] = value
 					continue
 				}
 
 				strAttr, ok := fieldValue.Interface().(string)
 				if ok {
-					node.Attributes[args[1]] = strAttr
+					node.Attributes[name] = strAttr
 				} else {
-					node.Attributes[args[1]] = fieldValue.Interface()
+					node.Attributes[name] = fieldValue.Interface()
 				}
 			}
 		} else if annotation == annotationRelation {
-			var omitEmpty bool
+			name := v.config.memberName(args[1])
+			fieldValue = relationValue(fieldValue)
 
-			//add support for 'omitempty' struct tag for marshaling as absent
-			if len(args) > 2 {
-				omitEmpty = args[2] == annotationOmitEmpty
-			}
-
-			isSlice := fieldValue.Type().Kind() == reflect.Slice
-			if omitEmpty &&
-				(isSlice && fieldValue.Len() < 1 ||
-					(!isSlice && fieldValue.IsNil())) {
+			if !v.config.relationshipVisible(identifier.Type, name) {
 				continue
 			}
 
-			if node.Relationships == nil {
-				node.Relationships = make(map[string]interface{})
-			}
+			var omitEmpty, noInclude, noLinkage bool
 
-			var relLinks *Links
-			if linkableModel, ok := model.(RelationshipLinkable); ok {
-				relLinks = linkableModel.JSONAPIRelationshipLinks(args[1])
+			if len(args) > 2 {
+				for _, arg := range args[2:] {
+					switch arg {
+					case annotationOmitEmpty:
+						//add support for 'omitempty' struct tag for marshaling as absent
+						omitEmpty = true
+					case annotationNoInclude:
+						noInclude = true
+					case annotationNoLinkage:
+						noLinkage = true
+					case annotationDeprecated:
+						deprecated = append(deprecated, name)
+					}
+				}
 			}
-
-			var relMeta *Meta
-			if metableModel, ok := model.(RelationshipMetable); ok {
-				relMeta = metableModel.JSONAPIRelationshipMeta(args[1])
+			idsType, idsOnly := relationIDsType(args)
+			if !idsOnly {
+				if err := checkRelationTarget(modelValue.Type(), structField); err != nil {
+					er = err
+					break
+				}
 			}
 
-			if isSlice {
-				// to-many relationship
-				relationship, err := visitModelNodeRelationships(
-					fieldValue,
-					included,
-					sideload,
-				)
+			relPath := joinIncludePath(path, name)
+			traverse := !noInclude && !noLinkage && !idsOnly &&
+				!v.config.maxDepthReached(includePathDepth(path)) &&
+				v.config.includes(relPath)
+
+			// Relationships that will be traversed may be loaded on demand
+			if loader, ok := model.(RelationshipLoader); ok && traverse {
+				loaded, err := loader.LoadJSONAPIRelationship(v.config.ctx, args[1])
 				if err != nil {
 					er = err
 					break
 				}
-				relationship.Links = relLinks
-				relationship.Meta = relMeta
-
-				if sideload {
-					shallowNodes := []*Node{}
-					for _, n := range relationship.Data {
-						appendIncluded(included, n)
-						shallowNodes = append(shallowNodes, toShallowNode(n))
+				if loaded != nil {
+					fieldValue = reflect.ValueOf(loaded)
+					if (fieldValue.Kind() == reflect.Slice) !=
+						(relationValue(f.value(modelValue)).Kind() == reflect.Slice) {
+						er = ErrBadLoadedRelationship
+						break
 					}
+				}
+			}
 
-					node.Relationships[args[1]] = &RelationshipManyNode{
-						Data:  shallowNodes,
-						Links: relationship.Links,
-						Meta:  relationship.Meta,
-					}
-				} else {
-					node.Relationships[args[1]] = relationship
+			var keyMetas []*Meta
+			if fieldValue.Kind() == reflect.Map {
+				// to-many relationship held by a map, rendered in key order
+				values, keys, err := relationMapValues(fieldValue)
+				if err != nil {
+					er = err
+					break
 				}
-			} else {
-				// to-one relationships
+				fieldValue = values
 
-				// Handle null relationship case
-				if fieldValue.IsNil() {
-					node.Relationships[args[1]] = &RelationshipOneNode{Data: nil}
-					continue
+				if metaName := relationMapKeyMeta(args); metaName != "" {
+					keyMetas = make([]*Meta, len(keys))
+					for i, key := range keys {
+						keyMetas[i] = &Meta{metaName: key}
+					}
 				}
+			}
 
-				relationship, err := VisitModelNode(
-					fieldValue.Interface(),
-					included,
-					sideload,
-				)
+			if v.config.sortRelationships && fieldValue.Kind() == reflect.Slice {
+				sorted, order, err := sortRelated(model, args[1], fieldValue, idsOnly)
 				if err != nil {
 					er = err
 					break
 				}
+				fieldValue = sorted
 
-				if sideload {
-					appendIncluded(included, relationship)
-					node.Relationships[args[1]] = &RelationshipOneNode{
-						Data:  toShallowNode(relationship),
-						Links: relLinks,
-						Meta:  relMeta,
-					}
-				} else {
-					node.Relationships[args[1]] = &RelationshipOneNode{
-						Data:  relationship,
-						Links: relLinks,
-						Meta:  relMeta,
+				if keyMetas != nil {
+					sortedMetas := make([]*Meta, len(order))
+					for i, j := range order {
+						sortedMetas[i] = keyMetas[j]
 					}
+					keyMetas = sortedMetas
 				}
 			}
 
-		} else {
-			er = ErrBadJSONAPIStructTag
-			break
-		}
-	}
+			if keyMetas != nil {
+				mapKeyMeta[name] = keyMetas
+			}
+
+			isSlice := fieldValue.Type().Kind() == reflect.Slice
+			if omitEmpty && isEmptyRelation(fieldValue) {
+				continue
+			}
+
+			if node.Relationships == nil {
+				node.Relationships = make(map[string]interface{})
+			}
+
+			relLinks := v.config.defaults.resolveLinks(relationshipLinks(
+				v.config.ctx, model, args[1], fieldValue.Interface(),
+			))
+			if relLinks == nil {
+				relLinks = v.config.resolvedRelationshipLinks(identifier.Type, identifier.ID, name)
+			}
+			relMeta := relationshipMeta(v.config.ctx, model, args[1], fieldValue.Interface())
+
+			if noLinkage {
+				// links and meta only, e.g. the count of an expensive relation;
+				// a relationship object needs at least one of them
+				if relLinks != nil || relMeta != nil {
+					node.Relationships[name] = &RelationshipLinksNode{
+						Links: relLinks,
+						Meta:  relMeta,
+					}
+				}
+			} else if isSlice && v.config.relationshipLimitExceeded(fieldValue) {
+				// too many members; links and count only
+				meta := Meta{}
+				if relMeta != nil {
+					for k, val := range *relMeta {
+						meta[k] = val
+					}
+				}
+				meta["count"] = fieldValue.Len()
+
+				node.Relationships[name] = &RelationshipLinksNode{
+					Links: relLinks,
+					Meta:  &meta,
+				}
+			} else if idsOnly {
+				// the field holds the related ID(s) rather than models
+				relationship, err := visitRelationshipIDs(fieldValue, idsType)
+				if err != nil {
+					er = err
+					break
+				}
+
+				switch r := relationship.(type) {
+				case *RelationshipOneNode:
+					r.Links = relLinks
+					r.Meta = relMeta
+				case *RelationshipManyNode:
+					r.Links = relLinks
+					r.Meta = relMeta
+				}
+
+				node.Relationships[name] = relationship
+			} else if !traverse {
+				// linkage only; the related resources are never sideloaded
+				if !noInclude && v.sideload && !isEmptyRelation(fieldValue) {
+					v.config.logAnomaly(Anomaly{
+						Kind:         AnomalyIncludeTruncated,
+						ResourceType: identifier.Type,
+						ResourceID:   identifier.ID,
+						Field:        name,
+					})
+				}
+				relationship, err := visitModelNodeIdentifiers(fieldValue, isSlice)
+				if err != nil {
+					er = err
+					break
+				}
+
+				switch r := relationship.(type) {
+				case *RelationshipOneNode:
+					r.Links = relLinks
+					r.Meta = relMeta
+				case *RelationshipManyNode:
+					r.Links = relLinks
+					r.Meta = relMeta
+				}
+
+				node.Relationships[name] = relationship
+			} else if isSlice {
+				// to-many relationship
+				relationship, err := v.visitModelNodeRelationships(
+					node,
+					name,
+					fieldValue,
+					relPath,
+				)
+				if err != nil {
+					er = err
+					break
+				}
+				relationship.Links = relLinks
+				relationship.Meta = relMeta
+
+				if v.sideload {
+					shallowNodes := []*Node{}
+					for _, n := range relationship.Data {
+						shallowNodes = append(shallowNodes, toShallowNode(n))
+					}
+
+					node.Relationships[name] = &RelationshipManyNode{
+						Data:  shallowNodes,
+						Links: relationship.Links,
+						Meta:  relationship.Meta,
+					}
+				} else {
+					node.Relationships[name] = relationship
+				}
+			} else {
+				// to-one relationships
+
+				// Handle null relationship case
+				if fieldValue.IsNil() {
+					node.Relationships[name] = &RelationshipOneNode{
+						Data:  nil,
+						Links: relLinks,
+						Meta:  relMeta,
+					}
+					continue
+				}
+
+				relationship, err := v.visitRelated(
+					node,
+					name,
+					fieldValue.Interface(),
+					relPath,
+				)
+				if err != nil {
+					er = err
+					break
+				}
+
+				if v.sideload {
+					node.Relationships[name] = &RelationshipOneNode{
+						Data:  toShallowNode(relationship),
+						Links: relLinks,
+						Meta:  relMeta,
+					}
+				} else {
+					node.Relationships[name] = &RelationshipOneNode{
+						Data:  relationship,
+						Links: relLinks,
+						Meta:  relMeta,
+					}
+				}
+			}
+
+		} else {
+			er = ErrBadJSONAPIStructTag
+			break
+		}
+	}
 
 	if er != nil {
 		return nil, er
 	}
 
-	if linkableModel, isLinkable := model.(Linkable); isLinkable {
+	// The attributes collected when unmarshaling are rendered back, unless
+	// the model declares them
+	for name, value := range extras {
+		if _, ok := node.Attributes[name]; ok || !v.config.attributeVisible(identifier.Type, name) {
+			continue
+		}
+		if node.Attributes == nil {
+			node.Attributes = make(map[string]interface{})
+		}
+		node.Attributes[name] = value
+	}
+
+	if attributer, ok := model.(Attributer); ok && !metaOnly {
+		for name, value := range attributer.JSONAPIAttributes() {
+			if !v.config.attributeVisible(identifier.Type, name) {
+				continue
+			}
+			if node.Attributes == nil {
+				node.Attributes = make(map[string]interface{})
+			}
+			node.Attributes[name] = value
+		}
+	}
+
+	// The relationships collected when unmarshaling are rendered back, unless
+	// the model declares them
+	for name, rel := range extraRelations {
+		if _, ok := node.Relationships[name]; ok || !v.config.relationshipVisible(identifier.Type, name) {
+			continue
+		}
+		if node.Relationships == nil {
+			node.Relationships = make(map[string]interface{})
+		}
+		node.Relationships[name] = rel
+	}
+
+	for name, fieldValue := range linkageMeta {
+		if err := setLinkageMeta(node.Relationships[name], fieldValue); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, meta := range relationMeta {
+		meta := meta
+		switch r := node.Relationships[name].(type) {
+		case *RelationshipOneNode:
+			r.Meta = mergeMeta(r.Meta, &meta)
+		case *RelationshipManyNode:
+			r.Meta = mergeMeta(r.Meta, &meta)
+		case *RelationshipLinksNode:
+			r.Meta = mergeMeta(r.Meta, &meta)
+		}
+	}
+
+	for name, metas := range mapKeyMeta {
+		if r, ok := node.Relationships[name].(*RelationshipManyNode); ok {
+			for i, n := range r.Data {
+				n.Meta = mergeMeta(n.Meta, metas[i])
+			}
+		}
+	}
+
+	if transform := v.config.attributeTransformer; transform != nil {
+		for name, value := range node.Attributes {
+			if value, keep := transform(node.Type, name, value); keep {
+				node.Attributes[name] = value
+			} else {
+				delete(node.Attributes, name)
+			}
+		}
+	}
+
+	// Empty attributes and relationships are rendered as {} only on request
+	if v.config.defaults.EmitEmptyMembers && !metaOnly {
+		node.emptyMembers = true
+		if node.Attributes == nil {
+			node.Attributes = make(map[string]interface{})
+		}
+		if node.Relationships == nil {
+			node.Relationships = make(map[string]interface{})
+		}
+	} else {
+		if len(node.Attributes) == 0 {
+			node.Attributes = nil
+		}
+		if len(node.Relationships) == 0 {
+			node.Relationships = nil
+		}
+	}
+
+	// Linkable takes precedence over a links annotated field
+	if linkableModel, isLinkable := model.(LinkableWithContext); isLinkable && !metaOnly {
+		jl := linkableModel.JSONAPILinksWithContext(v.config.ctx)
+		if er := jl.validate(); er != nil {
+			return nil, er
+		}
+		node.Links = v.config.defaults.resolveLinks(jl)
+	} else if linkableModel, isLinkable := model.(Linkable); isLinkable && !metaOnly {
 		jl := linkableModel.JSONAPILinks()
 		if er := jl.validate(); er != nil {
 			return nil, er
 		}
-		node.Links = linkableModel.JSONAPILinks()
+		node.Links = v.config.defaults.resolveLinks(linkableModel.JSONAPILinks())
+	}
+	if node.Links == nil && !metaOnly {
+		node.Links = v.config.resolvedResourceLinks(node.Type, node.ID)
 	}
 
-	if metableModel, ok := model.(Metable); ok {
+	// Metable takes precedence over a meta annotated field; the members named
+	// by meta annotated fields are merged into either
+	if metableModel, ok := model.(MetableWithContext); ok {
+		node.Meta = metableModel.JSONAPIMetaWithContext(v.config.ctx)
+	} else if metableModel, ok := model.(Metable); ok {
 		node.Meta = metableModel.JSONAPIMeta()
 	}
 
+	if len(memberMeta) > 0 {
+		node.Meta = mergeMeta(node.Meta, &memberMeta)
+	}
+
+	if len(deprecated) > 0 {
+		node.Meta = mergeMeta(node.Meta, &Meta{"deprecated": deprecated})
+	}
+
+	return node, nil
+}
+
+// formatPrimaryID renders the value of a primary annotated field as a JSON API
+// id string.
+func formatPrimaryID(fieldValue reflect.Value) (string, error) {
+	v := derefValue(fieldValue)
+
+	// Handle allowed types
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	default:
+		// We had a JSON float (numeric), but our field was not one of the
+		// allowed numeric types
+		return "", ErrBadJSONAPIID
+	}
+}
+
+// derefValue follows the pointers of v, however many levels, up to the value
+// they point to or to a nil pointer.
+func derefValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// isEmptyValue reports whether the attribute held by v is empty, as understood
+// by omitempty: as told by its Emptier implementation if it has one, or else
+// false, 0, a nil pointer or interface, an empty string, slice or map, or an
+// array or struct holding its zero value.
+func isEmptyValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return true
+	}
+	if e, ok := v.Interface().(Emptier); ok {
+		return e.JSONAPIIsEmpty()
+	}
+	if v.CanAddr() {
+		if e, ok := v.Addr().Interface().(Emptier); ok {
+			return e.JSONAPIIsEmpty()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+// isZeroValue reports whether v is logically zero: as told by its IsZero
+// method if it has one, e.g. for time.Time or decimal types, or else when it
+// holds the zero value of its type.
+func isZeroValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return true
+	}
+	if z, ok := v.Interface().(interface{ IsZero() bool }); ok {
+		return z.IsZero()
+	}
+	if v.CanAddr() {
+		if z, ok := v.Addr().Interface().(interface{ IsZero() bool }); ok {
+			return z.IsZero()
+		}
+	}
+	return v.IsZero()
+}
+
+// relationValue returns the slice, or the pointer to a struct, held by a
+// relation annotated field through any levels of pointers, e.g. for
+// *[]*Comment or **Comment fields. Nil pointers yield a nil slice or struct
+// pointer.
+func relationValue(fieldValue reflect.Value) reflect.Value {
+	for fieldValue.Kind() == reflect.Ptr {
+		elemKind := fieldValue.Type().Elem().Kind()
+		if elemKind != reflect.Ptr && elemKind != reflect.Slice {
+			break
+		}
+		if fieldValue.IsNil() {
+			fieldValue = reflect.Zero(fieldValue.Type().Elem())
+		} else {
+			fieldValue = fieldValue.Elem()
+		}
+	}
+	return fieldValue
+}
+
+// relationMapValues returns the values of a map-typed to-many relationship as a
+// slice, sorted by key, along with their keys.
+func relationMapValues(fieldValue reflect.Value) (reflect.Value, []string, error) {
+	if fieldValue.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}, nil, ErrBadJSONAPIStructTag
+	}
+
+	keys := make([]string, 0, fieldValue.Len())
+	for _, k := range fieldValue.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	values := reflect.MakeSlice(reflect.SliceOf(fieldValue.Type().Elem()), 0, len(keys))
+	for _, k := range keys {
+		key := reflect.ValueOf(k).Convert(fieldValue.Type().Key())
+		values = reflect.Append(values, fieldValue.MapIndex(key))
+	}
+
+	return values, keys, nil
+}
+
+// sortRelated returns a sorted copy of related, the members of the relation of
+// model, along with the original index of each of its members. The members are
+// sorted by the model when it implements RelationshipSorter, and by id
+// otherwise; idsOnly tells whether related holds the IDs themselves.
+func sortRelated(model interface{}, relation string, related reflect.Value,
+	idsOnly bool) (reflect.Value, []int, error) {
+	order := make([]int, related.Len())
+	for i := range order {
+		order[i] = i
+	}
+
+	if sorter, ok := model.(RelationshipSorter); ok {
+		sort.SliceStable(order, func(i, j int) bool {
+			return sorter.JSONAPILessRelated(relation,
+				related.Index(order[i]).Interface(), related.Index(order[j]).Interface())
+		})
+	} else {
+		ids := make([]string, related.Len())
+		for i := range ids {
+			var err error
+			if idsOnly {
+				ids[i], err = formatPrimaryID(related.Index(i))
+			} else {
+				var n *Node
+				n, err = visitModelIdentifier(related.Index(i).Interface())
+				if n != nil {
+					ids[i] = n.ID
+				}
+			}
+			if err != nil {
+				return reflect.Value{}, nil, err
+			}
+		}
+
+		sort.SliceStable(order, func(i, j int) bool {
+			return lessID(ids[order[i]], ids[order[j]])
+		})
+	}
+
+	sorted := reflect.MakeSlice(related.Type(), len(order), len(order))
+	for i, j := range order {
+		sorted.Index(i).Set(related.Index(j))
+	}
+
+	return sorted, order, nil
+}
+
+// lessID orders ids numerically when both are integers, and lexically
+// otherwise.
+func lessID(a, b string) bool {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
+
+// sortNodes orders nodes by type and id.
+func sortNodes(nodes []*Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Type != nodes[j].Type {
+			return nodes[i].Type < nodes[j].Type
+		}
+		return lessID(nodes[i].ID, nodes[j].ID)
+	})
+}
+
+// timeAttribute returns the rendering of a time attribute: a string in layout
+// if it is set, or else a unix timestamp.
+func timeAttribute(t time.Time, layout string) interface{} {
+	switch layout {
+	case "":
+		return t.Unix()
+	case unixMilliLayout:
+		return t.UnixNano() / int64(time.Millisecond)
+	case iso8601TimeFormat:
+		return t.UTC().Format(layout)
+	default:
+		// the calendar date of t where it was taken, e.g. a birthday, or t
+		// with its offset, e.g. for RFC3339
+		return t.Format(layout)
+	}
+}
+
+// mergeMeta returns the members of meta and extra in a new Meta, extra taking
+// precedence.
+func mergeMeta(meta, extra *Meta) *Meta {
+	merged := Meta{}
+	for _, m := range []*Meta{meta, extra} {
+		if m == nil {
+			continue
+		}
+		for k, v := range *m {
+			merged[k] = v
+		}
+	}
+	return &merged
+}
+
+// visitModelIdentifier builds a resource identifier (type and id only) for
+// model without visiting its attributes or relationships.
+func visitModelIdentifier(model interface{}) (*Node, error) {
+	if m, ok := modelPointer(model).(NodeMarshaler); ok {
+		n, err := m.MarshalJSONAPINode()
+		if err != nil {
+			return nil, err
+		}
+		return toShallowNode(n), nil
+	}
+
+	node := new(Node)
+
+	modelValue := reflect.Indirect(reflect.ValueOf(model))
+
+	for _, f := range schemaOf(modelValue.Type()).fields {
+		args := f.args
+
+		switch args[0] {
+		case annotationPrimary:
+			if len(args) < 2 {
+				return nil, ErrBadJSONAPIStructTag
+			}
+
+			id, err := formatPrimaryID(f.value(modelValue))
+			if err != nil {
+				return nil, err
+			}
+			node.ID = id
+			node.Type = args[1]
+		case annotationLocalID:
+			if localID := f.value(modelValue); localID.IsValid() {
+				node.LocalID = localID.String()
+			}
+		}
+	}
+
+	// A new record is identified by its local id only
+	if node.LocalID != "" && primaryIsZero(modelValue) {
+		node.ID = ""
+	}
+
 	return node, nil
 }
 
+// checkRelationTarget returns a *MissingPrimaryError when the models related by
+// field, a relation field of modelType, have neither a primary annotated
+// field nor a MarshalJSONAPINode method. Interface typed relations are not
+// checked.
+func checkRelationTarget(modelType reflect.Type, field reflect.StructField) error {
+	t := relationTargetType(field.Type)
+	if t.Kind() != reflect.Struct || hasPrimaryField(t) ||
+		reflect.PtrTo(t).Implements(nodeMarshalerType) {
+		return nil
+	}
+
+	return &MissingPrimaryError{Field: modelType.Name() + "." + field.Name, Type: t}
+}
+
+// relationTargetType returns the type of the models held by a relation field
+// of type t, e.g. Comment for []*Comment or map[string]*Comment.
+func relationTargetType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isTaggedModel reports whether model is a pointer to a struct with a primary
+// annotated field.
+func isTaggedModel(model interface{}) bool {
+	t := reflect.TypeOf(model)
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && hasPrimaryField(t.Elem())
+}
+
+// hasPrimaryField reports whether the struct type t has a primary annotated
+// field.
+func hasPrimaryField(t reflect.Type) bool {
+	_, ok := schemaOf(t).primary()
+	return ok
+}
+
+// primaryIsZero reports whether the primary annotated field of modelValue holds
+// its zero value.
+func primaryIsZero(modelValue reflect.Value) bool {
+	if f, ok := schemaOf(modelValue.Type()).primary(); ok {
+		return f.value(modelValue).IsZero()
+	}
+	return false
+}
+
+// visitModelNodeIdentifiers returns the relationship linkage for fieldValue
+// without visiting, or sideloading, the related models.
+func visitModelNodeIdentifiers(fieldValue reflect.Value, isSlice bool) (interface{}, error) {
+	if !isSlice {
+		if fieldValue.IsNil() {
+			return &RelationshipOneNode{Data: nil}, nil
+		}
+
+		n, err := visitModelIdentifier(fieldValue.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return &RelationshipOneNode{Data: n}, nil
+	}
+
+	nodes := []*Node{}
+	for i := 0; i < fieldValue.Len(); i++ {
+		n, err := visitModelIdentifier(fieldValue.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	return &RelationshipManyNode{Data: nodes}, nil
+}
+
+// visitRelationshipIDs returns the relationship linkage for a field holding
+// the ID, or a slice of IDs, of resources of type relType.
+func visitRelationshipIDs(fieldValue reflect.Value, relType string) (interface{}, error) {
+	if relType == "" {
+		// the type of the related resources can't be inferred from their IDs
+		return nil, ErrBadJSONAPIStructTag
+	}
+
+	if fieldValue.Kind() == reflect.Slice {
+		nodes := []*Node{}
+		for i := 0; i < fieldValue.Len(); i++ {
+			id, err := formatPrimaryID(fieldValue.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, &Node{Type: relType, ID: id})
+		}
+
+		return &RelationshipManyNode{Data: nodes}, nil
+	}
+
+	if isEmptyRelation(fieldValue) {
+		return &RelationshipOneNode{Data: nil}, nil
+	}
+
+	id, err := formatPrimaryID(fieldValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelationshipOneNode{Data: &Node{Type: relType, ID: id}}, nil
+}
+
+// isEmptyRelation reports whether a relation annotated field holds no related
+// resources.
+func isEmptyRelation(fieldValue reflect.Value) bool {
+	switch fieldValue.Kind() {
+	case reflect.Slice, reflect.Map:
+		return fieldValue.Len() < 1
+	case reflect.Ptr, reflect.Interface:
+		return fieldValue.IsNil()
+	default:
+		return fieldValue.IsZero()
+	}
+}
+
+// relationshipLinks returns the links of the relation of model, preferring
+// RelatedLinkable, then RelationshipLinkableWithContext, over
+// RelationshipLinkable.
+func relationshipLinks(ctx context.Context, model interface{}, relation string,
+	related interface{}) *Links {
+	if linkableModel, ok := model.(RelatedLinkable); ok {
+		return linkableModel.JSONAPIRelatedLinks(relation, related)
+	}
+	if linkableModel, ok := model.(RelationshipLinkableWithContext); ok {
+		return linkableModel.JSONAPIRelationshipLinksWithContext(ctx, relation)
+	}
+	if linkableModel, ok := model.(RelationshipLinkable); ok {
+		return linkableModel.JSONAPIRelationshipLinks(relation)
+	}
+	return nil
+}
+
+// relationshipMeta returns the meta of the relation of model, preferring
+// RelatedMetableWithContext, then RelatedMetable, then
+// RelationshipMetableWithContext, over RelationshipMetable.
+func relationshipMeta(ctx context.Context, model interface{}, relation string,
+	related interface{}) *Meta {
+	if metableModel, ok := model.(RelatedMetableWithContext); ok {
+		return metableModel.JSONAPIRelatedMetaWithContext(ctx, relation, related)
+	}
+	if metableModel, ok := model.(RelatedMetable); ok {
+		return metableModel.JSONAPIRelatedMeta(relation, related)
+	}
+	if metableModel, ok := model.(RelationshipMetableWithContext); ok {
+		return metableModel.JSONAPIRelationshipMetaWithContext(ctx, relation)
+	}
+	if metableModel, ok := model.(RelationshipMetable); ok {
+		return metableModel.JSONAPIRelationshipMeta(relation)
+	}
+	return nil
+}
+
+// setLinkageMeta sets the meta held by a linkage-meta annotated field on the
+// resource identifiers of relationship: a []*Meta paired, by index, with the
+// identifiers of a to-many relationship, or a *Meta for a to-one relationship.
+func setLinkageMeta(relationship interface{}, fieldValue reflect.Value) error {
+	switch r := relationship.(type) {
+	case nil:
+		return nil
+	case *RelationshipManyNode:
+		metas, ok := fieldValue.Interface().([]*Meta)
+		if !ok {
+			return ErrBadJSONAPIStructTag
+		}
+
+		for i, n := range r.Data {
+			if i < len(metas) && metas[i] != nil {
+				n.Meta = metas[i]
+			}
+		}
+	case *RelationshipOneNode:
+		meta, ok := fieldValue.Interface().(*Meta)
+		if !ok {
+			return ErrBadJSONAPIStructTag
+		}
+
+		if r.Data != nil && meta != nil {
+			r.Data.Meta = meta
+		}
+	}
+
+	return nil
+}
+
+// linksFieldValue returns the Links held by a links annotated field, which
+// must be of type Links or *Links.
+func linksFieldValue(fieldValue reflect.Value) (*Links, error) {
+	switch links := fieldValue.Interface().(type) {
+	case *Links:
+		if links == nil {
+			return nil, nil
+		}
+		if err := links.validate(); err != nil {
+			return nil, err
+		}
+		return links, nil
+	case Links:
+		if links == nil {
+			return nil, nil
+		}
+		if err := links.validate(); err != nil {
+			return nil, err
+		}
+		return &links, nil
+	default:
+		return nil, ErrBadJSONAPIStructTag
+	}
+}
+
+// metaFieldValue returns the Meta held by a meta annotated field, which may be
+// a Meta, a map or a struct that encodes to a JSON object.
+func metaFieldValue(fieldValue reflect.Value) (*Meta, error) {
+	if (fieldValue.Kind() == reflect.Ptr || fieldValue.Kind() == reflect.Map) &&
+		fieldValue.IsNil() {
+		return nil, nil
+	}
+
+	switch meta := fieldValue.Interface().(type) {
+	case *Meta:
+		return meta, nil
+	case Meta:
+		return &meta, nil
+	case map[string]interface{}:
+		m := Meta(meta)
+		return &m, nil
+	}
+
+	b, err := json.Marshal(fieldValue.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	meta := new(Meta)
+	if err := json.Unmarshal(b, meta); err != nil {
+		return nil, ErrBadJSONAPIStructTag
+	}
+
+	return meta, nil
+}
+
+// omitEmptyIDs leaves out the empty ids of nodes and of the resources of their
+// relationships, which are created along with them.
+func omitEmptyIDs(nodes ...*Node) {
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		n.omitEmptyID = true
+		for _, relationship := range n.Relationships {
+			switch r := relationship.(type) {
+			case *RelationshipOneNode:
+				omitEmptyIDs(r.Data)
+			case *RelationshipManyNode:
+				omitEmptyIDs(r.Data...)
+			}
+		}
+	}
+}
+
 func toShallowNode(node *Node) *Node {
 	return &Node{
-		ID:   node.ID,
-		Type: node.Type,
+		ID:      node.ID,
+		LocalID: node.LocalID,
+		Type:    node.Type,
 	}
 }
 
-func visitModelNodeRelationships(models reflect.Value, included *map[string]*Node,
-	sideload bool) (*RelationshipManyNode, error) {
+func (v *visitor) visitModelNodeRelationships(parent *Node, relation string,
+	models reflect.Value, path string) (*RelationshipManyNode, error) {
 	nodes := []*Node{}
 
 	for i := 0; i < models.Len(); i++ {
-		n := models.Index(i).Interface()
+		n := sliceElem(models, i)
 
-		node, err := VisitModelNode(n, included, sideload)
+		node, err := v.visitRelated(parent, relation, n, path)
 		if err != nil {
 			return nil, err
 		}
@@ -504,33 +1729,198 @@ func visitModelNodeRelationships(models reflect.Value, included *map[string]*Nod
 	return &RelationshipManyNode{Data: nodes}, nil
 }
 
-func appendIncluded(m *map[string]*Node, nodes ...*Node) {
-	included := *m
+// visitRelated builds the Node for a model related to parent, and sideloads it
+// unless the include func rejects it.
+func (v *visitor) visitRelated(parent *Node, relation string,
+	model interface{}, path string) (*Node, error) {
+	if !v.sideload || v.config.includeFunc == nil {
+		node, err := v.visitModelNode(model, path)
+		if err != nil {
+			return nil, err
+		}
+		if v.sideload {
+			v.appendIncluded(node)
+		}
+		return node, nil
+	}
 
-	for _, n := range nodes {
-		k := fmt.Sprintf("%s,%s", n.Type, n.ID)
+	// Records related to the model are sideloaded into a scratch set, so that
+	// nothing only reachable through a rejected model is included.
+	included := v.included
+	scratch := NewIncludedSet()
+	v.included = scratch
+	node, err := v.visitModelNode(model, path)
+	v.included = included
+	if err != nil {
+		return nil, err
+	}
+
+	if v.config.includeFunc(parent, relation, node) {
+		v.appendIncluded(node)
+		for _, n := range scratch.Nodes() {
+			if !v.includedLimitReached(n) {
+				v.included.Add(n)
+			}
+		}
+	} else {
+		v.config.logAnomaly(Anomaly{
+			Kind:         AnomalyIncludeTruncated,
+			ResourceType: parent.Type,
+			ResourceID:   parent.ID,
+			Field:        relation,
+		})
+	}
+
+	return node, nil
+}
+
+// sideloadRelated sideloads the records related to model, whose Node is built
+// by its NodeMarshaler, as returned by its RelatedMarshaler for the
+// relationships with linkage of node on an include path.
+func (v *visitor) sideloadRelated(model interface{}, r RelatedMarshaler, node *Node,
+	path string) error {
+	key := visitingKey(model, node)
+	if v.visiting[key] || v.config.maxDepthReached(includePathDepth(path)) {
+		return nil
+	}
+	v.visiting[key] = true
+	defer delete(v.visiting, key)
+
+	// in a stable order, as the related records are appended to included
+	names := make([]string, 0, len(node.Relationships))
+	for name, rel := range node.Relationships {
+		switch rel.(type) {
+		case *RelationshipOneNode, *RelationshipManyNode:
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
 
-		if _, hasNode := included[k]; hasNode {
+	for _, name := range names {
+		relPath := joinIncludePath(path, name)
+		if !v.config.includes(relPath) {
 			continue
 		}
 
-		included[k] = n
+		related := reflect.ValueOf(r.JSONAPIRelated(name))
+		switch related.Kind() {
+		case reflect.Slice:
+			for i := 0; i < related.Len(); i++ {
+				if isEmptyRelation(related.Index(i)) {
+					continue
+				}
+				if _, err := v.visitRelated(node, name, sliceElem(related, i), relPath); err != nil {
+					return err
+				}
+			}
+		case reflect.Ptr:
+			if related.IsNil() {
+				continue
+			}
+			if _, err := v.visitRelated(node, name, related.Interface(), relPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendIncluded sideloads n, unless n is a cycle stub for a model that is
+// still being visited; its full Node is added once that visit completes.
+func (v *visitor) appendIncluded(n *Node) {
+	if v.visiting[visitingKey(nil, n)] || v.includedLimitReached(n) {
+		return
+	}
+	v.included.Add(n)
+}
+
+// includedLimitReached reports whether n must be left out of the payload
+// because it would exceed the included limit, and records it as omitted.
+func (v *visitor) includedLimitReached(n *Node) bool {
+	limit := v.config.includedLimit
+	if limit <= 0 {
+		return false
+	}
+
+	if v.root.Has(n.Type, n.ID) || v.root.Len() < limit {
+		return false
+	}
+	v.omitted[includedKey(n.Type, n.ID)] = true
+	return true
+}
+
+// truncationMeta returns meta, with the included_truncated and omitted members
+// added if records were left out because of the included limit.
+func (v *visitor) truncationMeta(meta *Meta) *Meta {
+	if len(v.omitted) == 0 {
+		return meta
+	}
+
+	truncated := Meta{}
+	if meta != nil {
+		for k, val := range *meta {
+			truncated[k] = val
+		}
 	}
+	truncated["included_truncated"] = true
+	truncated["omitted"] = len(v.omitted)
+	return &truncated
 }
 
-func nodeMapValues(m *map[string]*Node) []*Node {
-	mp := *m
-	nodes := make([]*Node, len(mp))
+// visitingKey identifies a model during traversal by its type and id. Models
+// without an id yet (e.g. new records) are identified by their address.
+func visitingKey(model interface{}, identifier *Node) string {
+	if identifier.ID == "" && model != nil {
+		return fmt.Sprintf("%s,%p", identifier.Type, model)
+	}
+	return fmt.Sprintf("%s,%s", identifier.Type, identifier.ID)
+}
 
-	i := 0
-	for _, n := range mp {
-		nodes[i] = n
-		i++
+// withoutPrimary returns the included Nodes that are not in the primary data,
+// which the spec forbids to duplicate in included, e.g. when the primary
+// resources relate to each other.
+func withoutPrimary(included, data []*Node) []*Node {
+	primary := make(map[string]bool, len(data))
+	for _, n := range data {
+		if n.ID != "" {
+			primary[includedKey(n.Type, n.ID)] = true
+		}
 	}
 
+	nodes := included[:0]
+	for _, n := range included {
+		if !primary[includedKey(n.Type, n.ID)] {
+			nodes = append(nodes, n)
+		}
+	}
 	return nodes
 }
 
+// modelPointer returns model as a pointer to its struct, copying a struct value
+// into a new pointer, so that the interfaces implemented by models (Linkable,
+// Metable, ...) are found whether their methods have value or pointer
+// receivers.
+func modelPointer(model interface{}) interface{} {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Struct {
+		return model
+	}
+
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr.Interface()
+}
+
+// sliceElem returns the i-th element of the slice models, addressing struct
+// elements in place so that changes made by model hooks are kept.
+func sliceElem(models reflect.Value, i int) interface{} {
+	elem := models.Index(i)
+	if elem.Kind() == reflect.Struct && elem.CanAddr() {
+		return elem.Addr().Interface()
+	}
+	return elem.Interface()
+}
+
 func convertToSliceInterface(i *interface{}) ([]interface{}, error) {
 	vals := reflect.ValueOf(*i)
 	if vals.Kind() != reflect.Slice {
@@ -538,7 +1928,7 @@ func convertToSliceInterface(i *interface{}) ([]interface{}, error) {
 	}
 	var response []interface{}
 	for x := 0; x < vals.Len(); x++ {
-		response = append(response, vals.Index(x).Interface())
+		response = append(response, sliceElem(vals, x))
 	}
 	return response, nil
 }
@@ -2,7 +2,9 @@ package jsonapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"reflect"
 	"sort"
 	"testing"
@@ -211,6 +213,42 @@ func TestMarshall_invalidIDType(t *testing.T) {
 	}
 }
 
+func TestMarshalIDKinds(t *testing.T) {
+	type int8ID struct {
+		ID int8 `jsonapi:"primary,int8s"`
+	}
+	type uint16ID struct {
+		ID uint16 `jsonapi:"primary,uint16s"`
+	}
+	type int64PtrID struct {
+		ID *int64 `jsonapi:"primary,int64s"`
+	}
+	id := int64(-9000000000)
+
+	for model, expected := range map[interface{}]string{
+		&int8ID{ID: -8}:          "-8",
+		&uint16ID{ID: 65535}:     "65535",
+		&int64PtrID{ID: &id}:     "-9000000000",
+		&Car{ID: new(string)}:    "",
+		&Blog{ID: 5, Title: "x"}: "5",
+	} {
+		payload, err := MarshalOne(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e, a := expected, payload.Data.ID; e != a {
+			t.Fatalf("Was expecting the id %q, got %q", e, a)
+		}
+	}
+}
+
+func TestMarshalBadTagArgs(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, &BadModel{ID: 1}); err != ErrBadJSONAPIStructTag {
+		t.Fatalf("Was expecting a `%s` error, got `%v`", ErrBadJSONAPIStructTag, err)
+	}
+}
+
 func TestOmitsEmptyAnnotation(t *testing.T) {
 	book := &Book{
 		Author:      "aren55555",
@@ -829,3 +867,1608 @@ func testBlog() *Blog {
 		},
 	}
 }
+
+func TestMarshalNoIncludeRelation(t *testing.T) {
+	type BlogNoInclude struct {
+		ID          int     `jsonapi:"primary,blogs"`
+		Title       string  `jsonapi:"attr,title"`
+		Posts       []*Post `jsonapi:"relation,posts,noinclude"`
+		CurrentPost *Post   `jsonapi:"relation,current_post,omitempty,noinclude"`
+	}
+
+	blog := &BlogNoInclude{
+		ID:    5,
+		Title: "Title 1",
+		Posts: []*Post{
+			&Post{ID: 1, Title: "Foo", Comments: []*Comment{&Comment{ID: 1}}},
+			&Post{ID: 2, Title: "Fuubar"},
+		},
+		CurrentPost: &Post{ID: 1, Title: "Foo"},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, blog); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Included) != 0 {
+		t.Fatalf("Was expecting no included records, got %d", len(resp.Included))
+	}
+
+	posts := resp.Data.Relationships["posts"].(map[string]interface{})["data"].([]interface{})
+	if e, a := 2, len(posts); e != a {
+		t.Fatalf("Was expecting %d posts in the linkage, got %d", e, a)
+	}
+	for _, p := range posts {
+		post := p.(map[string]interface{})
+		if post["type"] != "posts" || post["id"] == "" {
+			t.Fatalf("Was expecting a posts resource identifier, got %v", post)
+		}
+		if _, exists := post["attributes"]; exists {
+			t.Fatal("Was expecting the posts linkage to omit attributes")
+		}
+	}
+
+	currentPost := resp.Data.Relationships["current_post"].(map[string]interface{})["data"].(map[string]interface{})
+	if e, a := "1", currentPost["id"]; e != a {
+		t.Fatalf("Was expecting current_post id %q, got %q", e, a)
+	}
+}
+
+func TestMarshalWithMaxDepth(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), WithMaxDepth(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// posts 1 and 2 are one level deep; their comments are two levels deep
+	if e, a := 2, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included records, got %d", e, a)
+	}
+	for _, n := range resp.Included {
+		if n.Type != "posts" {
+			t.Fatalf("Was expecting only posts to be included, got %s", n.Type)
+		}
+
+		comments := n.Relationships["comments"].(map[string]interface{})["data"].([]interface{})
+		if e, a := 2, len(comments); e != a {
+			t.Fatalf("Was expecting %d comments in the linkage, got %d", e, a)
+		}
+	}
+}
+
+func TestMarshalWithoutMaxDepthIncludesFullGraph(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), WithMaxDepth(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// posts 1 and 2, comments 1, 2 and 3
+	if e, a := 5, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included records, got %d", e, a)
+	}
+}
+
+func testCategoryTree() *Category {
+	root := &Category{ID: 1, Name: "root"}
+	left := &Category{ID: 2, Name: "left", Parent: root}
+	right := &Category{ID: 3, Name: "right", Parent: root}
+	leaf := &Category{ID: 4, Name: "leaf", Parent: left}
+
+	root.Children = []*Category{left, right}
+	left.Children = []*Category{leaf}
+
+	return root
+}
+
+func testPersonGraph() []*Person {
+	alice := &Person{ID: 1, Name: "alice"}
+	bob := &Person{ID: 2, Name: "bob"}
+	alice.BestFriend, bob.BestFriend = bob, alice
+
+	rex := &Pet{ID: 1, Name: "rex", Owner: alice}
+	tom := &Pet{ID: 2, Name: "tom", Owner: bob}
+	alice.Pets, bob.Pets = []*Pet{rex}, []*Pet{tom, rex}
+
+	return []*Person{alice, bob}
+}
+
+func TestMarshalCycles_tree(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testCategoryTree()); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 3, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included categories, got %d", e, a)
+	}
+	for _, n := range resp.Included {
+		if n.ID == "1" {
+			t.Fatal("Was expecting the root category to not be included")
+		}
+		if n.Attributes["name"] == nil {
+			t.Fatalf("Was expecting included category %s to be a full record", n.ID)
+		}
+
+		parent := n.Relationships["parent"].(map[string]interface{})["data"].(map[string]interface{})
+		if parent["type"] != "categories" || parent["id"] == "" {
+			t.Fatalf("Was expecting a parent identifier, got %v", parent)
+		}
+	}
+}
+
+func TestMarshalCycles_selfReference(t *testing.T) {
+	c := &Category{ID: 1, Name: "ouroboros"}
+	c.Parent = c
+	c.Children = []*Category{c}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, c); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Included) != 0 {
+		t.Fatalf("Was expecting no included records, got %d", len(resp.Included))
+	}
+	parent := resp.Data.Relationships["parent"].(map[string]interface{})["data"].(map[string]interface{})
+	if e, a := "1", parent["id"]; e != a {
+		t.Fatalf("Was expecting parent id %q, got %q", e, a)
+	}
+}
+
+func TestMarshalCycles_graph(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalManyPayload(out, testPersonGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(ManyPayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 2, len(resp.Data); e != a {
+		t.Fatalf("Was expecting %d people, got %d", e, a)
+	}
+
+	pets := 0
+	for _, n := range resp.Included {
+		if n.Attributes["name"] == nil {
+			t.Fatalf("Was expecting included %s %s to be a full record", n.Type, n.ID)
+		}
+		if n.Type == "pets" {
+			pets++
+		}
+	}
+	if e, a := 2, pets; e != a {
+		t.Fatalf("Was expecting %d included pets, got %d", e, a)
+	}
+}
+
+func TestMarshalManyExcludesPrimaryFromIncluded(t *testing.T) {
+	payload, err := MarshalMany([]interface{}{testPersonGraph()[0], testPersonGraph()[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// alice and bob are each other's best friend, and the primary data
+	for _, n := range payload.Included {
+		if n.Type == "people" {
+			t.Fatalf("Was expecting no primary resource in included, got person %s", n.ID)
+		}
+	}
+	if e, a := 2, len(payload.Included); e != a {
+		t.Fatalf("Was expecting %d included pets, got %d", e, a)
+	}
+}
+
+func TestMarshalCycles_embedded(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayloadEmbedded(out, testCategoryTree()); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	children := resp.Data.Relationships["children"].(map[string]interface{})["data"].([]interface{})
+	left := children[0].(map[string]interface{})
+	if e, a := "left", left["attributes"].(map[string]interface{})["name"]; e != a {
+		t.Fatalf("Was expecting the embedded child name %q, got %q", e, a)
+	}
+
+	parent := left["relationships"].(map[string]interface{})["parent"].(map[string]interface{})["data"].(map[string]interface{})
+	if _, exists := parent["attributes"]; exists {
+		t.Fatal("Was expecting the cyclic parent to be a resource identifier")
+	}
+}
+
+func TestMarshalLinksAnnotation(t *testing.T) {
+	article := &Article{
+		ID:    1,
+		Title: "JSON API paints my bikeshed!",
+		Links: &Links{"self": "http://example.com/articles/1"},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, article); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Data.Links == nil {
+		t.Fatal("Was expecting data.links to be set")
+	}
+	if e, a := "http://example.com/articles/1", resp.Data.Links.Href("self"); e != a {
+		t.Fatalf("Was expecting data.links.self to be %q, got %q", e, a)
+	}
+	if _, exists := resp.Data.Attributes["links"]; exists {
+		t.Fatal("Was expecting the links field to not be an attribute")
+	}
+
+	article.Links = &Links{"self": 1}
+	if err := MarshalOnePayload(bytes.NewBuffer(nil), article); err == nil {
+		t.Fatal("Was expecting an error for invalid links")
+	}
+}
+
+func TestMarshalMetaAnnotation(t *testing.T) {
+	doc := &Document{
+		ID:          1,
+		Title:       "Minutes",
+		Permissions: &ArticlePermissions{CanEdit: true},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Data.Meta == nil {
+		t.Fatal("Was expecting data.meta to be set")
+	}
+	meta := *resp.Data.Meta
+	if e, a := true, meta["can_edit"]; e != a {
+		t.Fatalf("Was expecting meta.can_edit to be %v, got %v", e, a)
+	}
+
+	// A nil meta field is omitted
+	out.Reset()
+	if err := MarshalOnePayload(out, &Score{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	resp = new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Meta != nil {
+		t.Fatalf("Was expecting data.meta to be omitted, got %v", *resp.Data.Meta)
+	}
+}
+
+func TestMarshalMetaMembers(t *testing.T) {
+	topic := &Topic{
+		ID:           1,
+		Title:        "Release notes",
+		Comments:     []*Comment{{ID: 2}},
+		Views:        40,
+		CommentCount: 12,
+	}
+
+	payload, err := MarshalOne(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := (&Meta{"views": 40}), payload.Data.Meta; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the meta %v, got %v", e, a)
+	}
+	comments := payload.Data.Relationships["comments"].(*RelationshipManyNode)
+	if e, a := (&Meta{"count": 12}), comments.Meta; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the relationship meta %v, got %v", e, a)
+	}
+
+	topic.Pinned = true
+	if payload, err = MarshalOne(topic); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := true, (*payload.Data.Meta)["pinned"]; e != a {
+		t.Fatalf("Was expecting meta.pinned to be %v, got %v", e, a)
+	}
+}
+
+func TestMarshalRelationshipIDs(t *testing.T) {
+	type ReviewIDs struct {
+		ID         int    `jsonapi:"primary,reviews"`
+		AuthorID   string `jsonapi:"relation,author,ids:people"`
+		EditorID   string `jsonapi:"relation,editor,ids:people"`
+		BookID     uint64 `jsonapi:"relation,book,ids:books,omitempty"`
+		CommentIDs []int  `jsonapi:"relation,comments,ids:comments"`
+	}
+
+	review := &ReviewIDs{ID: 1, AuthorID: "abc", CommentIDs: []int{1, 2}}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, review); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	relationships := resp.Data.Relationships
+	author := relationships["author"].(map[string]interface{})["data"].(map[string]interface{})
+	if author["type"] != "people" || author["id"] != "abc" {
+		t.Fatalf("Was expecting the author linkage, got %v", author)
+	}
+	if editor := relationships["editor"].(map[string]interface{})["data"]; editor != nil {
+		t.Fatalf("Was expecting a null editor linkage, got %v", editor)
+	}
+	if _, exists := relationships["book"]; exists {
+		t.Fatal("Was expecting the empty book relationship to be omitted")
+	}
+	comments := relationships["comments"].(map[string]interface{})["data"].([]interface{})
+	if e, a := 2, len(comments); e != a {
+		t.Fatalf("Was expecting %d comments, got %d", e, a)
+	}
+	if e, a := "2", comments[1].(map[string]interface{})["id"]; e != a {
+		t.Fatalf("Was expecting comment id %q, got %q", e, a)
+	}
+	if len(resp.Included) != 0 {
+		t.Fatalf("Was expecting nothing to be included, got %d", len(resp.Included))
+	}
+
+	// ids without a type can't be marshaled
+	if err := MarshalOnePayload(bytes.NewBuffer(nil), &Review{ID: 1}); err != ErrBadJSONAPIStructTag {
+		t.Fatalf("Was expecting a `%s` error, got `%v`", ErrBadJSONAPIStructTag, err)
+	}
+}
+
+func TestMarshalWithInclude(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), WithInclude("current_post.comments")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// current_post 1 and its comments 1 and 2; posts are not included
+	included := map[string]bool{}
+	for _, n := range resp.Included {
+		included[n.Type+","+n.ID] = true
+	}
+	for _, k := range []string{"posts,1", "comments,1", "comments,2"} {
+		if !included[k] {
+			t.Fatalf("Was expecting %s to be included", k)
+		}
+	}
+	if e, a := 3, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included records, got %d", e, a)
+	}
+
+	posts := resp.Data.Relationships["posts"].(map[string]interface{})["data"].([]interface{})
+	if e, a := 2, len(posts); e != a {
+		t.Fatalf("Was expecting %d posts in the linkage, got %d", e, a)
+	}
+
+	out.Reset()
+	if err := MarshalOnePayload(out, testBlog(), WithInclude()); err != nil {
+		t.Fatal(err)
+	}
+	resp = new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Included) != 0 {
+		t.Fatalf("Was expecting nothing to be included, got %d", len(resp.Included))
+	}
+}
+
+func TestMarshalWithIncludes(t *testing.T) {
+	for _, test := range []struct {
+		models   interface{}
+		paths    []string
+		expected []string
+	}{
+		{testBlog(), []string{"posts.latest_comment"}, []string{"posts,1", "posts,2", "comments,1"}},
+		{testBlog(), nil, nil},
+		{[]*Blog{testBlog()}, []string{"current_post"}, []string{"posts,1"}},
+	} {
+		out := bytes.NewBuffer(nil)
+		if err := MarshalWithIncludes(out, test.models, test.paths...); err != nil {
+			t.Fatal(err)
+		}
+
+		var resp struct {
+			Included []*Node `json:"included"`
+		}
+		if err := json.NewDecoder(out).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		var included []string
+		for _, n := range resp.Included {
+			included = append(included, n.Type+","+n.ID)
+		}
+		sort.Strings(included)
+		sort.Strings(test.expected)
+		if !reflect.DeepEqual(test.expected, included) {
+			t.Fatalf("%v: was expecting %v to be included, got %v", test.paths, test.expected, included)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	for _, models := range []interface{}{testBlog(), []*Blog{testBlog()}} {
+		out := bytes.NewBuffer(nil)
+		if err := Marshal(out, models, WithoutIncluded(), WithMeta(&Meta{"total": 1})); err != nil {
+			t.Fatal(err)
+		}
+
+		var resp map[string]json.RawMessage
+		if err := json.NewDecoder(out).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := resp["included"]; ok {
+			t.Fatalf("Was expecting no included records for %T", models)
+		}
+		if e, a := `{"total":1}`, string(resp["meta"]); e != a {
+			t.Fatalf("Was expecting the meta %s for %T, got %s", e, models, a)
+		}
+		if isArray := resp["data"][0] == '['; isArray != (reflect.TypeOf(models).Kind() == reflect.Slice) {
+			t.Fatalf("Was expecting the data of %T to match its kind, got %s", models, resp["data"])
+		}
+	}
+}
+
+func TestMarshalWithRelationshipLimit(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), WithRelationshipLimit(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := resp.Data.Relationships["posts"].(map[string]interface{})
+	if _, ok := posts["data"]; ok {
+		t.Fatalf("Was not expecting the posts linkage, got %v", posts["data"])
+	}
+	if _, ok := posts["links"].(map[string]interface{})["related"]; !ok {
+		t.Fatalf("Was expecting the posts related link, got %v", posts["links"])
+	}
+	meta := posts["meta"].(map[string]interface{})
+	if e, a := float64(2), meta["count"]; e != a {
+		t.Fatalf("Was expecting a count of %v, got %v", e, a)
+	}
+	if _, ok := meta["this"]; !ok {
+		t.Fatalf("Was expecting the relationship meta to be kept, got %v", meta)
+	}
+
+	// current_post and its latest_comment; its comments are over the limit too
+	included := map[string]bool{}
+	for _, n := range resp.Included {
+		included[n.Type+","+n.ID] = true
+	}
+	for _, k := range []string{"posts,1", "comments,1"} {
+		if !included[k] {
+			t.Fatalf("Was expecting %s to be included", k)
+		}
+	}
+	if e, a := 2, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included records, got %d", e, a)
+	}
+}
+
+func TestMarshalWithIncludedLimit(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), WithIncludedLimit(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// posts 1 and 2 and comments 1, 2 and 3 would be included
+	if e, a := 2, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included records, got %d", e, a)
+	}
+	if resp.Meta == nil {
+		t.Fatal("Was expecting the payload meta")
+	}
+	if e, a := true, (*resp.Meta)["included_truncated"]; e != a {
+		t.Fatalf("Was expecting included_truncated to be %v, got %v", e, a)
+	}
+	if e, a := float64(3), (*resp.Meta)["omitted"]; e != a {
+		t.Fatalf("Was expecting %v omitted records, got %v", e, a)
+	}
+
+	out.Reset()
+	if err := MarshalOnePayload(out, testBlog(), WithIncludedLimit(5)); err != nil {
+		t.Fatal(err)
+	}
+	resp = new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Meta != nil {
+		t.Fatalf("Was not expecting the payload meta, got %v", *resp.Meta)
+	}
+}
+
+func TestMarshalRelationshipLoader(t *testing.T) {
+	post := &LazyPost{ID: 1, Title: "Lazy"}
+	ctx := context.WithValue(context.Background(), ctxKey("author"), "alice")
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, post, WithInclude("comments"), WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := []string{"comments"}, post.loaded; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting only %v to be loaded, got %v", e, a)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 2, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included comments, got %d", e, a)
+	}
+	if _, exists := resp.Data.Relationships["author"]; exists {
+		t.Fatal("Was expecting the unloaded, empty author to be omitted")
+	}
+
+	post.loaded = nil
+	out.Reset()
+	if err := MarshalOnePayload(out, post, WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := []string{"comments", "author"}, post.loaded; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting %v to be loaded, got %v", e, a)
+	}
+
+	resp = new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range resp.Included {
+		if n.Type == "people" && n.Attributes["name"] != "alice" {
+			t.Fatalf("Was expecting the loaded author to be alice, got %v", n.Attributes["name"])
+		}
+	}
+}
+
+func TestMarshalWithIncludeFunc(t *testing.T) {
+	var relations []string
+	includeFunc := func(parent *Node, relation string, candidate *Node) bool {
+		relations = append(relations, parent.Type+"."+relation)
+		return !(candidate.Type == "posts" && candidate.ID == "2")
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), WithIncludeFunc(includeFunc)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	included := map[string]bool{}
+	for _, n := range resp.Included {
+		included[n.Type+","+n.ID] = true
+	}
+	if included["posts,2"] {
+		t.Fatal("Was expecting post 2 to be rejected")
+	}
+	if included["comments,3"] {
+		t.Fatal("Was expecting comment 3, only related to post 2, to not be included")
+	}
+	for _, k := range []string{"posts,1", "comments,1", "comments,2"} {
+		if !included[k] {
+			t.Fatalf("Was expecting %s to be included", k)
+		}
+	}
+
+	// the linkage to the rejected post is kept
+	posts := resp.Data.Relationships["posts"].(map[string]interface{})["data"].([]interface{})
+	if e, a := 2, len(posts); e != a {
+		t.Fatalf("Was expecting %d posts in the linkage, got %d", e, a)
+	}
+
+	sort.Strings(relations)
+	if e, a := "blogs.current_post", relations[0]; e != a {
+		t.Fatalf("Was expecting the parent and relation to be %q, got %q", e, a)
+	}
+}
+
+func TestMarshalWithAttributeTransformer(t *testing.T) {
+	transformer := func(resourceType, attrName string, value interface{}) (interface{}, bool) {
+		switch {
+		case resourceType == "comments" && attrName == "body":
+			return "[redacted]", true
+		case attrName == "view_count":
+			return nil, false
+		}
+		return value, true
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), WithAttributeTransformer(transformer)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exists := resp.Data.Attributes["view_count"]; exists {
+		t.Fatal("Was expecting view_count to be dropped")
+	}
+	if e, a := "Title 1", resp.Data.Attributes["title"]; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+
+	for _, n := range resp.Included {
+		if n.Type == "comments" && n.Attributes["body"] != "[redacted]" {
+			t.Fatalf("Was expecting included comment bodies to be redacted, got %v", n.Attributes["body"])
+		}
+		if n.Type == "posts" && n.Attributes["body"] == "[redacted]" {
+			t.Fatal("Was expecting post bodies to be left alone")
+		}
+	}
+}
+
+type rolePolicy struct{}
+
+func (rolePolicy) AttributeVisible(ctx context.Context, resourceType, name string) bool {
+	if role, _ := ctx.Value(ctxKey("role")).(string); role == "admin" {
+		return true
+	}
+	return !(resourceType == "blogs" && name == "view_count")
+}
+
+func (rolePolicy) RelationshipVisible(ctx context.Context, resourceType, name string) bool {
+	if role, _ := ctx.Value(ctxKey("role")).(string); role == "admin" {
+		return true
+	}
+	return !(resourceType == "posts" && name == "comments")
+}
+
+func TestMarshalWithFieldPolicy(t *testing.T) {
+	for _, role := range []string{"admin", "user"} {
+		ctx := context.WithValue(context.Background(), ctxKey("role"), role)
+
+		out := bytes.NewBuffer(nil)
+		if err := MarshalOnePayload(out, testBlog(), WithFieldPolicy(rolePolicy{}), WithContext(ctx)); err != nil {
+			t.Fatal(err)
+		}
+
+		resp := new(OnePayload)
+		if err := json.NewDecoder(out).Decode(resp); err != nil {
+			t.Fatal(err)
+		}
+
+		_, hasViewCount := resp.Data.Attributes["view_count"]
+		if e, a := role == "admin", hasViewCount; e != a {
+			t.Fatalf("Was expecting view_count visible to be %v for %s", e, role)
+		}
+
+		comments := 0
+		for _, n := range resp.Included {
+			if n.Type == "posts" {
+				_, hasComments := n.Relationships["comments"]
+				if e, a := role == "admin", hasComments; e != a {
+					t.Fatalf("Was expecting post comments visible to be %v for %s", e, role)
+				}
+			}
+			if n.Type == "comments" {
+				comments++
+			}
+		}
+		// only comment 1, the latest_comment of the posts, is visible to users
+		if role == "user" && comments != 1 {
+			t.Fatalf("Was expecting hidden comments to not be included, got %d", comments)
+		}
+	}
+}
+
+func TestMarshalWithScopes(t *testing.T) {
+	member := &Member{ID: 1, Name: "Ann", Email: "ann@example.com", Notes: "VIP"}
+
+	for _, tc := range []struct {
+		opts []MarshalOption
+		want []string
+	}{
+		{nil, []string{"name"}},
+		{[]MarshalOption{WithScopes("support")}, []string{"name", "notes"}},
+		{[]MarshalOption{WithScopes("admin")}, []string{"email", "name", "notes"}},
+		{[]MarshalOption{WithContext(ContextWithScopes(context.Background(), "admin"))}, []string{"email", "name", "notes"}},
+		{[]MarshalOption{WithScopes("support"), WithContext(ContextWithScopes(context.Background(), "billing"))}, []string{"name", "notes"}},
+	} {
+		payload, err := MarshalOne(member, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for name := range payload.Data.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tc.want) {
+			t.Fatalf("Was expecting the attributes %v, got %v", tc.want, names)
+		}
+	}
+}
+
+func TestMarshalWithFields(t *testing.T) {
+	fields := map[string][]string{"blogs": {"title", "posts"}, "posts": {"body"}}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayloadWithFields(out, testBlog(), fields); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := map[string]interface{}{"title": "Title 1"}, resp.Data.Attributes; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting the attributes %v, got %v", e, a)
+	}
+	if _, ok := resp.Data.Relationships["current_post"]; ok || resp.Data.Relationships["posts"] == nil {
+		t.Fatalf("Was expecting only the posts relationship, got %v", resp.Data.Relationships)
+	}
+
+	for _, n := range resp.Included {
+		if n.Type != "posts" {
+			t.Fatalf("Was expecting only the posts to be included, got %s %s", n.Type, n.ID)
+		}
+		if len(n.Attributes) != 1 || n.Attributes["body"] == nil || len(n.Relationships) != 0 {
+			t.Fatalf("Was expecting only the body of the posts, got %v and %v", n.Attributes, n.Relationships)
+		}
+	}
+}
+
+func TestMarshalWithFieldsRenamedTypes(t *testing.T) {
+	blogs := []interface{}{testBlog()}
+	payload, err := MarshalMany(blogs, WithTypePrefix("acme:"),
+		WithFields(map[string][]string{"acme:blogs": {}, "blogs": {"title"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := payload.Data[0]; len(n.Attributes) != 0 || len(n.Relationships) != 0 || len(payload.Included) != 0 {
+		t.Fatalf("Was expecting no fields, got %v and %v", n.Attributes, n.Relationships)
+	}
+}
+
+func TestMarshalBeforeMarshaler(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, &Account{ID: 1, Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "alice", resp.Data.Attributes["display_name"]; e != a {
+		t.Fatalf("Was expecting display_name %q, got %q", e, a)
+	}
+
+	if err := MarshalOnePayload(bytes.NewBuffer(nil), &Account{ID: 2}); err != errAccountEmail {
+		t.Fatalf("Was expecting `%v`, got `%v`", errAccountEmail, err)
+	}
+}
+
+type contextBlog struct {
+	ID    int    `jsonapi:"primary,blogs"`
+	Title string `jsonapi:"attr,title"`
+}
+
+func (b *contextBlog) BeforeJSONAPIMarshal(ctx context.Context) error {
+	if tenant, ok := ctx.Value(ctxKey("tenant")).(string); ok {
+		b.Title = tenant + ": " + b.Title
+	}
+	return nil
+}
+
+func TestMarshalOnePayloadContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey("tenant"), "acme")
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayloadContext(ctx, out, &contextBlog{ID: 1, Title: "News"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "acme: News", resp.Data.Attributes["title"]; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+
+	payload, err := MarshalManyContext(ctx, []interface{}{&contextBlog{ID: 2, Title: "Sport"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "acme: Sport", payload.Data[0].Attributes["title"]; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestMarshalMetaOnly(t *testing.T) {
+	subscription := &Subscription{
+		ID:        1,
+		Plan:      "gold",
+		Account:   &Account{ID: 2, Email: "alice@example.com"},
+		Cancelled: true,
+		Meta:      &Meta{"deleted": true},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp map[string]map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, member := range []string{"attributes", "relationships", "links"} {
+		if _, ok := resp["data"][member]; ok {
+			t.Fatalf("Was not expecting %s in a meta-only resource", member)
+		}
+	}
+	if _, ok := resp["included"]; ok {
+		t.Fatal("Was not expecting the account to be included")
+	}
+	if e, a := true, resp["data"]["meta"].(map[string]interface{})["deleted"]; e != a {
+		t.Fatalf("Was expecting meta deleted %v, got %v", e, a)
+	}
+
+	subscription.Cancelled = false
+	payload, err := MarshalOne(subscription)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "gold", payload.Data.Attributes["plan"]; e != a {
+		t.Fatalf("Was expecting plan %v, got %v", e, a)
+	}
+}
+
+func TestMarshalLinkageMeta(t *testing.T) {
+	team := &Team{
+		ID:          1,
+		Members:     []*Person{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}},
+		MemberMeta:  []*Meta{{"role": "lead"}, {"role": "dev"}},
+		Captain:     &Person{ID: 1, Name: "alice"},
+		CaptainMeta: &Meta{"since": 2019},
+	}
+
+	payload, err := MarshalOne(team)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := payload.Data.Relationships["members"].(*RelationshipManyNode)
+	for i, e := range []string{"lead", "dev"} {
+		if members.Data[i].Meta == nil {
+			t.Fatalf("Was expecting meta on member %d", i)
+		}
+		if a := (*members.Data[i].Meta)["role"]; e != a {
+			t.Fatalf("Was expecting role %q, got %v", e, a)
+		}
+	}
+
+	captain := payload.Data.Relationships["captain"].(*RelationshipOneNode)
+	if captain.Data.Meta == nil || (*captain.Data.Meta)["since"] != 2019 {
+		t.Fatalf("Was expecting meta on the captain, got %v", captain.Data.Meta)
+	}
+
+	for _, n := range payload.Included {
+		if n.Meta != nil {
+			t.Fatalf("Was not expecting linkage meta on included %s %s", n.Type, n.ID)
+		}
+	}
+}
+
+func TestMarshalRelatedLinksAndMeta(t *testing.T) {
+	library := &Library{
+		ID:       1,
+		Books:    []*Book{{ID: 1}, {ID: 2}},
+		Featured: &Book{ID: 2},
+	}
+
+	payload, err := MarshalOne(library)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	books := payload.Data.Relationships["books"].(*RelationshipManyNode)
+	if books.Meta == nil || (*books.Meta)["count"] != 2 {
+		t.Fatalf("Was expecting a count of 2, got %v", books.Meta)
+	}
+
+	featured := payload.Data.Relationships["featured"].(*RelationshipOneNode)
+	if e, a := "/books/2", featured.Links.Href("related"); e != a {
+		t.Fatalf("Was expecting related link %q, got %q", e, a)
+	}
+	if featured.Meta != nil {
+		t.Fatalf("Was not expecting meta for featured, got %v", featured.Meta)
+	}
+}
+
+func TestMarshalLinksWithContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey("host"), "acme.example.com")
+
+	payload, err := MarshalOneContext(ctx, &Tenant{ID: 1, Owner: &Person{ID: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "https://acme.example.com/tenants/1", payload.Data.Links.Href("self"); e != a {
+		t.Fatalf("Was expecting self link %q, got %q", e, a)
+	}
+	owner := payload.Data.Relationships["owner"].(*RelationshipOneNode)
+	if e, a := "https://acme.example.com/tenants/1/owner", owner.Links.Href("related"); e != a {
+		t.Fatalf("Was expecting related link %q, got %q", e, a)
+	}
+}
+
+func TestMarshalMetaWithContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey("host"), "acme.example.com")
+
+	payload, err := MarshalOneContext(ctx, &Tenant{ID: 1, Owner: &Person{ID: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := (&Meta{"host": "acme.example.com"}), payload.Data.Meta; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting meta %v, got %v", e, a)
+	}
+	owner := payload.Data.Relationships["owner"].(*RelationshipOneNode)
+	if e, a := (&Meta{"host": "acme.example.com"}), owner.Meta; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting relationship meta %v, got %v", e, a)
+	}
+
+	payload, err = MarshalOneContext(ctx, &Workspace{ID: 1, Members: []*Person{{ID: 2}, {ID: 3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := payload.Data.Relationships["members"].(*RelationshipManyNode)
+	if e, a := (&Meta{"host": "acme.example.com", "count": 2}), members.Meta; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting relationship meta %v, got %v", e, a)
+	}
+}
+
+func TestMarshalValueAndPointerReceivers(t *testing.T) {
+	playlists := []Playlist{{ID: 1, Tracks: []Track{{ID: 1, Title: "Intro"}, {ID: 2, Title: "Outro"}}}}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalManyPayload(out, playlists); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(ManyPayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Data[0].Meta == nil || (*resp.Data[0].Meta)["tracks"] != float64(2) {
+		t.Fatalf("Was expecting the playlist meta, got %v", resp.Data[0].Meta)
+	}
+	if e, a := 2, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included tracks, got %d", e, a)
+	}
+	for _, n := range resp.Included {
+		if e, a := "/tracks/"+n.ID, n.Links.Href("self"); e != a {
+			t.Fatalf("Was expecting self link %q, got %q", e, a)
+		}
+	}
+
+	// a single struct value
+	payload, err := MarshalOne(Track{ID: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "/tracks/3", payload.Data.Links.Href("self"); e != a {
+		t.Fatalf("Was expecting self link %q, got %q", e, a)
+	}
+}
+
+func TestMarshalAttributer(t *testing.T) {
+	product := &Product{
+		ID:         1,
+		Name:       "Chair",
+		Properties: map[string]interface{}{"color": "red", "legs": 4},
+	}
+
+	payload, err := MarshalOne(product)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"name": "Chair", "color": "red", "legs": 4}
+	if !reflect.DeepEqual(expected, payload.Data.Attributes) {
+		t.Fatalf("Was expecting attributes %v, got %v", expected, payload.Data.Attributes)
+	}
+}
+
+func TestMarshalNodeMarshaler(t *testing.T) {
+	trip := &Trip{ID: 1, Destination: &Coordinate{ID: "home", Lat: 51.5, Lng: -0.12}}
+
+	payload, err := MarshalOne(trip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destination := payload.Data.Relationships["destination"].(*RelationshipOneNode)
+	if e, a := "coordinates", destination.Data.Type; e != a {
+		t.Fatalf("Was expecting type %q, got %q", e, a)
+	}
+	if e, a := "home", destination.Data.ID; e != a {
+		t.Fatalf("Was expecting id %q, got %q", e, a)
+	}
+
+	if e, a := 1, len(payload.Included); e != a {
+		t.Fatalf("Was expecting %d included, got %d", e, a)
+	}
+	if e, a := "51.5,-0.12", payload.Included[0].Attributes["position"]; e != a {
+		t.Fatalf("Was expecting position %q, got %v", e, a)
+	}
+}
+
+func TestMarshalDefaultIncludes(t *testing.T) {
+	album := &Album{ID: 1, Artist: &Person{ID: 1}, Tracks: []*Track{{ID: 1}, {ID: 2}}}
+
+	includedTypes := func(payload *OnePayload) map[string]int {
+		types := map[string]int{}
+		for _, n := range payload.Included {
+			types[n.Type]++
+		}
+		return types
+	}
+
+	payload, err := MarshalOne(album)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := map[string]int{"people": 1}, includedTypes(payload); !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting included %v, got %v", e, a)
+	}
+
+	payload, err = MarshalOne(album, WithInclude("tracks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := map[string]int{"tracks": 2}, includedTypes(payload); !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting included %v, got %v", e, a)
+	}
+}
+
+func TestMarshalCreatePayload(t *testing.T) {
+	draft := &Draft{
+		LocalID:   "draft-1",
+		ClientID:  "abc",
+		Title:     "Hello",
+		CreatedAt: time.Now(),
+		Author:    &Person{ID: 1, Name: "alice"},
+		Tags:      []*Draft{{LocalID: "tag-1", Title: "news"}},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalCreatePayload(out, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp map[string]map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	data := resp["data"]
+
+	if _, ok := data["id"]; ok {
+		t.Fatalf("Was not expecting an id, got %v", data["id"])
+	}
+	if e, a := "draft-1", data["lid"]; e != a {
+		t.Fatalf("Was expecting lid %q, got %v", e, a)
+	}
+	if e, a := "abc", data["client-id"]; e != a {
+		t.Fatalf("Was expecting client-id %q, got %v", e, a)
+	}
+	attributes := data["attributes"].(map[string]interface{})
+	if _, ok := attributes["created_at"]; ok {
+		t.Fatal("Was not expecting the readonly created_at attribute")
+	}
+	if _, ok := resp["included"]; ok {
+		t.Fatal("Was not expecting included records")
+	}
+
+	relationships := data["relationships"].(map[string]interface{})
+	author := relationships["author"].(map[string]interface{})["data"].(map[string]interface{})
+	if _, ok := author["attributes"]; ok {
+		t.Fatalf("Was expecting the author as a resource identifier, got %v", author)
+	}
+	tag := relationships["tags"].(map[string]interface{})["data"].([]interface{})[0].(map[string]interface{})
+	if e, a := "tag-1", tag["lid"]; e != a {
+		t.Fatalf("Was expecting the tag lid %q, got %v", e, a)
+	}
+	if _, ok := tag["id"]; ok {
+		t.Fatalf("Was not expecting an id for the new tag, got %v", tag["id"])
+	}
+
+	// readonly attributes are rendered in other payloads
+	payload, err := MarshalOne(draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload.Data.Attributes["created_at"]; !ok {
+		t.Fatal("Was expecting the created_at attribute")
+	}
+}
+
+func TestMarshalKeepsEmptyID(t *testing.T) {
+	// only create payloads leave out an empty id
+	out := bytes.NewBuffer(nil)
+	id := ""
+	if err := MarshalOnePayload(out, &Car{ID: &id}); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp map[string]map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "", resp["data"]["id"]; e != a {
+		t.Fatalf("Was expecting id %q, got %v", e, a)
+	}
+}
+
+func TestMarshalPointerIndirection(t *testing.T) {
+	id := 7
+	idPtr := &id
+	comment := &Comment{ID: 1, Body: "foo"}
+	comments := []*Comment{comment, {ID: 2, Body: "bar"}}
+
+	thread := &Thread{ID: &idPtr, Title: "Thread", Comments: &comments, Pinned: &comment}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, thread); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "7", resp.Data.ID; e != a {
+		t.Fatalf("Was expecting id %q, got %q", e, a)
+	}
+	linkage := resp.Data.Relationships["comments"].(map[string]interface{})["data"].([]interface{})
+	if e, a := 2, len(linkage); e != a {
+		t.Fatalf("Was expecting %d comments, got %d", e, a)
+	}
+	pinned := resp.Data.Relationships["pinned"].(map[string]interface{})["data"].(map[string]interface{})
+	if e, a := "1", pinned["id"]; e != a {
+		t.Fatalf("Was expecting the pinned comment %q, got %v", e, a)
+	}
+	if e, a := 2, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included records, got %d", e, a)
+	}
+
+	// nil pointers render as empty relationships
+	out.Reset()
+	if err := MarshalOnePayload(out, &Thread{ID: &idPtr}); err != nil {
+		t.Fatal(err)
+	}
+	resp = new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 0, len(resp.Data.Relationships["comments"].(map[string]interface{})["data"].([]interface{})); e != a {
+		t.Fatalf("Was expecting %d comments, got %d", e, a)
+	}
+	if a := resp.Data.Relationships["pinned"].(map[string]interface{})["data"]; a != nil {
+		t.Fatalf("Was expecting no pinned comment, got %v", a)
+	}
+}
+
+func TestMarshalMapRelationship(t *testing.T) {
+	roster := &Roster{
+		ID: 1,
+		Players: map[string]*Person{
+			"striker": {ID: 9, Name: "Nine"},
+			"keeper":  {ID: 1, Name: "One"},
+		},
+		Coaches: map[string]*Person{"head": {ID: 20, Name: "Twenty"}},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, roster); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	players := resp.Data.Relationships["players"].(map[string]interface{})["data"].([]interface{})
+	if e, a := 2, len(players); e != a {
+		t.Fatalf("Was expecting %d players, got %d", e, a)
+	}
+	// rendered in key order, with the key as meta
+	keeper := players[0].(map[string]interface{})
+	if e, a := "1", keeper["id"]; e != a {
+		t.Fatalf("Was expecting the keeper %q first, got %v", e, a)
+	}
+	if e, a := "keeper", keeper["meta"].(map[string]interface{})["position"]; e != a {
+		t.Fatalf("Was expecting position %q, got %v", e, a)
+	}
+
+	coaches := resp.Data.Relationships["coaches"].(map[string]interface{})["data"].([]interface{})
+	if _, ok := coaches[0].(map[string]interface{})["meta"]; ok {
+		t.Fatalf("Was not expecting meta without the mapkey option, got %v", coaches[0])
+	}
+
+	if e, a := 3, len(resp.Included); e != a {
+		t.Fatalf("Was expecting %d included records, got %d", e, a)
+	}
+	for _, n := range resp.Included {
+		if n.Meta != nil {
+			t.Fatalf("Was not expecting meta on the included records, got %v", *n.Meta)
+		}
+	}
+}
+
+func TestMarshalWithSortedRelationships(t *testing.T) {
+	linkageIDs := func(resp *OnePayload, relation string) []string {
+		ids := []string{}
+		data := resp.Data.Relationships[relation].(map[string]interface{})["data"].([]interface{})
+		for _, d := range data {
+			ids = append(ids, d.(map[string]interface{})["id"].(string))
+		}
+		return ids
+	}
+
+	blog := testBlog()
+	blog.Posts[0], blog.Posts[1] = blog.Posts[1], blog.Posts[0]
+	posts := blog.Posts
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, blog, WithSortedRelationships()); err != nil {
+		t.Fatal(err)
+	}
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := []string{"1", "2"}, linkageIDs(resp, "posts"); !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting posts %v, got %v", e, a)
+	}
+	if posts[0].ID != 2 {
+		t.Fatal("Was not expecting the posts of the model to be sorted")
+	}
+
+	// included by type and id
+	included := []string{}
+	for _, n := range resp.Included {
+		included = append(included, n.Type+","+n.ID)
+	}
+	if e, a := []string{"comments,1", "comments,2", "comments,3", "posts,1", "posts,2"}, included; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting included %v, got %v", e, a)
+	}
+
+	board := &Leaderboard{ID: 1, Players: []*Person{
+		{ID: 1, Name: "Carol"}, {ID: 2, Name: "Alice"}, {ID: 10, Name: "Bob"},
+	}}
+	out.Reset()
+	if err := MarshalOnePayload(out, board, WithSortedRelationships()); err != nil {
+		t.Fatal(err)
+	}
+	resp = new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := []string{"2", "10", "1"}, linkageIDs(resp, "players"); !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting players %v, got %v", e, a)
+	}
+}
+
+func TestMarshalOmitZero(t *testing.T) {
+	booking := &Booking{
+		ID:     1,
+		Period: Period{End: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Tags:   []string{},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, booking); err != nil {
+		t.Fatal(err)
+	}
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// the period has no start, the tags are empty but not nil
+	if _, ok := resp.Data.Attributes["period"]; ok {
+		t.Fatal("Was not expecting the zero period")
+	}
+	if _, ok := resp.Data.Attributes["tags"]; !ok {
+		t.Fatal("Was expecting the empty tags")
+	}
+	for _, name := range []string{"guests", "location"} {
+		if _, ok := resp.Data.Attributes[name]; ok {
+			t.Fatalf("Was not expecting the zero %s", name)
+		}
+	}
+
+	booking.Period.Start = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	out.Reset()
+	if err := MarshalOnePayload(out, booking); err != nil {
+		t.Fatal(err)
+	}
+	resp = new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resp.Data.Attributes["period"]; !ok {
+		t.Fatal("Was expecting the period")
+	}
+}
+
+func TestMarshalOmitEmptyCollections(t *testing.T) {
+	for _, booking := range []*Booking{
+		{ID: 1},
+		{ID: 1, Notes: []string{}, Labels: map[string]string{}},
+	} {
+		out := bytes.NewBuffer(nil)
+		if err := MarshalOnePayload(out, booking); err != nil {
+			t.Fatal(err)
+		}
+		resp := new(OnePayload)
+		if err := json.NewDecoder(out).Decode(resp); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{"notes", "labels"} {
+			if _, ok := resp.Data.Attributes[name]; ok {
+				t.Fatalf("Was not expecting the empty %s", name)
+			}
+		}
+	}
+
+	booking := &Booking{ID: 1, Notes: []string{"late"}, Labels: map[string]string{"vip": "yes"}}
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, booking); err != nil {
+		t.Fatal(err)
+	}
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"notes", "labels"} {
+		if _, ok := resp.Data.Attributes[name]; !ok {
+			t.Fatalf("Was expecting the %s", name)
+		}
+	}
+}
+
+func TestMarshalOmitEmptyEmptier(t *testing.T) {
+	for _, tc := range []struct {
+		quote    *Quote
+		expected []string
+	}{
+		{&Quote{ID: 1}, []string{}},
+		{&Quote{ID: 1, Price: Money{Currency: "USD"}}, []string{"price"}},
+		{&Quote{ID: 1, Discount: &Money{}}, []string{}},
+		{&Quote{ID: 1, Discount: &Money{Amount: 0, Currency: "USD"}}, []string{"discount"}},
+	} {
+		out := bytes.NewBuffer(nil)
+		if err := MarshalOnePayload(out, tc.quote); err != nil {
+			t.Fatal(err)
+		}
+		resp := new(OnePayload)
+		if err := json.NewDecoder(out).Decode(resp); err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		for name := range resp.Data.Attributes {
+			names = append(names, name)
+		}
+		if !reflect.DeepEqual(tc.expected, names) {
+			t.Fatalf("Was expecting attributes %v, got %v", tc.expected, names)
+		}
+	}
+}
+
+func TestMarshalWithPayloadHook(t *testing.T) {
+	hook := WithPayloadHook(func(payload interface{}) error {
+		switch p := payload.(type) {
+		case *OnePayload:
+			p.Meta = &Meta{"hooked": "one"}
+			p.Data.Attributes["title"] = "Rewritten"
+		case *ManyPayload:
+			p.Meta = &Meta{"hooked": "many"}
+		}
+		return nil
+	})
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), hook); err != nil {
+		t.Fatal(err)
+	}
+	one := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(one); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "one", (*one.Meta)["hooked"]; e != a {
+		t.Fatalf("Was expecting meta %q, got %v", e, a)
+	}
+	if e, a := "Rewritten", one.Data.Attributes["title"]; e != a {
+		t.Fatalf("Was expecting title %q, got %v", e, a)
+	}
+
+	out.Reset()
+	if err := MarshalManyPayload(out, []*Blog{testBlog()}, hook); err != nil {
+		t.Fatal(err)
+	}
+	many := new(ManyPayload)
+	if err := json.NewDecoder(out).Decode(many); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "many", (*many.Meta)["hooked"]; e != a {
+		t.Fatalf("Was expecting meta %q, got %v", e, a)
+	}
+
+	errHook := errors.New("hook failed")
+	err := MarshalOnePayload(out, testBlog(), WithPayloadHook(func(interface{}) error { return errHook }))
+	if err != errHook {
+		t.Fatalf("Was expecting `%v`, got `%v`", errHook, err)
+	}
+}
+
+func TestMarshalOneWith(t *testing.T) {
+	links := &Links{"self": "https://example.com/blogs/5"}
+	meta := &Meta{"request_id": "abc"}
+
+	payload, err := MarshalOneWith(&Blog{ID: 5, Title: "Title 1"}, links, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "https://example.com/blogs/5", payload.Links.Href("self"); e != a {
+		t.Fatalf("Was expecting self link %q, got %q", e, a)
+	}
+	if e, a := "abc", (*payload.Meta)["request_id"]; e != a {
+		t.Fatalf("Was expecting request_id %q, got %v", e, a)
+	}
+
+	if _, err := MarshalOneWith(&Blog{ID: 5}, &Links{"self": 5}, nil); err == nil {
+		t.Fatal("Was expecting an error for an invalid link")
+	}
+}
+
+func TestMarshalManyWith(t *testing.T) {
+	links := &Links{KeyNextPage: "https://example.com/blogs?page[number]=2"}
+	meta := &Meta{"elapsed_ms": 12}
+	query := &Query{Sort: []string{"title"}}
+
+	payload, err := MarshalManyWith([]interface{}{&Blog{ID: 5}, &Blog{ID: 6}}, links, meta, WithQueryMeta(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "https://example.com/blogs?page[number]=2", payload.Links.Href(KeyNextPage); e != a {
+		t.Fatalf("Was expecting next link %q, got %q", e, a)
+	}
+	if e, a := 12, (*payload.Meta)["elapsed_ms"]; e != a {
+		t.Fatalf("Was expecting elapsed_ms %d, got %v", e, a)
+	}
+	if _, ok := (*payload.Meta)["query"]; !ok {
+		t.Fatal("Was expecting the query meta to be merged into the meta")
+	}
+	if _, ok := (*meta)["query"]; ok {
+		t.Fatal("Was expecting the meta given to be left untouched")
+	}
+}
+
+func TestMarshalRelationWithoutPrimary(t *testing.T) {
+	// the relation is checked even when it is empty
+	_, err := MarshalOne(&Invoice{ID: 1})
+
+	missingErr, ok := err.(*MissingPrimaryError)
+	if !ok {
+		t.Fatalf("Was expecting a *MissingPrimaryError, got %v", err)
+	}
+	if e, a := "Invoice.Lines", missingErr.Field; e != a {
+		t.Fatalf("Was expecting field %q, got %q", e, a)
+	}
+	if e, a := reflect.TypeOf(LineItem{}), missingErr.Type; e != a {
+		t.Fatalf("Was expecting type %v, got %v", e, a)
+	}
+}
+
+func TestMarshalRelationNoLinkage(t *testing.T) {
+	forum := &Forum{ID: 1, Name: "General", ThreadCount: 12,
+		Threads: []*Post{{ID: 1, Title: "Hello"}}}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, forum); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// the relationship without meta nor links is left out
+	expected := map[string]interface{}{
+		"threads": map[string]interface{}{"meta": map[string]interface{}{"count": float64(12)}},
+	}
+	data := doc["data"].(map[string]interface{})
+	if !reflect.DeepEqual(expected, data["relationships"]) {
+		t.Fatalf("Was expecting the relationships %v, got %v", expected, data["relationships"])
+	}
+	if _, ok := doc["included"]; ok {
+		t.Fatalf("Was expecting no included records, got %v", doc["included"])
+	}
+}
@@ -2,6 +2,7 @@ package jsonapi
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
@@ -19,13 +20,39 @@ const (
 
 type Runtime struct {
 	ctx map[string]interface{}
+
+	instrumenter Instrumenter
 }
 
 type Events func(*Runtime, Event, string, time.Duration)
 
 var Instrumentation Events
 
-func NewRuntime() *Runtime { return &Runtime{make(map[string]interface{})} }
+// EventStats describes the payload handled by an instrumented call. It is
+// empty for the start events.
+type EventStats struct {
+	// PrimaryCount is the number of resources in "data".
+	PrimaryCount int
+
+	// IncludedCount is the number of resources in "included".
+	IncludedCount int
+}
+
+// Instrumenter receives the events of a Runtime along with the size of the
+// payload, e.g. to feed histograms of the serialization cost:
+//
+//	func (m *metrics) InstrumentJSONAPI(r *jsonapi.Runtime, e jsonapi.Event, guid string,
+//		dur time.Duration, stats jsonapi.EventStats) {
+//		if e == jsonapi.MarshalStop {
+//			m.marshalSeconds.Observe(dur.Seconds())
+//			m.marshalNodes.Observe(float64(stats.PrimaryCount + stats.IncludedCount))
+//		}
+//	}
+type Instrumenter interface {
+	InstrumentJSONAPI(r *Runtime, e Event, guid string, dur time.Duration, stats EventStats)
+}
+
+func NewRuntime() *Runtime { return &Runtime{ctx: make(map[string]interface{})} }
 
 func (r *Runtime) WithValue(key string, value interface{}) *Runtime {
 	r.ctx[key] = value
@@ -41,46 +68,93 @@ func (r *Runtime) Instrument(key string) *Runtime {
 	return r.WithValue("instrument", key)
 }
 
+// WithInstrumenter sends the events of r to i, in addition to the package
+// level Instrumentation func.
+func (r *Runtime) WithInstrumenter(i Instrumenter) *Runtime {
+	r.instrumenter = i
+
+	return r
+}
+
 func (r *Runtime) shouldInstrument() bool {
-	return Instrumentation != nil
+	return Instrumentation != nil || r.instrumenter != nil
 }
 
-func (r *Runtime) UnmarshalPayload(reader io.Reader, model interface{}) error {
-	return r.instrumentCall(UnmarshalStart, UnmarshalStop, func() error {
-		return UnmarshalPayload(reader, model)
+func (r *Runtime) UnmarshalPayload(reader io.Reader, model interface{}, opts ...UnmarshalOption) error {
+	return r.instrumentCall(UnmarshalStart, UnmarshalStop, func() (EventStats, error) {
+		payload, err := unmarshalOne(reader, model, newUnmarshalConfig(opts))
+		if err != nil {
+			return EventStats{}, err
+		}
+		return EventStats{PrimaryCount: 1, IncludedCount: len(payload.Included)}, nil
 	})
 }
 
-func (r *Runtime) UnmarshalManyPayload(reader io.Reader, kind reflect.Type) (elems []interface{}, err error) {
-	r.instrumentCall(UnmarshalStart, UnmarshalStop, func() error {
-		elems, err = UnmarshalManyPayload(reader, kind)
-		return err
+func (r *Runtime) UnmarshalManyPayload(reader io.Reader, kind reflect.Type, opts ...UnmarshalOption) (elems []interface{}, err error) {
+	err = r.instrumentCall(UnmarshalStart, UnmarshalStop, func() (EventStats, error) {
+		var payload *ManyPayload
+		elems, payload, err = unmarshalMany(reader, kind, newUnmarshalConfig(opts))
+		if err != nil {
+			return EventStats{}, err
+		}
+		return EventStats{PrimaryCount: len(payload.Data), IncludedCount: len(payload.Included)}, nil
 	})
 
 	return
 }
 
-func (r *Runtime) MarshalOnePayload(w io.Writer, model interface{}) error {
-	return r.instrumentCall(MarshalStart, MarshalStop, func() error {
-		return MarshalOnePayload(w, model)
+func (r *Runtime) MarshalOnePayload(w io.Writer, model interface{}, opts ...MarshalOption) error {
+	return r.instrumentCall(MarshalStart, MarshalStop, func() (EventStats, error) {
+		payload, err := MarshalOne(model, opts...)
+		if err != nil {
+			return EventStats{}, err
+		}
+		if err := json.NewEncoder(w).Encode(payload); err != nil {
+			return EventStats{}, err
+		}
+		return EventStats{PrimaryCount: 1, IncludedCount: len(payload.Included)}, nil
 	})
 }
 
-func (r *Runtime) MarshalManyPayload(w io.Writer, models interface{}) error {
-	return r.instrumentCall(MarshalStart, MarshalStop, func() error {
-		return MarshalManyPayload(w, models)
+func (r *Runtime) MarshalManyPayload(w io.Writer, models interface{}, opts ...MarshalOption) error {
+	return r.instrumentCall(MarshalStart, MarshalStop, func() (EventStats, error) {
+		m, err := convertToSliceInterface(&models)
+		if err != nil {
+			return EventStats{}, err
+		}
+		payload, err := MarshalMany(m, opts...)
+		if err != nil {
+			return EventStats{}, err
+		}
+		if err := json.NewEncoder(w).Encode(payload); err != nil {
+			return EventStats{}, err
+		}
+		return EventStats{PrimaryCount: len(payload.Data), IncludedCount: len(payload.Included)}, nil
 	})
 }
 
-func (r *Runtime) MarshalOnePayloadEmbedded(w io.Writer, model interface{}) error {
-	return r.instrumentCall(MarshalStart, MarshalStop, func() error {
-		return MarshalOnePayloadEmbedded(w, model)
+// MarshalPayload does the same as MarshalManyPayload for a slice of models,
+// and as MarshalOnePayload otherwise.
+func (r *Runtime) MarshalPayload(w io.Writer, models interface{}, opts ...MarshalOption) error {
+	if reflect.ValueOf(models).Kind() == reflect.Slice {
+		return r.MarshalManyPayload(w, models, opts...)
+	}
+	return r.MarshalOnePayload(w, models, opts...)
+}
+
+func (r *Runtime) MarshalOnePayloadEmbedded(w io.Writer, model interface{}, opts ...MarshalOption) error {
+	return r.instrumentCall(MarshalStart, MarshalStop, func() (EventStats, error) {
+		if err := MarshalOnePayloadEmbedded(w, model, opts...); err != nil {
+			return EventStats{}, err
+		}
+		return EventStats{PrimaryCount: 1}, nil
 	})
 }
 
-func (r *Runtime) instrumentCall(start Event, stop Event, c func() error) error {
+func (r *Runtime) instrumentCall(start Event, stop Event, c func() (EventStats, error)) error {
 	if !r.shouldInstrument() {
-		return c()
+		_, err := c()
+		return err
 	}
 
 	instrumentationGUID, err := newUUID()
@@ -89,18 +163,29 @@ func (r *Runtime) instrumentCall(start Event, stop Event, c func() error) error
 	}
 
 	begin := time.Now()
-	Instrumentation(r, start, instrumentationGUID, time.Duration(0))
+	r.emit(start, instrumentationGUID, time.Duration(0), EventStats{})
 
-	if err := c(); err != nil {
+	stats, err := c()
+	if err != nil {
 		return err
 	}
 
 	diff := time.Duration(time.Now().UnixNano() - begin.UnixNano())
-	Instrumentation(r, stop, instrumentationGUID, diff)
+	r.emit(stop, instrumentationGUID, diff, stats)
 
 	return nil
 }
 
+// emit sends an event to the Instrumentation func and the Instrumenter of r.
+func (r *Runtime) emit(e Event, guid string, dur time.Duration, stats EventStats) {
+	if Instrumentation != nil {
+		Instrumentation(r, e, guid, dur)
+	}
+	if r.instrumenter != nil {
+		r.instrumenter.InstrumentJSONAPI(r, e, guid, dur, stats)
+	}
+}
+
 // citation: http://play.golang.org/p/4FkNSiUDMg
 func newUUID() (string, error) {
 	uuid := make([]byte, 16)
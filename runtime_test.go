@@ -0,0 +1,73 @@
+package jsonapi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type recordedEvent struct {
+	event Event
+	stats EventStats
+}
+
+type recordingInstrumenter struct {
+	events []recordedEvent
+}
+
+func (i *recordingInstrumenter) InstrumentJSONAPI(r *Runtime, e Event, guid string,
+	dur time.Duration, stats EventStats) {
+	i.events = append(i.events, recordedEvent{event: e, stats: stats})
+}
+
+func TestRuntimeInstrumenter(t *testing.T) {
+	instrumenter := new(recordingInstrumenter)
+	runtime := NewRuntime().WithInstrumenter(instrumenter)
+
+	out := bytes.NewBuffer(nil)
+	if err := runtime.MarshalManyPayload(out, []*Blog{testBlog(), testBlog()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runtime.UnmarshalManyPayload(out, reflect.TypeOf(new(Blog))); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Event{MarshalStart, MarshalStop, UnmarshalStart, UnmarshalStop}
+	if len(instrumenter.events) != len(expected) {
+		t.Fatalf("Was expecting %d events, got %d", len(expected), len(instrumenter.events))
+	}
+	for i, e := range expected {
+		if a := instrumenter.events[i].event; e != a {
+			t.Fatalf("Was expecting event %d to be %v, got %v", i, e, a)
+		}
+	}
+
+	for _, i := range []int{1, 3} {
+		stats := instrumenter.events[i].stats
+		if e, a := 2, stats.PrimaryCount; e != a {
+			t.Fatalf("Was expecting primary count %d, got %d", e, a)
+		}
+		if stats.IncludedCount == 0 {
+			t.Fatal("Was expecting a non zero included count")
+		}
+	}
+}
+
+func TestRuntimeMarshalPayload(t *testing.T) {
+	instrumenter := new(recordingInstrumenter)
+	runtime := NewRuntime().WithInstrumenter(instrumenter)
+
+	for _, models := range []interface{}{testBlog(), []*Blog{testBlog(), testBlog()}} {
+		out := bytes.NewBuffer(nil)
+		if err := runtime.MarshalPayload(out, models); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, e := range []int{1, 2} {
+		if a := instrumenter.events[2*i+1].stats.PrimaryCount; e != a {
+			t.Fatalf("Was expecting primary count %d, got %d", e, a)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package jsonapi
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sampleTime is the time of the time attributes of the samples.
+var sampleTime = time.Date(2020, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+// Sample returns a new instance of the type of model, a pointer to a struct,
+// holding sample values: an id, a value for each attribute chosen from its
+// type and name, e.g. "jane@example.com" for an "email" string, and a related
+// model, holding sample attributes too, for each relation. It is meant to
+// build example documents for documentation, mock servers and the tests of
+// clients; the values are the same on every call.
+func Sample(model interface{}) interface{} {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	s := &sampler{ids: make(map[reflect.Type]int)}
+	return s.model(t, true).Interface()
+}
+
+// MarshalSamplePayload writes the payload of the Sample of model, with its
+// related samples sideloaded, e.g.
+//
+//	jsonapi.MarshalSamplePayload(os.Stdout, new(Blog))
+func MarshalSamplePayload(w io.Writer, model interface{}, opts ...MarshalOption) error {
+	return MarshalOnePayload(w, Sample(model), opts...)
+}
+
+// sampler numbers the sample models of each type.
+type sampler struct {
+	ids map[reflect.Type]int
+}
+
+// model returns a pointer to a sample of the struct type t, with samples of
+// its related models if related is set.
+func (s *sampler) model(t reflect.Type, related bool) reflect.Value {
+	s.ids[t]++
+	id := s.ids[t]
+
+	model := reflect.New(t)
+	for _, f := range schemaOf(t).fields {
+		tag, err := ParseTag(f.field.Tag.Get(annotationJSONAPI))
+		if err != nil {
+			continue
+		}
+
+		switch tag.Annotation {
+		case annotationPrimary:
+			field := f.settable(model.Elem())
+			if v, err := parseIDValue(strconv.Itoa(id), derefType(field.Type()).Kind()); err == nil {
+				assign(field, v)
+			}
+		case annotationAttribute:
+			f.settable(model.Elem()).Set(sampleValue(f.field.Type, tag.Name))
+		case annotationRelation:
+			if related && !tag.HasOption(annotationIDs) {
+				s.relation(f.settable(model.Elem()))
+			}
+		}
+	}
+
+	return model
+}
+
+// relation sets a sample related model in the relation field.
+func (s *sampler) relation(field reflect.Value) {
+	switch t := field.Type(); {
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct:
+		field.Set(s.model(t.Elem(), false))
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Ptr &&
+		t.Elem().Elem().Kind() == reflect.Struct:
+		field.Set(reflect.Append(reflect.MakeSlice(t, 0, 1), s.model(t.Elem().Elem(), false)))
+	}
+}
+
+// sampleValue returns a sample value of type t for the attribute or member
+// name.
+func sampleValue(t reflect.Type, name string) reflect.Value {
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(sampleValue(t.Elem(), name))
+	case reflect.String:
+		v.SetString(sampleString(name))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(42)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(42)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(4.2)
+	case reflect.Slice:
+		v.Set(reflect.Append(reflect.MakeSlice(t, 0, 1), sampleValue(t.Elem(), name)))
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String {
+			v.Set(reflect.MakeMap(t))
+			v.SetMapIndex(reflect.ValueOf("key").Convert(t.Key()), sampleValue(t.Elem(), name))
+		}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(sampleTime))
+			break
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				v.Field(i).Set(sampleValue(f.Type, strings.Split(f.Tag.Get("json"), ",")[0]))
+			}
+		}
+	}
+
+	return v
+}
+
+// sampleString returns a sample string for the attribute name, e.g. an email
+// address for "email".
+func sampleString(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "email"):
+		return "jane@example.com"
+	case strings.Contains(name, "url") || strings.Contains(name, "link"):
+		return "https://example.com"
+	case strings.Contains(name, "name"):
+		return "Jane Doe"
+	case name == "":
+		return "Lorem ipsum"
+	default:
+		return "Lorem ipsum " + name
+	}
+}
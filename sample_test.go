@@ -0,0 +1,100 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	blog, ok := Sample(new(Blog)).(*Blog)
+	if !ok {
+		t.Fatalf("Was expecting a *Blog, got %T", Sample(new(Blog)))
+	}
+
+	if e, a := 1, blog.ID; e != a {
+		t.Fatalf("Was expecting id %d, got %d", e, a)
+	}
+	if e, a := "Lorem ipsum title", blog.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+	if e, a := 42, blog.ViewCount; e != a {
+		t.Fatalf("Was expecting view_count %d, got %d", e, a)
+	}
+	if !blog.CreatedAt.Equal(sampleTime) {
+		t.Fatalf("Was expecting created_at %v, got %v", sampleTime, blog.CreatedAt)
+	}
+	if blog.ClientID != "" {
+		t.Fatalf("Was expecting no client-id, got %q", blog.ClientID)
+	}
+
+	if len(blog.Posts) != 1 || blog.CurrentPost == nil {
+		t.Fatalf("Was expecting sample posts, got %v and %v", blog.Posts, blog.CurrentPost)
+	}
+	if e, a := []uint64{1, 2}, []uint64{blog.Posts[0].ID, blog.CurrentPost.ID}; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting post ids %v, got %v", e, a)
+	}
+	if e, a := "Lorem ipsum body", blog.Posts[0].Body; e != a {
+		t.Fatalf("Was expecting body %q, got %q", e, a)
+	}
+	if blog.Posts[0].Comments != nil || blog.Posts[0].LatestComment != nil {
+		t.Fatal("Was expecting the related samples to have no relations")
+	}
+
+	if !reflect.DeepEqual(blog, Sample(Blog{})) {
+		t.Fatal("Was expecting the same sample on every call")
+	}
+}
+
+func TestSampleContainerAttributes(t *testing.T) {
+	warehouse := Sample(new(Warehouse)).(*Warehouse)
+
+	item := StockItem{SKU: "Lorem ipsum sku", Quantity: 42}
+	if e, a := item, warehouse.Featured; e != a {
+		t.Fatalf("Was expecting featured %v, got %v", e, a)
+	}
+	if e, a := map[string][]StockItem{"key": {item}}, warehouse.Aisles; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting aisles %v, got %v", e, a)
+	}
+	if e, a := [][]*StockItem{{&item}}, warehouse.Pallets; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting pallets %v, got %v", e, a)
+	}
+}
+
+func TestMarshalSamplePayload(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := MarshalSamplePayload(out, new(Blog)); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := new(OnePayload)
+	if err := json.Unmarshal(out.Bytes(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "blogs", payload.Data.Type; e != a {
+		t.Fatalf("Was expecting type %q, got %q", e, a)
+	}
+	if e, a := 2, len(payload.Included); e != a {
+		t.Fatalf("Was expecting %d included posts, got %d", e, a)
+	}
+
+	blog := new(Blog)
+	if err := UnmarshalOneBytes(out.Bytes(), blog); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "Lorem ipsum title", blog.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+}
+
+func TestSampleEmbeddedStructs(t *testing.T) {
+	story := Sample(new(Story)).(*Story)
+
+	if !story.CreatedAt.Equal(sampleTime) {
+		t.Fatalf("Was expecting created_at %v, got %v", sampleTime, story.CreatedAt)
+	}
+	if story.Byline == nil || story.Author != "Lorem ipsum author" {
+		t.Fatalf("Was expecting the promoted author, got %+v", story.Byline)
+	}
+}
@@ -0,0 +1,198 @@
+package jsonapi
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// modelField is a jsonapi tagged field of a model type.
+type modelField struct {
+	// index is the index sequence of the field in the struct, as for
+	// reflect.Value.FieldByIndex, longer than one for the fields promoted
+	// from embedded structs.
+	index []int
+
+	field reflect.StructField
+
+	// args are the comma separated parts of the tag: the annotation, then the
+	// name and options, if any. They are shared by every call and must not be
+	// modified.
+	args []string
+}
+
+// modelSchema holds the jsonapi tagged fields of a model type, so that the
+// struct tags are read and split once per type rather than on every Marshal
+// and Unmarshal call.
+type modelSchema struct {
+	fields []modelField
+}
+
+// schemas caches the *modelSchema of each struct type.
+var schemas sync.Map
+
+// schemaOf returns the schema of the struct type t.
+//
+// The fields of the untagged structs embedded in t, or pointed to by its
+// embedded pointers, are promoted as encoding/json does, e.g. the created_at
+// and updated_at attributes of a shared Timestamps struct: a field of t hides
+// the fields of an embedded struct for the same member, and the fields for
+// the same member at the same depth hide each other. Embedded models, those
+// with a primary field of their own, are other resources and are left out.
+func schemaOf(t reflect.Type) *modelSchema {
+	if s, ok := schemas.Load(t); ok {
+		return s.(*modelSchema)
+	}
+
+	fields := collectFields(t, nil, map[reflect.Type]bool{t: true})
+
+	// the fields of each member by depth, the shallowest first
+	byMember := make(map[string][]modelField)
+	for _, f := range fields {
+		byMember[memberKey(f.args)] = append(byMember[memberKey(f.args)], f)
+	}
+
+	s := new(modelSchema)
+	for _, f := range fields {
+		same := byMember[memberKey(f.args)]
+		if len(same[0].index) != len(f.index) {
+			// hidden by a shallower field
+			continue
+		}
+		if len(same) > 1 && len(same[1].index) == len(f.index) {
+			// ambiguous
+			continue
+		}
+		s.fields = append(s.fields, f)
+	}
+
+	actual, _ := schemas.LoadOrStore(t, s)
+	return actual.(*modelSchema)
+}
+
+// TaggedFields returns the jsonapi tagged fields of the struct type t as
+// Marshal and Unmarshal see them, including those promoted from its embedded
+// structs, so that tools can walk models the way the package does. The Index
+// of each field is its index sequence in t, as for reflect.Value.FieldByIndex.
+func TaggedFields(t reflect.Type) []reflect.StructField {
+	fields := schemaOf(t).fields
+	tagged := make([]reflect.StructField, len(fields))
+	for i, f := range fields {
+		tagged[i] = f.field
+		tagged[i].Index = append([]int(nil), f.index...)
+	}
+	return tagged
+}
+
+// collectFields returns the jsonapi tagged fields of the struct type t, then
+// those of its embedded structs, each given their index sequence from index.
+// visited holds the embedded types on the path, so that recursive embedding
+// through pointers ends.
+func collectFields(t reflect.Type, index []int, visited map[reflect.Type]bool) []modelField {
+	var fields, promoted []modelField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		tag := field.Tag.Get(annotationJSONAPI)
+		if tag != "" {
+			fields = append(fields, modelField{
+				index: fieldIndex,
+				field: field,
+				args:  strings.Split(tag, annotationSeperator),
+			})
+			continue
+		}
+
+		if !field.Anonymous {
+			continue
+		}
+		embedded := derefType(field.Type)
+		if embedded.Kind() != reflect.Struct || visited[embedded] || declaresPrimary(embedded) {
+			continue
+		}
+		if !field.IsExported() && field.Type.Kind() == reflect.Ptr {
+			// can't be allocated when unmarshaling
+			continue
+		}
+
+		visited[embedded] = true
+		promoted = append(promoted, collectFields(embedded, fieldIndex, visited)...)
+		delete(visited, embedded)
+	}
+
+	// the depth of the fields orders them as schemaOf expects
+	fields = append(fields, promoted...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return len(fields[i].index) < len(fields[j].index)
+	})
+	return fields
+}
+
+// declaresPrimary reports whether the struct type t has a primary annotated
+// field of its own, without building its schema, which may embed t.
+func declaresPrimary(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get(annotationJSONAPI)
+		if strings.SplitN(tag, annotationSeperator, 2)[0] == annotationPrimary {
+			return true
+		}
+	}
+	return false
+}
+
+// memberKey identifies the member a field is bound to, so that the fields of
+// embedded structs for the same member are told apart: the annotation and its
+// name, if any, e.g. "attr,title", and the relationship of a meta member.
+func memberKey(args []string) string {
+	if len(args) < 2 || isBareAnnotation(args[0]) {
+		return args[0]
+	}
+	if args[0] == annotationMeta {
+		if rel := metaRelation(args); rel != "" {
+			return args[0] + "," + args[1] + "," + annotationRelation + annotationValueSeparator + rel
+		}
+	}
+	return args[0] + "," + args[1]
+}
+
+// value returns the field f of the struct v, or the zero Value when it is
+// promoted through a nil embedded pointer, as it is then left out.
+func (f modelField) value(v reflect.Value) reflect.Value {
+	for n, i := range f.index {
+		if n > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// settable returns the field f of the struct v, allocating the nil embedded
+// pointers it is promoted through.
+func (f modelField) settable(v reflect.Value) reflect.Value {
+	for n, i := range f.index {
+		if n > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// primary returns the primary annotated field of the schema, if any.
+func (s *modelSchema) primary() (modelField, bool) {
+	for _, f := range s.fields {
+		if f.args[0] == annotationPrimary {
+			return f, true
+		}
+	}
+	return modelField{}, false
+}
@@ -0,0 +1,114 @@
+package jsonapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchemaOf(t *testing.T) {
+	s := schemaOf(reflect.TypeOf(Blog{}))
+	if s != schemaOf(reflect.TypeOf(Blog{})) {
+		t.Fatal("Was expecting the schema to be cached")
+	}
+
+	var names []string
+	for _, f := range s.fields {
+		names = append(names, f.field.Name)
+	}
+	expected := []string{"ID", "ClientID", "Title", "Posts", "CurrentPost", "CurrentPostID", "CreatedAt", "ViewCount"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Fatalf("Was expecting the fields %v, got %v", expected, names)
+	}
+
+	if f, ok := s.primary(); !ok || !reflect.DeepEqual([]string{"primary", "blogs"}, f.args) {
+		t.Fatalf("Was expecting the primary field, got %v", f.args)
+	}
+}
+
+func TestSchemaUnmarshalRenamesDontLeak(t *testing.T) {
+	renames := Renames{Members: map[string]string{"title": "name"}}
+	payload := &OnePayload{Data: &Node{Type: "blogs", ID: "1", Attributes: map[string]interface{}{"name": "Renamed"}}}
+
+	blog := new(Blog)
+	if err := UnmarshalOne(payload, blog, WithUnmarshalRenames(renames)); err != nil {
+		t.Fatal(err)
+	}
+	if blog.Title != "Renamed" {
+		t.Fatalf("Was expecting the renamed title, got %q", blog.Title)
+	}
+
+	marshaled, err := MarshalOne(blog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := marshaled.Data.Attributes["title"]; !ok {
+		t.Fatalf("Was expecting the title under its tagged name, got %v", marshaled.Data.Attributes)
+	}
+}
+
+func TestSchemaEmbeddedStructs(t *testing.T) {
+	created := time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)
+	story := &Story{
+		Timestamps: Timestamps{CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+		Byline:     &Byline{Author: "Ann", Title: "Hidden"},
+		ID:         1,
+		Title:      "Composition",
+	}
+
+	payload, err := MarshalOne(story)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"created_at": "2016-08-17T08:27:12Z",
+		"updated_at": "2016-08-17T09:27:12Z",
+		"author":     "Ann",
+		"title":      "Composition",
+	}
+	if !reflect.DeepEqual(expected, payload.Data.Attributes) {
+		t.Fatalf("Was expecting the attributes %v, got %v", expected, payload.Data.Attributes)
+	}
+
+	unmarshaled := new(Story)
+	if err := UnmarshalOne(payload, unmarshaled); err != nil {
+		t.Fatal(err)
+	}
+	if !unmarshaled.CreatedAt.Equal(created) || unmarshaled.Byline == nil ||
+		unmarshaled.Author != "Ann" || unmarshaled.Title != "Composition" {
+		t.Fatalf("Was expecting the embedded fields to be set, got %+v", unmarshaled)
+	}
+
+	// the fields behind a nil embedded pointer are left out
+	story.Byline = nil
+	if payload, err = MarshalOne(story); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload.Data.Attributes["author"]; ok {
+		t.Fatalf("Was expecting no author, got %v", payload.Data.Attributes)
+	}
+
+	// and are only allocated when unmarshaling values for them
+	unmarshaled = new(Story)
+	if err := UnmarshalOne(payload, unmarshaled); err != nil {
+		t.Fatal(err)
+	}
+	if unmarshaled.Byline != nil {
+		t.Fatalf("Was expecting no byline, got %+v", unmarshaled.Byline)
+	}
+}
+
+func BenchmarkMarshalMany(b *testing.B) {
+	blogs := make([]interface{}, 1000)
+	for i := range blogs {
+		blogs[i] = testBlog()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalMany(blogs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package jsonapi
+
+import "encoding/json"
+
+// EstimateSize returns the number of bytes payload, such as a *OnePayload or
+// *ManyPayload returned by MarshalOne or MarshalMany, takes once encoded, so
+// that a server can choose between sideloading everything and rendering links
+// only before writing the response, e.g.
+//
+//	payload, err := jsonapi.MarshalOne(blog)
+//	...
+//	if jsonapi.EstimateSize(payload) > maxResponseSize {
+//		payload, err = jsonapi.MarshalOne(blog, jsonapi.WithInclude())
+//	}
+//
+// The payload is fully encoded and its bytes discarded, so the call costs as
+// much as marshaling it. It returns 0 for payloads that can't be encoded.
+func EstimateSize(payload interface{}) int {
+	counter := new(byteCounter)
+	if err := json.NewEncoder(counter).Encode(payload); err != nil {
+		return 0
+	}
+	return counter.n
+}
+
+// byteCounter is an io.Writer that only counts the bytes written to it.
+type byteCounter struct {
+	n int
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
@@ -0,0 +1,34 @@
+package jsonapi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEstimateSize(t *testing.T) {
+	payload, err := MarshalOne(testBlog())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog()); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := out.Len(), EstimateSize(payload); e != a {
+		t.Fatalf("Was expecting a size of %d, got %d", e, a)
+	}
+
+	linksOnly, err := MarshalOne(testBlog(), WithInclude())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if EstimateSize(linksOnly) >= EstimateSize(payload) {
+		t.Fatal("Was expecting the payload without included records to be smaller")
+	}
+
+	if e, a := 0, EstimateSize(make(chan int)); e != a {
+		t.Fatalf("Was expecting a size of %d, got %d", e, a)
+	}
+}
@@ -0,0 +1,362 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// ErrMalformedManyPayload is returned by ManyPayloadDecoder when the payload
+// isn't a JSON API document whose data is an array of resources.
+var ErrMalformedManyPayload = errors.New("The payload is not a document with an array of resources as data")
+
+// ErrEncoderClosed is returned by ManyPayloadEncoder.Encode once the encoder
+// is closed.
+var ErrEncoderClosed = errors.New("The encoder is closed")
+
+// ManyPayloadDecoder reads the resources of a many payload one at a time,
+// rather than decoding the whole document at once as UnmarshalManyPayload
+// does, so that large import bodies are unmarshaled with bounded memory, e.g.
+//
+//	dec := jsonapi.NewManyPayloadDecoder(r.Body, reflect.TypeOf(new(Blog)))
+//	for {
+//		blog, err := dec.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			...
+//		}
+//		// ...save blog.(*Blog)
+//	}
+//
+// Relationships are resolved against the included records only when
+// "included" comes before "data" in the document; otherwise the related models
+// only have their ids set.
+type ManyPayloadDecoder struct {
+	dec      *json.Decoder
+	t        reflect.Type
+	config   *unmarshalConfig
+	included map[string]*Node
+	u        *unmarshaler
+
+	started bool
+	inData  bool
+	done    bool
+}
+
+// NewManyPayloadDecoder returns a ManyPayloadDecoder reading the resources of
+// in into new instances of t.
+func NewManyPayloadDecoder(in io.Reader, t reflect.Type,
+	opts ...UnmarshalOption) *ManyPayloadDecoder {
+	config := newUnmarshalConfig(opts)
+	d := &ManyPayloadDecoder{
+		dec:      json.NewDecoder(config.reader(in)),
+		t:        t,
+		config:   config,
+		included: make(map[string]*Node),
+	}
+	d.u = newUnmarshaler(d.config, &d.included)
+	return d
+}
+
+// Next returns the next resource of the payload, or io.EOF once every resource
+// has been read.
+func (d *ManyPayloadDecoder) Next() (interface{}, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+
+	if !d.inData {
+		if err := d.seekData(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !d.dec.More() {
+		// end of the data array; the rest of the document is skipped
+		if _, err := d.dec.Token(); err != nil {
+			return nil, err
+		}
+		d.inData = false
+		if err := d.skipMembers(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	node := new(Node)
+	if err := d.dec.Decode(node); err != nil {
+		return nil, err
+	}
+	if err := d.config.renameTypes([]*Node{node}); err != nil {
+		return nil, err
+	}
+
+	// the models bound so far are not kept, so that memory stays bounded
+	d.u.models = make(map[string]reflect.Value)
+
+	model := reflect.New(d.t.Elem())
+	if err := d.u.unmarshalNode(node, model); err != nil {
+		return nil, err
+	}
+
+	return model.Interface(), nil
+}
+
+// seekData reads the members of the document up to the start of the data
+// array, indexing the included records met on the way.
+func (d *ManyPayloadDecoder) seekData() error {
+	if !d.started {
+		if err := d.expectDelim('{'); err != nil {
+			return err
+		}
+		d.started = true
+	}
+
+	for d.dec.More() {
+		key, err := d.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "data":
+			if err := d.expectDelim('['); err != nil {
+				return err
+			}
+			d.inData = true
+			return nil
+		case "included":
+			var included []*Node
+			if err := d.dec.Decode(&included); err != nil {
+				return err
+			}
+			if err := d.config.renameTypes(included); err != nil {
+				return err
+			}
+			for k, n := range includedNodeMap(included) {
+				d.included[k] = n
+			}
+		default:
+			var skipped json.RawMessage
+			if err := d.dec.Decode(&skipped); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the document has no data
+	return ErrMalformedManyPayload
+}
+
+// skipMembers reads the members of the document left after the data array.
+func (d *ManyPayloadDecoder) skipMembers() error {
+	for d.dec.More() {
+		if _, err := d.dec.Token(); err != nil {
+			return err
+		}
+		var skipped json.RawMessage
+		if err := d.dec.Decode(&skipped); err != nil {
+			return err
+		}
+	}
+
+	if err := d.expectDelim('}'); err != nil {
+		return err
+	}
+	d.done = true
+	return nil
+}
+
+func (d *ManyPayloadDecoder) expectDelim(delim json.Delim) error {
+	t, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return ErrMalformedManyPayload
+	}
+	return nil
+}
+
+// ManyPayloadEncoder writes a many payload one resource at a time, rather than
+// marshaling the whole collection at once as MarshalManyPayload does, so that
+// very large collections, e.g. read from a database cursor, are rendered
+// without holding every model in memory:
+//
+//	enc := jsonapi.NewManyPayloadEncoder(w)
+//	for rows.Next() {
+//		...
+//		if err := enc.Encode(blog); err != nil {
+//			...
+//		}
+//	}
+//	if err := enc.Close(); err != nil {
+//		...
+//	}
+//
+// The resources of "data" are written as they are encoded; only the included
+// records, deduplicated, and the keys of the primary records are kept until
+// Close writes "included" and the top-level links and meta. The hook of
+// WithPayloadHook is not run, as the payload is never whole.
+type ManyPayloadEncoder struct {
+	w       io.Writer
+	config  *marshalConfig
+	v       *visitor
+	primary map[string]bool
+	span    *tracedSpan
+
+	// resourceType is the tagged type of the first resource encoded.
+	resourceType string
+
+	count  int
+	err    error
+	closed bool
+}
+
+// NewManyPayloadEncoder returns a ManyPayloadEncoder writing to w.
+func NewManyPayloadEncoder(w io.Writer, opts ...MarshalOption) *ManyPayloadEncoder {
+	config := newMarshalConfig(opts)
+	// the span lasts until Close, or the first error
+	var span *tracedSpan
+	config.ctx, span = startSpan(config.ctx, config.tracer, "jsonapi.ManyPayloadEncoder")
+	return &ManyPayloadEncoder{
+		w:       w,
+		config:  config,
+		v:       newVisitor(NewIncludedSet(), true, config),
+		primary: make(map[string]bool),
+		span:    span,
+	}
+}
+
+// Encode writes model as the next resource of "data".
+func (e *ManyPayloadEncoder) Encode(model interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.closed {
+		return ErrEncoderClosed
+	}
+
+	node, err := e.v.visitModelNode(model, "")
+	if err != nil {
+		return e.fail(err)
+	}
+	if e.config.withoutIncluded {
+		// the related records were only visited for their linkage, and are
+		// not kept until Close
+		e.v.included = NewIncludedSet()
+		e.v.root = e.v.included
+	}
+	if node.ID != "" {
+		e.primary[includedKey(node.Type, node.ID)] = true
+	}
+	if e.count == 0 {
+		e.resourceType = node.Type
+	}
+	if p := e.config.pagination; p != nil && e.count < len(p.cursors) {
+		node.Meta = mergeMeta(node.Meta, &Meta{
+			"page": map[string]interface{}{"cursor": p.cursors[e.count]},
+		})
+	}
+	if err := e.config.renameTypes([]*Node{node}); err != nil {
+		return e.fail(err)
+	}
+
+	b, err := json.Marshal(node)
+	if err != nil {
+		return e.fail(err)
+	}
+	prefix := `,`
+	if e.count == 0 {
+		prefix = `{"data":[`
+	}
+	if _, err := io.WriteString(e.w, prefix); err != nil {
+		return e.fail(err)
+	}
+	if _, err := e.w.Write(b); err != nil {
+		return e.fail(err)
+	}
+	e.count++
+	return nil
+}
+
+// Close writes the rest of the document: the included records and the
+// top-level links, meta and jsonapi members. It must be called once every
+// model has been encoded, even if there were none.
+func (e *ManyPayloadEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	var included []*Node
+	if !e.config.withoutIncluded {
+		for _, n := range e.v.included.Nodes() {
+			if !e.primary[includedKey(n.Type, n.ID)] {
+				included = append(included, n)
+			}
+		}
+	}
+	rest := &ManyPayload{Included: included}
+	var err error
+	if rest.Links, err = e.config.documentLinks(); err != nil {
+		return e.fail(err)
+	}
+	rest.Links = mergeLinks(e.config.resolvedCollectionLinks(e.resourceType), rest.Links)
+	rest.Meta = e.config.deprecationMeta(e.config.queryMeta(e.v.truncationMeta(e.config.meta)))
+	rest.JSONAPI = e.config.jsonapiObject()
+	if p := e.config.pagination; p != nil {
+		// the cursors were rendered by Encode
+		(&pagination{links: p.links, meta: p.meta}).apply(rest)
+	}
+	if e.config.sortRelationships {
+		sortNodes(rest.Included)
+	}
+	if err := e.config.renameTypes(rest.Included); err != nil {
+		return e.fail(err)
+	}
+
+	b, err := json.Marshal(rest)
+	if err != nil {
+		return e.fail(err)
+	}
+	// rest has no data: what follows its null data member, "}" or the other
+	// members, ends the document
+	head := "]"
+	if e.count == 0 {
+		head = `{"data":[]`
+	}
+	b = append([]byte(head), b[len(`{"data":null`):]...)
+	if _, err := e.w.Write(append(b, '\n')); err != nil {
+		return e.fail(err)
+	}
+	e.span.finish(e.resourceType, e.count, len(rest.Included), nil)
+	return nil
+}
+
+// fail records err, returned by every later call, as the document written so
+// far can't be completed.
+func (e *ManyPayloadEncoder) fail(err error) error {
+	e.err = err
+	e.span.finish(e.resourceType, e.count, 0, err)
+	return err
+}
+
+// StreamManyPayload writes a many payload of the models received from models
+// until it is closed, with a ManyPayloadEncoder. The channel is not drained
+// after an error.
+func StreamManyPayload(w io.Writer, models <-chan interface{}, opts ...MarshalOption) error {
+	enc := NewManyPayloadEncoder(w, opts...)
+	for model := range models {
+		if err := enc.Encode(model); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
@@ -0,0 +1,132 @@
+package jsonapi
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManyPayloadDecoder(t *testing.T) {
+	data := `{
+		"meta": {"total": 2},
+		"included": [{"type": "comments", "id": "1", "attributes": {"body": "foo"}}],
+		"data": [
+			{"type": "posts", "id": "1", "attributes": {"title": "First"},
+			 "relationships": {"latest_comment": {"data": {"type": "comments", "id": "1"}}}},
+			{"type": "posts", "id": "2", "attributes": {"title": "Second"}}
+		],
+		"links": {"self": "/posts"}
+	}`
+
+	dec := NewManyPayloadDecoder(strings.NewReader(data), reflect.TypeOf(new(Post)))
+
+	var posts []*Post
+	for {
+		post, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		posts = append(posts, post.(*Post))
+	}
+
+	if e, a := 2, len(posts); e != a {
+		t.Fatalf("Was expecting %d posts, got %d", e, a)
+	}
+	if e, a := "Second", posts[1].Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+	if posts[0].LatestComment == nil || posts[0].LatestComment.Body != "foo" {
+		t.Fatalf("Was expecting the included latest comment, got %v", posts[0].LatestComment)
+	}
+
+	if _, err := dec.Next(); err != io.EOF {
+		t.Fatalf("Was expecting io.EOF, got %v", err)
+	}
+}
+
+func TestManyPayloadDecoderMalformed(t *testing.T) {
+	for _, data := range []string{
+		`{"data": {"type": "posts", "id": "1"}}`,
+		`{"meta": {}}`,
+		`[]`,
+	} {
+		dec := NewManyPayloadDecoder(strings.NewReader(data), reflect.TypeOf(new(Post)))
+		if _, err := dec.Next(); err != ErrMalformedManyPayload {
+			t.Fatalf("Was expecting ErrMalformedManyPayload for %s, got %v", data, err)
+		}
+	}
+}
+
+func TestStreamManyPayload(t *testing.T) {
+	blog := testBlog()
+	models := []interface{}{blog, blog.Posts[0], blog.Posts[1]}
+	meta := &Meta{"total": 3}
+
+	expected := new(bytes.Buffer)
+	if err := MarshalManyPayload(expected, models, WithMeta(meta)); err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{})
+	go func() {
+		for _, model := range models {
+			ch <- model
+		}
+		close(ch)
+	}()
+	out := new(bytes.Buffer)
+	if err := StreamManyPayload(out, ch, WithMeta(meta)); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := expected.String(), out.String(); e != a {
+		t.Fatalf("Was expecting\n%s\ngot\n%s", e, a)
+	}
+}
+
+func TestManyPayloadEncoderWithoutIncluded(t *testing.T) {
+	blogs := []interface{}{testBlog(), testBlog()}
+
+	expected := new(bytes.Buffer)
+	if err := MarshalManyPayload(expected, blogs, WithoutIncluded()); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	enc := NewManyPayloadEncoder(out, WithoutIncluded())
+	for _, blog := range blogs {
+		if err := enc.Encode(blog); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e, a := 0, enc.v.included.Len(); e != a {
+		t.Fatalf("Was expecting %d buffered included records, got %d", e, a)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := expected.String(), out.String(); e != a {
+		t.Fatalf("Was expecting\n%s\ngot\n%s", e, a)
+	}
+}
+
+func TestManyPayloadEncoderEmpty(t *testing.T) {
+	out := new(bytes.Buffer)
+	enc := NewManyPayloadEncoder(out)
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "{\"data\":[]}\n", out.String(); e != a {
+		t.Fatalf("Was expecting %q, got %q", e, a)
+	}
+	if err := enc.Encode(testBlog()); err != ErrEncoderClosed {
+		t.Fatalf("Was expecting ErrEncoderClosed, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package jsonapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// UnmarshalPayloadStrict does the same as UnmarshalPayload, rejecting the
+// documents that UnmarshalPayload would bind partially: those whose resource
+// type doesn't match the primary tag of model, with attributes or
+// relationships that model doesn't declare, or with members that can't be
+// bound to their fields, e.g. a string for an int attribute. Every problem is
+// reported, as a *ValidationError holding an error object for each of them
+// whose source points to the member, e.g. "/data/attributes/title", ready to
+// be written back with WriteError:
+//
+//	if err := jsonapi.UnmarshalPayloadStrict(r.Body, blog); err != nil {
+//		jsonapi.WriteError(w, err)
+//		return
+//	}
+//
+// The unknown attributes of models with an extras field, or implementing
+// AttributeSetter, and the unknown relationships of models with an
+// extra-relationships field are allowed.
+func UnmarshalPayloadStrict(in io.Reader, model interface{}, opts ...UnmarshalOption) error {
+	config := newUnmarshalConfig(opts)
+
+	payload := new(OnePayload)
+	if err := config.decode(in, payload); err != nil {
+		return err
+	}
+	if err := config.renameTypes([]*Node{payload.Data}, payload.Included); err != nil {
+		return err
+	}
+
+	modelValue := reflect.ValueOf(model)
+	if payload.Data != nil && modelValue.Kind() == reflect.Ptr &&
+		modelValue.Elem().Kind() == reflect.Struct {
+		if errs := strictErrors(payload.Data, modelValue, config); len(errs) > 0 {
+			return &ValidationError{Errors: errs}
+		}
+	}
+
+	return unmarshalOnePayload(payload, model, config)
+}
+
+// strictErrors returns the error objects of the problems of data, the primary
+// data of a document, as UnmarshalPayloadStrict reports them for model.
+func strictErrors(data *Node, model reflect.Value, config *unmarshalConfig) []*ErrorObject {
+	t := model.Elem().Type()
+
+	var errs []*ErrorObject
+	if f, ok := schemaOf(t).primary(); ok && len(f.args) > 1 && data.Type != f.args[1] {
+		errs = append(errs, strictError(http.StatusConflict, "Type mismatch",
+			fmt.Sprintf("The type %q doesn't match the type %q of the resource", data.Type, f.args[1]),
+			"/data/type"))
+	}
+
+	attrs, rels := make(map[string]bool), make(map[string]bool)
+	_, extraAttrs := model.Interface().(AttributeSetter)
+	extraRels := false
+	for _, f := range schemaOf(t).fields {
+		switch {
+		case f.args[0] == annotationAttribute && len(f.args) > 1:
+			attrs[config.memberName(f.args[1])] = true
+		case f.args[0] == annotationRelation && len(f.args) > 1:
+			rels[config.memberName(f.args[1])] = true
+		case f.args[0] == annotationExtras:
+			extraAttrs = true
+		case f.args[0] == annotationExtraRelations:
+			extraRels = true
+		}
+	}
+
+	for _, name := range sortedKeys(data.Attributes) {
+		pointer := "/data/attributes/" + escapePointer(name)
+		if !attrs[name] {
+			if !extraAttrs {
+				errs = append(errs, strictError(http.StatusBadRequest, "Unknown attribute",
+					fmt.Sprintf("The attribute %q is unknown", name), pointer))
+			}
+			continue
+		}
+		probe := &Node{Type: data.Type, Attributes: map[string]interface{}{name: data.Attributes[name]}}
+		if err := probeMember(probe, t, config); err != nil {
+			errs = append(errs, strictError(http.StatusBadRequest, "Invalid attribute",
+				err.Error(), pointer))
+		}
+	}
+
+	for _, name := range sortedKeys(data.Relationships) {
+		pointer := "/data/relationships/" + escapePointer(name)
+		if !rels[name] {
+			if !extraRels {
+				errs = append(errs, strictError(http.StatusBadRequest, "Unknown relationship",
+					fmt.Sprintf("The relationship %q is unknown", name), pointer))
+			}
+			continue
+		}
+		probe := &Node{Type: data.Type, Relationships: map[string]interface{}{name: data.Relationships[name]}}
+		if err := probeMember(probe, t, config); err != nil {
+			errs = append(errs, strictError(http.StatusBadRequest, "Invalid relationship",
+				err.Error(), pointer))
+		}
+	}
+
+	return errs
+}
+
+// probeMember binds probe, a Node holding a single member, to a scratch
+// instance of the struct type t, and returns the error of that member, if any.
+func probeMember(probe *Node, t reflect.Type, config *unmarshalConfig) error {
+	u := newUnmarshaler(config, nil)
+	u.probing = true
+	return u.unmarshalNode(probe, reflect.New(t))
+}
+
+func strictError(status int, title, detail, pointer string) *ErrorObject {
+	return &ErrorObject{
+		Title:  title,
+		Detail: detail,
+		Status: strconv.Itoa(status),
+		Source: &ErrorSource{Pointer: pointer},
+	}
+}
+
+// sortedKeys returns the keys of m in order, so that the problems are
+// reported in a stable order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
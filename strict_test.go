@@ -0,0 +1,57 @@
+package jsonapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalPayloadStrict(t *testing.T) {
+	data := `{"data": {"type": "blogs", "id": "5",
+		"attributes": {"title": "Title", "view_count": "many", "colour": "red"},
+		"relationships": {"owner": {"data": {"type": "people", "id": "1"}}}}}`
+
+	err := UnmarshalPayloadStrict(strings.NewReader(data), new(Blog))
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Was expecting a *ValidationError, got %v", err)
+	}
+
+	expected := []struct{ status, pointer string }{
+		{"400", "/data/attributes/colour"},
+		{"400", "/data/attributes/view_count"},
+		{"400", "/data/relationships/owner"},
+	}
+	if e, a := len(expected), len(validationErr.Errors); e != a {
+		t.Fatalf("Was expecting %d errors, got %d: %v", e, a, err)
+	}
+	for i, obj := range validationErr.Errors {
+		if obj.Status != expected[i].status || obj.Source == nil || obj.Source.Pointer != expected[i].pointer {
+			t.Fatalf("Was expecting a %s error for %s, got %+v", expected[i].status, expected[i].pointer, obj)
+		}
+	}
+}
+
+func TestUnmarshalPayloadStrictTypeMismatch(t *testing.T) {
+	data := `{"data": {"type": "posts", "attributes": {"title": "Title"}}}`
+
+	err := UnmarshalPayloadStrict(strings.NewReader(data), new(Blog))
+	validationErr, ok := err.(*ValidationError)
+	if !ok || len(validationErr.Errors) != 1 {
+		t.Fatalf("Was expecting a single error, got %v", err)
+	}
+	if obj := validationErr.Errors[0]; obj.Status != "409" || obj.Source.Pointer != "/data/type" {
+		t.Fatalf("Was expecting a 409 error for /data/type, got %+v", obj)
+	}
+}
+
+func TestUnmarshalPayloadStrictValid(t *testing.T) {
+	data := `{"data": {"type": "blogs", "id": "5", "attributes": {"title": "Title", "view_count": 3}}}`
+
+	blog := new(Blog)
+	if err := UnmarshalPayloadStrict(strings.NewReader(data), blog); err != nil {
+		t.Fatal(err)
+	}
+	if blog.ID != 5 || blog.Title != "Title" || blog.ViewCount != 3 {
+		t.Fatalf("Was expecting the blog to be bound, got %+v", blog)
+	}
+}
@@ -0,0 +1,265 @@
+package jsonapi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tag is a parsed jsonapi struct tag, e.g. `jsonapi:"attr,title,omitempty"`.
+type Tag struct {
+	// Annotation is the kind of the field, e.g. "attr" or "relation".
+	Annotation string
+
+	// Name is the member name, or the resource type of a primary field; it is
+	// empty for the annotations used without one, e.g. "links".
+	Name string
+
+	// Options holds the tag options, e.g. "omitempty" or "mapkey:position".
+	Options []string
+}
+
+// TagError is returned by ParseTag for a malformed jsonapi struct tag.
+type TagError struct {
+	Tag string
+
+	// Problem describes what is wrong with the tag, e.g. `unknown option
+	// "sorted"`.
+	Problem string
+}
+
+// Error implements the `Error` interface.
+func (e *TagError) Error() string {
+	return fmt.Sprintf("%v %q: %s", ErrBadJSONAPIStructTag, e.Tag, e.Problem)
+}
+
+// Unwrap returns ErrBadJSONAPIStructTag.
+func (e *TagError) Unwrap() error {
+	return ErrBadJSONAPIStructTag
+}
+
+// tagOptions are the options known for each annotation taking options; those
+// taking a value, e.g. "mapkey:<name>", are listed by name.
+var tagOptions = map[string]map[string]bool{
+	annotationAttribute: {
+		annotationOmitEmpty: true, annotationOmitZero: true, annotationISO8601: true,
+		annotationDate: true, annotationRFC3339: true, annotationUnixMilli: true,
+		annotationFormat: true, annotationReadOnly: true, annotationDeprecated: true,
+		annotationDiscriminator: true, annotationString: true, annotationScope: true,
+	},
+	annotationRelation: {
+		annotationOmitEmpty: true, annotationNoInclude: true, annotationNoLinkage: true,
+		annotationIDs: true, annotationMapKey: true, annotationInverse: true,
+		annotationDeprecated: true,
+	},
+	annotationMeta: {
+		annotationOmitEmpty: true, annotationRelation: true,
+	},
+}
+
+// ParseTag parses the value of a jsonapi struct tag, e.g. "attr,title,omitempty",
+// so that tools can check models the way the package reads them. It returns a
+// *TagError for an unknown annotation, a missing or unexpected name, or an
+// unknown option; in the last case the parsed Tag is returned along with the
+// error.
+func ParseTag(tag string) (*Tag, error) {
+	args := strings.Split(tag, annotationSeperator)
+	t := &Tag{Annotation: args[0]}
+
+	switch {
+	case isBareAnnotation(t.Annotation):
+		if len(args) != 1 {
+			return nil, &TagError{Tag: tag, Problem: fmt.Sprintf("%q takes no name", t.Annotation)}
+		}
+		return t, nil
+	case t.Annotation == annotationMeta && len(args) == 1:
+		return t, nil
+	case t.Annotation == annotationPrimary || t.Annotation == annotationAttribute ||
+		t.Annotation == annotationRelation || t.Annotation == annotationLinkageMeta ||
+		t.Annotation == annotationRelationPresent || t.Annotation == annotationMeta:
+		if len(args) < 2 || args[1] == "" {
+			return nil, &TagError{Tag: tag, Problem: fmt.Sprintf("%q needs a name", t.Annotation)}
+		}
+	default:
+		return nil, &TagError{Tag: tag, Problem: fmt.Sprintf("unknown annotation %q", t.Annotation)}
+	}
+
+	t.Name = args[1]
+	t.Options = args[2:]
+
+	for _, option := range t.Options {
+		name := strings.SplitN(option, annotationValueSeparator, 2)[0]
+		if !tagOptions[t.Annotation][name] {
+			return t, &TagError{Tag: tag, Problem: fmt.Sprintf("unknown option %q", option)}
+		}
+	}
+
+	return t, nil
+}
+
+// HasOption reports whether the tag has the option name, e.g. "omitempty",
+// with or without a value.
+func (t *Tag) HasOption(name string) bool {
+	for _, option := range t.Options {
+		if option == name || strings.HasPrefix(option, name+annotationValueSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBareAnnotation reports whether annotation is used without a name, e.g.
+// `jsonapi:"client-id"`.
+func isBareAnnotation(annotation string) bool {
+	return annotation == annotationClientID || annotation == annotationLocalID ||
+		annotation == annotationLinks || annotation == annotationExtraRelations ||
+		annotation == annotationExtras
+}
+
+// validTagArgs reports whether the tag args have a name if, and only if, their
+// annotation takes one; meta takes one optionally, for a single member of the
+// meta object, e.g. `jsonapi:"meta,count"`.
+func validTagArgs(args []string) bool {
+	switch {
+	case args[0] == annotationMeta:
+		return len(args) == 1 || args[1] != ""
+	case isBareAnnotation(args[0]):
+		return len(args) == 1
+	default:
+		return len(args) >= 2
+	}
+}
+
+// metaRelation returns the name of the relationship whose meta holds the
+// member of a meta annotated field, given as "relation:<name>".
+func metaRelation(args []string) string {
+	for _, arg := range args[2:] {
+		if strings.HasPrefix(arg, annotationRelation+annotationValueSeparator) {
+			return strings.TrimPrefix(arg, annotationRelation+annotationValueSeparator)
+		}
+	}
+	return ""
+}
+
+// relationMapKeyMeta returns the name of the resource identifier meta member
+// holding the map key of a map-typed relationship, given as "mapkey:<name>".
+func relationMapKeyMeta(args []string) string {
+	for _, arg := range args[2:] {
+		if strings.HasPrefix(arg, annotationMapKey+annotationValueSeparator) {
+			return strings.TrimPrefix(arg, annotationMapKey+annotationValueSeparator)
+		}
+	}
+	return ""
+}
+
+// relationInverse returns the name of the relationship of the related models
+// pointing back to the model, given as "inverse:<name>".
+func relationInverse(args []string) string {
+	for _, arg := range args[2:] {
+		if strings.HasPrefix(arg, annotationInverse+annotationValueSeparator) {
+			return strings.TrimPrefix(arg, annotationInverse+annotationValueSeparator)
+		}
+	}
+	return ""
+}
+
+// attributeDiscriminator returns the name of the member of an interface typed
+// attribute naming its registered type, given as "discriminator:<name>".
+func attributeDiscriminator(args []string) string {
+	for _, arg := range args[2:] {
+		if strings.HasPrefix(arg, annotationDiscriminator+annotationValueSeparator) {
+			return strings.TrimPrefix(arg, annotationDiscriminator+annotationValueSeparator)
+		}
+	}
+	return ""
+}
+
+// attributeScopes returns the scopes of an attribute, given as "scope:<name>",
+// one of which the call must be granted for the attribute to be rendered.
+func attributeScopes(args []string) []string {
+	var scopes []string
+	for _, arg := range args[2:] {
+		if strings.HasPrefix(arg, annotationScope+annotationValueSeparator) {
+			scopes = append(scopes, strings.TrimPrefix(arg, annotationScope+annotationValueSeparator))
+		}
+	}
+	return scopes
+}
+
+// relationIDsType reports whether the relation tag args include the "ids"
+// option and returns the related resource type given as "ids:<type>", if any.
+func relationIDsType(args []string) (string, bool) {
+	if len(args) < 3 {
+		return "", false
+	}
+
+	for _, arg := range args[2:] {
+		if arg == annotationIDs {
+			return "", true
+		}
+		if strings.HasPrefix(arg, annotationIDs+annotationValueSeparator) {
+			return strings.TrimPrefix(arg, annotationIDs+annotationValueSeparator), true
+		}
+	}
+
+	return "", false
+}
+
+// attributeStringEncoded reports whether the attr tag args include the
+// "string" option, encoding numbers and booleans as JSON strings.
+func attributeStringEncoded(args []string) bool {
+	for _, arg := range args[2:] {
+		if arg == annotationString {
+			return true
+		}
+	}
+	return false
+}
+
+// namedTimeLayouts are the layouts of the time package holding a comma, which
+// can't be given in a tag, by their name for the format option, e.g.
+// "format:rfc1123".
+var namedTimeLayouts = map[string]string{
+	"rfc850":   time.RFC850,
+	"rfc1123":  time.RFC1123,
+	"rfc1123z": time.RFC1123Z,
+}
+
+// attributeTimeLayout returns the layout of the time attributes tagged with
+// args: a calendar date for the "date" option, RFC3339 for "rfc3339", the
+// layout given, or named, with "format:<layout>", unixMilliLayout for
+// "unixmilli", ISO8601 for the "iso8601" option or when it is the default
+// format, or else "" for unix timestamps. ErrBadJSONAPIStructTag is returned
+// for a layout cut by a comma, its rest being taken for unknown options.
+func attributeTimeLayout(args []string, format TimeFormat) (string, error) {
+	iso8601 := format == TimeFormatISO8601
+	for i, arg := range args[2:] {
+		switch {
+		case arg == annotationDate:
+			return dateFormat, nil
+		case arg == annotationRFC3339:
+			return time.RFC3339, nil
+		case arg == annotationUnixMilli:
+			return unixMilliLayout, nil
+		case strings.HasPrefix(arg, annotationFormat+annotationValueSeparator):
+			for _, option := range args[2+i+1:] {
+				name := strings.SplitN(option, annotationValueSeparator, 2)[0]
+				if !tagOptions[annotationAttribute][name] {
+					return "", ErrBadJSONAPIStructTag
+				}
+			}
+			layout := strings.TrimPrefix(arg, annotationFormat+annotationValueSeparator)
+			if named, ok := namedTimeLayouts[layout]; ok {
+				return named, nil
+			}
+			return layout, nil
+		case arg == annotationISO8601:
+			iso8601 = true
+		}
+	}
+
+	if iso8601 {
+		return iso8601TimeFormat, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,51 @@
+package jsonapi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tag, err := ParseTag("relation,players,mapkey:position,omitempty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Tag{Annotation: "relation", Name: "players", Options: []string{"mapkey:position", "omitempty"}}
+	if !reflect.DeepEqual(expected, tag) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, tag)
+	}
+	if !tag.HasOption("mapkey") || tag.HasOption("noinclude") {
+		t.Fatalf("Was expecting the mapkey option only, got %v", tag.Options)
+	}
+
+	tag, err = ParseTag("meta,count,relation:comments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Name != "count" || !tag.HasOption("relation") {
+		t.Fatalf("Was expecting the count member of the comments meta, got %+v", tag)
+	}
+
+	for tag, problem := range map[string]string{
+		"primary":          `"primary" needs a name`,
+		"meta,,omitempty":  `"meta" needs a name`,
+		"links,self":       `"links" takes no name`,
+		"colour,color":     `unknown annotation "colour"`,
+		"attr,title,ids":   `unknown option "ids"`,
+		"primary,posts,id": `unknown option "id"`,
+	} {
+		_, err := ParseTag(tag)
+
+		tagErr, ok := err.(*TagError)
+		if !ok {
+			t.Fatalf("Was expecting a *TagError for %q, got %v", tag, err)
+		}
+		if e, a := problem, tagErr.Problem; e != a {
+			t.Fatalf("Was expecting the problem %q for %q, got %q", e, tag, a)
+		}
+		if !errors.Is(err, ErrBadJSONAPIStructTag) {
+			t.Fatalf("Was expecting %v to be an ErrBadJSONAPIStructTag", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package jsonapi
+
+import "context"
+
+// Span attribute keys set on the spans started for each Marshal and Unmarshal
+// call.
+const (
+	SpanAttributeResourceType  = "jsonapi.resource_type"
+	SpanAttributePrimaryCount  = "jsonapi.primary_count"
+	SpanAttributeIncludedCount = "jsonapi.included_count"
+)
+
+// Tracer starts the spans recorded around marshaling and unmarshaling. It is
+// small enough to be adapted to OpenTelemetry, e.g.
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, name string) (context.Context, jsonapi.Span) {
+//		ctx, span := t.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// tracedSpan wraps a possibly nil Span so that callers don't need to check
+// whether tracing is enabled.
+type tracedSpan struct {
+	span Span
+}
+
+// startSpan starts a span named name when tracer is not nil. The context
+// returned, holding the span, is the one to pass down to the hooks run while
+// the span is active.
+func startSpan(ctx context.Context, tracer Tracer, name string) (context.Context, *tracedSpan) {
+	if tracer == nil {
+		return ctx, &tracedSpan{}
+	}
+
+	ctx, span := tracer.StartSpan(ctx, name)
+	return ctx, &tracedSpan{span: span}
+}
+
+// finish records the outcome of the operation on the span and ends it.
+func (s *tracedSpan) finish(resourceType string, primary, included int, err error) {
+	if s.span == nil {
+		return
+	}
+
+	if resourceType != "" {
+		s.span.SetAttribute(SpanAttributeResourceType, resourceType)
+	}
+	s.span.SetAttribute(SpanAttributePrimaryCount, primary)
+	s.span.SetAttribute(SpanAttributeIncludedCount, included)
+	if err != nil {
+		s.span.RecordError(err)
+	}
+	s.span.End()
+}
@@ -0,0 +1,184 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+type recordedSpan struct {
+	name       string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *recordedSpan) RecordError(err error) { s.err = err }
+
+func (s *recordedSpan) End() { s.ended = true }
+
+type recordingTracer struct {
+	spans []*recordedSpan
+
+	// host, when set, is held by the context of the spans
+	host string
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, attributes: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	if t.host != "" {
+		ctx = context.WithValue(ctx, ctxKey("host"), t.host)
+	}
+	return ctx, span
+}
+
+func TestMarshalWithTracer(t *testing.T) {
+	tracer := new(recordingTracer)
+
+	if _, err := MarshalOne(testBlog(), WithTracer(tracer)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Was expecting 1 span, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if e, a := "jsonapi.MarshalOne", span.name; e != a {
+		t.Fatalf("Was expecting span %q, got %q", e, a)
+	}
+	if !span.ended {
+		t.Fatal("Was expecting the span to be ended")
+	}
+	if e, a := "blogs", span.attributes[SpanAttributeResourceType]; e != a {
+		t.Fatalf("Was expecting resource type %v, got %v", e, a)
+	}
+	if e, a := 1, span.attributes[SpanAttributePrimaryCount]; e != a {
+		t.Fatalf("Was expecting primary count %v, got %v", e, a)
+	}
+	if a := span.attributes[SpanAttributeIncludedCount].(int); a == 0 {
+		t.Fatal("Was expecting a non zero included count")
+	}
+}
+
+func TestMarshalWithTracerRecordsError(t *testing.T) {
+	tracer := new(recordingTracer)
+
+	_, err := MarshalMany([]interface{}{&Account{ID: 1}}, WithTracer(tracer))
+	if err != errAccountEmail {
+		t.Fatalf("Was expecting `%v`, got `%v`", errAccountEmail, err)
+	}
+	if e, a := errAccountEmail, tracer.spans[0].err; e != a {
+		t.Fatalf("Was expecting the span to record `%v`, got `%v`", e, a)
+	}
+}
+
+func TestUnmarshalWithTracer(t *testing.T) {
+	tracer := new(recordingTracer)
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalManyPayload(out, []*Blog{testBlog(), testBlog()}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := UnmarshalManyPayload(out, reflect.TypeOf(new(Blog)), WithUnmarshalTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := tracer.spans[0]
+	if e, a := "jsonapi.UnmarshalManyPayload", span.name; e != a {
+		t.Fatalf("Was expecting span %q, got %q", e, a)
+	}
+	if e, a := 2, span.attributes[SpanAttributePrimaryCount]; e != a {
+		t.Fatalf("Was expecting primary count %v, got %v", e, a)
+	}
+	if e, a := "blogs", span.attributes[SpanAttributeResourceType]; e != a {
+		t.Fatalf("Was expecting resource type %v, got %v", e, a)
+	}
+}
+
+func TestMarshalWithTracerPassesSpanContext(t *testing.T) {
+	tracer := &recordingTracer{host: "traced.example.com"}
+
+	payload, err := MarshalOne(&Tenant{ID: 1, Owner: &Person{ID: 2}}, WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "traced.example.com", (*payload.Data.Meta)["host"]; e != a {
+		t.Fatalf("Was expecting the hooks to get the span context, with host %v, got %v", e, a)
+	}
+}
+
+func TestMarshalEmbeddedWithTracer(t *testing.T) {
+	tracer := new(recordingTracer)
+
+	if err := MarshalOnePayloadEmbedded(bytes.NewBuffer(nil), testBlog(), WithTracer(tracer)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Was expecting 1 span, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if e, a := "jsonapi.MarshalOnePayloadEmbedded", span.name; e != a {
+		t.Fatalf("Was expecting span %q, got %q", e, a)
+	}
+	if !span.ended {
+		t.Fatal("Was expecting the span to be ended")
+	}
+	if e, a := "blogs", span.attributes[SpanAttributeResourceType]; e != a {
+		t.Fatalf("Was expecting resource type %v, got %v", e, a)
+	}
+}
+
+func TestManyPayloadEncoderWithTracer(t *testing.T) {
+	tracer := new(recordingTracer)
+
+	enc := NewManyPayloadEncoder(bytes.NewBuffer(nil), WithTracer(tracer))
+	for _, blog := range []*Blog{testBlog(), testBlog()} {
+		if err := enc.Encode(blog); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(tracer.spans) != 1 || tracer.spans[0].ended {
+		t.Fatal("Was expecting a single span, active until Close")
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	span := tracer.spans[0]
+	if e, a := "jsonapi.ManyPayloadEncoder", span.name; e != a {
+		t.Fatalf("Was expecting span %q, got %q", e, a)
+	}
+	if !span.ended {
+		t.Fatal("Was expecting the span to be ended")
+	}
+	if e, a := 2, span.attributes[SpanAttributePrimaryCount]; e != a {
+		t.Fatalf("Was expecting primary count %v, got %v", e, a)
+	}
+}
+
+func TestManyPayloadEncoderWithTracerRecordsError(t *testing.T) {
+	tracer := new(recordingTracer)
+
+	enc := NewManyPayloadEncoder(bytes.NewBuffer(nil), WithTracer(tracer))
+	if err := enc.Encode(&Account{ID: 1}); err != errAccountEmail {
+		t.Fatalf("Was expecting `%v`, got `%v`", errAccountEmail, err)
+	}
+
+	span := tracer.spans[0]
+	if e, a := errAccountEmail, span.err; e != a {
+		t.Fatalf("Was expecting the span to record `%v`, got `%v`", e, a)
+	}
+	if !span.ended {
+		t.Fatal("Was expecting the span to be ended")
+	}
+}
@@ -0,0 +1,123 @@
+package jsonapi
+
+import "errors"
+
+// ErrBadTypePrefix is returned when unmarshaling with WithUnmarshalTypePrefix
+// a payload holding a resource type without the expected prefix.
+var ErrBadTypePrefix = errors.New("resource type does not have the expected prefix")
+
+// typeRenamer returns the type name to use in place of the given one.
+type typeRenamer func(string) (string, error)
+
+// renameTypes applies rename to the type of the primary data, the included
+// records and the resource linkage of their relationships. Relationships may
+// be either RelationshipOneNode/RelationshipManyNode values, as built when
+// marshaling, or the generic maps decoded from a payload.
+func renameTypes(rename typeRenamer, nodes ...[]*Node) error {
+	seen := make(map[*Node]bool)
+
+	var renameNode func(n *Node) error
+	renameNode = func(n *Node) error {
+		if n == nil || seen[n] {
+			return nil
+		}
+		seen[n] = true
+
+		t, err := rename(n.Type)
+		if err != nil {
+			return err
+		}
+		n.Type = t
+
+		for _, relationship := range n.Relationships {
+			switch r := relationship.(type) {
+			case *RelationshipOneNode:
+				if err := renameNode(r.Data); err != nil {
+					return err
+				}
+			case *RelationshipManyNode:
+				for _, d := range r.Data {
+					if err := renameNode(d); err != nil {
+						return err
+					}
+				}
+			case map[string]interface{}:
+				if err := renameLinkage(rename, r["data"]); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	for _, ns := range nodes {
+		for _, n := range ns {
+			if err := renameNode(n); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// renameLinkage applies rename to decoded resource linkage: a resource
+// identifier object or an array of them.
+func renameLinkage(rename typeRenamer, data interface{}) error {
+	switch d := data.(type) {
+	case map[string]interface{}:
+		t, _ := d["type"].(string)
+		renamed, err := rename(t)
+		if err != nil {
+			return err
+		}
+		d["type"] = renamed
+	case []interface{}:
+		for _, item := range d {
+			if err := renameLinkage(rename, item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Renames maps the names used in the jsonapi tags of the models to the names
+// rendered in the payloads, e.g. for a version of the API that renamed some
+// resource types or members.
+type Renames struct {
+	// Types maps tagged resource types to rendered ones.
+	Types map[string]string
+
+	// Members maps tagged attribute and relationship names, of any resource
+	// type, to rendered ones.
+	Members map[string]string
+}
+
+// memberName returns the rendered name of the tagged member name.
+func (r Renames) memberName(name string) string {
+	if renamed, ok := r.Members[name]; ok {
+		return renamed
+	}
+	return name
+}
+
+// typeName returns the rendered name of the tagged resource type t.
+func (r Renames) typeName(t string) string {
+	if renamed, ok := r.Types[t]; ok {
+		return renamed
+	}
+	return t
+}
+
+// tagTypeName returns the tagged resource type of the rendered type t.
+func (r Renames) tagTypeName(t string) string {
+	for tagged, renamed := range r.Types {
+		if renamed == t {
+			return tagged
+		}
+	}
+	return t
+}
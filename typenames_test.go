@@ -0,0 +1,145 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalTypePrefix(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), WithTypePrefix("acme:")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(bytes.NewReader(out.Bytes())).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "acme:blogs", resp.Data.Type; e != a {
+		t.Fatalf("Was expecting type %q, got %q", e, a)
+	}
+	for _, n := range resp.Included {
+		if !strings.HasPrefix(n.Type, "acme:") {
+			t.Fatalf("Was expecting included type %q to be prefixed", n.Type)
+		}
+	}
+	posts := resp.Data.Relationships["posts"].(map[string]interface{})["data"].([]interface{})
+	if e, a := "acme:posts", posts[0].(map[string]interface{})["type"]; e != a {
+		t.Fatalf("Was expecting linkage type %q, got %v", e, a)
+	}
+
+	blog := new(Blog)
+	if err := UnmarshalPayload(bytes.NewReader(out.Bytes()), blog, WithUnmarshalTypePrefix("acme:")); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := testBlog().Posts[0].Comments[0].Body, blog.Posts[0].Comments[0].Body; e != a {
+		t.Fatalf("Was expecting comment body %q, got %q", e, a)
+	}
+
+	err := UnmarshalPayload(bytes.NewReader(out.Bytes()), new(Blog), WithUnmarshalTypePrefix("other:"))
+	if err != ErrBadTypePrefix {
+		t.Fatalf("Was expecting `%v`, got `%v`", ErrBadTypePrefix, err)
+	}
+}
+
+func TestMarshalManyTypePrefix(t *testing.T) {
+	payload, err := MarshalMany([]interface{}{&Comment{ID: 1}}, WithTypePrefix("acme:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "acme:comments", payload.Data[0].Type; e != a {
+		t.Fatalf("Was expecting type %q, got %q", e, a)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(out).Encode(payload); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := UnmarshalManyPayload(out, reflect.TypeOf(new(Comment)), WithUnmarshalTypePrefix("acme:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 1, comments[0].(*Comment).ID; e != a {
+		t.Fatalf("Was expecting id %d, got %d", e, a)
+	}
+}
+
+func TestRenames(t *testing.T) {
+	v2 := Renames{
+		Types:   map[string]string{"posts": "articles"},
+		Members: map[string]string{"title": "headline", "comments": "replies"},
+	}
+
+	post := &Post{ID: 1, Title: "Hello", Comments: []*Comment{{ID: 1, Body: "Hi"}}}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, post, WithRenames(v2)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(bytes.NewReader(out.Bytes())).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "articles", resp.Data.Type; e != a {
+		t.Fatalf("Was expecting type %q, got %q", e, a)
+	}
+	if e, a := "Hello", resp.Data.Attributes["headline"]; e != a {
+		t.Fatalf("Was expecting headline %q, got %v", e, a)
+	}
+	if _, ok := resp.Data.Relationships["replies"]; !ok {
+		t.Fatalf("Was expecting a replies relationship, got %v", resp.Data.Relationships)
+	}
+
+	decoded := new(Post)
+	if err := UnmarshalPayload(bytes.NewReader(out.Bytes()), decoded, WithUnmarshalRenames(v2)); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "Hello", decoded.Title; e != a {
+		t.Fatalf("Was expecting title %q, got %q", e, a)
+	}
+	if e, a := 1, len(decoded.Comments); e != a {
+		t.Fatalf("Was expecting %d comments, got %d", e, a)
+	}
+}
+
+func TestMarshalWithTypeNames(t *testing.T) {
+	renames := WithRenames(Renames{Types: map[string]string{"blogs": "journals", "posts": "entries"}})
+	typeNames := WithTypeNames(map[string]string{"posts": "articles"})
+
+	// the type names are kept, and take precedence, whatever the order
+	for _, opts := range [][]MarshalOption{{renames, typeNames}, {typeNames, renames}} {
+		testMarshalWithTypeNames(t, opts)
+	}
+}
+
+func testMarshalWithTypeNames(t *testing.T, opts []MarshalOption) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayload(out, testBlog(), opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := new(OnePayload)
+	if err := json.NewDecoder(out).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := "journals", resp.Data.Type; e != a {
+		t.Fatalf("Was expecting type %q, got %q", e, a)
+	}
+	posts := resp.Data.Relationships["posts"].(map[string]interface{})["data"].([]interface{})
+	if e, a := "articles", posts[0].(map[string]interface{})["type"]; e != a {
+		t.Fatalf("Was expecting linkage type %q, got %v", e, a)
+	}
+	types := map[string]bool{}
+	for _, n := range resp.Included {
+		types[n.Type] = true
+	}
+	if e, a := map[string]bool{"articles": true, "comments": true}, types; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting included types %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,85 @@
+package jsonapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// versionHeader is the request header naming the version when the Header of
+// Versions is empty.
+const versionHeader = "Api-Version"
+
+// Profile holds the options used to marshal and unmarshal the payloads of a
+// version of an API, e.g. its Renames and Config.
+type Profile struct {
+	MarshalOptions   []MarshalOption
+	UnmarshalOptions []UnmarshalOption
+}
+
+// Versions selects the Profile of a request, so that versioned serialization
+// is set up in one place rather than in every handler:
+//
+//	var versions = jsonapi.Versions{
+//		Default: "v2",
+//		Profiles: map[string]jsonapi.Profile{
+//			"v1": {MarshalOptions: []jsonapi.MarshalOption{jsonapi.WithRenames(v1Renames)}},
+//			"v2": {},
+//		},
+//	}
+//
+//	func ShowBlog(w http.ResponseWriter, r *http.Request) {
+//		_, profile := versions.Negotiate(r)
+//		...
+//		jsonapi.MarshalOnePayload(w, blog, profile.MarshalOptions...)
+//	}
+type Versions struct {
+	// Profiles maps each version, e.g. "v1", to its Profile.
+	Profiles map[string]Profile
+
+	// Default is the version used when the request doesn't ask for a known
+	// one.
+	Default string
+
+	// Header is the request header naming the version, "Api-Version" when
+	// empty.
+	Header string
+}
+
+// Negotiate returns the version requested by r and its Profile. The version
+// is taken from the Header of the request (e.g. "Api-Version: v1", where "1"
+// also selects "v1"), or else from the first segment of the URL path (e.g.
+// "/v1/blogs"), and falls back to Default. The specification allows no
+// version parameter on the JSON API media type, which CheckRequestHeaders
+// rejects, so the version is not read from the Accept header.
+func (v Versions) Negotiate(r *http.Request) (string, Profile) {
+	header := v.Header
+	if header == "" {
+		header = versionHeader
+	}
+	if version, ok := v.lookup(strings.TrimSpace(r.Header.Get(header))); ok {
+		return version, v.Profiles[version]
+	}
+
+	if r.URL != nil {
+		segment := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+		if version, ok := v.lookup(segment); ok {
+			return version, v.Profiles[version]
+		}
+	}
+
+	return v.Default, v.Profiles[v.Default]
+}
+
+// lookup returns the known version named name, or "v"+name.
+func (v Versions) lookup(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	if _, ok := v.Profiles[name]; ok {
+		return name, true
+	}
+	if _, ok := v.Profiles["v"+name]; ok {
+		return "v" + name, true
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+package jsonapi
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionsNegotiate(t *testing.T) {
+	versions := Versions{
+		Default: "v2",
+		Profiles: map[string]Profile{
+			"v1": {MarshalOptions: []MarshalOption{WithRenames(Renames{Members: map[string]string{"title": "name"}})}},
+			"v2": {},
+		},
+	}
+
+	for _, tc := range []struct {
+		path, header, version string
+	}{
+		{"/blogs", "", "v2"},
+		{"/v1/blogs", "", "v1"},
+		{"/v3/blogs", "", "v2"},
+		{"/blogs", "v1", "v1"},
+		{"/blogs", "1", "v1"},
+		{"/v1/blogs", "v2", "v2"},
+		{"/blogs", "v3", "v2"},
+	} {
+		r := httptest.NewRequest("GET", tc.path, nil)
+		r.Header.Set("Accept", MediaType)
+		if tc.header != "" {
+			r.Header.Set("Api-Version", tc.header)
+		}
+
+		// the version leaves the media type valid
+		if obj := CheckRequestHeaders(r); obj != nil {
+			t.Fatalf("Was expecting the request to be accepted, got %v", obj)
+		}
+
+		version, profile := versions.Negotiate(r)
+		if e, a := tc.version, version; e != a {
+			t.Fatalf("Was expecting version %q for %s %q, got %q", e, tc.path, tc.header, a)
+		}
+
+		payload, err := MarshalOne(&Blog{ID: 1, Title: "Hi"}, profile.MarshalOptions...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, renamed := payload.Data.Attributes["name"]; renamed != (version == "v1") {
+			t.Fatalf("Was expecting the %s profile, got attributes %v", version, payload.Data.Attributes)
+		}
+	}
+}
+
+func TestVersionsNegotiateHeader(t *testing.T) {
+	versions := Versions{Default: "v2", Header: "X-Version", Profiles: map[string]Profile{"v1": {}, "v2": {}}}
+
+	r := httptest.NewRequest("GET", "/blogs", nil)
+	r.Header.Set("Accept", MediaType+"; version=v1")
+	r.Header.Set("X-Version", "v1")
+
+	if version, _ := versions.Negotiate(r); version != "v1" {
+		t.Fatalf("Was expecting version %q, got %q", "v1", version)
+	}
+	if obj := CheckRequestHeaders(r); obj == nil || obj.Status != "406" {
+		t.Fatalf("Was expecting the version media type parameter to be rejected, got %v", obj)
+	}
+}